@@ -1,12 +1,18 @@
 package main
 
 import (
+	"context"
 	"log"
 	"os"
 
 	"github.com/Ryo-cool/guideforge/internal/api"
+	"github.com/Ryo-cool/guideforge/internal/auth"
 	"github.com/Ryo-cool/guideforge/internal/config"
+	"github.com/Ryo-cool/guideforge/internal/mail"
+	"github.com/Ryo-cool/guideforge/internal/password"
 	"github.com/Ryo-cool/guideforge/internal/repository"
+	"github.com/Ryo-cool/guideforge/internal/services"
+	"github.com/Ryo-cool/guideforge/internal/storage"
 	"github.com/joho/godotenv"
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/echo/v4/middleware"
@@ -21,6 +27,9 @@ func main() {
 	// Echo インスタンスの作成
 	e := echo.New()
 
+	// エラーレスポンスの共通化
+	e.HTTPErrorHandler = api.HTTPErrorHandler
+
 	// ミドルウェアの設定
 	e.Use(middleware.Logger())
 	e.Use(middleware.Recover())
@@ -32,6 +41,14 @@ func main() {
 		log.Fatalf("Failed to load configuration: %v", err)
 	}
 
+	// クライアントのIPアドレスの取得方法（ログイン試行の制限・セッションの記録に使用する）
+	// ヘッダーは偽装できるため、信頼できるリバースプロキシの背後で動かす場合のみ X-Forwarded-For を使用する
+	if cfg.TrustProxyHeaders {
+		e.IPExtractor = echo.ExtractIPFromXFFHeader()
+	} else {
+		e.IPExtractor = echo.ExtractIPDirect()
+	}
+
 	// データベース接続
 	db, err := repository.ConnectDB(cfg)
 	if err != nil {
@@ -39,8 +56,43 @@ func main() {
 	}
 	defer db.Close()
 
+	// メール送信の設定
+	mailer, err := mail.NewSender(cfg)
+	if err != nil {
+		log.Fatalf("Failed to configure mail sender: %v", err)
+	}
+
+	// ファイル保存先の設定
+	store, err := storage.New(cfg)
+	if err != nil {
+		log.Fatalf("Failed to configure storage: %v", err)
+	}
+
+	// トークンの署名鍵の設定
+	tokens, err := auth.NewTokenService(cfg)
+	if err != nil {
+		log.Fatalf("Failed to load JWT keys: %v", err)
+	}
+
+	// パスワードのハッシュ化とポリシーの設定
+	passwords, err := password.NewHasher(cfg)
+	if err != nil {
+		log.Fatalf("Failed to configure password hashing: %v", err)
+	}
+	passwordPolicy, err := password.NewPolicy(cfg)
+	if err != nil {
+		log.Fatalf("Failed to configure password policy: %v", err)
+	}
+
 	// ルートの設定
-	api.RegisterRoutes(e, cfg, db)
+	api.RegisterRoutes(e, cfg, db, mailer, store, tokens, passwords, passwordPolicy)
+
+	// 孤立ファイルのGC（複数のサーバーで起動しても同時には1台のみ実行される）
+	if cfg.StorageGCInterval > 0 {
+		repo := repository.NewRepository(db)
+		gc := services.NewStorageGCService(repo, repository.NewImageFileRepository(repo), store, cfg)
+		go gc.RunPeriodically(context.Background(), cfg.StorageGCInterval)
+	}
 
 	// サーバー起動
 	port := os.Getenv("PORT")
@@ -0,0 +1,42 @@
+// mock-oidc はシングルサインオンをローカルで確認するための簡易的なOpenID Connect IdP
+// 認可リクエストは確認画面を出さずに承認し、指定したメールアドレスのIDトークンを発行する
+//
+//	go run ./cmd/mock-oidc -email dev@example.com
+//	OIDC_ISSUER_URL=http://localhost:9000 OIDC_CLIENT_ID=guideforge OIDC_CLIENT_SECRET=secret go run ./cmd/api
+//
+// login_hint を指定した場合はそのメールアドレスでログインする。開発用のため本番環境では使用しないこと
+package main
+
+import (
+	"flag"
+	"log"
+	"net/http"
+
+	"github.com/Ryo-cool/guideforge/internal/mockoidc"
+)
+
+func main() {
+	addr := flag.String("addr", ":9000", "listen address")
+	issuer := flag.String("issuer", "http://localhost:9000", "issuer URL")
+	clientID := flag.String("client-id", "guideforge", "client ID")
+	clientSecret := flag.String("client-secret", "secret", "client secret")
+	email := flag.String("email", "dev@example.com", "default email of the signed-in user")
+	name := flag.String("name", "Dev User", "name of the signed-in user")
+	flag.Parse()
+
+	idp, err := mockoidc.New(mockoidc.Options{
+		Issuer:       *issuer,
+		ClientID:     *clientID,
+		ClientSecret: *clientSecret,
+		Email:        *email,
+		Name:         *name,
+	})
+	if err != nil {
+		log.Fatalf("Failed to create mock OpenID provider: %v", err)
+	}
+
+	log.Printf("Mock OpenID provider listening on %s (issuer %s)", *addr, idp.Issuer())
+	if err := http.ListenAndServe(*addr, idp); err != nil {
+		log.Fatalf("Failed to start server: %v", err)
+	}
+}
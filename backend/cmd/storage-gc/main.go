@@ -0,0 +1,66 @@
+// storage-gc はストレージと画像・改訂履歴・プロフィール画像の参照を突き合わせ、
+// 孤立ファイルと欠損ファイルを報告する管理コマンド
+//
+//	go run ./cmd/storage-gc -dry-run
+//	go run ./cmd/storage-gc -grace 72h
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"log"
+	"os"
+
+	"github.com/Ryo-cool/guideforge/internal/config"
+	"github.com/Ryo-cool/guideforge/internal/repository"
+	"github.com/Ryo-cool/guideforge/internal/services"
+	"github.com/Ryo-cool/guideforge/internal/storage"
+	"github.com/joho/godotenv"
+)
+
+func main() {
+	dryRun := flag.Bool("dry-run", false, "report orphans and missing files without deleting")
+	grace := flag.Duration("grace", 0, "override STORAGE_GC_GRACE_PERIOD (e.g. 72h)")
+	flag.Parse()
+
+	// 環境変数のロード
+	if err := godotenv.Load(); err != nil {
+		log.Println("No .env file found, using environment variables")
+	}
+
+	// 設定のロード
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatalf("Failed to load configuration: %v", err)
+	}
+	if *grace > 0 {
+		cfg.StorageGCGracePeriod = *grace
+	}
+
+	// データベース接続
+	db, err := repository.ConnectDB(cfg)
+	if err != nil {
+		log.Fatalf("Failed to connect to database: %v", err)
+	}
+	defer db.Close()
+
+	// ファイル保存先の設定
+	store, err := storage.New(cfg)
+	if err != nil {
+		log.Fatalf("Failed to configure storage: %v", err)
+	}
+
+	repo := repository.NewRepository(db)
+	gc := services.NewStorageGCService(repo, repository.NewImageFileRepository(repo), store, cfg)
+
+	report, err := gc.Run(*dryRun)
+	if err != nil {
+		log.Fatalf("Storage garbage collection failed: %v", err)
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(report); err != nil {
+		log.Fatalf("Failed to write report: %v", err)
+	}
+}
@@ -0,0 +1,137 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/Ryo-cool/guideforge/internal/apperror"
+	"github.com/labstack/echo/v4"
+)
+
+// errorResponse はエラー時のレスポンスボディ
+type errorResponse struct {
+	Success bool                  `json:"success"`
+	Error   string                `json:"error"`
+	Code    string                `json:"code"`
+	Details []apperror.FieldError `json:"details,omitempty"`
+}
+
+// HTTPErrorHandler はハンドラーから返されたエラーをHTTPレスポンスに変換する
+func HTTPErrorHandler(err error, c echo.Context) {
+	if c.Response().Committed {
+		return
+	}
+
+	// 再試行できるまでの時間を秒単位（切り上げ）で通知する
+	var rateLimitErr *apperror.RateLimitError
+	if errors.As(err, &rateLimitErr) && rateLimitErr.RetryAfter > 0 {
+		seconds := int(math.Ceil(rateLimitErr.RetryAfter.Seconds()))
+		c.Response().Header().Set("Retry-After", strconv.Itoa(seconds))
+	}
+
+	status, res := mapError(err)
+	if status >= http.StatusInternalServerError {
+		c.Logger().Errorf("%s %s: %v", c.Request().Method, c.Request().URL.Path, err)
+	}
+
+	var sendErr error
+	if c.Request().Method == http.MethodHead {
+		sendErr = c.NoContent(status)
+	} else {
+		sendErr = c.JSON(status, res)
+	}
+	if sendErr != nil {
+		c.Logger().Error(sendErr)
+	}
+}
+
+// mapError はエラーをステータスコードとレスポンスボディに変換する
+func mapError(err error) (int, errorResponse) {
+	var validationErr *apperror.ValidationError
+	if errors.As(err, &validationErr) {
+		return http.StatusBadRequest, errorResponse{
+			Error:   validationErr.Message,
+			Code:    "validation_error",
+			Details: validationErr.Fields,
+		}
+	}
+
+	var rateLimitErr *apperror.RateLimitError
+	if errors.As(err, &rateLimitErr) {
+		return http.StatusTooManyRequests, errorResponse{Error: rateLimitErr.Message, Code: "too_many_requests"}
+	}
+
+	var appErr *apperror.Error
+	if errors.As(err, &appErr) {
+		status, code := kindStatus(appErr.Kind)
+		return status, errorResponse{Error: appErr.Message, Code: code}
+	}
+
+	// Echo組み込みのエラー（ルーティング、JWTミドルウェア等）
+	var httpErr *echo.HTTPError
+	if errors.As(err, &httpErr) {
+		msg := fmt.Sprint(httpErr.Message)
+		if m, ok := httpErr.Message.(map[string]interface{}); ok {
+			if e, ok := m["error"].(string); ok {
+				msg = e
+			}
+		}
+		return httpErr.Code, errorResponse{Error: msg, Code: statusCode(httpErr.Code)}
+	}
+
+	// 想定外のエラーは詳細を隠して500とする
+	return http.StatusInternalServerError, errorResponse{
+		Error: "Internal server error",
+		Code:  "internal_error",
+	}
+}
+
+// kindStatus はエラー種別に対応するステータスコードとエラーコードを返す
+func kindStatus(kind error) (int, string) {
+	switch kind {
+	case apperror.ErrNotFound:
+		return http.StatusNotFound, "not_found"
+	case apperror.ErrForbidden:
+		return http.StatusForbidden, "forbidden"
+	case apperror.ErrUnauthorized:
+		return http.StatusUnauthorized, "unauthorized"
+	case apperror.ErrConflict:
+		return http.StatusConflict, "conflict"
+	case apperror.ErrValidation:
+		return http.StatusBadRequest, "validation_error"
+	default:
+		return http.StatusInternalServerError, "internal_error"
+	}
+}
+
+// statusCode はステータスコードに対応するエラーコードを返す
+func statusCode(status int) string {
+	switch status {
+	case http.StatusBadRequest:
+		return "bad_request"
+	case http.StatusUnauthorized:
+		return "unauthorized"
+	case http.StatusForbidden:
+		return "forbidden"
+	case http.StatusNotFound:
+		return "not_found"
+	case http.StatusMethodNotAllowed:
+		return "method_not_allowed"
+	case http.StatusConflict:
+		return "conflict"
+	case http.StatusRequestEntityTooLarge:
+		return "payload_too_large"
+	case http.StatusUnsupportedMediaType:
+		return "unsupported_media_type"
+	case http.StatusTooManyRequests:
+		return "too_many_requests"
+	default:
+		if status >= http.StatusInternalServerError {
+			return "internal_error"
+		}
+		return "error"
+	}
+}
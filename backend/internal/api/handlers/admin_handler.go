@@ -0,0 +1,336 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Ryo-cool/guideforge/internal/apperror"
+	"github.com/Ryo-cool/guideforge/internal/auth"
+	"github.com/Ryo-cool/guideforge/internal/models"
+	"github.com/Ryo-cool/guideforge/internal/services"
+	"github.com/go-playground/validator/v10"
+	"github.com/labstack/echo/v4"
+)
+
+// AdminHandler 管理者向けのハンドラー
+type AdminHandler struct {
+	userService          *services.UserService
+	storageGCService     *services.StorageGCService
+	loginThrottleService *services.LoginThrottleService
+	auditService         *services.AuditService
+	validator            *validator.Validate
+}
+
+// NewAdminHandler 新しい AdminHandler インスタンスを作成
+func NewAdminHandler(
+	userService *services.UserService,
+	storageGCService *services.StorageGCService,
+	loginThrottleService *services.LoginThrottleService,
+	auditService *services.AuditService,
+) *AdminHandler {
+	return &AdminHandler{
+		userService:          userService,
+		storageGCService:     storageGCService,
+		loginThrottleService: loginThrottleService,
+		auditService:         auditService,
+		validator:            newValidator(),
+	}
+}
+
+// ListUsers ユーザー一覧をロール付きで取得する
+func (h *AdminHandler) ListUsers(c echo.Context) error {
+	// ページネーション（不正な値はサービス側でデフォルト値に補正される）
+	page, _ := strconv.Atoi(c.QueryParam("page"))
+	limit, _ := strconv.Atoi(c.QueryParam("limit"))
+
+	res, err := h.userService.ListUsers(page, limit)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"success": true,
+		"data":    res,
+	})
+}
+
+// UpdateUserRole ユーザーのロールを変更する
+func (h *AdminHandler) UpdateUserRole(c echo.Context) error {
+	actorID, err := auth.GetUserIDFromToken(c)
+	if err != nil {
+		return errUnauthorized
+	}
+
+	id, err := parseIDParam(c, "id")
+	if err != nil {
+		return apperror.Validation("Invalid user ID")
+	}
+
+	var req models.UserRoleRequest
+	if err := c.Bind(&req); err != nil {
+		return errInvalidRequest
+	}
+
+	// バリデーション
+	if err := h.validator.Struct(req); err != nil {
+		return validationError(err)
+	}
+
+	user, err := h.userService.UpdateUserRole(actorID, id, req.Role, clientInfo(c))
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"success": true,
+		"data":    user,
+	})
+}
+
+// UnlockUser ログインの失敗によるアカウントのロックを解除する
+func (h *AdminHandler) UnlockUser(c echo.Context) error {
+	actorID, err := auth.GetUserIDFromToken(c)
+	if err != nil {
+		return errUnauthorized
+	}
+
+	id, err := parseIDParam(c, "id")
+	if err != nil {
+		return apperror.Validation("Invalid user ID")
+	}
+
+	if err := h.loginThrottleService.UnlockUserByAdmin(actorID, id, clientInfo(c)); err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"success": true,
+		"message": "User unlocked successfully",
+	})
+}
+
+// RunStorageGC 孤立ファイルのGCを実行し、結果を返す
+// dry_run=true の場合は削除せずに孤立・欠損ファイルの報告のみ行う
+func (h *AdminHandler) RunStorageGC(c echo.Context) error {
+	dryRun := false
+	if v := c.QueryParam("dry_run"); v != "" {
+		parsed, err := strconv.ParseBool(v)
+		if err != nil {
+			return apperror.Validation("Invalid dry_run parameter")
+		}
+		dryRun = parsed
+	}
+
+	report, err := h.storageGCService.Run(dryRun)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"success": true,
+		"data":    report,
+	})
+}
+
+// ListAuditEvents 監査ログを新しい順に取得する
+// actor_id・action・target_type・target_id・from・to で絞り込める
+func (h *AdminHandler) ListAuditEvents(c echo.Context) error {
+	filter, err := parseAuditEventFilter(c)
+	if err != nil {
+		return err
+	}
+
+	// ページネーション（不正な値はサービス側でデフォルト値に補正される）
+	page, _ := strconv.Atoi(c.QueryParam("page"))
+	limit, _ := strconv.Atoi(c.QueryParam("limit"))
+
+	res, err := h.auditService.ListEvents(filter, page, limit)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"success": true,
+		"data":    res,
+	})
+}
+
+// ExportAuditEvents 監査ログを古い順にCSVまたはJSONでダウンロードする
+// 絞り込み条件は ListAuditEvents と同じ
+func (h *AdminHandler) ExportAuditEvents(c echo.Context) error {
+	format := c.QueryParam("format")
+	if format == "" {
+		format = "csv"
+	}
+	if format != "csv" && format != "json" {
+		return apperror.Validation("Invalid format parameter, must be csv or json")
+	}
+
+	filter, err := parseAuditEventFilter(c)
+	if err != nil {
+		return err
+	}
+
+	events, err := h.auditService.ExportEvents(filter)
+	if err != nil {
+		return err
+	}
+
+	filename := "audit-events-" + time.Now().UTC().Format("20060102-150405")
+	if format == "json" {
+		data, err := json.Marshal(events)
+		if err != nil {
+			return err
+		}
+		setAttachment(c, filename+".json")
+		return c.Blob(http.StatusOK, echo.MIMEApplicationJSONCharsetUTF8, data)
+	}
+
+	data, err := auditEventsCSV(events)
+	if err != nil {
+		return err
+	}
+	setAttachment(c, filename+".csv")
+	return c.Blob(http.StatusOK, "text/csv; charset=utf-8", data)
+}
+
+// parseAuditEventFilter はクエリパラメータから監査ログの検索条件を作成する
+// from・to はRFC3339形式または日付（YYYY-MM-DD）で指定し、日付の to はその日を含む
+func parseAuditEventFilter(c echo.Context) (models.AuditEventFilter, error) {
+	filter := models.AuditEventFilter{
+		Action:     c.QueryParam("action"),
+		TargetType: c.QueryParam("target_type"),
+	}
+
+	for _, param := range []struct {
+		name string
+		dest **uint
+	}{
+		{"actor_id", &filter.ActorID},
+		{"target_id", &filter.TargetID},
+	} {
+		v := c.QueryParam(param.name)
+		if v == "" {
+			continue
+		}
+		id, err := strconv.ParseUint(v, 10, 32)
+		if err != nil {
+			return filter, apperror.Validation("Invalid " + param.name + " parameter")
+		}
+		value := uint(id)
+		*param.dest = &value
+	}
+
+	if v := c.QueryParam("from"); v != "" {
+		from, _, err := parseAuditTime(v)
+		if err != nil {
+			return filter, apperror.Validation("Invalid from parameter")
+		}
+		filter.From = &from
+	}
+	if v := c.QueryParam("to"); v != "" {
+		to, dateOnly, err := parseAuditTime(v)
+		if err != nil {
+			return filter, apperror.Validation("Invalid to parameter")
+		}
+		if dateOnly {
+			to = to.AddDate(0, 0, 1)
+		}
+		filter.To = &to
+	}
+	if filter.From != nil && filter.To != nil && !filter.From.Before(*filter.To) {
+		return filter, apperror.Validation("from must be before to")
+	}
+
+	return filter, nil
+}
+
+// parseAuditTime はRFC3339形式または日付（UTC）を解釈し、日付のみの指定だったかを返す
+func parseAuditTime(v string) (time.Time, bool, error) {
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return t, false, nil
+	}
+	t, err := time.Parse("2006-01-02", v)
+	return t, true, err
+}
+
+// auditEventsCSV は監査ログをCSVにする
+// 表計算ソフトで開いたときに数式として解釈されないよう、記号で始まる値の先頭に ' を付ける
+func auditEventsCSV(events []models.AuditEvent) ([]byte, error) {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+
+	header := []string{"id", "created_at", "actor_id", "actor_email", "action", "target_type", "target_id", "before", "after", "ip_address", "user_agent"}
+	if err := w.Write(header); err != nil {
+		return nil, err
+	}
+
+	for _, event := range events {
+		actorID, actorEmail := "", ""
+		if event.ActorID != nil {
+			actorID = strconv.FormatUint(uint64(*event.ActorID), 10)
+		}
+		if event.ActorEmail != nil {
+			actorEmail = *event.ActorEmail
+		}
+		before, err := auditSummaryJSON(event.Before)
+		if err != nil {
+			return nil, err
+		}
+		after, err := auditSummaryJSON(event.After)
+		if err != nil {
+			return nil, err
+		}
+
+		record := []string{
+			strconv.FormatUint(uint64(event.ID), 10),
+			event.CreatedAt.UTC().Format(time.RFC3339),
+			actorID,
+			actorEmail,
+			event.Action,
+			event.TargetType,
+			strconv.FormatUint(uint64(event.TargetID), 10),
+			before,
+			after,
+			event.IPAddress,
+			event.UserAgent,
+		}
+		for i := range record {
+			record[i] = escapeCSVFormula(record[i])
+		}
+		if err := w.Write(record); err != nil {
+			return nil, err
+		}
+	}
+
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// auditSummaryJSON は変更前後の値をJSON文字列にする（値がない場合は空文字）
+func auditSummaryJSON(summary models.AuditSummary) (string, error) {
+	if summary == nil {
+		return "", nil
+	}
+	data, err := json.Marshal(summary)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// escapeCSVFormula は数式として解釈される記号で始まる値の先頭に ' を付ける
+func escapeCSVFormula(v string) string {
+	if v != "" && strings.ContainsRune("=+-@\t\r", rune(v[0])) {
+		return "'" + v
+	}
+	return v
+}
@@ -0,0 +1,95 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/Ryo-cool/guideforge/internal/apperror"
+	"github.com/Ryo-cool/guideforge/internal/auth"
+	"github.com/Ryo-cool/guideforge/internal/models"
+	"github.com/Ryo-cool/guideforge/internal/services"
+	"github.com/go-playground/validator/v10"
+	"github.com/labstack/echo/v4"
+)
+
+// APITokenHandler 個人用APIトークンのハンドラー
+type APITokenHandler struct {
+	apiTokenService *services.APITokenService
+	validator       *validator.Validate
+}
+
+// NewAPITokenHandler 新しい APITokenHandler インスタンスを作成
+func NewAPITokenHandler(apiTokenService *services.APITokenService) *APITokenHandler {
+	return &APITokenHandler{
+		apiTokenService: apiTokenService,
+		validator:       newValidator(),
+	}
+}
+
+// ListTokens APIトークン一覧を取得する（トークンの値は含まない）
+func (h *APITokenHandler) ListTokens(c echo.Context) error {
+	userID, err := auth.GetUserIDFromToken(c)
+	if err != nil {
+		return errUnauthorized
+	}
+
+	tokens, err := h.apiTokenService.List(userID)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"success": true,
+		"data":    tokens,
+	})
+}
+
+// CreateToken APIトークンを発行する
+// トークンの値はこのレスポンスでのみ返し、後から再表示はできない
+func (h *APITokenHandler) CreateToken(c echo.Context) error {
+	userID, err := auth.GetUserIDFromToken(c)
+	if err != nil {
+		return errUnauthorized
+	}
+
+	var req models.APITokenCreateRequest
+	if err := c.Bind(&req); err != nil {
+		return errInvalidRequest
+	}
+
+	// バリデーション
+	if err := h.validator.Struct(req); err != nil {
+		return validationError(err)
+	}
+
+	token, err := h.apiTokenService.Create(userID, req, clientInfo(c))
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusCreated, map[string]interface{}{
+		"success": true,
+		"data":    token,
+	})
+}
+
+// RevokeToken APIトークンを失効させる
+func (h *APITokenHandler) RevokeToken(c echo.Context) error {
+	userID, err := auth.GetUserIDFromToken(c)
+	if err != nil {
+		return errUnauthorized
+	}
+
+	id, err := parseIDParam(c, "id")
+	if err != nil {
+		return apperror.Validation("Invalid API token ID")
+	}
+
+	if err := h.apiTokenService.Revoke(userID, id, clientInfo(c)); err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"success": true,
+		"message": "API token revoked successfully",
+	})
+}
@@ -0,0 +1,151 @@
+package handlers
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/Ryo-cool/guideforge/internal/apperror"
+	"github.com/Ryo-cool/guideforge/internal/auth"
+	"github.com/Ryo-cool/guideforge/internal/config"
+	"github.com/Ryo-cool/guideforge/internal/services"
+	"github.com/labstack/echo/v4"
+)
+
+// ExportHandler エクスポート・インポート関連のハンドラー
+type ExportHandler struct {
+	exportService *services.ExportService
+	importService *services.ImportService
+	config        *config.Config
+}
+
+// NewExportHandler 新しい ExportHandler インスタンスを作成
+func NewExportHandler(exportService *services.ExportService, importService *services.ImportService, config *config.Config) *ExportHandler {
+	return &ExportHandler{
+		exportService: exportService,
+		importService: importService,
+		config:        config,
+	}
+}
+
+// ExportPDF マニュアルをPDFとしてダウンロードする
+func (h *ExportHandler) ExportPDF(c echo.Context) error {
+	userID, err := auth.GetUserIDFromToken(c)
+	if err != nil {
+		return errUnauthorized
+	}
+
+	id, err := parseIDParam(c, "id")
+	if err != nil {
+		return apperror.Validation("Invalid manual ID")
+	}
+
+	// エラー時にJSONで応答できるよう、生成が完了してから送信する
+	var buf bytes.Buffer
+	manual, err := h.exportService.ExportPDF(&buf, id, userID)
+	if err != nil {
+		return err
+	}
+
+	setAttachment(c, exportFileName(manual.Title, id)+".pdf")
+	return c.Blob(http.StatusOK, "application/pdf", buf.Bytes())
+}
+
+// ExportMarkdown マニュアルをMarkdownバンドル（zip）としてダウンロードする
+func (h *ExportHandler) ExportMarkdown(c echo.Context) error {
+	userID, err := auth.GetUserIDFromToken(c)
+	if err != nil {
+		return errUnauthorized
+	}
+
+	id, err := parseIDParam(c, "id")
+	if err != nil {
+		return apperror.Validation("Invalid manual ID")
+	}
+
+	// エラー時にJSONで応答できるよう、生成が完了してから送信する
+	var buf bytes.Buffer
+	manual, err := h.exportService.ExportMarkdown(&buf, id, userID)
+	if err != nil {
+		return err
+	}
+
+	setAttachment(c, exportFileName(manual.Title, id)+".zip")
+	return c.Blob(http.StatusOK, "application/zip", buf.Bytes())
+}
+
+// ImportMarkdown Markdownバンドル（zip）から新しいマニュアルを作成する
+// フォーム項目: file（必須）, workspace_id（任意）
+func (h *ExportHandler) ImportMarkdown(c echo.Context) error {
+	userID, err := auth.GetUserIDFromToken(c)
+	if err != nil {
+		return errUnauthorized
+	}
+
+	// リクエストボディ全体のサイズを制限（マルチパートのオーバーヘッド分を上乗せ）
+	c.Request().Body = http.MaxBytesReader(c.Response(), c.Request().Body, h.config.MaxImportSize+1024*1024)
+
+	file, fileHeader, err := c.Request().FormFile("file")
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			return echo.NewHTTPError(http.StatusRequestEntityTooLarge, fmt.Sprintf("File too large (max %d bytes)", h.config.MaxImportSize))
+		}
+		return apperror.Validation("Invalid file upload")
+	}
+	defer file.Close()
+
+	if fileHeader.Size > h.config.MaxImportSize {
+		return echo.NewHTTPError(http.StatusRequestEntityTooLarge, fmt.Sprintf("File too large (max %d bytes)", h.config.MaxImportSize))
+	}
+
+	var workspaceID *uint
+	if value := c.FormValue("workspace_id"); value != "" {
+		id, err := strconv.ParseUint(value, 10, 32)
+		if err != nil {
+			return apperror.Validation("Invalid workspace ID")
+		}
+		wid := uint(id)
+		workspaceID = &wid
+	}
+
+	manual, err := h.importService.ImportMarkdown(userID, workspaceID, file, fileHeader.Size, clientInfo(c))
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusCreated, map[string]interface{}{
+		"success": true,
+		"data":    manual,
+	})
+}
+
+// setAttachment はダウンロード用のContent-Dispositionヘッダーを設定する
+func setAttachment(c echo.Context, filename string) {
+	c.Response().Header().Set(echo.HeaderContentDisposition, mime.FormatMediaType("attachment", map[string]string{
+		"filename": filename,
+	}))
+}
+
+// exportFileName はマニュアルのタイトルからダウンロード用のファイル名（拡張子なし）を作成する
+func exportFileName(title string, id uint) string {
+	name := strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) || strings.ContainsRune(`/\:*?"<>|`, r) {
+			return '_'
+		}
+		return r
+	}, strings.TrimSpace(title))
+
+	if name == "" {
+		return "manual-" + strconv.FormatUint(uint64(id), 10)
+	}
+	if runes := []rune(name); len(runes) > 100 {
+		name = string(runes[:100])
+	}
+	return name
+}
@@ -3,11 +3,13 @@ package handlers
 import (
 	"errors"
 	"fmt"
+	"io"
 	"net/http"
-	"strconv"
 
+	"github.com/Ryo-cool/guideforge/internal/apperror"
 	"github.com/Ryo-cool/guideforge/internal/auth"
 	"github.com/Ryo-cool/guideforge/internal/config"
+	"github.com/Ryo-cool/guideforge/internal/imageproc"
 	"github.com/Ryo-cool/guideforge/internal/models"
 	"github.com/Ryo-cool/guideforge/internal/services"
 	"github.com/go-playground/validator/v10"
@@ -19,28 +21,36 @@ type UserHandlerContext struct {
 	AuthService *services.AuthService
 	UserService *services.UserService
 	Validator   *validator.Validate
+	Config      *config.Config
 }
 
 // NewUserHandlerContext は新しいUserHandlerContextを作成
-func NewUserHandlerContext(authService *services.AuthService, userService *services.UserService) *UserHandlerContext {
+func NewUserHandlerContext(authService *services.AuthService, userService *services.UserService, cfg *config.Config) *UserHandlerContext {
 	return &UserHandlerContext{
 		AuthService: authService,
 		UserService: userService,
-		Validator:   validator.New(),
+		Validator:   newValidator(),
+		Config:      cfg,
 	}
 }
 
 // AuthHandler 認証関連のハンドラー
 type AuthHandler struct {
-	authService *services.AuthService
-	config      *config.Config
+	authService              *services.AuthService
+	passwordResetService     *services.PasswordResetService
+	emailVerificationService *services.EmailVerificationService
+	config                   *config.Config
+	validator                *validator.Validate
 }
 
 // NewAuthHandler 新しい AuthHandler インスタンスを作成
-func NewAuthHandler(authService *services.AuthService, config *config.Config) *AuthHandler {
+func NewAuthHandler(authService *services.AuthService, passwordResetService *services.PasswordResetService, emailVerificationService *services.EmailVerificationService, config *config.Config) *AuthHandler {
 	return &AuthHandler{
-		authService: authService,
-		config:      config,
+		authService:              authService,
+		passwordResetService:     passwordResetService,
+		emailVerificationService: emailVerificationService,
+		config:                   config,
+		validator:                newValidator(),
 	}
 }
 
@@ -48,27 +58,41 @@ func NewAuthHandler(authService *services.AuthService, config *config.Config) *A
 func (h *AuthHandler) Login(c echo.Context) error {
 	var req models.UserLoginRequest
 	if err := c.Bind(&req); err != nil {
-		return c.JSON(http.StatusBadRequest, map[string]interface{}{
-			"success": false,
-			"error":   "Invalid request format",
-		})
+		return errInvalidRequest
 	}
 
 	// バリデーション
 	if req.Email == "" || req.Password == "" {
-		return c.JSON(http.StatusBadRequest, map[string]interface{}{
-			"success": false,
-			"error":   "Email and password are required",
-		})
+		return apperror.Validation("Email and password are required")
 	}
 
 	// 認証サービスを使用してログイン
-	res, err := h.authService.Login(req)
+	res, challenge, err := h.authService.Login(req, clientInfo(c))
 	if err != nil {
-		return c.JSON(http.StatusUnauthorized, map[string]interface{}{
-			"success": false,
-			"error":   err.Error(),
-		})
+		return err
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"success": true,
+		"data":    loginResponseData(res, challenge),
+	})
+}
+
+// LoginTwoFactor ログインの二要素目（認証アプリのコードまたはリカバリーコード）を検証する
+func (h *AuthHandler) LoginTwoFactor(c echo.Context) error {
+	var req models.TwoFactorLoginRequest
+	if err := c.Bind(&req); err != nil {
+		return errInvalidRequest
+	}
+
+	// バリデーション
+	if err := h.validator.Struct(req); err != nil {
+		return validationError(err)
+	}
+
+	res, err := h.authService.LoginTwoFactor(req, clientInfo(c))
+	if err != nil {
+		return err
 	}
 
 	return c.JSON(http.StatusOK, map[string]interface{}{
@@ -81,32 +105,124 @@ func (h *AuthHandler) Login(c echo.Context) error {
 func (h *UserHandlerContext) Login(c echo.Context) error {
 	var loginReq models.UserLoginRequest
 	if err := c.Bind(&loginReq); err != nil {
-		return c.JSON(http.StatusBadRequest, map[string]interface{}{
-			"success": false,
-			"error":   "Invalid request format",
-		})
+		return errInvalidRequest
 	}
 
 	// バリデーション
 	if err := h.Validator.Struct(loginReq); err != nil {
-		return c.JSON(http.StatusBadRequest, map[string]interface{}{
-			"success": false,
-			"error":   fmt.Sprintf("Validation error: %v", err),
-		})
+		return validationError(err)
 	}
 
 	// ログイン処理
-	authResp, err := h.AuthService.Login(loginReq)
+	authResp, challenge, err := h.AuthService.Login(loginReq, clientInfo(c))
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"success": true,
+		"data":    loginResponseData(authResp, challenge),
+	})
+}
+
+// RefreshToken リフレッシュトークンを使ってアクセストークンを再発行する
+// 使用したリフレッシュトークンは無効になり、レスポンスの新しいトークンに置き換わる
+func (h *AuthHandler) RefreshToken(c echo.Context) error {
+	var req models.RefreshTokenRequest
+	if err := c.Bind(&req); err != nil {
+		return errInvalidRequest
+	}
+
+	// バリデーション
+	if err := h.validator.Struct(req); err != nil {
+		return apperror.Validation("Refresh token is required")
+	}
+
+	res, err := h.authService.RefreshToken(req.RefreshToken)
 	if err != nil {
-		return c.JSON(http.StatusUnauthorized, map[string]interface{}{
-			"success": false,
-			"error":   err.Error(),
-		})
+		return err
 	}
 
 	return c.JSON(http.StatusOK, map[string]interface{}{
 		"success": true,
-		"data":    authResp,
+		"data":    res,
+	})
+}
+
+// Logout 現在のセッションを失効させる
+func (h *AuthHandler) Logout(c echo.Context) error {
+	userID, err := auth.GetUserIDFromToken(c)
+	if err != nil {
+		return errUnauthorized
+	}
+	sessionID, err := auth.GetSessionIDFromToken(c)
+	if err != nil {
+		return errUnauthorized
+	}
+
+	if err := h.authService.Logout(userID, sessionID); err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"success": true,
+		"message": "Logged out successfully",
+	})
+}
+
+// ListSessions ログイン中のセッション一覧を取得する
+func (h *AuthHandler) ListSessions(c echo.Context) error {
+	userID, err := auth.GetUserIDFromToken(c)
+	if err != nil {
+		return errUnauthorized
+	}
+	sessionID, err := auth.GetSessionIDFromToken(c)
+	if err != nil {
+		return errUnauthorized
+	}
+
+	sessions, err := h.authService.ListSessions(userID, sessionID)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"success": true,
+		"data":    sessions,
+	})
+}
+
+// RevokeSession 指定したセッションをログアウトさせる
+func (h *AuthHandler) RevokeSession(c echo.Context) error {
+	userID, err := auth.GetUserIDFromToken(c)
+	if err != nil {
+		return errUnauthorized
+	}
+
+	if err := h.authService.RevokeSession(userID, c.Param("id")); err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"success": true,
+		"message": "Session revoked successfully",
+	})
+}
+
+// RevokeAllSessions 現在のセッションを含むすべてのセッションをログアウトさせる
+func (h *AuthHandler) RevokeAllSessions(c echo.Context) error {
+	userID, err := auth.GetUserIDFromToken(c)
+	if err != nil {
+		return errUnauthorized
+	}
+
+	if err := h.authService.RevokeAllSessions(userID); err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"success": true,
+		"message": "All sessions have been logged out",
 	})
 }
 
@@ -114,32 +230,23 @@ func (h *UserHandlerContext) Login(c echo.Context) error {
 func (h *AuthHandler) CreateUser(c echo.Context) error {
 	var req models.UserRegisterRequest
 	if err := c.Bind(&req); err != nil {
-		return c.JSON(http.StatusBadRequest, map[string]interface{}{
-			"success": false,
-			"error":   "Invalid request format",
-		})
+		return errInvalidRequest
 	}
 
 	// バリデーション
 	if req.Username == "" || req.Email == "" || req.Password == "" {
-		return c.JSON(http.StatusBadRequest, map[string]interface{}{
-			"success": false,
-			"error":   "Username, email and password are required",
-		})
+		return apperror.Validation("Username, email and password are required")
 	}
 
 	// 認証サービスを使用してユーザー登録
-	res, err := h.authService.RegisterUser(req)
+	res, pending, err := h.authService.RegisterUser(req, clientInfo(c))
 	if err != nil {
-		return c.JSON(http.StatusBadRequest, map[string]interface{}{
-			"success": false,
-			"error":   err.Error(),
-		})
+		return err
 	}
 
 	return c.JSON(http.StatusCreated, map[string]interface{}{
 		"success": true,
-		"data":    res,
+		"data":    registerResponseData(res, pending),
 	})
 }
 
@@ -147,39 +254,23 @@ func (h *AuthHandler) CreateUser(c echo.Context) error {
 func (h *UserHandlerContext) CreateUser(c echo.Context) error {
 	var registerReq models.UserRegisterRequest
 	if err := c.Bind(&registerReq); err != nil {
-		return c.JSON(http.StatusBadRequest, map[string]interface{}{
-			"success": false,
-			"error":   "Invalid request format",
-		})
+		return errInvalidRequest
 	}
 
 	// バリデーション
 	if err := h.Validator.Struct(registerReq); err != nil {
-		return c.JSON(http.StatusBadRequest, map[string]interface{}{
-			"success": false,
-			"error":   fmt.Sprintf("Validation error: %v", err),
-		})
+		return validationError(err)
 	}
 
 	// ユーザー登録
-	authResp, err := h.AuthService.RegisterUser(registerReq)
+	authResp, pending, err := h.AuthService.RegisterUser(registerReq, clientInfo(c))
 	if err != nil {
-		// 既に登録されているメールアドレスの場合
-		if err.Error() == "email already registered" {
-			return c.JSON(http.StatusConflict, map[string]interface{}{
-				"success": false,
-				"error":   err.Error(),
-			})
-		}
-		return c.JSON(http.StatusInternalServerError, map[string]interface{}{
-			"success": false,
-			"error":   fmt.Sprintf("Failed to register user: %v", err),
-		})
+		return err
 	}
 
 	return c.JSON(http.StatusCreated, map[string]interface{}{
 		"success": true,
-		"data":    authResp,
+		"data":    registerResponseData(authResp, pending),
 	})
 }
 
@@ -188,19 +279,13 @@ func (h *AuthHandler) GetCurrentUser(c echo.Context) error {
 	// JWTトークンからユーザーIDを取得
 	userID, err := auth.GetUserIDFromToken(c)
 	if err != nil {
-		return c.JSON(http.StatusUnauthorized, map[string]interface{}{
-			"success": false,
-			"error":   "Unauthorized",
-		})
+		return errUnauthorized
 	}
 
 	// ユーザーサービスからユーザー情報を取得
 	user, err := h.authService.GetUserByID(userID)
 	if err != nil {
-		return c.JSON(http.StatusNotFound, map[string]interface{}{
-			"success": false,
-			"error":   "User not found",
-		})
+		return err
 	}
 
 	return c.JSON(http.StatusOK, map[string]interface{}{
@@ -214,19 +299,13 @@ func (h *UserHandlerContext) GetCurrentUser(c echo.Context) error {
 	// JWTトークンからユーザーID取得
 	userID, err := auth.GetUserIDFromToken(c)
 	if err != nil {
-		return c.JSON(http.StatusUnauthorized, map[string]interface{}{
-			"success": false,
-			"error":   "Unauthorized",
-		})
+		return errUnauthorized
 	}
 
 	// ユーザー情報取得
 	user, err := h.UserService.GetUserByID(userID)
 	if err != nil {
-		return c.JSON(http.StatusNotFound, map[string]interface{}{
-			"success": false,
-			"error":   fmt.Sprintf("User not found: %v", err),
-		})
+		return err
 	}
 
 	return c.JSON(http.StatusOK, map[string]interface{}{
@@ -240,31 +319,24 @@ func (h *AuthHandler) UpdateCurrentUser(c echo.Context) error {
 	// JWTトークンからユーザーIDを取得
 	userID, err := auth.GetUserIDFromToken(c)
 	if err != nil {
-		return c.JSON(http.StatusUnauthorized, map[string]interface{}{
-			"success": false,
-			"error":   "Unauthorized",
-		})
+		return errUnauthorized
 	}
 
 	// リクエストボディをバインド
-	var user models.User
-	if err := c.Bind(&user); err != nil {
-		return c.JSON(http.StatusBadRequest, map[string]interface{}{
-			"success": false,
-			"error":   "Invalid request format",
-		})
+	var req models.UserUpdateRequest
+	if err := c.Bind(&req); err != nil {
+		return errInvalidRequest
 	}
 
-	// ユーザーIDをセット
-	user.ID = userID
+	// バリデーション
+	if err := h.validator.Struct(req); err != nil {
+		return validationError(err)
+	}
 
 	// ユーザー情報を更新
-	updatedUser, err := h.authService.UpdateUser(&user)
+	updatedUser, err := h.authService.UpdateUser(userID, &req, clientInfo(c))
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]interface{}{
-			"success": false,
-			"error":   err.Error(),
-		})
+		return err
 	}
 
 	return c.JSON(http.StatusOK, map[string]interface{}{
@@ -278,10 +350,7 @@ func (h *UserHandlerContext) UpdateCurrentUser(c echo.Context) error {
 	// JWTトークンからユーザーID取得
 	userID, err := auth.GetUserIDFromToken(c)
 	if err != nil {
-		return c.JSON(http.StatusUnauthorized, map[string]interface{}{
-			"success": false,
-			"error":   "Unauthorized",
-		})
+		return errUnauthorized
 	}
 
 	// リクエストのバインド
@@ -292,34 +361,18 @@ func (h *UserHandlerContext) UpdateCurrentUser(c echo.Context) error {
 
 	var updateReq UpdateUserRequest
 	if err := c.Bind(&updateReq); err != nil {
-		return c.JSON(http.StatusBadRequest, map[string]interface{}{
-			"success": false,
-			"error":   "Invalid request format",
-		})
+		return errInvalidRequest
 	}
 
 	// バリデーション
 	if err := h.Validator.Struct(updateReq); err != nil {
-		return c.JSON(http.StatusBadRequest, map[string]interface{}{
-			"success": false,
-			"error":   fmt.Sprintf("Validation error: %v", err),
-		})
+		return validationError(err)
 	}
 
 	// ユーザー情報更新
-	user, err := h.UserService.UpdateUserProfile(userID, updateReq.Username, updateReq.Email)
+	user, err := h.UserService.UpdateUserProfile(userID, updateReq.Username, updateReq.Email, clientInfo(c))
 	if err != nil {
-		// メールアドレスが既に使用されている場合
-		if err.Error() == "email already registered" {
-			return c.JSON(http.StatusConflict, map[string]interface{}{
-				"success": false,
-				"error":   err.Error(),
-			})
-		}
-		return c.JSON(http.StatusInternalServerError, map[string]interface{}{
-			"success": false,
-			"error":   fmt.Sprintf("Failed to update user: %v", err),
-		})
+		return err
 	}
 
 	return c.JSON(http.StatusOK, map[string]interface{}{
@@ -333,47 +386,32 @@ func (h *UserHandlerContext) ChangePassword(c echo.Context) error {
 	// JWTトークンからユーザーID取得
 	userID, err := auth.GetUserIDFromToken(c)
 	if err != nil {
-		return c.JSON(http.StatusUnauthorized, map[string]interface{}{
-			"success": false,
-			"error":   "Unauthorized",
-		})
+		return errUnauthorized
 	}
 
 	// リクエストのバインド
 	type ChangePasswordRequest struct {
-		CurrentPassword string `json:"current_password" validate:"required,min=6"`
-		NewPassword     string `json:"new_password" validate:"required,min=6"`
+		CurrentPassword string `json:"current_password" validate:"required"`
+		NewPassword     string `json:"new_password" validate:"required"`
 	}
 
 	var passwordReq ChangePasswordRequest
 	if err := c.Bind(&passwordReq); err != nil {
-		return c.JSON(http.StatusBadRequest, map[string]interface{}{
-			"success": false,
-			"error":   "Invalid request format",
-		})
+		return errInvalidRequest
 	}
 
 	// バリデーション
 	if err := h.Validator.Struct(passwordReq); err != nil {
-		return c.JSON(http.StatusBadRequest, map[string]interface{}{
-			"success": false,
-			"error":   fmt.Sprintf("Validation error: %v", err),
-		})
-	}
-
-	// パスワード変更
-	if err := h.AuthService.ChangePassword(userID, passwordReq.CurrentPassword, passwordReq.NewPassword); err != nil {
-		// 現在のパスワードが間違っている場合
-		if err.Error() == "current password is incorrect" {
-			return c.JSON(http.StatusBadRequest, map[string]interface{}{
-				"success": false,
-				"error":   err.Error(),
-			})
-		}
-		return c.JSON(http.StatusInternalServerError, map[string]interface{}{
-			"success": false,
-			"error":   fmt.Sprintf("Failed to change password: %v", err),
-		})
+		return validationError(err)
+	}
+
+	// パスワード変更（現在のセッション以外はログアウトされる）
+	sessionID, err := auth.GetSessionIDFromToken(c)
+	if err != nil {
+		return errUnauthorized
+	}
+	if err := h.AuthService.ChangePassword(userID, sessionID, passwordReq.CurrentPassword, passwordReq.NewPassword, clientInfo(c)); err != nil {
+		return err
 	}
 
 	return c.JSON(http.StatusOK, map[string]interface{}{
@@ -387,46 +425,43 @@ func (h *UserHandlerContext) UpdateProfileImage(c echo.Context) error {
 	// JWTトークンからユーザーID取得
 	userID, err := auth.GetUserIDFromToken(c)
 	if err != nil {
-		return c.JSON(http.StatusUnauthorized, map[string]interface{}{
-			"success": false,
-			"error":   "Unauthorized",
-		})
+		return errUnauthorized
 	}
 
+	// リクエストボディ全体のサイズを制限（マルチパートのオーバーヘッド分を上乗せ）
+	c.Request().Body = http.MaxBytesReader(c.Response(), c.Request().Body, h.Config.MaxUploadSize+1024*1024)
+
 	// マルチパートフォームから画像ファイル取得
 	file, fileHeader, err := c.Request().FormFile("image")
 	if err != nil {
-		return c.JSON(http.StatusBadRequest, map[string]interface{}{
-			"success": false,
-			"error":   "Invalid file upload",
-		})
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			return echo.NewHTTPError(http.StatusRequestEntityTooLarge, fmt.Sprintf("File too large (max %d bytes)", h.Config.MaxUploadSize))
+		}
+		return apperror.Validation("Invalid file upload")
 	}
 	defer file.Close()
 
-	// ファイルサイズチェック (5MB制限)
-	if fileHeader.Size > 5*1024*1024 {
-		return c.JSON(http.StatusBadRequest, map[string]interface{}{
-			"success": false,
-			"error":   "File too large (max 5MB)",
-		})
+	// ファイルサイズチェック（縮小前の画像を受け付け、保存時に縮小する）
+	if fileHeader.Size > h.Config.MaxUploadSize {
+		return echo.NewHTTPError(http.StatusRequestEntityTooLarge, fmt.Sprintf("File too large (max %d bytes)", h.Config.MaxUploadSize))
 	}
 
 	// ファイルコンテンツ読み込み
-	fileData := make([]byte, fileHeader.Size)
-	if _, err := file.Read(fileData); err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]interface{}{
-			"success": false,
-			"error":   "Failed to read uploaded file",
-		})
+	fileData, err := io.ReadAll(io.LimitReader(file, h.Config.MaxUploadSize+1))
+	if err != nil {
+		return err
+	}
+
+	// 画像ファイルのみ受け付ける（クライアントが申告したContent-Typeではなくファイルの内容で判定）
+	if _, err := imageproc.DetectType(fileData); err != nil {
+		return echo.NewHTTPError(http.StatusUnsupportedMediaType, "Only JPEG, PNG, GIF and WebP images are allowed")
 	}
 
 	// プロフィール画像更新
-	user, err := h.UserService.UpdateProfileImage(userID, fileHeader.Filename, fileData)
+	user, err := h.UserService.UpdateProfileImage(userID, fileData, clientInfo(c))
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]interface{}{
-			"success": false,
-			"error":   fmt.Sprintf("Failed to update profile image: %v", err),
-		})
+		return err
 	}
 
 	return c.JSON(http.StatusOK, map[string]interface{}{
@@ -440,18 +475,12 @@ func (h *UserHandlerContext) DeleteUser(c echo.Context) error {
 	// JWTトークンからユーザーID取得
 	userID, err := auth.GetUserIDFromToken(c)
 	if err != nil {
-		return c.JSON(http.StatusUnauthorized, map[string]interface{}{
-			"success": false,
-			"error":   "Unauthorized",
-		})
+		return errUnauthorized
 	}
 
 	// ユーザー削除
-	if err := h.UserService.DeleteUser(userID); err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]interface{}{
-			"success": false,
-			"error":   fmt.Sprintf("Failed to delete user: %v", err),
-		})
+	if err := h.UserService.DeleteUser(userID, clientInfo(c)); err != nil {
+		return err
 	}
 
 	return c.JSON(http.StatusOK, map[string]interface{}{
@@ -462,18 +491,20 @@ func (h *UserHandlerContext) DeleteUser(c echo.Context) error {
 
 // RequestPasswordReset パスワードリセット要求を処理する
 func (h *AuthHandler) RequestPasswordReset(c echo.Context) error {
-	var req struct {
-		Email string `json:"email"`
+	var req models.PasswordResetRequest
+	if err := c.Bind(&req); err != nil {
+		return errInvalidRequest
 	}
 
-	if err := c.Bind(&req); err != nil || req.Email == "" {
-		return c.JSON(http.StatusBadRequest, map[string]interface{}{
-			"success": false,
-			"error":   "Email is required",
-		})
+	// バリデーション
+	if err := h.validator.Struct(req); err != nil {
+		return apperror.Validation("Valid email is required")
+	}
+
+	if err := h.passwordResetService.RequestPasswordReset(req.Email); err != nil {
+		return err
 	}
 
-	// TODO: 実際のパスワードリセットメール送信処理
 	// 注: セキュリティのため、ユーザーが存在しない場合でも同じレスポンスを返す
 	return c.JSON(http.StatusOK, map[string]interface{}{
 		"success": true,
@@ -483,129 +514,101 @@ func (h *AuthHandler) RequestPasswordReset(c echo.Context) error {
 
 // ResetPassword パスワードをリセットする
 func (h *AuthHandler) ResetPassword(c echo.Context) error {
-	var req struct {
-		Token       string `json:"token"`
-		NewPassword string `json:"new_password"`
+	var req models.PasswordResetConfirmRequest
+	if err := c.Bind(&req); err != nil {
+		return errInvalidRequest
 	}
 
-	if err := c.Bind(&req); err != nil || req.Token == "" || req.NewPassword == "" {
-		return c.JSON(http.StatusBadRequest, map[string]interface{}{
-			"success": false,
-			"error":   "Token and new password are required",
-		})
+	// バリデーション
+	if err := h.validator.Struct(req); err != nil {
+		return validationError(err)
+	}
+
+	if err := h.passwordResetService.ResetPassword(req.Token, req.NewPassword, clientInfo(c)); err != nil {
+		return err
 	}
 
-	// TODO: 実際のパスワードリセット処理
 	return c.JSON(http.StatusOK, map[string]interface{}{
 		"success": true,
 		"message": "Password has been reset successfully",
 	})
 }
 
-// ListManuals マニュアル一覧を取得する
-func ListManuals(c echo.Context) error {
-	// TODO: マニュアル一覧取得処理の実装
+// GetPasswordPolicy 新しいパスワードが満たすべき条件を返す
+func (h *AuthHandler) GetPasswordPolicy(c echo.Context) error {
 	return c.JSON(http.StatusOK, map[string]interface{}{
 		"success": true,
-		"message": "List Manuals API - to be implemented",
+		"data":    h.authService.PasswordPolicy(),
 	})
 }
 
-// CreateManual 新規マニュアルを作成する
-func CreateManual(c echo.Context) error {
-	// TODO: マニュアル作成処理の実装
-	return c.JSON(http.StatusOK, map[string]interface{}{
-		"success": true,
-		"message": "Create Manual API - to be implemented",
-	})
-}
+// VerifyEmail メールアドレスの確認トークンを検証する
+func (h *AuthHandler) VerifyEmail(c echo.Context) error {
+	var req models.EmailVerifyRequest
+	if err := c.Bind(&req); err != nil {
+		return errInvalidRequest
+	}
 
-// GetManual 特定のマニュアルを取得する
-func GetManual(c echo.Context) error {
-	// TODO: マニュアル取得処理の実装
-	return c.JSON(http.StatusOK, map[string]interface{}{
-		"success": true,
-		"message": "Get Manual API - to be implemented",
-	})
-}
+	// バリデーション
+	if err := h.validator.Struct(req); err != nil {
+		return validationError(err)
+	}
 
-// UpdateManual マニュアルを更新する
-func UpdateManual(c echo.Context) error {
-	// TODO: マニュアル更新処理の実装
-	return c.JSON(http.StatusOK, map[string]interface{}{
-		"success": true,
-		"message": "Update Manual API - to be implemented",
-	})
-}
+	if err := h.emailVerificationService.Verify(req.Token, clientInfo(c)); err != nil {
+		return err
+	}
 
-// DeleteManual マニュアルを削除する
-func DeleteManual(c echo.Context) error {
-	// TODO: マニュアル削除処理の実装
 	return c.JSON(http.StatusOK, map[string]interface{}{
 		"success": true,
-		"message": "Delete Manual API - to be implemented",
+		"message": "Email address has been verified",
 	})
 }
 
-// ListSteps 特定マニュアルの手順一覧を取得する
-func ListSteps(c echo.Context) error {
-	// TODO: 手順一覧取得処理の実装
-	return c.JSON(http.StatusOK, map[string]interface{}{
-		"success": true,
-		"message": "List Steps API - to be implemented",
-	})
-}
+// ResendEmailVerification 確認メールを再送信する
+func (h *AuthHandler) ResendEmailVerification(c echo.Context) error {
+	var req models.EmailVerificationResendRequest
+	if err := c.Bind(&req); err != nil {
+		return errInvalidRequest
+	}
 
-// CreateStep 手順を作成する
-func CreateStep(c echo.Context) error {
-	// TODO: 手順作成処理の実装
-	return c.JSON(http.StatusOK, map[string]interface{}{
-		"success": true,
-		"message": "Create Step API - to be implemented",
-	})
-}
+	// バリデーション
+	if err := h.validator.Struct(req); err != nil {
+		return apperror.Validation("Valid email is required")
+	}
 
-// UpdateStep 手順を更新する
-func UpdateStep(c echo.Context) error {
-	// TODO: 手順更新処理の実装
-	return c.JSON(http.StatusOK, map[string]interface{}{
-		"success": true,
-		"message": "Update Step API - to be implemented",
-	})
-}
+	if err := h.emailVerificationService.Resend(req.Email); err != nil {
+		return err
+	}
 
-// DeleteStep 手順を削除する
-func DeleteStep(c echo.Context) error {
-	// TODO: 手順削除処理の実装
+	// 注: セキュリティのため、ユーザーが存在しない場合や確認済みの場合でも同じレスポンスを返す
 	return c.JSON(http.StatusOK, map[string]interface{}{
 		"success": true,
-		"message": "Delete Step API - to be implemented",
+		"message": "If an unverified account with that email exists, we have sent a verification link",
 	})
 }
 
-// UpdateStepsOrder 手順の順番を更新する
-func UpdateStepsOrder(c echo.Context) error {
-	// TODO: 手順順番更新処理の実装
-	return c.JSON(http.StatusOK, map[string]interface{}{
-		"success": true,
-		"message": "Update Steps Order API - to be implemented",
-	})
+// clientInfo はセッションに記録するクライアント情報をリクエストから取得する
+func clientInfo(c echo.Context) models.ClientInfo {
+	return models.ClientInfo{
+		UserAgent: c.Request().UserAgent(),
+		IPAddress: c.RealIP(),
+	}
 }
 
-// UploadImage 画像をアップロードする
-func UploadImage(c echo.Context) error {
-	// TODO: 画像アップロード処理の実装
-	return c.JSON(http.StatusOK, map[string]interface{}{
-		"success": true,
-		"message": "Upload Image API - to be implemented",
-	})
+// loginResponseData はログインのレスポンスとして返すデータを選ぶ
+// 二要素目の入力が必要な場合はトークンの代わりにチャレンジを返す
+func loginResponseData(res *models.AuthResponse, challenge *models.TwoFactorChallenge) interface{} {
+	if challenge != nil {
+		return challenge
+	}
+	return res
 }
 
-// DeleteImage 画像を削除する
-func DeleteImage(c echo.Context) error {
-	// TODO: 画像削除処理の実装
-	return c.JSON(http.StatusOK, map[string]interface{}{
-		"success": true,
-		"message": "Delete Image API - to be implemented",
-	})
-}
\ No newline at end of file
+// registerResponseData は登録のレスポンスとして返すデータを選ぶ
+// メールアドレスの確認が必要な場合はトークンの代わりに確認待ちであることを返す
+func registerResponseData(res *models.AuthResponse, pending *models.EmailVerificationPending) interface{} {
+	if pending != nil {
+		return pending
+	}
+	return res
+}
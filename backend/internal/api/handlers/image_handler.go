@@ -0,0 +1,98 @@
+package handlers
+
+import (
+	"mime"
+	"net/http"
+
+	"github.com/Ryo-cool/guideforge/internal/apperror"
+	"github.com/Ryo-cool/guideforge/internal/auth"
+	"github.com/Ryo-cool/guideforge/internal/services"
+	"github.com/Ryo-cool/guideforge/internal/storage"
+	"github.com/labstack/echo/v4"
+)
+
+// imageCacheControl は画像レスポンスのCache-Control
+// 認証が必要なため共有キャッシュには保存させず、期限切れ後はETagで再検証させる
+const imageCacheControl = "private, max-age=300, must-revalidate"
+
+// ImageHandler 画像配信関連のハンドラー
+type ImageHandler struct {
+	imageService *services.ImageService
+}
+
+// NewImageHandler 新しい ImageHandler インスタンスを作成
+func NewImageHandler(imageService *services.ImageService) *ImageHandler {
+	return &ImageHandler{
+		imageService: imageService,
+	}
+}
+
+// GetStepImage 手順画像を配信する
+// クエリパラメータ size: original（省略時）, medium, thumbnail
+func (h *ImageHandler) GetStepImage(c echo.Context) error {
+	userID, err := auth.GetUserIDFromToken(c)
+	if err != nil {
+		return errUnauthorized
+	}
+
+	id, err := parseIDParam(c, "id")
+	if err != nil {
+		return apperror.Validation("Invalid image ID")
+	}
+
+	image, obj, err := h.imageService.GetStepImage(id, userID, c.QueryParam("size"))
+	if err != nil {
+		return err
+	}
+	defer obj.Close()
+
+	return serveObject(c, obj, image.MimeType, image.FileName)
+}
+
+// GetProfileImage ユーザーのプロフィール画像を配信する（IDに "me" を指定すると自分の画像）
+func (h *ImageHandler) GetProfileImage(c echo.Context) error {
+	userID, err := auth.GetUserIDFromToken(c)
+	if err != nil {
+		return errUnauthorized
+	}
+
+	id := userID
+	if c.Param("id") != "me" {
+		id, err = parseIDParam(c, "id")
+		if err != nil {
+			return apperror.Validation("Invalid user ID")
+		}
+	}
+
+	obj, err := h.imageService.GetProfileImage(id)
+	if err != nil {
+		return err
+	}
+	defer obj.Close()
+
+	return serveObject(c, obj, "", "")
+}
+
+// serveObject はファイルをキャッシュ関連ヘッダー付きで送信する
+// 条件付きリクエスト（If-None-Match / If-Modified-Since）とRangeリクエストは http.ServeContent が処理する
+func serveObject(c echo.Context, obj *storage.Object, contentType, fileName string) error {
+	if contentType == "" {
+		contentType = obj.ContentType
+	}
+
+	header := c.Response().Header()
+	if contentType != "" {
+		header.Set(echo.HeaderContentType, contentType)
+	}
+	if obj.ETag != "" {
+		header.Set("ETag", obj.ETag)
+	}
+	header.Set("Cache-Control", imageCacheControl)
+	header.Set("X-Content-Type-Options", "nosniff")
+	if fileName != "" {
+		header.Set(echo.HeaderContentDisposition, mime.FormatMediaType("inline", map[string]string{"filename": fileName}))
+	}
+
+	http.ServeContent(c.Response(), c.Request(), "", obj.ModTime, obj)
+	return nil
+}
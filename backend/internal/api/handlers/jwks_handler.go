@@ -0,0 +1,28 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/Ryo-cool/guideforge/internal/auth"
+	"github.com/labstack/echo/v4"
+)
+
+// JWKSHandler トークン検証用の公開鍵を配信するハンドラー
+type JWKSHandler struct {
+	tokens *auth.TokenService
+}
+
+// NewJWKSHandler 新しい JWKSHandler インスタンスを作成
+func NewJWKSHandler(tokens *auth.TokenService) *JWKSHandler {
+	return &JWKSHandler{
+		tokens: tokens,
+	}
+}
+
+// GetJWKS 公開鍵の一覧を返す
+// 他のサービスが標準のJWKSとして読み込めるよう、共通のレスポンス形式で包まずに返す
+func (h *JWKSHandler) GetJWKS(c echo.Context) error {
+	// 鍵のローテーションが反映されるよう、キャッシュは短時間に留める
+	c.Response().Header().Set("Cache-Control", "public, max-age=300")
+	return c.JSON(http.StatusOK, h.tokens.JWKS())
+}
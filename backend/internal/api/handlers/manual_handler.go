@@ -0,0 +1,171 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/Ryo-cool/guideforge/internal/apperror"
+	"github.com/Ryo-cool/guideforge/internal/auth"
+	"github.com/Ryo-cool/guideforge/internal/models"
+	"github.com/Ryo-cool/guideforge/internal/services"
+	"github.com/go-playground/validator/v10"
+	"github.com/labstack/echo/v4"
+)
+
+// ManualHandler マニュアル関連のハンドラー
+type ManualHandler struct {
+	manualService *services.ManualService
+	validator     *validator.Validate
+}
+
+// NewManualHandler 新しい ManualHandler インスタンスを作成
+func NewManualHandler(manualService *services.ManualService) *ManualHandler {
+	return &ManualHandler{
+		manualService: manualService,
+		validator:     newValidator(),
+	}
+}
+
+// ListManuals マニュアル一覧を取得する
+// クエリパラメータ: page, limit, public=true（公開マニュアル一覧）
+func (h *ManualHandler) ListManuals(c echo.Context) error {
+	userID, err := auth.GetUserIDFromToken(c)
+	if err != nil {
+		return errUnauthorized
+	}
+
+	// ページネーション（不正な値はサービス側でデフォルト値に補正される）
+	page, _ := strconv.Atoi(c.QueryParam("page"))
+	limit, _ := strconv.Atoi(c.QueryParam("limit"))
+
+	var res *models.PaginatedResponse
+	if c.QueryParam("public") == "true" {
+		res, err = h.manualService.GetPublicManuals(page, limit)
+	} else {
+		res, err = h.manualService.GetUserManuals(userID, page, limit)
+	}
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"success": true,
+		"data":    res,
+	})
+}
+
+// CreateManual 新規マニュアルを作成する
+func (h *ManualHandler) CreateManual(c echo.Context) error {
+	userID, err := auth.GetUserIDFromToken(c)
+	if err != nil {
+		return errUnauthorized
+	}
+
+	var req models.ManualRequest
+	if err := c.Bind(&req); err != nil {
+		return errInvalidRequest
+	}
+
+	// バリデーション
+	if err := h.validator.Struct(req); err != nil {
+		return validationError(err)
+	}
+
+	manual, err := h.manualService.CreateManual(userID, req, clientInfo(c))
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusCreated, map[string]interface{}{
+		"success": true,
+		"data":    manual,
+	})
+}
+
+// GetManual 特定のマニュアルを手順付きで取得する
+func (h *ManualHandler) GetManual(c echo.Context) error {
+	userID, err := auth.GetUserIDFromToken(c)
+	if err != nil {
+		return errUnauthorized
+	}
+
+	id, err := parseIDParam(c, "id")
+	if err != nil {
+		return apperror.Validation("Invalid manual ID")
+	}
+
+	manual, err := h.manualService.GetManualByID(id, userID)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"success": true,
+		"data":    manual,
+	})
+}
+
+// UpdateManual マニュアルを更新する
+func (h *ManualHandler) UpdateManual(c echo.Context) error {
+	userID, err := auth.GetUserIDFromToken(c)
+	if err != nil {
+		return errUnauthorized
+	}
+
+	id, err := parseIDParam(c, "id")
+	if err != nil {
+		return apperror.Validation("Invalid manual ID")
+	}
+
+	var req models.ManualRequest
+	if err := c.Bind(&req); err != nil {
+		return errInvalidRequest
+	}
+
+	// バリデーション
+	if err := h.validator.Struct(req); err != nil {
+		return validationError(err)
+	}
+
+	manual, err := h.manualService.UpdateManual(id, userID, req, clientInfo(c))
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"success": true,
+		"data":    manual,
+	})
+}
+
+// DeleteManual マニュアルを削除する
+func (h *ManualHandler) DeleteManual(c echo.Context) error {
+	userID, err := auth.GetUserIDFromToken(c)
+	if err != nil {
+		return errUnauthorized
+	}
+
+	id, err := parseIDParam(c, "id")
+	if err != nil {
+		return apperror.Validation("Invalid manual ID")
+	}
+
+	if err := h.manualService.DeleteManual(id, userID, clientInfo(c)); err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"success": true,
+		"message": "Manual deleted successfully",
+	})
+}
+
+// parseIDParam はパスパラメータからIDを取得する
+func parseIDParam(c echo.Context, name string) (uint, error) {
+	id, err := strconv.ParseUint(c.Param(name), 10, 64)
+	if err != nil || id == 0 {
+		return 0, fmt.Errorf("invalid %s", name)
+	}
+	return uint(id), nil
+}
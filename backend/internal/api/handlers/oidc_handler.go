@@ -0,0 +1,116 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Ryo-cool/guideforge/internal/config"
+	"github.com/Ryo-cool/guideforge/internal/services"
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	// oidcStateCookie は認可リクエストをブラウザに紐づけるstateを保存するCookie
+	oidcStateCookie = "oidc_state"
+	// oidcCookiePath はstateのCookieを送信するパス
+	oidcCookiePath = "/api/auth/oidc"
+)
+
+// OIDCHandler シングルサインオン（OpenID Connect）のハンドラー
+type OIDCHandler struct {
+	oidcService *services.OIDCService
+	config      *config.Config
+}
+
+// NewOIDCHandler 新しい OIDCHandler インスタンスを作成
+func NewOIDCHandler(oidcService *services.OIDCService, config *config.Config) *OIDCHandler {
+	return &OIDCHandler{
+		oidcService: oidcService,
+		config:      config,
+	}
+}
+
+// GetProviders 利用できるログイン方法を返す
+func (h *OIDCHandler) GetProviders(c echo.Context) error {
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"success": true,
+		"data": map[string]bool{
+			"password": h.config.PasswordLoginEnabled,
+			"oidc":     h.oidcService.Enabled(),
+		},
+	})
+}
+
+// Login IdPの認可エンドポイントへリダイレクトする
+// redirect にログイン後に表示するフロントエンドのパスを指定できる
+func (h *OIDCHandler) Login(c echo.Context) error {
+	authURL, state, err := h.oidcService.BeginLogin(c.Request().Context(), c.QueryParam("redirect"))
+	if err != nil {
+		return err
+	}
+
+	h.setStateCookie(c, state, int(services.OIDCLoginStateExpiration/time.Second))
+	return c.Redirect(http.StatusFound, authURL)
+}
+
+// Callback IdPからのコールバックを処理し、トークンを付けてフロントエンドへリダイレクトする
+// トークンはサーバーのログに残らないよう、URLのフラグメントで渡す
+func (h *OIDCHandler) Callback(c echo.Context) error {
+	state := c.QueryParam("state")
+	cookie, _ := c.Cookie(oidcStateCookie)
+	h.setStateCookie(c, "", -1)
+
+	if idpError := c.QueryParam("error"); idpError != "" {
+		log.Printf("oidc login failed at identity provider: %s", idpError)
+		return h.redirectWithError(c, "sso_denied")
+	}
+
+	// 別のブラウザで開始された認可リクエストのコールバックは受け付けない（ログインCSRF対策）
+	if state == "" || cookie == nil || subtle.ConstantTimeCompare([]byte(cookie.Value), []byte(state)) != 1 {
+		return h.redirectWithError(c, "sso_invalid_state")
+	}
+
+	res, redirectPath, err := h.oidcService.CompleteLogin(c.Request().Context(), state, c.QueryParam("code"), clientInfo(c))
+	if err != nil {
+		log.Printf("oidc login failed: %v", err)
+		return h.redirectWithError(c, "sso_failed")
+	}
+
+	fragment := url.Values{}
+	fragment.Set("token", res.Token)
+	fragment.Set("refresh_token", res.RefreshToken)
+	fragment.Set("expires_in", strconv.FormatInt(res.ExpiresIn, 10))
+	if redirectPath != "" {
+		fragment.Set("redirect", redirectPath)
+	}
+	return c.Redirect(http.StatusFound, h.frontendURL("/auth/callback")+"#"+fragment.Encode())
+}
+
+// setStateCookie はstateのCookieを設定する（maxAgeが負の場合は削除する）
+func (h *OIDCHandler) setStateCookie(c echo.Context, value string, maxAge int) {
+	c.SetCookie(&http.Cookie{
+		Name:     oidcStateCookie,
+		Value:    value,
+		Path:     oidcCookiePath,
+		MaxAge:   maxAge,
+		HttpOnly: true,
+		Secure:   c.Scheme() == "https",
+		// IdPからのトップレベルのリダイレクトで送信されるようLaxにする
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// redirectWithError はエラーコードを付けてフロントエンドのログイン画面へリダイレクトする
+func (h *OIDCHandler) redirectWithError(c echo.Context, code string) error {
+	return c.Redirect(http.StatusFound, h.frontendURL("/login")+"#error="+url.QueryEscape(code))
+}
+
+// frontendURL はフロントエンドのURLを返す
+func (h *OIDCHandler) frontendURL(path string) string {
+	return strings.TrimRight(h.config.FrontendURL, "/") + path
+}
@@ -0,0 +1,147 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/Ryo-cool/guideforge/internal/apperror"
+	"github.com/Ryo-cool/guideforge/internal/auth"
+	"github.com/Ryo-cool/guideforge/internal/services"
+	"github.com/labstack/echo/v4"
+)
+
+// RevisionHandler マニュアル改訂履歴関連のハンドラー
+type RevisionHandler struct {
+	revisionService *services.RevisionService
+}
+
+// NewRevisionHandler 新しい RevisionHandler インスタンスを作成
+func NewRevisionHandler(revisionService *services.RevisionService) *RevisionHandler {
+	return &RevisionHandler{
+		revisionService: revisionService,
+	}
+}
+
+// ListRevisions マニュアルの改訂履歴一覧を取得する
+func (h *RevisionHandler) ListRevisions(c echo.Context) error {
+	userID, err := auth.GetUserIDFromToken(c)
+	if err != nil {
+		return errUnauthorized
+	}
+
+	manualID, err := parseIDParam(c, "id")
+	if err != nil {
+		return apperror.Validation("Invalid manual ID")
+	}
+
+	revisions, err := h.revisionService.ListRevisions(manualID, userID)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"success": true,
+		"data":    revisions,
+	})
+}
+
+// GetRevision 特定の改訂履歴を取得する
+func (h *RevisionHandler) GetRevision(c echo.Context) error {
+	userID, err := auth.GetUserIDFromToken(c)
+	if err != nil {
+		return errUnauthorized
+	}
+
+	manualID, err := parseIDParam(c, "id")
+	if err != nil {
+		return apperror.Validation("Invalid manual ID")
+	}
+
+	revisionNumber, err := parseRevisionNumber(c.Param("revision"))
+	if err != nil {
+		return apperror.Validation("Invalid revision number")
+	}
+
+	revision, err := h.revisionService.GetRevision(manualID, userID, revisionNumber)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"success": true,
+		"data":    revision,
+	})
+}
+
+// DiffRevisions 2つの改訂履歴の差分を取得する
+// クエリパラメータ: from, to（改訂番号）
+func (h *RevisionHandler) DiffRevisions(c echo.Context) error {
+	userID, err := auth.GetUserIDFromToken(c)
+	if err != nil {
+		return errUnauthorized
+	}
+
+	manualID, err := parseIDParam(c, "id")
+	if err != nil {
+		return apperror.Validation("Invalid manual ID")
+	}
+
+	from, err := parseRevisionNumber(c.QueryParam("from"))
+	if err != nil {
+		return apperror.Validation("Invalid revision number",
+			apperror.FieldError{Field: "from", Message: "must be a positive revision number"})
+	}
+
+	to, err := parseRevisionNumber(c.QueryParam("to"))
+	if err != nil {
+		return apperror.Validation("Invalid revision number",
+			apperror.FieldError{Field: "to", Message: "must be a positive revision number"})
+	}
+
+	diff, err := h.revisionService.DiffRevisions(manualID, userID, from, to)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"success": true,
+		"data":    diff,
+	})
+}
+
+// RestoreRevision マニュアルを指定した改訂履歴の状態に戻す
+func (h *RevisionHandler) RestoreRevision(c echo.Context) error {
+	userID, err := auth.GetUserIDFromToken(c)
+	if err != nil {
+		return errUnauthorized
+	}
+
+	manualID, err := parseIDParam(c, "id")
+	if err != nil {
+		return apperror.Validation("Invalid manual ID")
+	}
+
+	revisionNumber, err := parseRevisionNumber(c.Param("revision"))
+	if err != nil {
+		return apperror.Validation("Invalid revision number")
+	}
+
+	manual, err := h.revisionService.RestoreRevision(manualID, userID, revisionNumber, clientInfo(c))
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"success": true,
+		"data":    manual,
+	})
+}
+
+// parseRevisionNumber は改訂番号を解析する
+func parseRevisionNumber(value string) (int, error) {
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 1 {
+		return 0, strconv.ErrSyntax
+	}
+	return n, nil
+}
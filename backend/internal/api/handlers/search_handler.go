@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/Ryo-cool/guideforge/internal/apperror"
+	"github.com/Ryo-cool/guideforge/internal/auth"
+	"github.com/Ryo-cool/guideforge/internal/models"
+	"github.com/Ryo-cool/guideforge/internal/services"
+	"github.com/labstack/echo/v4"
+)
+
+// SearchHandler 検索関連のハンドラー
+type SearchHandler struct {
+	searchService *services.SearchService
+}
+
+// NewSearchHandler 新しい SearchHandler インスタンスを作成
+func NewSearchHandler(searchService *services.SearchService) *SearchHandler {
+	return &SearchHandler{
+		searchService: searchService,
+	}
+}
+
+// SearchManuals マニュアルを全文検索する
+// クエリパラメータ: q（必須）, category, owner, workspace, visibility=public|private, page, limit
+func (h *SearchHandler) SearchManuals(c echo.Context) error {
+	userID, err := auth.GetUserIDFromToken(c)
+	if err != nil {
+		return errUnauthorized
+	}
+
+	params := models.ManualSearchParams{
+		Query:      c.QueryParam("q"),
+		Category:   c.QueryParam("category"),
+		Visibility: c.QueryParam("visibility"),
+	}
+
+	if len([]rune(params.Query)) > 200 {
+		return apperror.Validation(
+			"Validation error",
+			apperror.FieldError{Field: "q", Message: "must be at most 200"},
+		)
+	}
+
+	switch params.Visibility {
+	case "", "public", "private":
+	default:
+		return apperror.Validation(
+			"Validation error",
+			apperror.FieldError{Field: "visibility", Message: "must be one of: public private"},
+		)
+	}
+
+	if owner := c.QueryParam("owner"); owner != "" {
+		id, err := strconv.ParseUint(owner, 10, 32)
+		if err != nil {
+			return apperror.Validation("Invalid owner ID")
+		}
+		ownerID := uint(id)
+		params.OwnerID = &ownerID
+	}
+
+	if workspace := c.QueryParam("workspace"); workspace != "" {
+		id, err := strconv.ParseUint(workspace, 10, 32)
+		if err != nil {
+			return apperror.Validation("Invalid workspace ID")
+		}
+		workspaceID := uint(id)
+		params.WorkspaceID = &workspaceID
+	}
+
+	// ページネーション（不正な値はサービス側でデフォルト値に補正される）
+	params.Page, _ = strconv.Atoi(c.QueryParam("page"))
+	params.Limit, _ = strconv.Atoi(c.QueryParam("limit"))
+
+	res, err := h.searchService.SearchManuals(userID, params)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"success": true,
+		"data":    res,
+	})
+}
@@ -0,0 +1,251 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/Ryo-cool/guideforge/internal/apperror"
+	"github.com/Ryo-cool/guideforge/internal/auth"
+	"github.com/Ryo-cool/guideforge/internal/config"
+	"github.com/Ryo-cool/guideforge/internal/imageproc"
+	"github.com/Ryo-cool/guideforge/internal/models"
+	"github.com/Ryo-cool/guideforge/internal/services"
+	"github.com/go-playground/validator/v10"
+	"github.com/labstack/echo/v4"
+)
+
+// StepHandler 手順・画像関連のハンドラー
+type StepHandler struct {
+	manualService *services.ManualService
+	config        *config.Config
+	validator     *validator.Validate
+}
+
+// NewStepHandler 新しい StepHandler インスタンスを作成
+func NewStepHandler(manualService *services.ManualService, config *config.Config) *StepHandler {
+	return &StepHandler{
+		manualService: manualService,
+		config:        config,
+		validator:     newValidator(),
+	}
+}
+
+// ListSteps 特定マニュアルの手順一覧を取得する
+func (h *StepHandler) ListSteps(c echo.Context) error {
+	userID, err := auth.GetUserIDFromToken(c)
+	if err != nil {
+		return errUnauthorized
+	}
+
+	manualID, err := parseIDParam(c, "id")
+	if err != nil {
+		return apperror.Validation("Invalid manual ID")
+	}
+
+	steps, err := h.manualService.GetManualSteps(manualID, userID)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"success": true,
+		"data":    steps,
+	})
+}
+
+// CreateStep 手順を作成する
+func (h *StepHandler) CreateStep(c echo.Context) error {
+	userID, err := auth.GetUserIDFromToken(c)
+	if err != nil {
+		return errUnauthorized
+	}
+
+	manualID, err := parseIDParam(c, "id")
+	if err != nil {
+		return apperror.Validation("Invalid manual ID")
+	}
+
+	var req models.StepRequest
+	if err := c.Bind(&req); err != nil {
+		return errInvalidRequest
+	}
+
+	// バリデーション
+	if err := h.validator.Struct(req); err != nil {
+		return validationError(err)
+	}
+
+	step, err := h.manualService.CreateStep(manualID, userID, req, clientInfo(c))
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusCreated, map[string]interface{}{
+		"success": true,
+		"data":    step,
+	})
+}
+
+// UpdateStep 手順を更新する
+func (h *StepHandler) UpdateStep(c echo.Context) error {
+	userID, err := auth.GetUserIDFromToken(c)
+	if err != nil {
+		return errUnauthorized
+	}
+
+	stepID, err := parseIDParam(c, "id")
+	if err != nil {
+		return apperror.Validation("Invalid step ID")
+	}
+
+	var req models.StepRequest
+	if err := c.Bind(&req); err != nil {
+		return errInvalidRequest
+	}
+
+	// バリデーション
+	if err := h.validator.Struct(req); err != nil {
+		return validationError(err)
+	}
+
+	step, err := h.manualService.UpdateStep(stepID, userID, req, clientInfo(c))
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"success": true,
+		"data":    step,
+	})
+}
+
+// DeleteStep 手順を削除する
+func (h *StepHandler) DeleteStep(c echo.Context) error {
+	userID, err := auth.GetUserIDFromToken(c)
+	if err != nil {
+		return errUnauthorized
+	}
+
+	stepID, err := parseIDParam(c, "id")
+	if err != nil {
+		return apperror.Validation("Invalid step ID")
+	}
+
+	if err := h.manualService.DeleteStep(stepID, userID, clientInfo(c)); err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"success": true,
+		"message": "Step deleted successfully",
+	})
+}
+
+// UpdateStepsOrder 手順の順番を更新する
+func (h *StepHandler) UpdateStepsOrder(c echo.Context) error {
+	userID, err := auth.GetUserIDFromToken(c)
+	if err != nil {
+		return errUnauthorized
+	}
+
+	manualID, err := parseIDParam(c, "id")
+	if err != nil {
+		return apperror.Validation("Invalid manual ID")
+	}
+
+	var req models.StepOrderRequest
+	if err := c.Bind(&req); err != nil {
+		return errInvalidRequest
+	}
+
+	// バリデーション
+	if err := h.validator.Struct(req); err != nil {
+		return validationError(err)
+	}
+
+	if err := h.manualService.UpdateStepOrder(manualID, userID, req.Steps, clientInfo(c)); err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"success": true,
+		"message": "Steps order updated successfully",
+	})
+}
+
+// UploadImage 手順に画像をアップロードする
+func (h *StepHandler) UploadImage(c echo.Context) error {
+	userID, err := auth.GetUserIDFromToken(c)
+	if err != nil {
+		return errUnauthorized
+	}
+
+	stepID, err := parseIDParam(c, "id")
+	if err != nil {
+		return apperror.Validation("Invalid step ID")
+	}
+
+	// リクエストボディ全体のサイズを制限（マルチパートのオーバーヘッド分を上乗せ）
+	c.Request().Body = http.MaxBytesReader(c.Response(), c.Request().Body, h.config.MaxUploadSize+1024*1024)
+
+	// マルチパートフォームから画像ファイル取得
+	file, fileHeader, err := c.Request().FormFile("image")
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			return echo.NewHTTPError(http.StatusRequestEntityTooLarge, fmt.Sprintf("File too large (max %d bytes)", h.config.MaxUploadSize))
+		}
+		return apperror.Validation("Invalid file upload")
+	}
+	defer file.Close()
+
+	// ファイルサイズチェック
+	if fileHeader.Size > h.config.MaxUploadSize {
+		return echo.NewHTTPError(http.StatusRequestEntityTooLarge, fmt.Sprintf("File too large (max %d bytes)", h.config.MaxUploadSize))
+	}
+
+	// ファイルコンテンツ読み込み
+	fileData, err := io.ReadAll(io.LimitReader(file, h.config.MaxUploadSize+1))
+	if err != nil {
+		return err
+	}
+
+	// 画像ファイルのみ受け付ける（クライアントが申告したContent-Typeではなくファイルの内容で判定）
+	if _, err := imageproc.DetectType(fileData); err != nil {
+		return echo.NewHTTPError(http.StatusUnsupportedMediaType, "Only JPEG, PNG, GIF and WebP images are allowed")
+	}
+
+	image, err := h.manualService.UploadStepImage(stepID, userID, fileHeader.Filename, fileData, clientInfo(c))
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusCreated, map[string]interface{}{
+		"success": true,
+		"data":    image,
+	})
+}
+
+// DeleteImage 画像を削除する
+func (h *StepHandler) DeleteImage(c echo.Context) error {
+	userID, err := auth.GetUserIDFromToken(c)
+	if err != nil {
+		return errUnauthorized
+	}
+
+	imageID, err := parseIDParam(c, "id")
+	if err != nil {
+		return apperror.Validation("Invalid image ID")
+	}
+
+	if err := h.manualService.DeleteStepImage(imageID, userID, clientInfo(c)); err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"success": true,
+		"message": "Image deleted successfully",
+	})
+}
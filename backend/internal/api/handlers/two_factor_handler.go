@@ -0,0 +1,145 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/Ryo-cool/guideforge/internal/auth"
+	"github.com/Ryo-cool/guideforge/internal/models"
+	"github.com/Ryo-cool/guideforge/internal/services"
+	"github.com/go-playground/validator/v10"
+	"github.com/labstack/echo/v4"
+)
+
+// TwoFactorHandler 二要素認証の設定のハンドラー
+type TwoFactorHandler struct {
+	twoFactorService *services.TwoFactorService
+	validator        *validator.Validate
+}
+
+// NewTwoFactorHandler 新しい TwoFactorHandler インスタンスを作成
+func NewTwoFactorHandler(twoFactorService *services.TwoFactorService) *TwoFactorHandler {
+	return &TwoFactorHandler{
+		twoFactorService: twoFactorService,
+		validator:        newValidator(),
+	}
+}
+
+// GetStatus 二要素認証の設定状況を取得する
+func (h *TwoFactorHandler) GetStatus(c echo.Context) error {
+	userID, err := auth.GetUserIDFromToken(c)
+	if err != nil {
+		return errUnauthorized
+	}
+
+	status, err := h.twoFactorService.Status(userID)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"success": true,
+		"data":    status,
+	})
+}
+
+// BeginTOTPEnrollment 認証アプリの登録を開始し、QRコードを返す
+func (h *TwoFactorHandler) BeginTOTPEnrollment(c echo.Context) error {
+	userID, err := auth.GetUserIDFromToken(c)
+	if err != nil {
+		return errUnauthorized
+	}
+
+	enrollment, err := h.twoFactorService.BeginEnrollment(userID)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"success": true,
+		"data":    enrollment,
+	})
+}
+
+// ConfirmTOTPEnrollment 認証アプリのコードを確認して二要素認証を有効にする
+// リカバリーコードはこのレスポンスでのみ返す
+func (h *TwoFactorHandler) ConfirmTOTPEnrollment(c echo.Context) error {
+	userID, err := auth.GetUserIDFromToken(c)
+	if err != nil {
+		return errUnauthorized
+	}
+
+	var req models.TwoFactorCodeRequest
+	if err := c.Bind(&req); err != nil {
+		return errInvalidRequest
+	}
+
+	// バリデーション
+	if err := h.validator.Struct(req); err != nil {
+		return validationError(err)
+	}
+
+	codes, err := h.twoFactorService.ConfirmEnrollment(userID, req.Code, clientInfo(c))
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"success": true,
+		"data":    codes,
+	})
+}
+
+// Disable 二要素認証を無効にする
+func (h *TwoFactorHandler) Disable(c echo.Context) error {
+	userID, err := auth.GetUserIDFromToken(c)
+	if err != nil {
+		return errUnauthorized
+	}
+
+	var req models.TwoFactorDisableRequest
+	if err := c.Bind(&req); err != nil {
+		return errInvalidRequest
+	}
+
+	// バリデーション
+	if err := h.validator.Struct(req); err != nil {
+		return validationError(err)
+	}
+
+	if err := h.twoFactorService.Disable(userID, req.Password, req.Code, clientInfo(c)); err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"success": true,
+		"message": "Two-factor authentication disabled successfully",
+	})
+}
+
+// RegenerateRecoveryCodes リカバリーコードを発行し直す
+func (h *TwoFactorHandler) RegenerateRecoveryCodes(c echo.Context) error {
+	userID, err := auth.GetUserIDFromToken(c)
+	if err != nil {
+		return errUnauthorized
+	}
+
+	var req models.TwoFactorCodeRequest
+	if err := c.Bind(&req); err != nil {
+		return errInvalidRequest
+	}
+
+	// バリデーション
+	if err := h.validator.Struct(req); err != nil {
+		return validationError(err)
+	}
+
+	codes, err := h.twoFactorService.RegenerateRecoveryCodes(userID, req.Code, clientInfo(c))
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"success": true,
+		"data":    codes,
+	})
+}
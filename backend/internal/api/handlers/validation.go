@@ -0,0 +1,66 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/Ryo-cool/guideforge/internal/apperror"
+	"github.com/go-playground/validator/v10"
+)
+
+// newValidator はフィールド名にJSONタグ名を使用するバリデーターを作成する
+func newValidator() *validator.Validate {
+	v := validator.New()
+	v.RegisterTagNameFunc(func(field reflect.StructField) string {
+		name := strings.SplitN(field.Tag.Get("json"), ",", 2)[0]
+		if name == "-" {
+			return ""
+		}
+		return name
+	})
+	return v
+}
+
+// validationError はバリデーターのエラーをフィールド詳細付きのエラーに変換する
+func validationError(err error) error {
+	var errs validator.ValidationErrors
+	if !errors.As(err, &errs) {
+		return apperror.Validation(fmt.Sprintf("Validation error: %v", err))
+	}
+
+	fields := make([]apperror.FieldError, 0, len(errs))
+	for _, fe := range errs {
+		fields = append(fields, apperror.FieldError{
+			Field:   fe.Field(),
+			Message: fieldErrorMessage(fe),
+		})
+	}
+
+	return apperror.Validation("Validation error", fields...)
+}
+
+// fieldErrorMessage はフィールドエラーのメッセージを組み立てる
+func fieldErrorMessage(fe validator.FieldError) string {
+	switch fe.Tag() {
+	case "required":
+		return "is required"
+	case "email":
+		return "must be a valid email address"
+	case "min":
+		return fmt.Sprintf("must be at least %s", fe.Param())
+	case "max":
+		return fmt.Sprintf("must be at most %s", fe.Param())
+	case "oneof":
+		return fmt.Sprintf("must be one of: %s", fe.Param())
+	default:
+		return fmt.Sprintf("failed on the '%s' rule", fe.Tag())
+	}
+}
+
+// errInvalidRequest はリクエストボディの形式が不正な場合のエラー
+var errInvalidRequest = apperror.Validation("Invalid request format")
+
+// errUnauthorized はトークンからユーザーを特定できない場合のエラー
+var errUnauthorized = apperror.Unauthorized("Unauthorized")
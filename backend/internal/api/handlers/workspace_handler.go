@@ -0,0 +1,240 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/Ryo-cool/guideforge/internal/apperror"
+	"github.com/Ryo-cool/guideforge/internal/auth"
+	"github.com/Ryo-cool/guideforge/internal/models"
+	"github.com/Ryo-cool/guideforge/internal/services"
+	"github.com/go-playground/validator/v10"
+	"github.com/labstack/echo/v4"
+)
+
+// WorkspaceHandler ワークスペース関連のハンドラー
+type WorkspaceHandler struct {
+	workspaceService *services.WorkspaceService
+	manualService    *services.ManualService
+	validator        *validator.Validate
+}
+
+// NewWorkspaceHandler 新しい WorkspaceHandler インスタンスを作成
+func NewWorkspaceHandler(workspaceService *services.WorkspaceService, manualService *services.ManualService) *WorkspaceHandler {
+	return &WorkspaceHandler{
+		workspaceService: workspaceService,
+		manualService:    manualService,
+		validator:        newValidator(),
+	}
+}
+
+// ListWorkspaces 所属するワークスペース一覧を取得する
+func (h *WorkspaceHandler) ListWorkspaces(c echo.Context) error {
+	userID, err := auth.GetUserIDFromToken(c)
+	if err != nil {
+		return errUnauthorized
+	}
+
+	workspaces, err := h.workspaceService.GetUserWorkspaces(userID)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"success": true,
+		"data":    workspaces,
+	})
+}
+
+// CreateWorkspace ワークスペースを作成する
+func (h *WorkspaceHandler) CreateWorkspace(c echo.Context) error {
+	userID, err := auth.GetUserIDFromToken(c)
+	if err != nil {
+		return errUnauthorized
+	}
+
+	var req models.WorkspaceRequest
+	if err := c.Bind(&req); err != nil {
+		return errInvalidRequest
+	}
+
+	// バリデーション
+	if err := h.validator.Struct(req); err != nil {
+		return validationError(err)
+	}
+
+	workspace, err := h.workspaceService.CreateWorkspace(userID, req, clientInfo(c))
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusCreated, map[string]interface{}{
+		"success": true,
+		"data":    workspace,
+	})
+}
+
+// GetWorkspace ワークスペースをメンバー一覧付きで取得する
+func (h *WorkspaceHandler) GetWorkspace(c echo.Context) error {
+	userID, err := auth.GetUserIDFromToken(c)
+	if err != nil {
+		return errUnauthorized
+	}
+
+	id, err := parseIDParam(c, "id")
+	if err != nil {
+		return apperror.Validation("Invalid workspace ID")
+	}
+
+	workspace, err := h.workspaceService.GetWorkspace(id, userID)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"success": true,
+		"data":    workspace,
+	})
+}
+
+// UpdateWorkspace ワークスペースを更新する
+func (h *WorkspaceHandler) UpdateWorkspace(c echo.Context) error {
+	userID, err := auth.GetUserIDFromToken(c)
+	if err != nil {
+		return errUnauthorized
+	}
+
+	id, err := parseIDParam(c, "id")
+	if err != nil {
+		return apperror.Validation("Invalid workspace ID")
+	}
+
+	var req models.WorkspaceRequest
+	if err := c.Bind(&req); err != nil {
+		return errInvalidRequest
+	}
+
+	// バリデーション
+	if err := h.validator.Struct(req); err != nil {
+		return validationError(err)
+	}
+
+	workspace, err := h.workspaceService.UpdateWorkspace(id, userID, req, clientInfo(c))
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"success": true,
+		"data":    workspace,
+	})
+}
+
+// DeleteWorkspace ワークスペースを削除する
+func (h *WorkspaceHandler) DeleteWorkspace(c echo.Context) error {
+	userID, err := auth.GetUserIDFromToken(c)
+	if err != nil {
+		return errUnauthorized
+	}
+
+	id, err := parseIDParam(c, "id")
+	if err != nil {
+		return apperror.Validation("Invalid workspace ID")
+	}
+
+	if err := h.workspaceService.DeleteWorkspace(id, userID, clientInfo(c)); err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"success": true,
+		"message": "Workspace deleted successfully",
+	})
+}
+
+// AddMember ワークスペースにメンバーを追加する
+func (h *WorkspaceHandler) AddMember(c echo.Context) error {
+	userID, err := auth.GetUserIDFromToken(c)
+	if err != nil {
+		return errUnauthorized
+	}
+
+	id, err := parseIDParam(c, "id")
+	if err != nil {
+		return apperror.Validation("Invalid workspace ID")
+	}
+
+	var req models.WorkspaceMemberRequest
+	if err := c.Bind(&req); err != nil {
+		return errInvalidRequest
+	}
+
+	// バリデーション
+	if err := h.validator.Struct(req); err != nil {
+		return validationError(err)
+	}
+
+	members, err := h.workspaceService.AddMember(id, userID, req, clientInfo(c))
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"success": true,
+		"data":    members,
+	})
+}
+
+// RemoveMember ワークスペースからメンバーを削除する
+func (h *WorkspaceHandler) RemoveMember(c echo.Context) error {
+	userID, err := auth.GetUserIDFromToken(c)
+	if err != nil {
+		return errUnauthorized
+	}
+
+	id, err := parseIDParam(c, "id")
+	if err != nil {
+		return apperror.Validation("Invalid workspace ID")
+	}
+
+	memberID, err := parseIDParam(c, "userId")
+	if err != nil {
+		return apperror.Validation("Invalid user ID")
+	}
+
+	if err := h.workspaceService.RemoveMember(id, userID, memberID, clientInfo(c)); err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"success": true,
+		"message": "Member removed successfully",
+	})
+}
+
+// ListWorkspaceManuals ワークスペースのマニュアル一覧を取得する
+func (h *WorkspaceHandler) ListWorkspaceManuals(c echo.Context) error {
+	userID, err := auth.GetUserIDFromToken(c)
+	if err != nil {
+		return errUnauthorized
+	}
+
+	id, err := parseIDParam(c, "id")
+	if err != nil {
+		return apperror.Validation("Invalid workspace ID")
+	}
+
+	// ページネーション（不正な値はサービス側でデフォルト値に補正される）
+	page, _ := strconv.Atoi(c.QueryParam("page"))
+	limit, _ := strconv.Atoi(c.QueryParam("limit"))
+
+	res, err := h.manualService.GetWorkspaceManuals(id, userID, page, limit)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"success": true,
+		"data":    res,
+	})
+}
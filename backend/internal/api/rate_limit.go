@@ -0,0 +1,39 @@
+package api
+
+import (
+	"time"
+
+	"github.com/Ryo-cool/guideforge/internal/apperror"
+	"github.com/Ryo-cool/guideforge/internal/config"
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+	"golang.org/x/time/rate"
+)
+
+// authRateLimiter は認証不要のエンドポイント向けに、IPアドレスごとのリクエスト数を制限するミドルウェアを返す
+// 1分あたり AuthRateLimit 回まで受け付け、同じ回数までの連続したリクエストを許容する
+// 制限はサーバーのメモリ上で行うため、複数のサーバーで動かす場合はサーバーごとの値になる
+func authRateLimiter(cfg *config.Config) echo.MiddlewareFunc {
+	if cfg.AuthRateLimit <= 0 {
+		return func(next echo.HandlerFunc) echo.HandlerFunc {
+			return next
+		}
+	}
+
+	limit := rate.Every(time.Minute / time.Duration(cfg.AuthRateLimit))
+	store := middleware.NewRateLimiterMemoryStoreWithConfig(middleware.RateLimiterMemoryStoreConfig{
+		Rate:      limit,
+		Burst:     cfg.AuthRateLimit,
+		ExpiresIn: 3 * time.Minute,
+	})
+
+	return middleware.RateLimiterWithConfig(middleware.RateLimiterConfig{
+		Store: store,
+		IdentifierExtractor: func(c echo.Context) (string, error) {
+			return c.RealIP(), nil
+		},
+		DenyHandler: func(c echo.Context, identifier string, err error) error {
+			return apperror.RateLimited(time.Duration(float64(time.Second)/float64(limit)), "too many requests, please try again later")
+		},
+	})
+}
@@ -6,26 +6,75 @@ import (
 	"github.com/Ryo-cool/guideforge/internal/api/handlers"
 	"github.com/Ryo-cool/guideforge/internal/auth"
 	"github.com/Ryo-cool/guideforge/internal/config"
+	"github.com/Ryo-cool/guideforge/internal/mail"
+	"github.com/Ryo-cool/guideforge/internal/models"
+	"github.com/Ryo-cool/guideforge/internal/password"
 	"github.com/Ryo-cool/guideforge/internal/repository"
 	"github.com/Ryo-cool/guideforge/internal/services"
+	"github.com/Ryo-cool/guideforge/internal/storage"
 	"github.com/jmoiron/sqlx"
 	"github.com/labstack/echo/v4"
 	_ "github.com/lib/pq" // PostgreSQLドライバ
 )
 
 // RegisterRoutes はアプリケーションのルートを設定する
-func RegisterRoutes(e *echo.Echo, cfg *config.Config, db *sqlx.DB) {
+func RegisterRoutes(e *echo.Echo, cfg *config.Config, db *sqlx.DB, mailer mail.Sender, store storage.Storage, tokenService *auth.TokenService, passwords *password.Hasher, passwordPolicy *password.Policy) {
 	// リポジトリの初期化
 	repo := repository.NewRepository(db)
 	userRepo := repository.NewUserRepository(repo)
-	
+	manualRepo := repository.NewManualRepository(repo)
+	stepRepo := repository.NewStepRepository(repo)
+	imageRepo := repository.NewImageRepository(repo)
+	imageFileRepo := repository.NewImageFileRepository(repo)
+	passwordResetRepo := repository.NewPasswordResetRepository(repo)
+	revisionRepo := repository.NewRevisionRepository(repo)
+	workspaceRepo := repository.NewWorkspaceRepository(repo)
+	searchRepo := repository.NewSearchRepository(repo)
+	sessionRepo := repository.NewSessionRepository(repo)
+	identityRepo := repository.NewIdentityRepository(repo)
+	apiTokenRepo := repository.NewAPITokenRepository(repo)
+	loginThrottleRepo := repository.NewLoginThrottleRepository(repo)
+	twoFactorRepo := repository.NewTwoFactorRepository(repo)
+	emailVerificationRepo := repository.NewEmailVerificationRepository(repo)
+	auditRepo := repository.NewAuditRepository(repo)
+
 	// サービスの初期化
-	userService := services.NewUserService(userRepo, cfg)
-	authService := services.NewAuthService(userRepo, cfg)
-	
+	auditService := services.NewAuditService(auditRepo)
+	emailVerificationService := services.NewEmailVerificationService(repo, userRepo, emailVerificationRepo, auditService, mailer, cfg)
+	userService := services.NewUserService(repo, userRepo, workspaceRepo, emailVerificationService, auditService, store, cfg)
+	loginThrottleService := services.NewLoginThrottleService(repo, loginThrottleRepo, userRepo, auditService, mailer, cfg)
+	twoFactorService := services.NewTwoFactorService(repo, userRepo, twoFactorRepo, passwords, auditService)
+	authService := services.NewAuthService(repo, userRepo, sessionRepo, tokenService, passwords, passwordPolicy, loginThrottleService, twoFactorService, emailVerificationService, auditService, cfg)
+	manualService := services.NewManualService(repo, manualRepo, stepRepo, imageRepo, imageFileRepo, revisionRepo, workspaceRepo, auditService, store, cfg)
+	oidcService := services.NewOIDCService(userRepo, identityRepo, authService, cfg)
+	passwordResetService := services.NewPasswordResetService(repo, userRepo, passwordResetRepo, sessionRepo, passwords, passwordPolicy, loginThrottleService, auditService, mailer, cfg)
+	workspaceService := services.NewWorkspaceService(repo, workspaceRepo, userRepo, auditService)
+	revisionService := services.NewRevisionService(repo, manualRepo, revisionRepo, workspaceRepo, auditService, store, cfg)
+	searchService := services.NewSearchService(searchRepo)
+	exportService := services.NewExportService(manualRepo, workspaceRepo, store, cfg)
+	importService := services.NewImportService(repo, manualRepo, stepRepo, imageRepo, imageFileRepo, revisionRepo, workspaceRepo, auditService, store, cfg)
+	imageService := services.NewImageService(manualRepo, stepRepo, imageRepo, userRepo, workspaceRepo, store)
+	storageGCService := services.NewStorageGCService(repo, imageFileRepo, store, cfg)
+	apiTokenService := services.NewAPITokenService(repo, apiTokenRepo, auditService)
+
 	// ハンドラーの初期化
-	authHandler := handlers.NewAuthHandler(authService, cfg)
-	userHandler := handlers.NewUserHandlerContext(authService, userService)
+	authHandler := handlers.NewAuthHandler(authService, passwordResetService, emailVerificationService, cfg)
+	userHandler := handlers.NewUserHandlerContext(authService, userService, cfg)
+	manualHandler := handlers.NewManualHandler(manualService)
+	stepHandler := handlers.NewStepHandler(manualService, cfg)
+	revisionHandler := handlers.NewRevisionHandler(revisionService)
+	workspaceHandler := handlers.NewWorkspaceHandler(workspaceService, manualService)
+	adminHandler := handlers.NewAdminHandler(userService, storageGCService, loginThrottleService, auditService)
+	searchHandler := handlers.NewSearchHandler(searchService)
+	exportHandler := handlers.NewExportHandler(exportService, importService, cfg)
+	imageHandler := handlers.NewImageHandler(imageService)
+	jwksHandler := handlers.NewJWKSHandler(tokenService)
+	oidcHandler := handlers.NewOIDCHandler(oidcService, cfg)
+	apiTokenHandler := handlers.NewAPITokenHandler(apiTokenService)
+	twoFactorHandler := handlers.NewTwoFactorHandler(twoFactorService)
+
+	// トークン検証用の公開鍵（他のサービス向けのため /api の外に置く）
+	e.GET("/.well-known/jwks.json", jwksHandler.GetJWKS)
 
 	// APIのベースパス
 	api := e.Group("/api")
@@ -38,38 +87,103 @@ func RegisterRoutes(e *echo.Echo, cfg *config.Config, db *sqlx.DB) {
 		})
 	})
 
-	// 認証不要のエンドポイント
-	api.POST("/login", authHandler.Login)
-	api.POST("/users", authHandler.CreateUser)
-	api.POST("/password/reset", authHandler.RequestPasswordReset)
-	api.PUT("/password/reset", authHandler.ResetPassword)
+	// 認証不要のエンドポイント（総当たり攻撃対策としてIPアドレスごとにリクエスト数を制限する）
+	rateLimit := authRateLimiter(cfg)
+	api.POST("/login", authHandler.Login, rateLimit)
+	api.POST("/login/2fa", authHandler.LoginTwoFactor, rateLimit)
+	api.POST("/token/refresh", authHandler.RefreshToken, rateLimit)
+	api.GET("/auth/providers", oidcHandler.GetProviders)
+	api.GET("/auth/oidc/login", oidcHandler.Login, rateLimit)
+	api.GET("/auth/oidc/callback", oidcHandler.Callback, rateLimit)
+	api.POST("/users", authHandler.CreateUser, rateLimit)
+	api.GET("/password/policy", authHandler.GetPasswordPolicy)
+	api.POST("/password/reset", authHandler.RequestPasswordReset, rateLimit)
+	api.PUT("/password/reset", authHandler.ResetPassword, rateLimit)
+	api.POST("/email/verify", authHandler.VerifyEmail, rateLimit)
+	api.POST("/email/verification/resend", authHandler.ResendEmailVerification, rateLimit)
 
 	// JWT認証が必要なエンドポイント
 	authenticated := api.Group("")
-	authenticated.Use(auth.JWTMiddleware(cfg))
+	authenticated.Use(auth.JWTMiddleware(tokenService, sessionRepo, apiTokenService))
+
+	// 閲覧者（viewer）は参照のみ可能で、作成・編集には editor 以上のロールが必要
+	requireEditor := auth.RequireRole(models.RoleEditor)
+	// アカウントの管理はログインセッションでのみ行え、APIトークンでは行えない
+	requireSession := auth.RequireUserSession()
+
+	// セッション関連
+	authenticated.POST("/logout", authHandler.Logout, requireSession)
+	authenticated.GET("/users/me/sessions", authHandler.ListSessions, requireSession)
+	authenticated.DELETE("/users/me/sessions", authHandler.RevokeAllSessions, requireSession)
+	authenticated.DELETE("/users/me/sessions/:id", authHandler.RevokeSession, requireSession)
+
+	// 二要素認証関連（コードの総当たりを防ぐため、コードを受け付けるエンドポイントはリクエスト数を制限する）
+	authenticated.GET("/users/me/2fa", twoFactorHandler.GetStatus, requireSession)
+	authenticated.POST("/users/me/2fa/totp", twoFactorHandler.BeginTOTPEnrollment, requireSession, rateLimit)
+	authenticated.POST("/users/me/2fa/totp/verify", twoFactorHandler.ConfirmTOTPEnrollment, requireSession, rateLimit)
+	authenticated.POST("/users/me/2fa/disable", twoFactorHandler.Disable, requireSession, rateLimit)
+	authenticated.POST("/users/me/2fa/recovery-codes", twoFactorHandler.RegenerateRecoveryCodes, requireSession, rateLimit)
+
+	// APIトークン関連
+	authenticated.GET("/users/me/tokens", apiTokenHandler.ListTokens, requireSession)
+	authenticated.POST("/users/me/tokens", apiTokenHandler.CreateToken, requireSession)
+	authenticated.DELETE("/users/me/tokens/:id", apiTokenHandler.RevokeToken, requireSession)
 
 	// ユーザー関連
 	authenticated.GET("/users/me", authHandler.GetCurrentUser)
 	authenticated.PUT("/users/me", authHandler.UpdateCurrentUser)
-	authenticated.PUT("/users/me/password", userHandler.ChangePassword)
+	authenticated.PUT("/users/me/password", userHandler.ChangePassword, requireSession)
 	authenticated.POST("/users/me/profile-image", userHandler.UpdateProfileImage)
-	authenticated.DELETE("/users/me", userHandler.DeleteUser)
+	authenticated.GET("/users/:id/profile-image", imageHandler.GetProfileImage)
+	authenticated.DELETE("/users/me", userHandler.DeleteUser, requireSession)
+
+	// ワークスペース関連
+	authenticated.GET("/workspaces", workspaceHandler.ListWorkspaces)
+	authenticated.POST("/workspaces", workspaceHandler.CreateWorkspace, requireEditor)
+	authenticated.GET("/workspaces/:id", workspaceHandler.GetWorkspace)
+	authenticated.PUT("/workspaces/:id", workspaceHandler.UpdateWorkspace)
+	authenticated.DELETE("/workspaces/:id", workspaceHandler.DeleteWorkspace)
+	authenticated.POST("/workspaces/:id/members", workspaceHandler.AddMember)
+	authenticated.DELETE("/workspaces/:id/members/:userId", workspaceHandler.RemoveMember)
+	authenticated.GET("/workspaces/:id/manuals", workspaceHandler.ListWorkspaceManuals)
 
 	// マニュアル関連
-	authenticated.GET("/manuals", handlers.ListManuals)
-	authenticated.POST("/manuals", handlers.CreateManual)
-	authenticated.GET("/manuals/:id", handlers.GetManual)
-	authenticated.PUT("/manuals/:id", handlers.UpdateManual)
-	authenticated.DELETE("/manuals/:id", handlers.DeleteManual)
+	authenticated.GET("/manuals", manualHandler.ListManuals)
+	authenticated.GET("/manuals/search", searchHandler.SearchManuals)
+	authenticated.POST("/manuals", manualHandler.CreateManual, requireEditor)
+	authenticated.GET("/manuals/:id", manualHandler.GetManual)
+	authenticated.PUT("/manuals/:id", manualHandler.UpdateManual, requireEditor)
+	authenticated.DELETE("/manuals/:id", manualHandler.DeleteManual, requireEditor)
+
+	// エクスポート関連
+	authenticated.GET("/manuals/:id/export/pdf", exportHandler.ExportPDF)
+	authenticated.GET("/manuals/:id/export/markdown", exportHandler.ExportMarkdown)
+	authenticated.POST("/manuals/import", exportHandler.ImportMarkdown, requireEditor)
+
+	// 改訂履歴関連
+	authenticated.GET("/manuals/:id/revisions", revisionHandler.ListRevisions)
+	authenticated.GET("/manuals/:id/revisions/diff", revisionHandler.DiffRevisions)
+	authenticated.GET("/manuals/:id/revisions/:revision", revisionHandler.GetRevision)
+	authenticated.POST("/manuals/:id/revisions/:revision/restore", revisionHandler.RestoreRevision, requireEditor)
 
 	// 手順関連
-	authenticated.GET("/manuals/:id/steps", handlers.ListSteps)
-	authenticated.POST("/manuals/:id/steps", handlers.CreateStep)
-	authenticated.PUT("/steps/:id", handlers.UpdateStep)
-	authenticated.DELETE("/steps/:id", handlers.DeleteStep)
-	authenticated.PUT("/manuals/:id/steps/order", handlers.UpdateStepsOrder)
+	authenticated.GET("/manuals/:id/steps", stepHandler.ListSteps)
+	authenticated.POST("/manuals/:id/steps", stepHandler.CreateStep, requireEditor)
+	authenticated.PUT("/steps/:id", stepHandler.UpdateStep, requireEditor)
+	authenticated.DELETE("/steps/:id", stepHandler.DeleteStep, requireEditor)
+	authenticated.PUT("/manuals/:id/steps/order", stepHandler.UpdateStepsOrder, requireEditor)
 
 	// 画像関連
-	authenticated.POST("/steps/:id/images", handlers.UploadImage)
-	authenticated.DELETE("/images/:id", handlers.DeleteImage)
-}
\ No newline at end of file
+	authenticated.POST("/steps/:id/images", stepHandler.UploadImage, requireEditor)
+	authenticated.GET("/images/:id", imageHandler.GetStepImage)
+	authenticated.DELETE("/images/:id", stepHandler.DeleteImage, requireEditor)
+
+	// 管理者用エンドポイント
+	admin := authenticated.Group("/admin", requireSession, auth.RequireRole(models.RoleAdmin))
+	admin.GET("/users", adminHandler.ListUsers)
+	admin.PUT("/users/:id/role", adminHandler.UpdateUserRole)
+	admin.POST("/users/:id/unlock", adminHandler.UnlockUser)
+	admin.POST("/storage/gc", adminHandler.RunStorageGC)
+	admin.GET("/audit-events", adminHandler.ListAuditEvents)
+	admin.GET("/audit-events/export", adminHandler.ExportAuditEvents)
+}
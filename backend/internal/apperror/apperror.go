@@ -0,0 +1,116 @@
+package apperror
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// エラー種別を表すセンチネルエラー
+// errors.Is(err, apperror.ErrNotFound) のように種別判定に使用する
+var (
+	ErrNotFound     = errors.New("not found")
+	ErrForbidden    = errors.New("forbidden")
+	ErrUnauthorized = errors.New("unauthorized")
+	ErrConflict     = errors.New("conflict")
+	ErrValidation   = errors.New("validation failed")
+	ErrRateLimited  = errors.New("too many requests")
+)
+
+// Error は種別・メッセージ・原因エラーを持つドメインエラー
+type Error struct {
+	Kind    error
+	Message string
+	Err     error
+}
+
+// Error はerrorインターフェースの実装
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("%s: %v", e.Message, e.Err)
+	}
+	return e.Message
+}
+
+// Is は種別のセンチネルエラーとの比較を可能にする
+func (e *Error) Is(target error) bool {
+	return e.Kind == target
+}
+
+// Unwrap は原因エラーを返す
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// NotFound はリソースが存在しないことを表すエラーを作成する
+func NotFound(format string, args ...interface{}) *Error {
+	return &Error{Kind: ErrNotFound, Message: fmt.Sprintf(format, args...)}
+}
+
+// Forbidden は操作権限がないことを表すエラーを作成する
+func Forbidden(format string, args ...interface{}) *Error {
+	return &Error{Kind: ErrForbidden, Message: fmt.Sprintf(format, args...)}
+}
+
+// Unauthorized は認証に失敗したことを表すエラーを作成する
+func Unauthorized(format string, args ...interface{}) *Error {
+	return &Error{Kind: ErrUnauthorized, Message: fmt.Sprintf(format, args...)}
+}
+
+// Conflict は既存データとの競合を表すエラーを作成する
+func Conflict(format string, args ...interface{}) *Error {
+	return &Error{Kind: ErrConflict, Message: fmt.Sprintf(format, args...)}
+}
+
+// Wrap は原因エラーを保持したまま種別付きエラーを作成する
+func Wrap(kind error, err error, format string, args ...interface{}) *Error {
+	return &Error{Kind: kind, Message: fmt.Sprintf(format, args...), Err: err}
+}
+
+// FieldError はフィールド単位のバリデーションエラー
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// ValidationError はフィールドの詳細を持つバリデーションエラー
+type ValidationError struct {
+	Message string
+	Fields  []FieldError
+}
+
+// Error はerrorインターフェースの実装
+func (e *ValidationError) Error() string {
+	return e.Message
+}
+
+// Is はErrValidationとの比較を可能にする
+func (e *ValidationError) Is(target error) bool {
+	return target == ErrValidation
+}
+
+// Validation はバリデーションエラーを作成する
+func Validation(message string, fields ...FieldError) *ValidationError {
+	return &ValidationError{Message: message, Fields: fields}
+}
+
+// RateLimitError は試行回数の制限により拒否されたことを表すエラー
+type RateLimitError struct {
+	Message    string
+	RetryAfter time.Duration // 再試行できるまでの時間
+}
+
+// Error はerrorインターフェースの実装
+func (e *RateLimitError) Error() string {
+	return e.Message
+}
+
+// Is はErrRateLimitedとの比較を可能にする
+func (e *RateLimitError) Is(target error) bool {
+	return target == ErrRateLimited
+}
+
+// RateLimited は試行回数の制限によるエラーを作成する
+func RateLimited(retryAfter time.Duration, format string, args ...interface{}) *RateLimitError {
+	return &RateLimitError{Message: fmt.Sprintf(format, args...), RetryAfter: retryAfter}
+}
@@ -1,84 +1,137 @@
 package auth
 
 import (
+	"errors"
 	"net/http"
-	"time"
+	"strings"
 
-	"github.com/Ryo-cool/guideforge/internal/config"
-	"github.com/golang-jwt/jwt/v4"
+	"github.com/Ryo-cool/guideforge/internal/apperror"
+	"github.com/Ryo-cool/guideforge/internal/models"
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/echo/v4/middleware"
 )
 
-// JWTClaims はJWTのクレームを表す構造体
-type JWTClaims struct {
-	UserID uint   `json:"user_id"`
-	Email  string `json:"email"`
-	jwt.StandardClaims
+// SessionChecker はアクセストークンのセッションが有効かを確認する
+type SessionChecker interface {
+	IsActive(sessionID string) (bool, error)
 }
 
-// Valid はjwt.Claimsインターフェースの実装
-func (c *JWTClaims) Valid() error {
-	return c.StandardClaims.Valid()
-}
-
-// GenerateToken はJWTトークンを生成する
-func GenerateToken(userID uint, email string, cfg *config.Config) (string, error) {
-	// トークンの有効期限を設定
-	expirationTime := time.Now().Add(cfg.JWTExpiration)
-
-	// クレームを作成
-	claims := &JWTClaims{
-		UserID: userID,
-		Email:  email,
-		StandardClaims: jwt.StandardClaims{
-			ExpiresAt: expirationTime.Unix(),
-			IssuedAt:  time.Now().Unix(),
-		},
-	}
+// APITokenPrefix は個人用APIトークンの接頭辞
+// JWTと区別するため、この接頭辞を持つトークンはAPIトークンとして検証する
+const APITokenPrefix = "gfp_"
 
-	// トークンを作成
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-
-	// 秘密鍵で署名
-	tokenString, err := token.SignedString([]byte(cfg.JWTSecret))
-	if err != nil {
-		return "", err
-	}
-
-	return tokenString, nil
+// APITokenVerifier は個人用APIトークンを検証し、クレームを返す
+type APITokenVerifier interface {
+	VerifyAPIToken(token string) (*JWTClaims, error)
 }
 
 // JWTMiddleware はJWT認証を行うミドルウェアを返す
-func JWTMiddleware(cfg *config.Config) echo.MiddlewareFunc {
+// ログアウトやパスワード変更で失効したセッションのトークンは有効期限内でも拒否する
+// 個人用APIトークンも受け付け、スコープに応じて許可するHTTPメソッドを制限する
+// 検証済みのクレーム（*JWTClaims）はコンテキストの "user" に格納される
+func JWTMiddleware(tokens *TokenService, sessions SessionChecker, apiTokens APITokenVerifier) echo.MiddlewareFunc {
 	config := middleware.JWTConfig{
-		Claims:     &JWTClaims{},
-		SigningKey: []byte(cfg.JWTSecret),
 		TokenLookup: "header:Authorization,query:token,cookie:token",
 		AuthScheme:  "Bearer",
+		// 署名・クレームの検証はTokenServiceで行い、発行時と同じクレームの形式で解析する
+		ParseTokenFunc: func(auth string, c echo.Context) (interface{}, error) {
+			if strings.HasPrefix(auth, APITokenPrefix) {
+				claims, err := apiTokens.VerifyAPIToken(auth)
+				if err != nil {
+					return nil, err
+				}
+				if err := checkScope(claims, c.Request().Method); err != nil {
+					return nil, err
+				}
+				return claims, nil
+			}
+
+			claims, err := tokens.Verify(auth)
+			if err != nil {
+				return nil, err
+			}
+			if err := checkSession(claims, sessions); err != nil {
+				return nil, err
+			}
+			return claims, nil
+		},
 		ErrorHandler: func(err error) error {
-			return echo.NewHTTPError(http.StatusUnauthorized, map[string]interface{}{
-				"success": false,
-				"error":   "Unauthorized access",
-				"message": err.Error(),
-			})
+			// スコープ不足は認証には成功しているため、403として返す
+			if errors.Is(err, apperror.ErrForbidden) {
+				return err
+			}
+			return apperror.Wrap(apperror.ErrUnauthorized, err, "Unauthorized access")
 		},
 	}
 	return middleware.JWTWithConfig(config)
 }
 
-// RequireRole は特定のロールを要求するミドルウェア（将来の拡張用）
+// checkSession はトークンのセッションが失効していないかを確認する
+// セッション導入前に発行されたトークンはセッションを持たないため拒否する
+func checkSession(claims *JWTClaims, sessions SessionChecker) error {
+	if claims.SessionID == "" {
+		return errors.New("token has no session")
+	}
+	active, err := sessions.IsActive(claims.SessionID)
+	if err != nil {
+		return err
+	}
+	if !active {
+		return errors.New("session has been revoked")
+	}
+	return nil
+}
+
+// checkScope はAPIトークンのスコープでリクエストのHTTPメソッドが許可されているかを確認する
+// 参照系のメソッドは read または write、それ以外は write を要求する
+func checkScope(claims *JWTClaims, method string) error {
+	for _, scope := range claims.Scopes {
+		if scope == models.APITokenScopeWrite {
+			return nil
+		}
+		if scope == models.APITokenScopeRead && isSafeMethod(method) {
+			return nil
+		}
+	}
+	return apperror.Forbidden("API token does not have the required scope")
+}
+
+// isSafeMethod はリソースを変更しないHTTPメソッドかを返す
+func isSafeMethod(method string) bool {
+	return method == http.MethodGet || method == http.MethodHead || method == http.MethodOptions
+}
+
+// RequireUserSession はログインセッションによる認証を要求するミドルウェア
+// セッションやパスワード、APIトークン自体の管理はAPIトークンでは行えないようにする
+// JWTMiddlewareの後に使用する
+func RequireUserSession() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			claims, err := getClaims(c)
+			if err != nil {
+				return apperror.Unauthorized("Unauthorized access")
+			}
+			if claims.APITokenID != 0 {
+				return apperror.Forbidden("this operation is not allowed with an API token")
+			}
+			return next(c)
+		}
+	}
+}
+
+// RequireRole は指定したロール以上の権限を要求するミドルウェア
+// ロールの強さは admin > editor > viewer の順で、JWTMiddlewareの後に使用する
 func RequireRole(role string) echo.MiddlewareFunc {
 	return func(next echo.HandlerFunc) echo.HandlerFunc {
 		return func(c echo.Context) error {
-			// JWTからクレームを取得
-			user := c.Get("user").(*jwt.Token)
-			claims := user.Claims.(*JWTClaims)
+			claims, err := getClaims(c)
+			if err != nil {
+				return apperror.Unauthorized("Unauthorized access")
+			}
 
-			// 現在はロールの実装はないが、将来的には以下のようにロールチェックを実装可能
-			// if claims.Role != role {
-			// 	return echo.NewHTTPError(http.StatusForbidden, "insufficient privileges")
-			// }
+			if !models.HasRole(claimsRole(claims), role) {
+				return apperror.Forbidden("insufficient privileges")
+			}
 
 			// ユーザーIDをコンテキストに格納しておく（便利のため）
 			c.Set("userID", claims.UserID)
@@ -90,7 +143,45 @@ func RequireRole(role string) echo.MiddlewareFunc {
 
 // GetUserIDFromToken はJWTトークンからユーザーIDを取得する
 func GetUserIDFromToken(c echo.Context) (uint, error) {
-	user := c.Get("user").(*jwt.Token)
-	claims := user.Claims.(*JWTClaims)
+	claims, err := getClaims(c)
+	if err != nil {
+		return 0, err
+	}
 	return claims.UserID, nil
 }
+
+// GetSessionIDFromToken はJWTトークンからセッションIDを取得する
+func GetSessionIDFromToken(c echo.Context) (string, error) {
+	claims, err := getClaims(c)
+	if err != nil {
+		return "", err
+	}
+	return claims.SessionID, nil
+}
+
+// GetRoleFromToken はJWTトークンからユーザーのロールを取得する
+func GetRoleFromToken(c echo.Context) (string, error) {
+	claims, err := getClaims(c)
+	if err != nil {
+		return "", err
+	}
+	return claimsRole(claims), nil
+}
+
+// getClaims はコンテキストに格納された検証済みのクレームを取得する
+func getClaims(c echo.Context) (*JWTClaims, error) {
+	claims, ok := c.Get("user").(*JWTClaims)
+	if !ok {
+		return nil, errors.New("missing token")
+	}
+	return claims, nil
+}
+
+// claimsRole はクレームのロールを返す
+// ロール導入前に発行されたトークンはロールを持たないため、既定のeditorとして扱う
+func claimsRole(claims *JWTClaims) string {
+	if claims.Role == "" {
+		return models.RoleEditor
+	}
+	return claims.Role
+}
@@ -0,0 +1,172 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// minRSAKeyBits はRS256の鍵として受け付ける最小の鍵長
+const minRSAKeyBits = 2048
+
+// signingKey はトークンの署名・検証に使用する鍵
+// 検証のみに使用する鍵（ローテーション前後の鍵）は private が nil になる
+type signingKey struct {
+	id      string
+	method  jwt.SigningMethod
+	private interface{}
+	public  interface{}
+}
+
+// JWK は公開鍵のJSON Web Key表現（RFC 7517）
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+// JWKS は公開鍵の一覧（/.well-known/jwks.json のレスポンス）
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// loadKeyFile はPEMファイルからRSA・Ed25519の秘密鍵または公開鍵を読み込む
+// kid は公開鍵のJWKサムプリント（RFC 7638）とし、同じ鍵であればどのサーバーでも同じ値になる
+func loadKeyFile(path string) (*signingKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read JWT key %s: %w", path, err)
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("failed to decode JWT key %s: no PEM data", path)
+	}
+
+	var parsed interface{}
+	switch block.Type {
+	case "PRIVATE KEY":
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PUBLIC KEY":
+		parsed, err = x509.ParsePKIXPublicKey(block.Bytes)
+	case "RSA PUBLIC KEY":
+		parsed, err = x509.ParsePKCS1PublicKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("unsupported JWT key %s: PEM type %q", path, block.Type)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse JWT key %s: %w", path, err)
+	}
+
+	key := &signingKey{}
+	switch k := parsed.(type) {
+	case *rsa.PrivateKey:
+		key.method, key.private, key.public = jwt.SigningMethodRS256, k, &k.PublicKey
+	case *rsa.PublicKey:
+		key.method, key.public = jwt.SigningMethodRS256, k
+	case ed25519.PrivateKey:
+		key.method, key.private, key.public = jwt.SigningMethodEdDSA, k, k.Public()
+	case ed25519.PublicKey:
+		key.method, key.public = jwt.SigningMethodEdDSA, k
+	default:
+		return nil, fmt.Errorf("unsupported JWT key %s: only RSA and Ed25519 keys are supported", path)
+	}
+
+	if pub, ok := key.public.(*rsa.PublicKey); ok && pub.N.BitLen() < minRSAKeyBits {
+		return nil, fmt.Errorf("JWT key %s is too short: RSA keys must be at least %d bits", path, minRSAKeyBits)
+	}
+
+	key.id, err = thumbprint(key.jwk())
+	if err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// hmacKey は共有シークレットによるHS256の鍵を作成する（開発環境向け）
+func hmacKey(secret string) *signingKey {
+	sum := sha256.Sum256([]byte(secret))
+	return &signingKey{
+		// 鍵そのものを推測できないよう、ハッシュの先頭のみを使用する
+		id:      "hs-" + hex.EncodeToString(sum[:8]),
+		method:  jwt.SigningMethodHS256,
+		private: []byte(secret),
+		public:  []byte(secret),
+	}
+}
+
+// jwk は公開鍵のJWKを返す（HS256の鍵は公開しないためnilを返す）
+func (k *signingKey) jwk() *JWK {
+	switch pub := k.public.(type) {
+	case *rsa.PublicKey:
+		return &JWK{
+			Kty: "RSA",
+			Kid: k.id,
+			Use: "sig",
+			Alg: k.method.Alg(),
+			N:   base64.RawURLEncoding.EncodeToString(pub.N.Bytes()),
+			E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+		}
+	case ed25519.PublicKey:
+		return &JWK{
+			Kty: "OKP",
+			Kid: k.id,
+			Use: "sig",
+			Alg: k.method.Alg(),
+			Crv: "Ed25519",
+			X:   base64.RawURLEncoding.EncodeToString(pub),
+		}
+	}
+	return nil
+}
+
+// thumbprint はJWKサムプリント（RFC 7638）を返す
+// 必須メンバーのみを辞書順に並べたJSONのSHA-256ハッシュを使用する
+func thumbprint(jwk *JWK) (string, error) {
+	if jwk == nil {
+		return "", errors.New("unsupported JWT key type")
+	}
+
+	var members interface{}
+	switch jwk.Kty {
+	case "RSA":
+		members = struct {
+			E   string `json:"e"`
+			Kty string `json:"kty"`
+			N   string `json:"n"`
+		}{jwk.E, jwk.Kty, jwk.N}
+	case "OKP":
+		members = struct {
+			Crv string `json:"crv"`
+			Kty string `json:"kty"`
+			X   string `json:"x"`
+		}{jwk.Crv, jwk.Kty, jwk.X}
+	default:
+		return "", fmt.Errorf("unsupported JWK type %q", jwk.Kty)
+	}
+
+	data, err := json.Marshal(members)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return base64.RawURLEncoding.EncodeToString(sum[:]), nil
+}
@@ -0,0 +1,203 @@
+package auth
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/Ryo-cool/guideforge/internal/config"
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// JWTClaims はアクセストークンのクレームを表す構造体
+// ユーザーIDは sub（文字列）に格納し、検証時に UserID へ変換する
+type JWTClaims struct {
+	Email     string `json:"email"`
+	Role      string `json:"role"`
+	SessionID string `json:"sid"`
+	jwt.RegisteredClaims
+
+	// UserID は sub から変換したユーザーID（トークンには含めない）
+	UserID uint `json:"-"`
+	// APITokenID と Scopes は個人用APIトークンで認証した場合のみ設定される
+	APITokenID uint     `json:"-"`
+	Scopes     []string `json:"-"`
+}
+
+// TokenService はアクセストークンの発行と検証を行う
+// トークンの発行・検証はすべてこのサービスを経由し、クレームの形式を統一する
+type TokenService struct {
+	signing    *signingKey
+	keys       map[string]*signingKey // kid → 鍵（署名鍵とローテーション前後の検証用の鍵）
+	issuer     string
+	audience   string
+	expiration time.Duration
+}
+
+// NewTokenService は設定された鍵を読み込み、新しいTokenServiceインスタンスを作成する
+// JWTSigningKey を指定した場合はその鍵（RSAはRS256、Ed25519はEdDSA）で署名し、
+// JWTVerificationKeys の鍵で署名されたトークンも受け付ける（鍵のローテーション用）
+// JWTSigningKey を指定しない場合は JWTSecret によるHS256で署名する
+func NewTokenService(cfg *config.Config) (*TokenService, error) {
+	s := &TokenService{
+		keys:       make(map[string]*signingKey),
+		issuer:     cfg.JWTIssuer,
+		audience:   cfg.JWTAudience,
+		expiration: cfg.AccessTokenExpiration,
+	}
+
+	if cfg.JWTSigningKey == "" {
+		s.signing = hmacKey(cfg.JWTSecret)
+	} else {
+		key, err := loadKeyFile(cfg.JWTSigningKey)
+		if err != nil {
+			return nil, err
+		}
+		if key.private == nil {
+			return nil, fmt.Errorf("JWT signing key %s must be a private key", cfg.JWTSigningKey)
+		}
+		s.signing = key
+	}
+	s.keys[s.signing.id] = s.signing
+
+	for _, path := range cfg.JWTVerificationKeys {
+		key, err := loadKeyFile(path)
+		if err != nil {
+			return nil, err
+		}
+		if _, exists := s.keys[key.id]; !exists {
+			s.keys[key.id] = key
+		}
+	}
+
+	return s, nil
+}
+
+// TwoFactorTokenExpiration はパスワードの確認後に二要素目を入力するまでの有効期間
+const TwoFactorTokenExpiration = 5 * time.Minute
+
+// twoFactorAudienceSuffix は二要素目の検証用トークンの aud の接尾辞
+// aud が異なるため、このトークンはAPIの認証（Verify）には使用できない
+const twoFactorAudienceSuffix = ":2fa"
+
+// Issue はセッションに紐づくアクセストークンを発行する
+func (s *TokenService) Issue(userID uint, email, role, sessionID string) (string, error) {
+	return s.issue(&JWTClaims{
+		Email:     email,
+		Role:      role,
+		SessionID: sessionID,
+	}, userID, s.audience, s.expiration)
+}
+
+// IssueTwoFactorToken はパスワードを確認済みのユーザーに、二要素目の検証用のトークンを発行する
+func (s *TokenService) IssueTwoFactorToken(userID uint) (string, error) {
+	return s.issue(&JWTClaims{}, userID, s.audience+twoFactorAudienceSuffix, TwoFactorTokenExpiration)
+}
+
+// Verify はアクセストークンの署名・有効期限・発行者・利用者を検証してクレームを返す
+func (s *TokenService) Verify(tokenString string) (*JWTClaims, error) {
+	return s.verify(tokenString, s.audience)
+}
+
+// VerifyTwoFactorToken は二要素目の検証用のトークンを検証してユーザーIDを返す
+func (s *TokenService) VerifyTwoFactorToken(tokenString string) (uint, error) {
+	claims, err := s.verify(tokenString, s.audience+twoFactorAudienceSuffix)
+	if err != nil {
+		return 0, err
+	}
+	return claims.UserID, nil
+}
+
+// issue は共通の登録済みクレームを設定してトークンに署名する
+func (s *TokenService) issue(claims *JWTClaims, userID uint, audience string, expiration time.Duration) (string, error) {
+	jti, err := newTokenID()
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	claims.RegisteredClaims = jwt.RegisteredClaims{
+		Issuer:    s.issuer,
+		Subject:   strconv.FormatUint(uint64(userID), 10),
+		Audience:  jwt.ClaimStrings{audience},
+		ExpiresAt: jwt.NewNumericDate(now.Add(expiration)),
+		NotBefore: jwt.NewNumericDate(now),
+		IssuedAt:  jwt.NewNumericDate(now),
+		ID:        jti,
+	}
+
+	token := jwt.NewWithClaims(s.signing.method, claims)
+	token.Header["kid"] = s.signing.id
+
+	return token.SignedString(s.signing.private)
+}
+
+// verify はトークンの署名・有効期限・発行者と、aud が audience と一致することを検証する
+func (s *TokenService) verify(tokenString, audience string) (*JWTClaims, error) {
+	claims := &JWTClaims{}
+	_, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		key, ok := s.keys[kid]
+		if !ok {
+			return nil, errors.New("unknown key id")
+		}
+		// 公開鍵をHMACのシークレットとして使わせないよう、鍵ごとのアルゴリズムと一致するものだけを受け付ける
+		if token.Method.Alg() != key.method.Alg() {
+			return nil, errors.New("unexpected signing method")
+		}
+		return key.public, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if !claims.VerifyIssuer(s.issuer, true) {
+		return nil, errors.New("invalid token issuer")
+	}
+	if !claims.VerifyAudience(audience, true) {
+		return nil, errors.New("invalid token audience")
+	}
+	if claims.ID == "" {
+		return nil, errors.New("token has no id")
+	}
+
+	userID, err := strconv.ParseUint(claims.Subject, 10, 64)
+	if err != nil || userID == 0 {
+		return nil, fmt.Errorf("invalid token subject %q", claims.Subject)
+	}
+	claims.UserID = uint(userID)
+
+	return claims, nil
+}
+
+// JWKS は検証に使用できる公開鍵の一覧を返す
+// 他のサービスはこの一覧でトークンを検証する（HS256の共有シークレットは含めない）
+func (s *TokenService) JWKS() JWKS {
+	jwks := JWKS{Keys: []JWK{}}
+	for _, key := range s.keys {
+		if jwk := key.jwk(); jwk != nil {
+			jwks.Keys = append(jwks.Keys, *jwk)
+		}
+	}
+	// 署名鍵を先頭にし、それ以外はkidの順に並べる
+	sort.Slice(jwks.Keys, func(i, j int) bool {
+		if (jwks.Keys[i].Kid == s.signing.id) != (jwks.Keys[j].Kid == s.signing.id) {
+			return jwks.Keys[i].Kid == s.signing.id
+		}
+		return jwks.Keys[i].Kid < jwks.Keys[j].Kid
+	})
+	return jwks
+}
+
+// newTokenID はトークンごとに一意なjtiを生成する
+func newTokenID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -4,9 +4,13 @@ import (
 	"fmt"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 )
 
+// defaultJWTSecret は開発環境向けの既定のシークレット（本番環境では使用できない）
+const defaultJWTSecret = "your_jwt_secret_key_change_in_production"
+
 // Config はアプリケーション設定を保持する構造体
 type Config struct {
 	// データベース設定
@@ -18,17 +22,94 @@ type Config struct {
 	DBSSLMode  string
 
 	// JWT設定
-	JWTSecret     string
-	JWTExpiration time.Duration
+	JWTSecret              string        // JWTSigningKey を指定しない場合のHS256の共有シークレット（開発環境向け）
+	JWTSigningKey          string        // 署名に使用するRSA・Ed25519秘密鍵（PEM）のパス
+	JWTVerificationKeys    []string      // ローテーション前後の鍵（PEM）のパス。署名には使用せず検証とJWKSの公開のみに使用する
+	JWTIssuer              string        // トークンの iss
+	JWTAudience            string        // トークンの aud（このAPI以外で発行されたトークンを受け付けない）
+	AccessTokenExpiration  time.Duration // 失効はリフレッシュ時に反映されるため短くする
+	RefreshTokenExpiration time.Duration // 最後に使用してからの有効期間
 
 	// サーバー設定
-	Port         string
-	Environment  string
-	AllowOrigins []string
+	Port              string
+	Environment       string
+	AllowOrigins      []string
+	TrustProxyHeaders bool // X-Forwarded-For からクライアントのIPアドレスを取得する（リバースプロキシの背後で動かす場合）
+
+	// ログイン試行の制限設定
+	LoginMaxFailures     int           // アカウントごとに連続してこの回数失敗するとロックする
+	LoginIPMaxFailures   int           // IPアドレスごとに連続してこの回数失敗するとロックする
+	LoginLockoutDuration time.Duration // ロックの期間（失敗回数もこの期間が経過すると数え直す）
+	AuthRateLimit        int           // 認証不要のエンドポイントへのIPアドレスごとの1分あたりのリクエスト数（0の場合は制限しない）
 
 	// ファイルアップロード設定
-	UploadDir     string
-	MaxUploadSize int64
+	StorageDriver    string // "local" または "s3"
+	StoragePublicURL string // ファイル配信URLのベース（空の場合はドライバーの既定値）
+	UploadDir        string // StorageDriver が "local" の場合の保存先
+	MaxUploadSize    int64  // アップロードする画像（縮小前）の最大サイズ
+	MaxImportSize    int64  // インポートするバンドル（zip）の最大サイズ
+
+	// 画像処理設定（いずれも長辺のピクセル数）
+	ImageMaxDimension  int // これより大きい画像は縮小して保存する
+	ImageMediumSize    int
+	ImageThumbnailSize int
+	ProfileImageSize   int
+
+	// 孤立ファイルのGC設定
+	StorageGCInterval    time.Duration // 0の場合はバックグラウンドで実行しない
+	StorageGCGracePeriod time.Duration // これより新しいファイルは参照されていなくても削除しない
+
+	// S3互換ストレージ設定（StorageDriver が "s3" の場合）
+	S3Endpoint        string // MinIOなどを使う場合に指定する
+	S3Region          string
+	S3Bucket          string
+	S3AccessKeyID     string
+	S3SecretAccessKey string
+	S3UsePathStyle    bool
+
+	// メール設定
+	MailDriver   string // "smtp" または "log"
+	MailFrom     string
+	MailLogFile  string
+	SMTPHost     string
+	SMTPPort     int
+	SMTPUsername string
+	SMTPPassword string
+
+	// シングルサインオン（OpenID Connect）設定（OIDCIssuerURL が空の場合は無効）
+	OIDCIssuerURL        string
+	OIDCClientID         string
+	OIDCClientSecret     string
+	OIDCRedirectURL      string   // IdPに登録するコールバックURL（/api/auth/oidc/callback）
+	OIDCScopes           []string // openid は常に要求する
+	OIDCAllowedDomains   []string // 空の場合はすべてのメールドメインを許可する
+	OIDCAutoProvision    bool     // 未登録のメールアドレスでログインした場合にユーザーを作成する
+	PasswordLoginEnabled bool     // false の場合はメールアドレス・パスワードでのログインと登録を受け付けない
+
+	// メールアドレス確認設定
+	EmailVerificationRequired   bool          // true の場合はメールアドレスの確認が済むまでパスワードでログインできない
+	EmailVerificationExpiration time.Duration // 確認トークンの有効期間（未確認のまま期間を過ぎたアカウントは同じアドレスで登録し直せる）
+	RegistrationAllowedDomains  []string      // 空の場合はすべてのメールドメインで登録できる（メールアドレスの変更にも適用する）
+
+	// パスワードポリシー設定
+	PasswordMinLength           int    // 文字数
+	PasswordMaxLength           int    // 文字数（bcrypt の場合は72バイトを超えるパスワードも受け付けない）
+	PasswordMinCharacterClasses int    // 英小文字・英大文字・数字・記号のうち含める必要がある種類の数（0〜4）
+	PasswordBreachedListFile    string // 使用を禁止するパスワードの一覧（1行に1つ、平文またはSHA-1の16進表記）。空の場合は確認しない
+
+	// パスワードハッシュ設定（変更するとログイン時に新しい設定でハッシュし直す）
+	PasswordHashAlgorithm string // "bcrypt" または "argon2id"
+	BcryptCost            int
+	Argon2Memory          uint32 // KiB
+	Argon2Iterations      uint32
+	Argon2Parallelism     uint8
+
+	// パスワードリセット設定
+	FrontendURL             string
+	PasswordResetExpiration time.Duration
+
+	// エクスポート設定
+	PDFFontPath string // 日本語を出力する場合はTrueTypeフォント（.ttf）を指定する
 }
 
 // Load は環境変数から設定を読み込む
@@ -38,23 +119,155 @@ func Load() (*Config, error) {
 		return nil, fmt.Errorf("invalid DB_PORT: %w", err)
 	}
 
-	jwtExpiration, err := strconv.Atoi(getEnv("JWT_EXPIRATION", "24"))
+	accessTokenExpiration, err := strconv.Atoi(getEnv("ACCESS_TOKEN_EXPIRATION", "15")) // 分
 	if err != nil {
-		return nil, fmt.Errorf("invalid JWT_EXPIRATION: %w", err)
+		return nil, fmt.Errorf("invalid ACCESS_TOKEN_EXPIRATION: %w", err)
 	}
 
-	maxUploadSize, err := strconv.ParseInt(getEnv("MAX_UPLOAD_SIZE", "5242880"), 10, 64) // デフォルト 5MB
+	refreshTokenExpiration, err := strconv.Atoi(getEnv("REFRESH_TOKEN_EXPIRATION", "720")) // 時間（デフォルト 30日）
+	if err != nil {
+		return nil, fmt.Errorf("invalid REFRESH_TOKEN_EXPIRATION: %w", err)
+	}
+
+	// スマートフォンで撮影した写真をそのまま受け付け、保存時に縮小する（デコードする画素数は imageproc で制限している）
+	maxUploadSize, err := strconv.ParseInt(getEnv("MAX_UPLOAD_SIZE", "20971520"), 10, 64) // デフォルト 20MB
 	if err != nil {
 		return nil, fmt.Errorf("invalid MAX_UPLOAD_SIZE: %w", err)
 	}
 
-	// アップロードディレクトリの作成
-	uploadDir := getEnv("UPLOAD_DIR", "./uploads")
-	if err := os.MkdirAll(uploadDir, 0755); err != nil {
-		return nil, fmt.Errorf("failed to create upload directory: %w", err)
+	maxImportSize, err := strconv.ParseInt(getEnv("MAX_IMPORT_SIZE", "52428800"), 10, 64) // デフォルト 50MB
+	if err != nil {
+		return nil, fmt.Errorf("invalid MAX_IMPORT_SIZE: %w", err)
+	}
+
+	smtpPort, err := strconv.Atoi(getEnv("SMTP_PORT", "587"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid SMTP_PORT: %w", err)
+	}
+
+	passwordResetExpiration, err := strconv.Atoi(getEnv("PASSWORD_RESET_EXPIRATION", "60")) // 分
+	if err != nil {
+		return nil, fmt.Errorf("invalid PASSWORD_RESET_EXPIRATION: %w", err)
+	}
+
+	frontendURL := getEnv("FRONTEND_URL", "http://localhost:3000")
+
+	imageMaxDimension, err := strconv.Atoi(getEnv("IMAGE_MAX_DIMENSION", "2560"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid IMAGE_MAX_DIMENSION: %w", err)
+	}
+
+	imageMediumSize, err := strconv.Atoi(getEnv("IMAGE_MEDIUM_SIZE", "1024"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid IMAGE_MEDIUM_SIZE: %w", err)
+	}
+
+	imageThumbnailSize, err := strconv.Atoi(getEnv("IMAGE_THUMBNAIL_SIZE", "256"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid IMAGE_THUMBNAIL_SIZE: %w", err)
+	}
+
+	profileImageSize, err := strconv.Atoi(getEnv("PROFILE_IMAGE_SIZE", "512"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid PROFILE_IMAGE_SIZE: %w", err)
+	}
+
+	storageGCInterval, err := strconv.Atoi(getEnv("STORAGE_GC_INTERVAL", "24")) // 時間
+	if err != nil {
+		return nil, fmt.Errorf("invalid STORAGE_GC_INTERVAL: %w", err)
+	}
+
+	storageGCGracePeriod, err := strconv.Atoi(getEnv("STORAGE_GC_GRACE_PERIOD", "24")) // 時間
+	if err != nil {
+		return nil, fmt.Errorf("invalid STORAGE_GC_GRACE_PERIOD: %w", err)
+	}
+
+	trustProxyHeaders, err := strconv.ParseBool(getEnv("TRUST_PROXY_HEADERS", "false"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid TRUST_PROXY_HEADERS: %w", err)
+	}
+
+	loginMaxFailures, err := strconv.Atoi(getEnv("LOGIN_MAX_FAILURES", "10"))
+	if err != nil || loginMaxFailures < 1 {
+		return nil, fmt.Errorf("invalid LOGIN_MAX_FAILURES: must be a positive integer")
+	}
+
+	loginIPMaxFailures, err := strconv.Atoi(getEnv("LOGIN_IP_MAX_FAILURES", "50"))
+	if err != nil || loginIPMaxFailures < 1 {
+		return nil, fmt.Errorf("invalid LOGIN_IP_MAX_FAILURES: must be a positive integer")
+	}
+
+	loginLockoutDuration, err := strconv.Atoi(getEnv("LOGIN_LOCKOUT_DURATION", "15")) // 分
+	if err != nil || loginLockoutDuration < 1 {
+		return nil, fmt.Errorf("invalid LOGIN_LOCKOUT_DURATION: must be a positive integer")
+	}
+
+	authRateLimit, err := strconv.Atoi(getEnv("AUTH_RATE_LIMIT", "30"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid AUTH_RATE_LIMIT: %w", err)
 	}
 
-	return &Config{
+	oidcAutoProvision, err := strconv.ParseBool(getEnv("OIDC_AUTO_PROVISION", "true"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid OIDC_AUTO_PROVISION: %w", err)
+	}
+
+	passwordLoginEnabled, err := strconv.ParseBool(getEnv("PASSWORD_LOGIN_ENABLED", "true"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid PASSWORD_LOGIN_ENABLED: %w", err)
+	}
+
+	emailVerificationRequired, err := strconv.ParseBool(getEnv("EMAIL_VERIFICATION_REQUIRED", "true"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid EMAIL_VERIFICATION_REQUIRED: %w", err)
+	}
+
+	emailVerificationExpiration, err := strconv.Atoi(getEnv("EMAIL_VERIFICATION_EXPIRATION", "24")) // 時間
+	if err != nil || emailVerificationExpiration < 1 {
+		return nil, fmt.Errorf("invalid EMAIL_VERIFICATION_EXPIRATION: must be a positive integer")
+	}
+
+	passwordMinLength, err := strconv.Atoi(getEnv("PASSWORD_MIN_LENGTH", "12"))
+	if err != nil || passwordMinLength < 1 {
+		return nil, fmt.Errorf("invalid PASSWORD_MIN_LENGTH: must be a positive integer")
+	}
+
+	passwordMaxLength, err := strconv.Atoi(getEnv("PASSWORD_MAX_LENGTH", "128"))
+	if err != nil || passwordMaxLength < passwordMinLength {
+		return nil, fmt.Errorf("invalid PASSWORD_MAX_LENGTH: must be an integer not less than PASSWORD_MIN_LENGTH")
+	}
+
+	passwordMinCharacterClasses, err := strconv.Atoi(getEnv("PASSWORD_MIN_CHARACTER_CLASSES", "0"))
+	if err != nil || passwordMinCharacterClasses < 0 || passwordMinCharacterClasses > 4 {
+		return nil, fmt.Errorf("invalid PASSWORD_MIN_CHARACTER_CLASSES: must be between 0 and 4")
+	}
+
+	bcryptCost, err := strconv.Atoi(getEnv("PASSWORD_BCRYPT_COST", "10"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid PASSWORD_BCRYPT_COST: %w", err)
+	}
+
+	argon2Memory, err := strconv.ParseUint(getEnv("PASSWORD_ARGON2_MEMORY", "19456"), 10, 32) // KiB（デフォルト 19MiB）
+	if err != nil {
+		return nil, fmt.Errorf("invalid PASSWORD_ARGON2_MEMORY: %w", err)
+	}
+
+	argon2Iterations, err := strconv.ParseUint(getEnv("PASSWORD_ARGON2_ITERATIONS", "2"), 10, 32)
+	if err != nil {
+		return nil, fmt.Errorf("invalid PASSWORD_ARGON2_ITERATIONS: %w", err)
+	}
+
+	argon2Parallelism, err := strconv.ParseUint(getEnv("PASSWORD_ARGON2_PARALLELISM", "1"), 10, 8)
+	if err != nil {
+		return nil, fmt.Errorf("invalid PASSWORD_ARGON2_PARALLELISM: %w", err)
+	}
+
+	s3UsePathStyle, err := strconv.ParseBool(getEnv("S3_USE_PATH_STYLE", "false"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid S3_USE_PATH_STYLE: %w", err)
+	}
+
+	cfg := &Config{
 		// データベース設定
 		DBHost:     getEnv("DB_HOST", "localhost"),
 		DBPort:     dbPort,
@@ -64,20 +277,120 @@ func Load() (*Config, error) {
 		DBSSLMode:  getEnv("DB_SSL_MODE", "disable"),
 
 		// JWT設定
-		JWTSecret:     getEnv("JWT_SECRET", "your_jwt_secret_key_change_in_production"),
-		JWTExpiration: time.Duration(jwtExpiration) * time.Hour,
+		JWTSecret:              getEnv("JWT_SECRET", defaultJWTSecret),
+		JWTSigningKey:          getEnv("JWT_SIGNING_KEY", ""),
+		JWTVerificationKeys:    splitList(getEnv("JWT_VERIFICATION_KEYS", "")),
+		JWTIssuer:              getEnv("JWT_ISSUER", "guideforge"),
+		JWTAudience:            getEnv("JWT_AUDIENCE", "guideforge-api"),
+		AccessTokenExpiration:  time.Duration(accessTokenExpiration) * time.Minute,
+		RefreshTokenExpiration: time.Duration(refreshTokenExpiration) * time.Hour,
 
 		// サーバー設定
 		Port:        getEnv("PORT", "8080"),
 		Environment: getEnv("GO_ENV", "development"),
 		AllowOrigins: []string{
-			getEnv("FRONTEND_URL", "http://localhost:3000"),
+			frontendURL,
 		},
+		TrustProxyHeaders: trustProxyHeaders,
+
+		// ログイン試行の制限設定
+		LoginMaxFailures:     loginMaxFailures,
+		LoginIPMaxFailures:   loginIPMaxFailures,
+		LoginLockoutDuration: time.Duration(loginLockoutDuration) * time.Minute,
+		AuthRateLimit:        authRateLimit,
 
 		// ファイルアップロード設定
-		UploadDir:     uploadDir,
-		MaxUploadSize: maxUploadSize,
-	}, nil
+		StorageDriver:    getEnv("STORAGE_DRIVER", "local"),
+		StoragePublicURL: getEnv("STORAGE_PUBLIC_URL", ""),
+		UploadDir:        getEnv("UPLOAD_DIR", "./uploads"),
+		MaxUploadSize:    maxUploadSize,
+		MaxImportSize:    maxImportSize,
+
+		// 画像処理設定
+		ImageMaxDimension:  imageMaxDimension,
+		ImageMediumSize:    imageMediumSize,
+		ImageThumbnailSize: imageThumbnailSize,
+		ProfileImageSize:   profileImageSize,
+
+		// 孤立ファイルのGC設定
+		StorageGCInterval:    time.Duration(storageGCInterval) * time.Hour,
+		StorageGCGracePeriod: time.Duration(storageGCGracePeriod) * time.Hour,
+
+		// S3互換ストレージ設定
+		S3Endpoint:        getEnv("S3_ENDPOINT", ""),
+		S3Region:          getEnv("S3_REGION", "us-east-1"),
+		S3Bucket:          getEnv("S3_BUCKET", ""),
+		S3AccessKeyID:     getEnv("S3_ACCESS_KEY_ID", ""),
+		S3SecretAccessKey: getEnv("S3_SECRET_ACCESS_KEY", ""),
+		S3UsePathStyle:    s3UsePathStyle,
+
+		// メール設定
+		MailDriver:   getEnv("MAIL_DRIVER", "log"),
+		MailFrom:     getEnv("MAIL_FROM", "GuideForge <no-reply@guideforge.local>"),
+		MailLogFile:  getEnv("MAIL_LOG_FILE", ""),
+		SMTPHost:     getEnv("SMTP_HOST", "localhost"),
+		SMTPPort:     smtpPort,
+		SMTPUsername: getEnv("SMTP_USERNAME", ""),
+		SMTPPassword: getEnv("SMTP_PASSWORD", ""),
+
+		// シングルサインオン設定
+		OIDCIssuerURL:        getEnv("OIDC_ISSUER_URL", ""),
+		OIDCClientID:         getEnv("OIDC_CLIENT_ID", ""),
+		OIDCClientSecret:     getEnv("OIDC_CLIENT_SECRET", ""),
+		OIDCRedirectURL:      getEnv("OIDC_REDIRECT_URL", "http://localhost:8080/api/auth/oidc/callback"),
+		OIDCScopes:           splitList(getEnv("OIDC_SCOPES", "openid,email,profile")),
+		OIDCAllowedDomains:   splitList(getEnv("OIDC_ALLOWED_DOMAINS", "")),
+		OIDCAutoProvision:    oidcAutoProvision,
+		PasswordLoginEnabled: passwordLoginEnabled,
+
+		// メールアドレス確認設定
+		EmailVerificationRequired:   emailVerificationRequired,
+		EmailVerificationExpiration: time.Duration(emailVerificationExpiration) * time.Hour,
+		RegistrationAllowedDomains:  splitList(getEnv("REGISTRATION_ALLOWED_DOMAINS", "")),
+
+		// パスワードポリシー設定
+		PasswordMinLength:           passwordMinLength,
+		PasswordMaxLength:           passwordMaxLength,
+		PasswordMinCharacterClasses: passwordMinCharacterClasses,
+		PasswordBreachedListFile:    getEnv("PASSWORD_BREACHED_LIST_FILE", ""),
+
+		// パスワードハッシュ設定
+		PasswordHashAlgorithm: getEnv("PASSWORD_HASH_ALGORITHM", "bcrypt"),
+		BcryptCost:            bcryptCost,
+		Argon2Memory:          uint32(argon2Memory),
+		Argon2Iterations:      uint32(argon2Iterations),
+		Argon2Parallelism:     uint8(argon2Parallelism),
+
+		// パスワードリセット設定
+		FrontendURL:             frontendURL,
+		PasswordResetExpiration: time.Duration(passwordResetExpiration) * time.Minute,
+
+		// エクスポート設定
+		PDFFontPath: getEnv("PDF_FONT_PATH", ""),
+	}
+
+	// 本番環境では既定のシークレットで署名したトークンを受け付けない
+	if cfg.Environment == "production" && cfg.JWTSigningKey == "" && (cfg.JWTSecret == defaultJWTSecret || cfg.JWTSecret == "") {
+		return nil, fmt.Errorf("JWT_SIGNING_KEY or a non-default JWT_SECRET must be set in production")
+	}
+
+	// シングルサインオンを必須にする場合はIdPの設定が必要
+	if !cfg.PasswordLoginEnabled && cfg.OIDCIssuerURL == "" {
+		return nil, fmt.Errorf("OIDC_ISSUER_URL must be set when PASSWORD_LOGIN_ENABLED is false")
+	}
+
+	return cfg, nil
+}
+
+// splitList はカンマ区切りの値を空要素を除いて分割する
+func splitList(value string) []string {
+	var list []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
 }
 
 // getEnv は環境変数を取得し、存在しない場合はデフォルト値を返す
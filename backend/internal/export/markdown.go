@@ -0,0 +1,377 @@
+package export
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/Ryo-cool/guideforge/internal/apperror"
+	"github.com/Ryo-cool/guideforge/internal/models"
+	"github.com/Ryo-cool/guideforge/internal/storage"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// markdownFileName はバンドル内のMarkdownファイル名
+	markdownFileName = "manual.md"
+	// imagesDir はバンドル内の画像フォルダ名
+	imagesDir = "images"
+	// maxMarkdownSize はバンドル内のMarkdownファイルの最大サイズ
+	maxMarkdownSize = 2 << 20
+	// maxBundleEntries はバンドルに含められるファイル数の上限
+	maxBundleEntries = 1000
+)
+
+var (
+	// stepNumberPrefix は手順見出しの番号（例: "1. "）
+	stepNumberPrefix = regexp.MustCompile(`^\d+[.)]\s+`)
+	// imageLine は画像のみの行（例: "![name.png](images/01-1-name.png)"）
+	imageLine = regexp.MustCompile(`^!\[([^\]]*)\]\(<?([^)>]+?)>?\)$`)
+	// unsafeFileNameChars はバンドル内のファイル名に使用しない文字
+	unsafeFileNameChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+)
+
+// frontMatter はMarkdownファイル先頭のYAMLフロントマター
+type frontMatter struct {
+	Title       string     `yaml:"title"`
+	Description string     `yaml:"description,omitempty"`
+	Category    string     `yaml:"category,omitempty"`
+	IsPublic    bool       `yaml:"is_public"`
+	ExportedAt  *time.Time `yaml:"exported_at,omitempty"`
+}
+
+// Bundle はMarkdownバンドルから読み込んだマニュアル
+type Bundle struct {
+	Title       string
+	Description string
+	Category    string
+	IsPublic    bool
+	Steps       []BundleStep
+}
+
+// BundleStep はバンドル内の手順
+type BundleStep struct {
+	Title   string
+	Content string
+	Images  []BundleImage
+}
+
+// BundleImage はバンドル内の手順画像
+type BundleImage struct {
+	FileName string
+	MimeType string
+	Data     []byte
+
+	ref string // Markdown内の参照先パス
+}
+
+// BundleLimits はバンドル読み込み時の制限
+type BundleLimits struct {
+	// MaxImageSize は画像1枚あたりの最大サイズ
+	MaxImageSize int64
+	// MaxTotalSize は展開後の合計サイズの上限
+	MaxTotalSize int64
+}
+
+// WriteMarkdownBundle はマニュアルをフロントマター付きMarkdownと画像フォルダを含むzipとして書き出す
+// 読み込めない画像はバンドルに含めない
+func WriteMarkdownBundle(w io.Writer, manual *models.Manual, store storage.Storage) error {
+	zw := zip.NewWriter(w)
+
+	var md bytes.Buffer
+	now := time.Now().UTC().Truncate(time.Second)
+	meta, err := yaml.Marshal(frontMatter{
+		Title:       manual.Title,
+		Description: manual.Description,
+		Category:    manual.Category,
+		IsPublic:    manual.IsPublic,
+		ExportedAt:  &now,
+	})
+	if err != nil {
+		return err
+	}
+	md.WriteString("---\n")
+	md.Write(meta)
+	md.WriteString("---\n\n")
+	md.WriteString("# " + singleLine(manual.Title) + "\n")
+	if manual.Description != "" {
+		md.WriteString("\n" + manual.Description + "\n")
+	}
+
+	for i, step := range manual.Steps {
+		fmt.Fprintf(&md, "\n## %d. %s\n", i+1, singleLine(step.Title))
+		if content := strings.TrimSpace(step.Content); content != "" {
+			md.WriteString("\n" + content + "\n")
+		}
+
+		for j, img := range step.Images {
+			data, err := storage.ReadAll(store, img.FilePath)
+			if err != nil {
+				continue
+			}
+
+			name := path.Join(imagesDir, bundleImageName(i+1, j+1, img.FileName))
+			f, err := zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Store, Modified: now})
+			if err != nil {
+				return err
+			}
+			if _, err := f.Write(data); err != nil {
+				return err
+			}
+
+			alt := strings.NewReplacer("[", "", "]", "").Replace(singleLine(img.FileName))
+			fmt.Fprintf(&md, "\n![%s](%s)\n", alt, name)
+		}
+	}
+
+	f, err := zw.CreateHeader(&zip.FileHeader{Name: markdownFileName, Method: zip.Deflate, Modified: now})
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(md.Bytes()); err != nil {
+		return err
+	}
+
+	return zw.Close()
+}
+
+// ReadMarkdownBundle はzip形式のMarkdownバンドルを読み込む
+// 手順は "## " 見出しで区切り、画像のみの行はバンドル内の画像として手順に添付する
+func ReadMarkdownBundle(r io.ReaderAt, size int64, limits BundleLimits) (*Bundle, error) {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, invalidBundle("file is not a valid zip archive")
+	}
+	if len(zr.File) > maxBundleEntries {
+		return nil, invalidBundle("too many files in bundle")
+	}
+
+	// Markdownファイルを探す（manual.md を優先し、なければ唯一の .md ファイル）
+	files := make(map[string]*zip.File, len(zr.File))
+	var markdownFiles []*zip.File
+	for _, f := range zr.File {
+		name := path.Clean(strings.ReplaceAll(f.Name, `\`, "/"))
+		files[name] = f
+		if strings.EqualFold(path.Ext(name), ".md") {
+			markdownFiles = append(markdownFiles, f)
+		}
+	}
+	markdown := files[markdownFileName]
+	if markdown == nil {
+		if len(markdownFiles) != 1 {
+			return nil, invalidBundle("bundle must contain " + markdownFileName)
+		}
+		markdown = markdownFiles[0]
+	}
+	baseDir := path.Dir(path.Clean(strings.ReplaceAll(markdown.Name, `\`, "/")))
+
+	var total int64
+	source, err := readZipFile(markdown, maxMarkdownSize)
+	if err != nil {
+		return nil, err
+	}
+	total += int64(len(source))
+
+	bundle, err := parseMarkdown(string(source))
+	if err != nil {
+		return nil, err
+	}
+
+	// 画像の読み込み
+	for i := range bundle.Steps {
+		for j := range bundle.Steps[i].Images {
+			img := &bundle.Steps[i].Images[j]
+			f := files[path.Clean(path.Join(baseDir, img.ref))]
+			if f == nil {
+				return nil, invalidBundle(fmt.Sprintf("image not found in bundle: %s", img.ref))
+			}
+
+			data, err := readZipFile(f, limits.MaxImageSize)
+			if err != nil {
+				return nil, err
+			}
+			total += int64(len(data))
+			if limits.MaxTotalSize > 0 && total > limits.MaxTotalSize {
+				return nil, invalidBundle("bundle is too large")
+			}
+
+			mimeType := http.DetectContentType(data)
+			if !strings.HasPrefix(mimeType, "image/") {
+				return nil, invalidBundle(fmt.Sprintf("file is not an image: %s", img.ref))
+			}
+
+			img.Data = data
+			img.MimeType = mimeType
+		}
+	}
+
+	return bundle, nil
+}
+
+// parseMarkdown はフロントマター付きMarkdownを解析する（画像データは読み込まない）
+func parseMarkdown(source string) (*Bundle, error) {
+	source = strings.TrimPrefix(strings.ReplaceAll(source, "\r\n", "\n"), "\ufeff")
+
+	var meta frontMatter
+	if strings.HasPrefix(source, "---\n") {
+		end := strings.Index(source[4:], "\n---")
+		if end < 0 {
+			return nil, invalidBundle("front matter is not terminated")
+		}
+		if err := yaml.Unmarshal([]byte(source[4:4+end]), &meta); err != nil {
+			return nil, invalidBundle("front matter is not valid YAML")
+		}
+		source = source[4+end+4:]
+		if i := strings.IndexByte(source, '\n'); i >= 0 {
+			source = source[i+1:]
+		} else {
+			source = ""
+		}
+	}
+
+	bundle := &Bundle{
+		Title:       strings.TrimSpace(meta.Title),
+		Description: meta.Description,
+		Category:    meta.Category,
+		IsPublic:    meta.IsPublic,
+	}
+
+	var (
+		current *BundleStep
+		content []string
+		inFence bool
+	)
+	flush := func() {
+		if current != nil {
+			current.Content = strings.TrimSpace(strings.Join(content, "\n"))
+			bundle.Steps = append(bundle.Steps, *current)
+		}
+		content = nil
+	}
+
+	scanner := bufio.NewScanner(strings.NewReader(source))
+	scanner.Buffer(make([]byte, 64*1024), maxMarkdownSize)
+	for scanner.Scan() {
+		line := scanner.Text()
+		trimmed := strings.TrimSpace(line)
+
+		// コードブロック内の見出しや画像は解釈しない
+		if strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~") {
+			inFence = !inFence
+		}
+
+		if !inFence {
+			switch {
+			case strings.HasPrefix(line, "## "):
+				flush()
+				title := stepNumberPrefix.ReplaceAllString(strings.TrimSpace(line[3:]), "")
+				current = &BundleStep{Title: title}
+				continue
+			case current == nil && strings.HasPrefix(line, "# "):
+				if bundle.Title == "" {
+					bundle.Title = strings.TrimSpace(line[2:])
+				}
+				continue
+			case current != nil:
+				if m := imageLine.FindStringSubmatch(trimmed); m != nil && isLocalReference(m[2]) {
+					name := strings.TrimSpace(m[1])
+					if name == "" {
+						name = path.Base(m[2])
+					}
+					current.Images = append(current.Images, BundleImage{FileName: name, ref: m[2]})
+					continue
+				}
+			}
+		}
+
+		if current != nil {
+			content = append(content, line)
+		} else if meta.Description == "" && trimmed != "" {
+			// フロントマターに説明がない場合は最初の見出しより前の本文を説明とする
+			if bundle.Description != "" {
+				bundle.Description += "\n"
+			}
+			bundle.Description += trimmed
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, invalidBundle("failed to read " + markdownFileName)
+	}
+	flush()
+
+	return bundle, validateBundle(bundle)
+}
+
+// validateBundle はバンドルの内容がマニュアル・手順の入力制限を満たすか検証する
+func validateBundle(bundle *Bundle) error {
+	var fields []apperror.FieldError
+	if n := len([]rune(bundle.Title)); n < 3 || n > 255 {
+		fields = append(fields, apperror.FieldError{Field: "title", Message: "must be between 3 and 255 characters"})
+	}
+	for i, step := range bundle.Steps {
+		if n := len([]rune(step.Title)); n < 3 || n > 255 {
+			fields = append(fields, apperror.FieldError{
+				Field:   fmt.Sprintf("steps[%d].title", i),
+				Message: "must be between 3 and 255 characters",
+			})
+		}
+	}
+
+	if len(fields) > 0 {
+		return apperror.Validation("Invalid bundle", fields...)
+	}
+	return nil
+}
+
+// readZipFile はzip内のファイルをサイズ上限付きで読み込む
+func readZipFile(f *zip.File, limit int64) ([]byte, error) {
+	rc, err := f.Open()
+	if err != nil {
+		return nil, invalidBundle(fmt.Sprintf("failed to read %s", f.Name))
+	}
+	defer rc.Close()
+
+	// 展開後のサイズはヘッダーの値を信用せず実際に読み込んだ量で判定する
+	data, err := io.ReadAll(io.LimitReader(rc, limit+1))
+	if err != nil {
+		return nil, invalidBundle(fmt.Sprintf("failed to read %s", f.Name))
+	}
+	if int64(len(data)) > limit {
+		return nil, invalidBundle(fmt.Sprintf("file is too large: %s", f.Name))
+	}
+	return data, nil
+}
+
+// isLocalReference は画像の参照先がバンドル内のファイルかを返す
+func isLocalReference(ref string) bool {
+	return !strings.Contains(ref, "://") && !strings.HasPrefix(ref, "/") && !strings.HasPrefix(ref, "data:")
+}
+
+// bundleImageName はバンドル内の画像ファイル名を作成する（手順番号と画像番号で一意にする）
+func bundleImageName(stepNumber, imageNumber int, fileName string) string {
+	ext := strings.ToLower(path.Ext(fileName))
+	base := strings.TrimSuffix(path.Base(strings.ReplaceAll(fileName, `\`, "/")), path.Ext(fileName))
+	base = strings.Trim(unsafeFileNameChars.ReplaceAllString(base, "_"), "_.")
+	if base == "" {
+		base = "image"
+	}
+	ext = unsafeFileNameChars.ReplaceAllString(ext, "")
+	return fmt.Sprintf("%02d-%d-%s%s", stepNumber, imageNumber, base, ext)
+}
+
+// singleLine は改行を空白に置き換えて1行にする
+func singleLine(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// invalidBundle はバンドルの形式が不正な場合のエラーを返す
+func invalidBundle(message string) error {
+	return apperror.Validation("Invalid bundle: " + message)
+}
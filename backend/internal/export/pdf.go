@@ -0,0 +1,353 @@
+package export
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	_ "image/gif"  // GIF画像のデコード
+	_ "image/jpeg" // JPEG画像のデコード
+	"image/png"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Ryo-cool/guideforge/internal/models"
+	"github.com/Ryo-cool/guideforge/internal/storage"
+	"github.com/jung-kurt/gofpdf"
+)
+
+const (
+	// utf8FontFamily はPDFOptions.FontPathのフォントに付けるファミリー名
+	utf8FontFamily = "body"
+	// coreFontFamily はフォント未指定時に使用する標準フォント
+	coreFontFamily = "Helvetica"
+	// lineHeight は本文の行の高さ（mm）
+	lineHeight = 6
+	// maxImageHeight は手順画像の最大の高さ（mm）
+	maxImageHeight = 110
+)
+
+// PDFOptions はPDF出力のオプション
+type PDFOptions struct {
+	// FontPath はTrueTypeフォントのパス（空の場合は標準フォントを使用し、Latin-1以外の文字は出力できない）
+	FontPath string
+	// Storage は手順画像の保存先
+	Storage storage.Storage
+}
+
+// pdfImage はPDFに埋め込む画像
+type pdfImage struct {
+	data      []byte
+	imageType string
+}
+
+// pdfRenderer はマニュアルをPDFに描画する
+type pdfRenderer struct {
+	manual *models.Manual
+	opts   PDFOptions
+	images map[uint]*pdfImage
+	font   []byte
+
+	pdf       *gofpdf.Fpdf
+	family    string
+	translate func(string) string
+}
+
+// WritePDF はマニュアルを表紙・目次・番号付き手順・手順画像を含むPDFとして書き出す
+func WritePDF(w io.Writer, manual *models.Manual, opts PDFOptions) error {
+	r := &pdfRenderer{
+		manual: manual,
+		opts:   opts,
+		images: loadImages(manual, opts.Storage),
+	}
+
+	if opts.FontPath != "" {
+		font, err := os.ReadFile(opts.FontPath)
+		if err != nil {
+			return fmt.Errorf("failed to load PDF font: %w", err)
+		}
+		r.font = font
+	}
+
+	// 目次のページ番号は描画後に確定するため、1回目で各手順の開始ページを求めて2回目で出力する
+	stepPages, err := r.render(nil)
+	if err != nil {
+		return err
+	}
+	if _, err := r.render(stepPages); err != nil {
+		return err
+	}
+
+	return r.pdf.Output(w)
+}
+
+// render はPDFを描画し、各手順の開始ページを返す
+func (r *pdfRenderer) render(stepPages []int) ([]int, error) {
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	r.pdf = pdf
+	if r.font != nil {
+		pdf.AddUTF8FontFromBytes(utf8FontFamily, "", r.font)
+		pdf.AddUTF8FontFromBytes(utf8FontFamily, "B", r.font)
+		r.family = utf8FontFamily
+		r.translate = func(s string) string { return s }
+	} else {
+		// 標準フォントはcp1252のみ対応のため変換する
+		r.family = coreFontFamily
+		r.translate = pdf.UnicodeTranslatorFromDescriptor("")
+	}
+	if err := pdf.Error(); err != nil {
+		return nil, fmt.Errorf("failed to load PDF font: %w", err)
+	}
+
+	pdf.SetTitle(r.manual.Title, true)
+	pdf.SetCreator("GuideForge", true)
+	pdf.SetMargins(20, 20, 20)
+	pdf.SetAutoPageBreak(true, 20)
+	pdf.AliasNbPages("")
+	pdf.SetHeaderFunc(r.header)
+	pdf.SetFooterFunc(r.footer)
+
+	links := make([]int, len(r.manual.Steps))
+	for i := range links {
+		links[i] = pdf.AddLink()
+	}
+
+	r.cover()
+	r.tableOfContents(links, stepPages)
+
+	pages := make([]int, len(r.manual.Steps))
+	for i := range r.manual.Steps {
+		pages[i] = r.step(i, links[i])
+	}
+
+	if err := pdf.Error(); err != nil {
+		return nil, fmt.Errorf("failed to render PDF: %w", err)
+	}
+
+	return pages, nil
+}
+
+// header は表紙以外のページにマニュアル名を出力する
+func (r *pdfRenderer) header() {
+	if r.pdf.PageNo() == 1 {
+		return
+	}
+
+	r.setFont("", 9)
+	r.pdf.SetTextColor(120, 120, 120)
+	r.pdf.CellFormat(0, 5, r.fit(r.manual.Title, r.contentWidth()), "B", 1, "L", false, 0, "")
+	r.pdf.SetTextColor(0, 0, 0)
+	r.pdf.Ln(4)
+}
+
+// footer はページ番号を出力する
+func (r *pdfRenderer) footer() {
+	r.pdf.SetY(-15)
+	r.setFont("", 9)
+	r.pdf.SetTextColor(120, 120, 120)
+	r.pdf.CellFormat(0, 10, fmt.Sprintf("%d / {nb}", r.pdf.PageNo()), "", 0, "C", false, 0, "")
+	r.pdf.SetTextColor(0, 0, 0)
+}
+
+// cover は表紙を出力する
+func (r *pdfRenderer) cover() {
+	pdf := r.pdf
+	pdf.AddPage()
+	pdf.SetY(80)
+
+	r.setFont("B", 24)
+	pdf.MultiCell(0, 12, r.translate(r.manual.Title), "", "C", false)
+	pdf.Ln(4)
+
+	if r.manual.Category != "" {
+		r.setFont("", 12)
+		pdf.MultiCell(0, lineHeight, r.translate(r.manual.Category), "", "C", false)
+		pdf.Ln(4)
+	}
+
+	if r.manual.Description != "" {
+		r.setFont("", 11)
+		pdf.MultiCell(0, lineHeight, r.translate(r.manual.Description), "", "C", false)
+	}
+
+	pdf.SetY(-60)
+	r.setFont("", 10)
+	meta := []string{
+		fmt.Sprintf("Steps: %d", len(r.manual.Steps)),
+		"Last updated: " + r.manual.UpdatedAt.Format("2006-01-02"),
+		"Exported: " + time.Now().Format("2006-01-02 15:04"),
+	}
+	for _, line := range meta {
+		pdf.CellFormat(0, lineHeight, line, "", 1, "C", false, 0, "")
+	}
+}
+
+// tableOfContents は目次を出力する（stepPagesがnilの場合はページ番号を空欄にする）
+func (r *pdfRenderer) tableOfContents(links, stepPages []int) {
+	pdf := r.pdf
+	pdf.AddPage()
+
+	r.setFont("B", 16)
+	pdf.CellFormat(0, 10, "Contents", "", 1, "L", false, 0, "")
+	pdf.Ln(4)
+
+	if len(r.manual.Steps) == 0 {
+		r.setFont("", 11)
+		pdf.CellFormat(0, lineHeight, "This manual has no steps.", "", 1, "L", false, 0, "")
+		return
+	}
+
+	r.setFont("", 11)
+	pageWidth := 15.0
+	titleWidth := r.contentWidth() - pageWidth
+	for i, step := range r.manual.Steps {
+		page := ""
+		if stepPages != nil {
+			page = strconv.Itoa(stepPages[i])
+		}
+		label := fmt.Sprintf("%d. ", i+1) + step.Title
+		pdf.CellFormat(titleWidth, lineHeight+1, r.fit(label, titleWidth), "", 0, "L", false, links[i], "")
+		pdf.CellFormat(pageWidth, lineHeight+1, page, "", 1, "R", false, links[i], "")
+	}
+}
+
+// step は手順を出力し、開始ページを返す
+func (r *pdfRenderer) step(index, link int) int {
+	pdf := r.pdf
+	step := r.manual.Steps[index]
+
+	// 各手順は新しいページから開始する
+	pdf.AddPage()
+	pdf.SetLink(link, -1, pdf.PageNo())
+	page := pdf.PageNo()
+
+	r.setFont("B", 14)
+	pdf.SetFillColor(240, 240, 240)
+	pdf.MultiCell(0, 9, r.translate(fmt.Sprintf("Step %d  ", index+1)+step.Title), "", "L", true)
+	pdf.Ln(4)
+
+	if step.Content != "" {
+		r.setFont("", 11)
+		pdf.MultiCell(0, lineHeight, r.translate(step.Content), "", "L", false)
+		pdf.Ln(4)
+	}
+
+	for _, img := range step.Images {
+		r.image(img)
+	}
+
+	return page
+}
+
+// image は手順画像をページ幅と最大の高さに収まるように出力する
+func (r *pdfRenderer) image(img models.Image) {
+	pdf := r.pdf
+	data, ok := r.images[img.ID]
+	if !ok {
+		r.setFont("", 9)
+		pdf.SetTextColor(150, 0, 0)
+		pdf.MultiCell(0, lineHeight, r.translate("[Image not available: "+img.FileName+"]"), "", "L", false)
+		pdf.SetTextColor(0, 0, 0)
+		pdf.Ln(2)
+		return
+	}
+
+	name := "image_" + strconv.FormatUint(uint64(img.ID), 10)
+	info := pdf.RegisterImageOptionsReader(name, gofpdf.ImageOptions{ImageType: data.imageType}, bytes.NewReader(data.data))
+	if info == nil {
+		return
+	}
+
+	width, height := info.Extent()
+	maxWidth := r.contentWidth()
+	if width > maxWidth {
+		height = height * maxWidth / width
+		width = maxWidth
+	}
+	if height > maxImageHeight {
+		width = width * maxImageHeight / height
+		height = maxImageHeight
+	}
+
+	// 残りの高さに収まらない場合は改ページする
+	_, pageHeight := pdf.GetPageSize()
+	_, _, _, bottom := pdf.GetMargins()
+	if pdf.GetY()+height > pageHeight-bottom {
+		pdf.AddPage()
+	}
+
+	left, _, _, _ := pdf.GetMargins()
+	x := left + (maxWidth-width)/2
+	pdf.ImageOptions(name, x, pdf.GetY(), width, height, true, gofpdf.ImageOptions{ImageType: data.imageType}, 0, "")
+	pdf.Ln(4)
+}
+
+// setFont は本文のフォントを設定する
+func (r *pdfRenderer) setFont(style string, size float64) {
+	r.pdf.SetFont(r.family, style, size)
+}
+
+// contentWidth は余白を除いたページ幅を返す
+func (r *pdfRenderer) contentWidth() float64 {
+	pageWidth, _ := r.pdf.GetPageSize()
+	left, _, right, _ := r.pdf.GetMargins()
+	return pageWidth - left - right
+}
+
+// fit は1行に収まるように文字列を切り詰める
+func (r *pdfRenderer) fit(s string, width float64) string {
+	s = strings.Join(strings.Fields(s), " ")
+	if r.pdf.GetStringWidth(r.translate(s)) <= width {
+		return r.translate(s)
+	}
+
+	// 標準フォントでは変換後の文字列がUTF-8ではないため、変換前の文字列を切り詰める
+	const ellipsis = "..."
+	runes := []rune(s)
+	for len(runes) > 0 && r.pdf.GetStringWidth(r.translate(string(runes)+ellipsis)) > width {
+		runes = runes[:len(runes)-1]
+	}
+	return r.translate(string(runes) + ellipsis)
+}
+
+// loadImages は手順画像を読み込み、PDFに埋め込める形式に変換する
+// 読み込めない画像は含めず、PDF上では代替テキストを表示する
+func loadImages(manual *models.Manual, store storage.Storage) map[uint]*pdfImage {
+	images := make(map[uint]*pdfImage)
+	for _, step := range manual.Steps {
+		for _, img := range step.Images {
+			data, err := storage.ReadAll(store, img.FilePath)
+			if err != nil {
+				continue
+			}
+			if converted := convertImage(data); converted != nil {
+				images[img.ID] = converted
+			}
+		}
+	}
+	return images
+}
+
+// convertImage は画像をPDFに埋め込める形式に変換する
+// JPEGはそのまま埋め込み、それ以外（PNG/GIF）はインターレースなしのPNGに再エンコードする
+func convertImage(data []byte) *pdfImage {
+	_, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil
+	}
+	if format == "jpeg" {
+		return &pdfImage{data: data, imageType: "JPG"}
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil
+	}
+	return &pdfImage{data: buf.Bytes(), imageType: "PNG"}
+}
@@ -0,0 +1,133 @@
+package imageproc
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/gif" // GIF画像のデコード
+	"image/jpeg"
+	"image/png"
+	"net/http"
+
+	"github.com/disintegration/imaging"
+	_ "golang.org/x/image/webp" // WebP画像のデコード
+)
+
+const (
+	// maxPixels はデコードを許可する最大の画素数（展開後のメモリ使用量を抑えるため）
+	maxPixels = 50_000_000
+	// jpegQuality は再エンコード時のJPEG品質
+	jpegQuality = 85
+)
+
+var (
+	// ErrUnsupportedFormat は対応していない画像形式の場合のエラー
+	ErrUnsupportedFormat = errors.New("unsupported image format")
+	// ErrInvalidImage は画像として読み込めない場合のエラー
+	ErrInvalidImage = errors.New("invalid image")
+)
+
+// supportedTypes は受け付ける画像形式（ファイル先頭のマジックバイトから判定したMIMEタイプ）
+var supportedTypes = map[string]bool{
+	"image/jpeg": true,
+	"image/png":  true,
+	"image/gif":  true,
+	"image/webp": true,
+}
+
+// Source はデコード済みの画像
+type Source struct {
+	image    image.Image
+	mimeType string
+}
+
+// Variant はエンコード済みの画像
+type Variant struct {
+	Data     []byte
+	MimeType string
+	Ext      string
+	Width    int
+	Height   int
+}
+
+// DetectType はファイル先頭のマジックバイトから画像のMIMEタイプを判定する
+// 対応していない形式の場合は ErrUnsupportedFormat を返す
+func DetectType(data []byte) (string, error) {
+	mimeType := http.DetectContentType(data)
+	if !supportedTypes[mimeType] {
+		return "", ErrUnsupportedFormat
+	}
+	return mimeType, nil
+}
+
+// Decode は画像をデコードする
+// クライアントが申告したMIMEタイプではなくマジックバイトで形式を判定し、EXIFの向き情報を画素に反映する
+// デコードした画素のみを扱うため、再エンコードした画像にはEXIF（位置情報など）のメタデータは残らない
+func Decode(data []byte) (*Source, error) {
+	mimeType, err := DetectType(data)
+	if err != nil {
+		return nil, err
+	}
+
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidImage, err)
+	}
+	if config.Width <= 0 || config.Height <= 0 || config.Width*config.Height > maxPixels {
+		return nil, fmt.Errorf("%w: image dimensions %dx%d are not allowed", ErrInvalidImage, config.Width, config.Height)
+	}
+
+	img, err := imaging.Decode(bytes.NewReader(data), imaging.AutoOrientation(true))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidImage, err)
+	}
+
+	return &Source{image: img, mimeType: mimeType}, nil
+}
+
+// Width は画像の幅を返す
+func (s *Source) Width() int {
+	return s.image.Bounds().Dx()
+}
+
+// Height は画像の高さを返す
+func (s *Source) Height() int {
+	return s.image.Bounds().Dy()
+}
+
+// Fits は画像の長辺がsize以下かを返す
+func (s *Source) Fits(size int) bool {
+	return s.Width() <= size && s.Height() <= size
+}
+
+// Encode は長辺がmaxSize以下になるよう縮小してエンコードする（拡大はしない、maxSizeが0以下の場合は縮小しない）
+// JPEGはJPEGのまま、それ以外の形式は透過を保つためPNGとして出力する
+func (s *Source) Encode(maxSize int) (*Variant, error) {
+	img := s.image
+	if maxSize > 0 && !s.Fits(maxSize) {
+		img = imaging.Fit(img, maxSize, maxSize, imaging.Lanczos)
+	}
+
+	var buf bytes.Buffer
+	variant := &Variant{
+		Width:  img.Bounds().Dx(),
+		Height: img.Bounds().Dy(),
+	}
+	if s.mimeType == "image/jpeg" {
+		if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: jpegQuality}); err != nil {
+			return nil, err
+		}
+		variant.MimeType = "image/jpeg"
+		variant.Ext = ".jpg"
+	} else {
+		if err := png.Encode(&buf, img); err != nil {
+			return nil, err
+		}
+		variant.MimeType = "image/png"
+		variant.Ext = ".png"
+	}
+	variant.Data = buf.Bytes()
+
+	return variant, nil
+}
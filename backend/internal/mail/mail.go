@@ -0,0 +1,124 @@
+package mail
+
+import (
+	"fmt"
+	"log"
+	"mime"
+	netmail "net/mail"
+	"net/smtp"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Ryo-cool/guideforge/internal/config"
+)
+
+// Sender はメール送信を抽象化するインターフェース
+type Sender interface {
+	Send(to, subject, body string) error
+}
+
+// NewSender は設定に応じたSenderを作成する
+func NewSender(cfg *config.Config) (Sender, error) {
+	switch cfg.MailDriver {
+	case "smtp":
+		return NewSMTPSender(cfg.SMTPHost, cfg.SMTPPort, cfg.SMTPUsername, cfg.SMTPPassword, cfg.MailFrom), nil
+	case "log", "":
+		return NewLogSender(cfg.MailLogFile), nil
+	default:
+		return nil, fmt.Errorf("unknown mail driver: %s", cfg.MailDriver)
+	}
+}
+
+// SMTPSender はSMTPサーバー経由でメールを送信する
+type SMTPSender struct {
+	addr string
+	auth smtp.Auth
+	from string
+}
+
+// NewSMTPSender は新しいSMTPSenderインスタンスを作成
+func NewSMTPSender(host string, port int, username, password, from string) *SMTPSender {
+	var auth smtp.Auth
+	if username != "" {
+		auth = smtp.PlainAuth("", username, password, host)
+	}
+
+	return &SMTPSender{
+		addr: fmt.Sprintf("%s:%d", host, port),
+		auth: auth,
+		from: from,
+	}
+}
+
+// Send はメールを送信する
+func (s *SMTPSender) Send(to, subject, body string) error {
+	// エンベロープの送信元は表示名を除いたアドレスのみ
+	envelopeFrom := s.from
+	if addr, err := netmail.ParseAddress(s.from); err == nil {
+		envelopeFrom = addr.Address
+	}
+
+	msg := buildMessage(s.from, to, subject, body)
+	if err := smtp.SendMail(s.addr, s.auth, envelopeFrom, []string{to}, msg); err != nil {
+		return fmt.Errorf("failed to send mail: %w", err)
+	}
+	return nil
+}
+
+// LogSender はメールを送信せずにファイルまたは標準ログへ書き出す（開発環境用）
+type LogSender struct {
+	path string
+	mu   sync.Mutex
+}
+
+// NewLogSender は新しいLogSenderインスタンスを作成
+// pathが空の場合は標準ログに出力する
+func NewLogSender(path string) *LogSender {
+	return &LogSender{path: path}
+}
+
+// Send はメール内容を書き出す
+func (s *LogSender) Send(to, subject, body string) error {
+	if s.path == "" {
+		log.Printf("[mail] to=%s subject=%q\n%s", to, subject, body)
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	f, err := os.OpenFile(s.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to open mail log: %w", err)
+	}
+	defer f.Close()
+
+	entry := fmt.Sprintf("----- %s -----\nTo: %s\nSubject: %s\n\n%s\n\n", time.Now().Format(time.RFC3339), to, subject, body)
+	if _, err := f.WriteString(entry); err != nil {
+		return fmt.Errorf("failed to write mail log: %w", err)
+	}
+	return nil
+}
+
+// buildMessage はRFC 5322形式のメールメッセージを組み立てる
+func buildMessage(from, to, subject, body string) []byte {
+	var b strings.Builder
+	b.WriteString("From: " + headerValue(from) + "\r\n")
+	b.WriteString("To: " + headerValue(to) + "\r\n")
+	b.WriteString("Subject: " + headerValue(subject) + "\r\n")
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	b.WriteString("Content-Transfer-Encoding: 8bit\r\n")
+	b.WriteString("Date: " + time.Now().Format(time.RFC1123Z) + "\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(strings.ReplaceAll(body, "\n", "\r\n"))
+	return []byte(b.String())
+}
+
+// headerValue はヘッダーインジェクションを防ぐため改行を除去し、非ASCII文字をエンコードする
+func headerValue(s string) string {
+	s = strings.NewReplacer("\r", "", "\n", "").Replace(s)
+	return mime.BEncoding.Encode("UTF-8", s)
+}
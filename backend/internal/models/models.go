@@ -1,7 +1,12 @@
 package models
 
 import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
 	"time"
+
+	"github.com/lib/pq"
 )
 
 // User ユーザーモデル
@@ -11,8 +16,47 @@ type User struct {
 	Email        string    `json:"email" db:"email"`
 	PasswordHash string    `json:"-" db:"password_hash"`
 	ProfileImage string    `json:"profile_image,omitempty" db:"profile_image"`
+	Role         string    `json:"role" db:"role"`
 	CreatedAt    time.Time `json:"created_at" db:"created_at"`
 	UpdatedAt    time.Time `json:"updated_at" db:"updated_at"`
+
+	// 二要素認証（TOTP）。TOTPEnabledAt が nil の場合は無効
+	TOTPSecret    *string    `json:"-" db:"totp_secret"`
+	TOTPEnabledAt *time.Time `json:"-" db:"totp_enabled_at"`
+	TOTPLastStep  int64      `json:"-" db:"totp_last_step"`
+
+	// メールアドレスの確認日時。nil の場合は未確認
+	EmailVerifiedAt *time.Time `json:"-" db:"email_verified_at"`
+}
+
+// TwoFactorEnabled は二要素認証が有効かを返す
+func (u *User) TwoFactorEnabled() bool {
+	return u.TOTPEnabledAt != nil
+}
+
+// EmailVerified はメールアドレスの確認が済んでいるかを返す
+func (u *User) EmailVerified() bool {
+	return u.EmailVerifiedAt != nil
+}
+
+// システム全体のユーザーロール
+const (
+	RoleAdmin  = "admin"
+	RoleEditor = "editor"
+	RoleViewer = "viewer"
+)
+
+// roleLevels はロールの強さ（大きいほど多くの操作が可能）
+var roleLevels = map[string]int{
+	RoleViewer: 1,
+	RoleEditor: 2,
+	RoleAdmin:  3,
+}
+
+// HasRole はロールが要求されたロール以上の権限を持つかを返す
+func HasRole(role, required string) bool {
+	level, ok := roleLevels[role]
+	return ok && level >= roleLevels[required]
 }
 
 // Manual マニュアルモデル
@@ -22,6 +66,7 @@ type Manual struct {
 	Description string    `json:"description,omitempty" db:"description"`
 	Category    string    `json:"category,omitempty" db:"category"`
 	UserID      uint      `json:"user_id" db:"user_id"`
+	WorkspaceID *uint     `json:"workspace_id,omitempty" db:"workspace_id"`
 	IsPublic    bool      `json:"is_public" db:"is_public"`
 	CreatedAt   time.Time `json:"created_at" db:"created_at"`
 	UpdatedAt   time.Time `json:"updated_at" db:"updated_at"`
@@ -42,13 +87,372 @@ type Step struct {
 
 // Image 画像モデル
 type Image struct {
-	ID        uint      `json:"id" db:"id"`
-	StepID    uint      `json:"step_id" db:"step_id"`
-	FilePath  string    `json:"file_path" db:"file_path"`
-	FileName  string    `json:"file_name" db:"file_name"`
-	FileSize  int64     `json:"file_size" db:"file_size"`
-	MimeType  string    `json:"mime_type" db:"mime_type"`
-	CreatedAt time.Time `json:"created_at" db:"created_at"`
+	ID            uint      `json:"id" db:"id"`
+	StepID        uint      `json:"step_id" db:"step_id"`
+	FilePath      string    `json:"file_path" db:"file_path"`
+	FileName      string    `json:"file_name" db:"file_name"`
+	FileSize      int64     `json:"file_size" db:"file_size"`
+	MimeType      string    `json:"mime_type" db:"mime_type"`
+	Width         int       `json:"width" db:"width"`
+	Height        int       `json:"height" db:"height"`
+	ThumbnailPath string    `json:"thumbnail_path,omitempty" db:"thumbnail_path"` // 元画像が十分小さい場合は空
+	MediumPath    string    `json:"medium_path,omitempty" db:"medium_path"`       // 元画像が十分小さい場合は空
+	CreatedAt     time.Time `json:"created_at" db:"created_at"`
+}
+
+// Workspace ワークスペースモデル
+type Workspace struct {
+	ID               uint              `json:"id" db:"id"`
+	Name             string            `json:"name" db:"name"`
+	CreatedBy        *uint             `json:"created_by,omitempty" db:"created_by"`
+	CreatedAt        time.Time         `json:"created_at" db:"created_at"`
+	UpdatedAt        time.Time         `json:"updated_at" db:"updated_at"`
+	RequireTwoFactor bool              `json:"require_two_factor" db:"require_two_factor"`
+	Role             string            `json:"role,omitempty" db:"role"`
+	Members          []WorkspaceMember `json:"members,omitempty" db:"-"`
+}
+
+// WorkspaceMember ワークスペースメンバーモデル
+type WorkspaceMember struct {
+	WorkspaceID uint      `json:"workspace_id" db:"workspace_id"`
+	UserID      uint      `json:"user_id" db:"user_id"`
+	Username    string    `json:"username" db:"username"`
+	Email       string    `json:"email" db:"email"`
+	Role        string    `json:"role" db:"role"`
+	CreatedAt   time.Time `json:"created_at" db:"created_at"`
+}
+
+// ワークスペース内の役割
+const (
+	WorkspaceRoleOwner  = "owner"
+	WorkspaceRoleEditor = "editor"
+	WorkspaceRoleViewer = "viewer"
+)
+
+// CanEditInWorkspace はワークスペース内の役割がマニュアルを編集できるかを返す
+func CanEditInWorkspace(role string) bool {
+	return role == WorkspaceRoleOwner || role == WorkspaceRoleEditor
+}
+
+// ManualSearchResult マニュアル検索結果
+type ManualSearchResult struct {
+	Manual
+	Rank       float64           `json:"rank" db:"rank"`
+	Highlights []SearchHighlight `json:"highlights" db:"-"`
+}
+
+// SearchHighlight 検索語を強調表示した抜粋
+// Snippet はHTMLエスケープ済みで、一致箇所のみ <mark> タグで囲まれる
+type SearchHighlight struct {
+	Field   string `json:"field"` // title, description, step_title, step_content
+	StepID  *uint  `json:"step_id,omitempty"`
+	Snippet string `json:"snippet"`
+}
+
+// PasswordResetToken パスワードリセットトークンモデル
+type PasswordResetToken struct {
+	ID        uint       `json:"id" db:"id"`
+	UserID    uint       `json:"user_id" db:"user_id"`
+	TokenHash string     `json:"-" db:"token_hash"`
+	ExpiresAt time.Time  `json:"expires_at" db:"expires_at"`
+	UsedAt    *time.Time `json:"used_at,omitempty" db:"used_at"`
+	CreatedAt time.Time  `json:"created_at" db:"created_at"`
+}
+
+// EmailVerificationToken メールアドレス確認トークンモデル
+// Email は確認後に有効になるアドレス（登録時は登録したアドレス、変更時は変更後のアドレス）
+type EmailVerificationToken struct {
+	ID        uint       `json:"id" db:"id"`
+	UserID    uint       `json:"user_id" db:"user_id"`
+	Email     string     `json:"email" db:"email"`
+	TokenHash string     `json:"-" db:"token_hash"`
+	ExpiresAt time.Time  `json:"expires_at" db:"expires_at"`
+	UsedAt    *time.Time `json:"used_at,omitempty" db:"used_at"`
+	CreatedAt time.Time  `json:"created_at" db:"created_at"`
+}
+
+// UserSession ログインセッションモデル
+// リフレッシュトークンはハッシュのみを保存し、使用するたびに置き換える
+type UserSession struct {
+	ID                string     `json:"id" db:"id"`
+	UserID            uint       `json:"user_id" db:"user_id"`
+	RefreshTokenHash  string     `json:"-" db:"refresh_token_hash"`
+	PreviousTokenHash *string    `json:"-" db:"previous_token_hash"`
+	UserAgent         string     `json:"user_agent" db:"user_agent"`
+	IPAddress         string     `json:"ip_address" db:"ip_address"`
+	ExpiresAt         time.Time  `json:"expires_at" db:"expires_at"`
+	RevokedAt         *time.Time `json:"revoked_at,omitempty" db:"revoked_at"`
+	LastUsedAt        time.Time  `json:"last_used_at" db:"last_used_at"`
+	CreatedAt         time.Time  `json:"created_at" db:"created_at"`
+}
+
+// LoginThrottle はアカウント・IPアドレスごとのログイン失敗の記録
+type LoginThrottle struct {
+	Key           string     `json:"key" db:"key"`
+	Failures      int        `json:"failures" db:"failures"`
+	LockedUntil   *time.Time `json:"locked_until,omitempty" db:"locked_until"`
+	LastFailureAt time.Time  `json:"last_failure_at" db:"last_failure_at"`
+}
+
+// 個人用APIトークンのスコープ
+const (
+	APITokenScopeRead  = "read"  // 参照（GET）のみ
+	APITokenScopeWrite = "write" // 参照と作成・更新・削除
+)
+
+// APIToken 個人用APIトークンモデル
+type APIToken struct {
+	ID          uint           `json:"id" db:"id"`
+	UserID      uint           `json:"user_id" db:"user_id"`
+	Name        string         `json:"name" db:"name"`
+	TokenHash   string         `json:"-" db:"token_hash"`
+	TokenPrefix string         `json:"token_prefix" db:"token_prefix"`
+	Scopes      pq.StringArray `json:"scopes" db:"scopes"`
+	ExpiresAt   time.Time      `json:"expires_at" db:"expires_at"`
+	LastUsedAt  *time.Time     `json:"last_used_at,omitempty" db:"last_used_at"`
+	RevokedAt   *time.Time     `json:"revoked_at,omitempty" db:"revoked_at"`
+	CreatedAt   time.Time      `json:"created_at" db:"created_at"`
+}
+
+// UserIdentity 外部IdP（OpenID Connect）のアカウントとユーザーの紐づけモデル
+type UserIdentity struct {
+	ID          uint      `json:"id" db:"id"`
+	UserID      uint      `json:"user_id" db:"user_id"`
+	Issuer      string    `json:"issuer" db:"issuer"`
+	Subject     string    `json:"subject" db:"subject"`
+	Email       string    `json:"email" db:"email"`
+	LastLoginAt time.Time `json:"last_login_at" db:"last_login_at"`
+	CreatedAt   time.Time `json:"created_at" db:"created_at"`
+}
+
+// OIDCLoginState 認可リクエストの途中状態モデル
+type OIDCLoginState struct {
+	StateHash    string    `db:"state_hash"`
+	Nonce        string    `db:"nonce"`
+	CodeVerifier string    `db:"code_verifier"`
+	RedirectPath string    `db:"redirect_path"`
+	ExpiresAt    time.Time `db:"expires_at"`
+	CreatedAt    time.Time `db:"created_at"`
+}
+
+// ManualRevision マニュアル改訂履歴モデル
+type ManualRevision struct {
+	ID             uint          `json:"id" db:"id"`
+	ManualID       uint          `json:"manual_id" db:"manual_id"`
+	RevisionNumber int           `json:"revision_number" db:"revision_number"`
+	Title          string        `json:"title" db:"title"`
+	Description    string        `json:"description,omitempty" db:"description"`
+	Category       string        `json:"category,omitempty" db:"category"`
+	IsPublic       bool          `json:"is_public" db:"is_public"`
+	Steps          RevisionSteps `json:"steps,omitempty" db:"steps"`
+	Summary        string        `json:"summary" db:"summary"`
+	CreatedBy      *uint         `json:"created_by,omitempty" db:"created_by"`
+	CreatedAt      time.Time     `json:"created_at" db:"created_at"`
+}
+
+// RevisionStep 改訂履歴に保存される手順のスナップショット
+type RevisionStep struct {
+	StepID      uint            `json:"step_id"`
+	OrderNumber int             `json:"order_number"`
+	Title       string          `json:"title"`
+	Content     string          `json:"content,omitempty"`
+	Images      []RevisionImage `json:"images,omitempty"`
+}
+
+// RevisionImage 改訂履歴に保存される画像参照
+type RevisionImage struct {
+	ImageID       uint   `json:"image_id"`
+	FilePath      string `json:"file_path"`
+	FileName      string `json:"file_name"`
+	FileSize      int64  `json:"file_size"`
+	MimeType      string `json:"mime_type"`
+	Width         int    `json:"width,omitempty"`
+	Height        int    `json:"height,omitempty"`
+	ThumbnailPath string `json:"thumbnail_path,omitempty"`
+	MediumPath    string `json:"medium_path,omitempty"`
+}
+
+// RevisionSteps はJSONBカラムとの相互変換を行う手順スナップショットの一覧
+type RevisionSteps []RevisionStep
+
+// Value はdriver.Valuerインターフェースの実装
+func (s RevisionSteps) Value() (driver.Value, error) {
+	if s == nil {
+		return []byte("[]"), nil
+	}
+	return json.Marshal(s)
+}
+
+// Scan はsql.Scannerインターフェースの実装
+func (s *RevisionSteps) Scan(src interface{}) error {
+	var data []byte
+	switch v := src.(type) {
+	case []byte:
+		data = v
+	case string:
+		data = []byte(v)
+	case nil:
+		*s = nil
+		return nil
+	default:
+		return errors.New("unsupported type for RevisionSteps")
+	}
+	return json.Unmarshal(data, s)
+}
+
+// AuditEvent 監査ログモデル
+// Before・After には変更前後の主な値を記録する（パスワードや本文などは含めない）
+type AuditEvent struct {
+	ID         uint         `json:"id" db:"id"`
+	ActorID    *uint        `json:"actor_id" db:"actor_id"`
+	ActorEmail *string      `json:"actor_email" db:"actor_email"`
+	Action     string       `json:"action" db:"action"`
+	TargetType string       `json:"target_type" db:"target_type"`
+	TargetID   uint         `json:"target_id" db:"target_id"`
+	Before     AuditSummary `json:"before" db:"before"`
+	After      AuditSummary `json:"after" db:"after"`
+	IPAddress  string       `json:"ip_address" db:"ip_address"`
+	UserAgent  string       `json:"user_agent" db:"user_agent"`
+	CreatedAt  time.Time    `json:"created_at" db:"created_at"`
+}
+
+// AuditSummary はJSONBカラムとの相互変換を行う変更前後の値
+type AuditSummary map[string]interface{}
+
+// Value はdriver.Valuerインターフェースの実装
+func (s AuditSummary) Value() (driver.Value, error) {
+	if s == nil {
+		return nil, nil
+	}
+	return json.Marshal(s)
+}
+
+// Scan はsql.Scannerインターフェースの実装
+func (s *AuditSummary) Scan(src interface{}) error {
+	var data []byte
+	switch v := src.(type) {
+	case []byte:
+		data = v
+	case string:
+		data = []byte(v)
+	case nil:
+		*s = nil
+		return nil
+	default:
+		return errors.New("unsupported type for AuditSummary")
+	}
+	return json.Unmarshal(data, s)
+}
+
+// 監査ログの操作の種類（"対象の種類.操作" の形式）
+const (
+	AuditActionManualCreate  = "manual.create"
+	AuditActionManualUpdate  = "manual.update"
+	AuditActionManualDelete  = "manual.delete"
+	AuditActionManualImport  = "manual.import"
+	AuditActionManualRestore = "manual.restore"
+
+	AuditActionStepCreate  = "step.create"
+	AuditActionStepUpdate  = "step.update"
+	AuditActionStepDelete  = "step.delete"
+	AuditActionStepReorder = "step.reorder"
+
+	AuditActionImageUpload = "image.upload"
+	AuditActionImageDelete = "image.delete"
+
+	AuditActionWorkspaceCreate       = "workspace.create"
+	AuditActionWorkspaceUpdate       = "workspace.update"
+	AuditActionWorkspaceDelete       = "workspace.delete"
+	AuditActionWorkspaceMemberAdd    = "workspace.member_add"
+	AuditActionWorkspaceMemberRemove = "workspace.member_remove"
+
+	AuditActionUserRegister           = "user.register"
+	AuditActionUserUpdate             = "user.update"
+	AuditActionUserEmailVerify        = "user.email_verify"
+	AuditActionUserProfileImageUpdate = "user.profile_image_update"
+	AuditActionUserDelete             = "user.delete"
+	AuditActionUserUnverifiedDelete   = "user.unverified_delete" // 確認されないまま有効期間を過ぎたアカウントの削除
+	AuditActionUserRoleChange         = "user.role_change"
+	AuditActionUserUnlock             = "user.unlock"
+	AuditActionUserIdentityLink       = "user.identity_link"
+
+	AuditActionPasswordChange = "password.change"
+	AuditActionPasswordReset  = "password.reset"
+
+	AuditActionTwoFactorEnable        = "two_factor.enable"
+	AuditActionTwoFactorDisable       = "two_factor.disable"
+	AuditActionTwoFactorRecoveryCodes = "two_factor.recovery_codes_regenerate"
+
+	AuditActionAPITokenCreate = "api_token.create"
+	AuditActionAPITokenRevoke = "api_token.revoke"
+)
+
+// 監査ログの対象の種類
+const (
+	AuditTargetManual    = "manual"
+	AuditTargetStep      = "step"
+	AuditTargetImage     = "image"
+	AuditTargetWorkspace = "workspace"
+	AuditTargetUser      = "user"
+	AuditTargetAPIToken  = "api_token"
+)
+
+// RevisionDiff 2つの改訂履歴の差分
+type RevisionDiff struct {
+	FromRevision int           `json:"from_revision"`
+	ToRevision   int           `json:"to_revision"`
+	Fields       []FieldChange `json:"fields"`
+	Steps        []StepDiff    `json:"steps"`
+}
+
+// FieldChange マニュアル項目の変更内容
+type FieldChange struct {
+	Field string      `json:"field"`
+	From  interface{} `json:"from"`
+	To    interface{} `json:"to"`
+}
+
+// StepDiff 手順単位の差分
+// Status は added / removed / modified / unchanged のいずれか
+type StepDiff struct {
+	StepID  uint          `json:"step_id"`
+	Status  string        `json:"status"`
+	From    *RevisionStep `json:"from,omitempty"`
+	To      *RevisionStep `json:"to,omitempty"`
+	Changes []FieldChange `json:"changes,omitempty"`
+}
+
+// StorageGCReport ストレージと画像・ユーザー情報の突き合わせ結果
+// Orphans・Missing は最大件数までのみ含み、件数は OrphanCount・MissingCount に集計する
+type StorageGCReport struct {
+	DryRun       bool          `json:"dry_run"`
+	GracePeriod  string        `json:"grace_period"`
+	StartedAt    time.Time     `json:"started_at"`
+	FinishedAt   time.Time     `json:"finished_at"`
+	Scanned      int           `json:"scanned"`
+	Referenced   int           `json:"referenced"`
+	OrphanCount  int           `json:"orphan_count"`
+	DeletedCount int           `json:"deleted_count"`
+	DeletedBytes int64         `json:"deleted_bytes"`
+	MissingCount int           `json:"missing_count"`
+	Orphans      []OrphanFile  `json:"orphans"`
+	Missing      []MissingFile `json:"missing"`
+}
+
+// OrphanFile どこからも参照されていないファイル
+// 猶予期間内のファイルはアップロード途中の可能性があるため削除しない
+type OrphanFile struct {
+	Path    string    `json:"path"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mod_time"`
+	Deleted bool      `json:"deleted"`
+}
+
+// MissingFile 参照されているがストレージに存在しないファイル
+// Source は image / revision / profile のいずれか
+type MissingFile struct {
+	Path   string `json:"path"`
+	Source string `json:"source"`
 }
 
 // リクエスト・レスポンス用の構造体
@@ -63,7 +467,14 @@ type UserLoginRequest struct {
 type UserRegisterRequest struct {
 	Username string `json:"username" validate:"required,min=3,max=100"`
 	Email    string `json:"email" validate:"required,email"`
-	Password string `json:"password" validate:"required,min=6"`
+	Password string `json:"password" validate:"required"` // 長さなどの条件はパスワードポリシーで確認する
+}
+
+// UserUpdateRequest ユーザー情報更新リクエスト（空の項目は変更しない）
+// プロフィール画像は専用のエンドポイントでのみ変更できるため含めない
+type UserUpdateRequest struct {
+	Username string `json:"username" validate:"omitempty,min=3,max=100"`
+	Email    string `json:"email" validate:"omitempty,email"`
 }
 
 // UserResponse ユーザーレスポンス
@@ -72,14 +483,154 @@ type UserResponse struct {
 	Username     string    `json:"username"`
 	Email        string    `json:"email"`
 	ProfileImage string    `json:"profile_image,omitempty"`
+	Role         string    `json:"role"`
 	CreatedAt    time.Time `json:"created_at"`
 	UpdatedAt    time.Time `json:"updated_at"`
+
+	TwoFactorEnabled bool   `json:"two_factor_enabled"`
+	EmailVerified    bool   `json:"email_verified"`
+	PendingEmail     string `json:"pending_email,omitempty"` // 確認待ちの変更後のメールアドレス（変更を受け付けた場合のみ）
+}
+
+// UserRoleRequest ユーザーロール変更リクエスト
+type UserRoleRequest struct {
+	Role string `json:"role" validate:"required,oneof=admin editor viewer"`
 }
 
 // AuthResponse 認証レスポンス
+// Token は短命のアクセストークンで、期限切れ後は RefreshToken で再発行する
 type AuthResponse struct {
-	Token string       `json:"token"`
-	User  UserResponse `json:"user"`
+	Token        string       `json:"token"`
+	RefreshToken string       `json:"refresh_token"`
+	ExpiresIn    int64        `json:"expires_in"` // アクセストークンの有効期間（秒）
+	User         UserResponse `json:"user"`
+}
+
+// RefreshTokenRequest トークン再発行・ログアウトリクエスト
+type RefreshTokenRequest struct {
+	RefreshToken string `json:"refresh_token" validate:"required"`
+}
+
+// TwoFactorChallenge はパスワードの確認後に二要素目の入力を求めるレスポンス
+// TwoFactorToken は二要素目の検証にのみ使用でき、APIの認証には使用できない
+type TwoFactorChallenge struct {
+	TwoFactorRequired bool   `json:"two_factor_required"`
+	TwoFactorToken    string `json:"two_factor_token"`
+	ExpiresIn         int64  `json:"expires_in"`
+}
+
+// TwoFactorLoginRequest 二要素目の検証リクエスト
+// Code には認証アプリの6桁のコードまたはリカバリーコードを指定する
+type TwoFactorLoginRequest struct {
+	TwoFactorToken string `json:"two_factor_token" validate:"required"`
+	Code           string `json:"code" validate:"required,max=32"`
+}
+
+// TwoFactorCodeRequest 認証アプリのコードによる確認リクエスト
+type TwoFactorCodeRequest struct {
+	Code string `json:"code" validate:"required,max=32"`
+}
+
+// TwoFactorDisableRequest 二要素認証の無効化リクエスト
+type TwoFactorDisableRequest struct {
+	Password string `json:"password" validate:"required"`
+	Code     string `json:"code" validate:"required,max=32"`
+}
+
+// TOTPEnrollment 認証アプリの登録情報
+// QRCode は provisioning_uri を表すPNG画像のdata URL
+type TOTPEnrollment struct {
+	Secret          string `json:"secret"`
+	ProvisioningURI string `json:"provisioning_uri"`
+	QRCode          string `json:"qr_code"`
+}
+
+// RecoveryCodesResponse 発行したリカバリーコード（平文は発行時にのみ返す）
+type RecoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+// TwoFactorStatus 二要素認証の設定状況
+type TwoFactorStatus struct {
+	Enabled                bool       `json:"enabled"`
+	EnabledAt              *time.Time `json:"enabled_at,omitempty"`
+	RecoveryCodesRemaining int        `json:"recovery_codes_remaining"`
+}
+
+// APITokenCreateRequest 個人用APIトークン作成リクエスト
+type APITokenCreateRequest struct {
+	Name          string   `json:"name" validate:"required,max=100"`
+	Scopes        []string `json:"scopes" validate:"required,min=1,dive,oneof=read write"`
+	ExpiresInDays int      `json:"expires_in_days" validate:"required,min=1,max=365"`
+}
+
+// APITokenCreateResponse 個人用APIトークン作成レスポンス
+// Token は作成時にのみ返し、サーバーには保存しない
+type APITokenCreateResponse struct {
+	APIToken
+	Token string `json:"token"`
+}
+
+// ClientInfo セッション・監査ログに記録するクライアント情報
+type ClientInfo struct {
+	UserAgent string
+	IPAddress string
+}
+
+// SessionResponse セッション一覧の要素
+type SessionResponse struct {
+	ID         string    `json:"id"`
+	UserAgent  string    `json:"user_agent"`
+	IPAddress  string    `json:"ip_address"`
+	Current    bool      `json:"current"`
+	LastUsedAt time.Time `json:"last_used_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// EmailVerificationPending メールアドレスの確認待ちを表すレスポンス
+// 確認が必要な場合、登録時にはトークンを発行せずにこのレスポンスを返す
+type EmailVerificationPending struct {
+	VerificationRequired bool   `json:"verification_required"`
+	Email                string `json:"email"`
+}
+
+// EmailVerifyRequest メールアドレス確認リクエスト
+type EmailVerifyRequest struct {
+	Token string `json:"token" validate:"required"`
+}
+
+// EmailVerificationResendRequest 確認メール再送信リクエスト
+type EmailVerificationResendRequest struct {
+	Email string `json:"email" validate:"required,email"`
+}
+
+// AuditEventFilter 監査ログの検索条件（未指定の条件では絞り込まない）
+type AuditEventFilter struct {
+	ActorID    *uint
+	Action     string
+	TargetType string
+	TargetID   *uint
+	From       *time.Time // この日時以降
+	To         *time.Time // この日時より前
+}
+
+// PasswordPolicyResponse パスワードポリシーのレスポンス
+type PasswordPolicyResponse struct {
+	MinLength           int `json:"min_length"`
+	MaxLength           int `json:"max_length"`
+	MinCharacterClasses int `json:"min_character_classes"` // 英小文字・英大文字・数字・記号のうち含める必要がある種類の数
+}
+
+// PasswordResetRequest パスワードリセット要求リクエスト
+type PasswordResetRequest struct {
+	Email string `json:"email" validate:"required,email"`
+}
+
+// PasswordResetConfirmRequest パスワードリセット実行リクエスト
+type PasswordResetConfirmRequest struct {
+	Token       string `json:"token" validate:"required"`
+	NewPassword string `json:"new_password" validate:"required"`
 }
 
 // ManualRequest マニュアル作成/更新リクエスト
@@ -88,6 +639,31 @@ type ManualRequest struct {
 	Description string `json:"description"`
 	Category    string `json:"category"`
 	IsPublic    bool   `json:"is_public"`
+	WorkspaceID *uint  `json:"workspace_id"`
+}
+
+// WorkspaceRequest ワークスペース作成/更新リクエスト
+type WorkspaceRequest struct {
+	Name string `json:"name" validate:"required,min=1,max=100"`
+	// RequireTwoFactor はメンバーに二要素認証を必須にするか（省略した場合は変更しない）
+	RequireTwoFactor *bool `json:"require_two_factor"`
+}
+
+// WorkspaceMemberRequest ワークスペースメンバー追加リクエスト
+type WorkspaceMemberRequest struct {
+	Email string `json:"email" validate:"required,email"`
+	Role  string `json:"role" validate:"omitempty,oneof=owner editor viewer"`
+}
+
+// ManualSearchParams マニュアル検索条件
+type ManualSearchParams struct {
+	Query       string
+	Category    string
+	OwnerID     *uint
+	WorkspaceID *uint
+	Visibility  string // public, private, 空文字はすべて
+	Page        int
+	Limit       int
 }
 
 // StepRequest 手順作成/更新リクエスト
@@ -105,7 +681,7 @@ type StepOrderRequest struct {
 // StepOrder 手順順序
 type StepOrder struct {
 	ID          uint `json:"id" validate:"required"`
-	OrderNumber int  `json:"order_number" validate:"required,min=0"`
+	OrderNumber int  `json:"order_number" validate:"min=0"`
 }
 
 // PaginationResponse ページネーションレスポンス
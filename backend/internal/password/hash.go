@@ -0,0 +1,184 @@
+package password
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/Ryo-cool/guideforge/internal/config"
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	// AlgorithmBcrypt は bcrypt でハッシュする
+	AlgorithmBcrypt = "bcrypt"
+	// AlgorithmArgon2id は Argon2id でハッシュする
+	AlgorithmArgon2id = "argon2id"
+
+	// argon2idPrefix は Argon2id のハッシュ（PHC文字列形式）の先頭
+	argon2idPrefix = "$argon2id$"
+	// argon2SaltLength は Argon2id のソルトのバイト数
+	argon2SaltLength = 16
+	// argon2KeyLength は Argon2id の出力のバイト数
+	argon2KeyLength = 32
+)
+
+var (
+	// ErrMismatch はパスワードがハッシュと一致しない場合のエラー
+	ErrMismatch = errors.New("password does not match")
+	// ErrInvalidHash は保存されているハッシュを解釈できない場合のエラー
+	ErrInvalidHash = errors.New("invalid password hash")
+)
+
+// argon2Params は Argon2id のパラメータ
+type argon2Params struct {
+	memory      uint32
+	iterations  uint32
+	parallelism uint8
+}
+
+// Hasher はパスワードのハッシュ化と照合を行う
+// 照合は設定に関わらず bcrypt と Argon2id のどちらのハッシュにも対応し、
+// NeedsRehash で現在の設定と異なるハッシュを判定できる（ログイン時にハッシュし直すために使用する）
+type Hasher struct {
+	algorithm  string
+	bcryptCost int
+	argon2     argon2Params
+}
+
+// NewHasher は設定に応じたHasherを作成する
+func NewHasher(cfg *config.Config) (*Hasher, error) {
+	h := &Hasher{
+		algorithm:  cfg.PasswordHashAlgorithm,
+		bcryptCost: cfg.BcryptCost,
+		argon2: argon2Params{
+			memory:      cfg.Argon2Memory,
+			iterations:  cfg.Argon2Iterations,
+			parallelism: cfg.Argon2Parallelism,
+		},
+	}
+
+	switch h.algorithm {
+	case AlgorithmBcrypt:
+		if h.bcryptCost < bcrypt.MinCost || h.bcryptCost > bcrypt.MaxCost {
+			return nil, fmt.Errorf("bcrypt cost must be between %d and %d", bcrypt.MinCost, bcrypt.MaxCost)
+		}
+	case AlgorithmArgon2id:
+		if h.argon2.memory < 8*uint32(h.argon2.parallelism) || h.argon2.iterations < 1 || h.argon2.parallelism < 1 {
+			return nil, fmt.Errorf("argon2id parameters must be positive and memory must be at least 8 KiB per thread")
+		}
+	default:
+		return nil, fmt.Errorf("unknown password hash algorithm: %s", h.algorithm)
+	}
+
+	return h, nil
+}
+
+// Hash はパスワードを現在の設定でハッシュ化する
+func (h *Hasher) Hash(password string) (string, error) {
+	if h.algorithm == AlgorithmArgon2id {
+		salt := make([]byte, argon2SaltLength)
+		if _, err := rand.Read(salt); err != nil {
+			return "", err
+		}
+		key := argon2.IDKey([]byte(password), salt, h.argon2.iterations, h.argon2.memory, h.argon2.parallelism, argon2KeyLength)
+		return encodeArgon2id(h.argon2, salt, key), nil
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), h.bcryptCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+// Compare はパスワードがハッシュと一致するかを確認する
+// 一致しない場合は ErrMismatch、ハッシュを解釈できない場合は ErrInvalidHash を返す
+func (h *Hasher) Compare(hash, password string) error {
+	if strings.HasPrefix(hash, argon2idPrefix) {
+		params, salt, key, err := decodeArgon2id(hash)
+		if err != nil {
+			return err
+		}
+		actual := argon2.IDKey([]byte(password), salt, params.iterations, params.memory, params.parallelism, uint32(len(key)))
+		if subtle.ConstantTimeCompare(actual, key) != 1 {
+			return ErrMismatch
+		}
+		return nil
+	}
+
+	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
+	if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+		return ErrMismatch
+	}
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidHash, err)
+	}
+	return nil
+}
+
+// NeedsRehash はハッシュのアルゴリズムやパラメータが現在の設定と異なるかを返す
+func (h *Hasher) NeedsRehash(hash string) bool {
+	if strings.HasPrefix(hash, argon2idPrefix) {
+		if h.algorithm != AlgorithmArgon2id {
+			return true
+		}
+		params, _, _, err := decodeArgon2id(hash)
+		return err != nil || params != h.argon2
+	}
+
+	if h.algorithm != AlgorithmBcrypt {
+		return true
+	}
+	cost, err := bcrypt.Cost([]byte(hash))
+	return err != nil || cost != h.bcryptCost
+}
+
+// encodeArgon2id は Argon2id のハッシュをPHC文字列形式にする
+func encodeArgon2id(params argon2Params, salt, key []byte) string {
+	return fmt.Sprintf(
+		"$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version,
+		params.memory,
+		params.iterations,
+		params.parallelism,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	)
+}
+
+// decodeArgon2id はPHC文字列形式の Argon2id のハッシュを解釈する
+func decodeArgon2id(hash string) (argon2Params, []byte, []byte, error) {
+	var params argon2Params
+
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 {
+		return params, nil, nil, ErrInvalidHash
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return params, nil, nil, ErrInvalidHash
+	}
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.memory, &params.iterations, &params.parallelism); err != nil {
+		return params, nil, nil, ErrInvalidHash
+	}
+	if params.iterations < 1 || params.parallelism < 1 {
+		return params, nil, nil, ErrInvalidHash
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return params, nil, nil, ErrInvalidHash
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(key) == 0 {
+		return params, nil, nil, ErrInvalidHash
+	}
+
+	return params, salt, key, nil
+}
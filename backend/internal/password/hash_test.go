@@ -0,0 +1,197 @@
+package password
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/Ryo-cool/guideforge/internal/config"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// テストを速くするため、各アルゴリズムの最小限に近いパラメータを使用する
+func bcryptConfig(cost int) *config.Config {
+	return &config.Config{PasswordHashAlgorithm: AlgorithmBcrypt, BcryptCost: cost}
+}
+
+func argon2idConfig(memory, iterations uint32) *config.Config {
+	return &config.Config{
+		PasswordHashAlgorithm: AlgorithmArgon2id,
+		Argon2Memory:          memory,
+		Argon2Iterations:      iterations,
+		Argon2Parallelism:     1,
+	}
+}
+
+func mustHasher(t *testing.T, cfg *config.Config) *Hasher {
+	t.Helper()
+	h, err := NewHasher(cfg)
+	if err != nil {
+		t.Fatalf("NewHasher() error = %v", err)
+	}
+	return h
+}
+
+func TestHasherRoundTrip(t *testing.T) {
+	tests := []struct {
+		name   string
+		cfg    *config.Config
+		prefix string
+	}{
+		{name: "bcrypt", cfg: bcryptConfig(bcrypt.MinCost), prefix: "$2a$"},
+		{name: "argon2id", cfg: argon2idConfig(64, 1), prefix: argon2idPrefix},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := mustHasher(t, tt.cfg)
+
+			hash, err := h.Hash("correct horse battery staple")
+			if err != nil {
+				t.Fatalf("Hash() error = %v", err)
+			}
+			if !strings.HasPrefix(hash, tt.prefix) {
+				t.Fatalf("Hash() = %q, want prefix %q", hash, tt.prefix)
+			}
+
+			if err := h.Compare(hash, "correct horse battery staple"); err != nil {
+				t.Errorf("Compare() with the same password error = %v", err)
+			}
+			if err := h.Compare(hash, "Correct horse battery staple"); !errors.Is(err, ErrMismatch) {
+				t.Errorf("Compare() with a different password error = %v, want ErrMismatch", err)
+			}
+			if h.NeedsRehash(hash) {
+				t.Errorf("NeedsRehash() = true for a hash made with the current settings")
+			}
+		})
+	}
+}
+
+func TestHasherCompareAcrossAlgorithms(t *testing.T) {
+	bcryptHasher := mustHasher(t, bcryptConfig(bcrypt.MinCost))
+	argon2Hasher := mustHasher(t, argon2idConfig(64, 1))
+
+	bcryptHash, err := bcryptHasher.Hash("secret")
+	if err != nil {
+		t.Fatalf("Hash() error = %v", err)
+	}
+	argon2Hash, err := argon2Hasher.Hash("secret")
+	if err != nil {
+		t.Fatalf("Hash() error = %v", err)
+	}
+
+	// 設定を切り替えた後も既存のハッシュで照合できる
+	if err := argon2Hasher.Compare(bcryptHash, "secret"); err != nil {
+		t.Errorf("argon2id hasher Compare(bcrypt hash) error = %v", err)
+	}
+	if err := bcryptHasher.Compare(argon2Hash, "secret"); err != nil {
+		t.Errorf("bcrypt hasher Compare(argon2id hash) error = %v", err)
+	}
+}
+
+func TestHasherCompareInvalidHash(t *testing.T) {
+	h := mustHasher(t, bcryptConfig(bcrypt.MinCost))
+
+	tests := []struct {
+		name string
+		hash string
+	}{
+		{name: "empty", hash: ""},
+		{name: "not a hash", hash: "plaintext"},
+		{name: "argon2id missing key", hash: "$argon2id$v=19$m=64,t=1,p=1$c2FsdHNhbHQ"},
+		{name: "argon2id unknown version", hash: "$argon2id$v=16$m=64,t=1,p=1$c2FsdHNhbHQ$a2V5"},
+		{name: "argon2id zero iterations", hash: "$argon2id$v=19$m=64,t=0,p=1$c2FsdHNhbHQ$a2V5"},
+		{name: "argon2id bad salt", hash: "$argon2id$v=19$m=64,t=1,p=1$!!!$a2V5"},
+		{name: "argon2id empty key", hash: "$argon2id$v=19$m=64,t=1,p=1$c2FsdHNhbHQ$"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := h.Compare(tt.hash, "secret"); !errors.Is(err, ErrInvalidHash) {
+				t.Errorf("Compare() error = %v, want ErrInvalidHash", err)
+			}
+		})
+	}
+}
+
+func TestArgon2idEncodeDecode(t *testing.T) {
+	params := argon2Params{memory: 65536, iterations: 3, parallelism: 4}
+	salt := []byte("0123456789abcdef")
+	key := []byte("0123456789abcdef0123456789abcdef")
+
+	hash := encodeArgon2id(params, salt, key)
+	want := "$argon2id$v=19$m=65536,t=3,p=4$MDEyMzQ1Njc4OWFiY2RlZg$MDEyMzQ1Njc4OWFiY2RlZjAxMjM0NTY3ODlhYmNkZWY"
+	if hash != want {
+		t.Fatalf("encodeArgon2id() = %q, want %q", hash, want)
+	}
+
+	gotParams, gotSalt, gotKey, err := decodeArgon2id(hash)
+	if err != nil {
+		t.Fatalf("decodeArgon2id() error = %v", err)
+	}
+	if gotParams != params {
+		t.Errorf("decodeArgon2id() params = %+v, want %+v", gotParams, params)
+	}
+	if string(gotSalt) != string(salt) {
+		t.Errorf("decodeArgon2id() salt = %q, want %q", gotSalt, salt)
+	}
+	if string(gotKey) != string(key) {
+		t.Errorf("decodeArgon2id() key = %q, want %q", gotKey, key)
+	}
+}
+
+func TestHasherNeedsRehash(t *testing.T) {
+	bcryptHash, err := mustHasher(t, bcryptConfig(bcrypt.MinCost)).Hash("secret")
+	if err != nil {
+		t.Fatalf("Hash() error = %v", err)
+	}
+	argon2Hash, err := mustHasher(t, argon2idConfig(64, 1)).Hash("secret")
+	if err != nil {
+		t.Fatalf("Hash() error = %v", err)
+	}
+
+	tests := []struct {
+		name string
+		cfg  *config.Config
+		hash string
+		want bool
+	}{
+		{name: "bcrypt same cost", cfg: bcryptConfig(bcrypt.MinCost), hash: bcryptHash, want: false},
+		{name: "bcrypt cost raised", cfg: bcryptConfig(bcrypt.MinCost + 1), hash: bcryptHash, want: true},
+		{name: "bcrypt to argon2id", cfg: argon2idConfig(64, 1), hash: bcryptHash, want: true},
+		{name: "argon2id same params", cfg: argon2idConfig(64, 1), hash: argon2Hash, want: false},
+		{name: "argon2id memory raised", cfg: argon2idConfig(128, 1), hash: argon2Hash, want: true},
+		{name: "argon2id iterations raised", cfg: argon2idConfig(64, 2), hash: argon2Hash, want: true},
+		{name: "argon2id to bcrypt", cfg: bcryptConfig(bcrypt.MinCost), hash: argon2Hash, want: true},
+		{name: "invalid bcrypt hash", cfg: bcryptConfig(bcrypt.MinCost), hash: "plaintext", want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := mustHasher(t, tt.cfg).NeedsRehash(tt.hash); got != tt.want {
+				t.Errorf("NeedsRehash() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewHasherRejectsInvalidSettings(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  *config.Config
+	}{
+		{name: "bcrypt cost too low", cfg: bcryptConfig(bcrypt.MinCost - 1)},
+		{name: "bcrypt cost too high", cfg: bcryptConfig(bcrypt.MaxCost + 1)},
+		{name: "argon2id memory below 8 KiB per thread", cfg: argon2idConfig(7, 1)},
+		{name: "argon2id zero iterations", cfg: argon2idConfig(64, 0)},
+		{name: "unknown algorithm", cfg: &config.Config{PasswordHashAlgorithm: "scrypt"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewHasher(tt.cfg); err == nil {
+				t.Errorf("NewHasher() error = nil, want an error")
+			}
+		})
+	}
+}
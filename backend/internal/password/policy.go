@@ -0,0 +1,183 @@
+package password
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/Ryo-cool/guideforge/internal/config"
+)
+
+// bcryptMaxBytes は bcrypt がハッシュに使用する最大のバイト数（これを超える部分は無視されるため受け付けない）
+const bcryptMaxBytes = 72
+
+// Policy は新しく設定するパスワードが満たすべき条件
+type Policy struct {
+	MinLength           int
+	MaxLength           int
+	MaxBytes            int // 0の場合は制限しない
+	MinCharacterClasses int
+
+	// breached は使用を禁止するパスワードのSHA-1ハッシュ
+	breached map[[sha1.Size]byte]struct{}
+}
+
+// NewPolicy は設定に応じたPolicyを作成する
+// PasswordBreachedListFile を指定した場合は一覧を読み込む
+func NewPolicy(cfg *config.Config) (*Policy, error) {
+	p := &Policy{
+		MinLength:           cfg.PasswordMinLength,
+		MaxLength:           cfg.PasswordMaxLength,
+		MinCharacterClasses: cfg.PasswordMinCharacterClasses,
+	}
+	if cfg.PasswordHashAlgorithm == AlgorithmBcrypt {
+		p.MaxBytes = bcryptMaxBytes
+	}
+
+	if cfg.PasswordBreachedListFile != "" {
+		breached, err := loadBreachedList(cfg.PasswordBreachedListFile)
+		if err != nil {
+			return nil, err
+		}
+		p.breached = breached
+	}
+
+	return p, nil
+}
+
+// Validate はパスワードが条件を満たすかを確認し、満たしていない条件の説明を返す
+// email・username には利用者のメールアドレスとユーザー名を指定する（それらと同じパスワードは受け付けない）
+func (p *Policy) Validate(password, email, username string) []string {
+	var violations []string
+
+	length := utf8.RuneCountInString(password)
+	if length < p.MinLength {
+		violations = append(violations, fmt.Sprintf("must be at least %d characters", p.MinLength))
+	}
+	if length > p.MaxLength {
+		violations = append(violations, fmt.Sprintf("must be at most %d characters", p.MaxLength))
+	} else if p.MaxBytes > 0 && len(password) > p.MaxBytes {
+		violations = append(violations, fmt.Sprintf("must be at most %d bytes", p.MaxBytes))
+	}
+
+	if characterClasses(password) < p.MinCharacterClasses {
+		violations = append(violations, fmt.Sprintf(
+			"must contain at least %d of the following: lowercase letters, uppercase letters, digits and symbols",
+			p.MinCharacterClasses,
+		))
+	}
+
+	if isPersonalInfo(password, email, username) {
+		violations = append(violations, "must not be the same as your email address or username")
+	}
+
+	if p.isBreached(password) {
+		violations = append(violations, "is too common or has appeared in a data breach")
+	}
+
+	return violations
+}
+
+// isBreached はパスワードが使用を禁止する一覧に含まれるかを返す
+// 大文字・小文字を変えただけのものも禁止する
+func (p *Policy) isBreached(password string) bool {
+	if len(p.breached) == 0 {
+		return false
+	}
+	for _, candidate := range []string{password, strings.ToLower(password)} {
+		if _, ok := p.breached[sha1.Sum([]byte(candidate))]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// characterClasses はパスワードに含まれる文字の種類（英小文字・英大文字・数字・記号）の数を返す
+func characterClasses(password string) int {
+	var lower, upper, digit, symbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsDigit(r):
+			digit = true
+		case unicode.IsPunct(r) || unicode.IsSymbol(r) || unicode.IsSpace(r):
+			symbol = true
+		}
+	}
+
+	count := 0
+	for _, ok := range []bool{lower, upper, digit, symbol} {
+		if ok {
+			count++
+		}
+	}
+	return count
+}
+
+// isPersonalInfo はパスワードがメールアドレス・メールアドレスの@より前・ユーザー名のいずれかと同じかを返す
+func isPersonalInfo(password, email, username string) bool {
+	candidates := []string{email, username}
+	if at := strings.LastIndex(email, "@"); at > 0 {
+		candidates = append(candidates, email[:at])
+	}
+
+	for _, candidate := range candidates {
+		if candidate != "" && strings.EqualFold(strings.TrimSpace(password), strings.TrimSpace(candidate)) {
+			return true
+		}
+	}
+	return false
+}
+
+// loadBreachedList は使用を禁止するパスワードの一覧を読み込む
+// 1行に1つのパスワードを平文で記載するか、SHA-1の16進表記（"ハッシュ:件数" の形式も可）で記載する
+// 空行と # で始まる行は無視する
+func loadBreachedList(path string) (map[[sha1.Size]byte]struct{}, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open breached password list: %w", err)
+	}
+	defer file.Close()
+
+	breached := make(map[[sha1.Size]byte]struct{})
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if strings.TrimSpace(line) == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		if sum, ok := parseSHA1Hex(line); ok {
+			breached[sum] = struct{}{}
+			continue
+		}
+		breached[sha1.Sum([]byte(line))] = struct{}{}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read breached password list: %w", err)
+	}
+
+	return breached, nil
+}
+
+// parseSHA1Hex は一覧の行がSHA-1の16進表記であれば解釈して返す
+func parseSHA1Hex(line string) ([sha1.Size]byte, bool) {
+	var sum [sha1.Size]byte
+
+	value := strings.TrimSpace(strings.SplitN(line, ":", 2)[0])
+	if len(value) != hex.EncodedLen(sha1.Size) {
+		return sum, false
+	}
+	if _, err := hex.Decode(sum[:], []byte(value)); err != nil {
+		return sum, false
+	}
+	return sum, true
+}
@@ -0,0 +1,150 @@
+package password
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/Ryo-cool/guideforge/internal/config"
+)
+
+const classesViolation = "must contain at least 3 of the following: lowercase letters, uppercase letters, digits and symbols"
+
+func writeBreachedList(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "breached.txt")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("failed to write breached list: %v", err)
+	}
+	return path
+}
+
+func TestPolicyValidate(t *testing.T) {
+	p := &Policy{MinLength: 8, MaxLength: 72, MaxBytes: bcryptMaxBytes, MinCharacterClasses: 3}
+
+	tests := []struct {
+		name     string
+		password string
+		want     []string
+	}{
+		{name: "valid", password: "Guide-forge1", want: nil},
+		{name: "too short", password: "Ab1!", want: []string{"must be at least 8 characters"}},
+		{name: "at max length", password: "Aa1" + strings.Repeat("x", 69), want: nil},
+		{name: "too long", password: "Aa1" + strings.Repeat("x", 70), want: []string{"must be at most 72 characters"}},
+		// 文字数は上限以内でも、bcrypt が無視する72バイトを超える部分は受け付けない
+		{name: "over 72 bytes", password: "Aa1" + strings.Repeat("あ", 24), want: []string{"must be at most 72 bytes"}},
+		{name: "too few character classes", password: "manual-steps", want: []string{classesViolation}},
+		{name: "same as username", password: "GuideForge1", want: []string{"must not be the same as your email address or username"}},
+		{name: "same as email local part", password: "Alice.Smith1", want: []string{"must not be the same as your email address or username"}},
+		{
+			name:     "multiple violations",
+			password: "abc",
+			want:     []string{"must be at least 8 characters", classesViolation},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := p.Validate(tt.password, "alice.smith1@example.com", "guideforge1")
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Validate(%q) = %q, want %q", tt.password, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewPolicyMaxBytes(t *testing.T) {
+	tests := []struct {
+		algorithm string
+		want      int
+	}{
+		{algorithm: AlgorithmBcrypt, want: bcryptMaxBytes},
+		{algorithm: AlgorithmArgon2id, want: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.algorithm, func(t *testing.T) {
+			p, err := NewPolicy(&config.Config{PasswordHashAlgorithm: tt.algorithm})
+			if err != nil {
+				t.Fatalf("NewPolicy() error = %v", err)
+			}
+			if p.MaxBytes != tt.want {
+				t.Errorf("MaxBytes = %d, want %d", p.MaxBytes, tt.want)
+			}
+		})
+	}
+}
+
+func TestPolicyBreachedList(t *testing.T) {
+	path := writeBreachedList(t, strings.Join([]string{
+		"# common passwords",
+		"",
+		"letmein-2024",
+		// "Password123!" のSHA-1（件数付き）
+		"49efef5f70d47adc2db2eb397fbef5f7bc560e29:1234",
+		// "qwerty-uiop" のSHA-1
+		"  295562F258DF1B74F44F990C3F054BF6F7D00FD8  ",
+		"#ignored-password",
+	}, "\r\n"))
+
+	p, err := NewPolicy(&config.Config{PasswordBreachedListFile: path})
+	if err != nil {
+		t.Fatalf("NewPolicy() error = %v", err)
+	}
+
+	tests := []struct {
+		password string
+		want     bool
+	}{
+		{password: "letmein-2024", want: true},
+		{password: "LetMeIn-2024", want: true},
+		{password: "Password123!", want: true},
+		{password: "qwerty-uiop", want: true},
+		{password: "ignored-password", want: false},
+		{password: "# common passwords", want: false},
+		{password: "unlisted-password", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.password, func(t *testing.T) {
+			if got := p.isBreached(tt.password); got != tt.want {
+				t.Errorf("isBreached(%q) = %v, want %v", tt.password, got, tt.want)
+			}
+		})
+	}
+
+	if len(p.breached) != 3 {
+		t.Errorf("loaded %d entries, want 3", len(p.breached))
+	}
+}
+
+func TestNewPolicyMissingBreachedList(t *testing.T) {
+	cfg := &config.Config{PasswordBreachedListFile: filepath.Join(t.TempDir(), "missing.txt")}
+	if _, err := NewPolicy(cfg); err == nil {
+		t.Errorf("NewPolicy() error = nil, want an error")
+	}
+}
+
+func TestCharacterClasses(t *testing.T) {
+	tests := []struct {
+		password string
+		want     int
+	}{
+		{password: "", want: 0},
+		{password: "abc", want: 1},
+		{password: "abcABC", want: 2},
+		{password: "abcABC123", want: 3},
+		{password: "abcABC123!", want: 4},
+		{password: "pass phrase", want: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.password, func(t *testing.T) {
+			if got := characterClasses(tt.password); got != tt.want {
+				t.Errorf("characterClasses(%q) = %d, want %d", tt.password, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,108 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+
+	"github.com/Ryo-cool/guideforge/internal/apperror"
+	"github.com/Ryo-cool/guideforge/internal/models"
+	"github.com/jmoiron/sqlx"
+)
+
+// APITokenRepository は個人用APIトークンのデータアクセスを管理する
+type APITokenRepository struct {
+	db *sqlx.DB
+}
+
+// APITokenOwner はAPIトークンと所有するユーザーの認証情報
+type APITokenOwner struct {
+	models.APIToken
+	Email string `db:"email"`
+	Role  string `db:"role"`
+}
+
+// NewAPITokenRepository は新しいAPITokenRepositoryインスタンスを作成
+func NewAPITokenRepository(repo *Repository) *APITokenRepository {
+	return &APITokenRepository{
+		db: repo.GetDB(),
+	}
+}
+
+// CreateTx はトランザクション内で新しいAPIトークンを作成する
+func (r *APITokenRepository) CreateTx(tx *sqlx.Tx, token *models.APIToken) error {
+	query := `
+		INSERT INTO api_tokens (user_id, name, token_hash, token_prefix, scopes, expires_at, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, NOW())
+		RETURNING id, created_at
+	`
+
+	return tx.QueryRowx(query,
+		token.UserID,
+		token.Name,
+		token.TokenHash,
+		token.TokenPrefix,
+		token.Scopes,
+		token.ExpiresAt,
+	).Scan(&token.ID, &token.CreatedAt)
+}
+
+// ListByUserID はユーザーの失効していないAPIトークンを作成日時の新しい順に取得する
+// 期限切れのトークンも再発行の判断に使えるよう含める
+func (r *APITokenRepository) ListByUserID(userID uint) ([]models.APIToken, error) {
+	tokens := []models.APIToken{}
+	query := `
+		SELECT * FROM api_tokens
+		WHERE user_id = $1 AND revoked_at IS NULL
+		ORDER BY created_at DESC
+	`
+
+	if err := r.db.Select(&tokens, query, userID); err != nil {
+		return nil, err
+	}
+	return tokens, nil
+}
+
+// RevokeTx はトランザクション内でユーザーのAPIトークンを失効させる
+func (r *APITokenRepository) RevokeTx(tx *sqlx.Tx, userID, id uint) error {
+	query := `
+		UPDATE api_tokens
+		SET revoked_at = NOW()
+		WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL
+	`
+
+	result, err := tx.Exec(query, id, userID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return apperror.NotFound("API token not found")
+	}
+	return nil
+}
+
+// Authenticate は有効なAPIトークンの最終利用日時を更新し、所有するユーザーの情報と合わせて返す
+// 期限切れ・失効済み・存在しないトークンはすべて同じエラーになる
+func (r *APITokenRepository) Authenticate(tokenHash string) (*APITokenOwner, error) {
+	query := `
+		UPDATE api_tokens t
+		SET last_used_at = NOW()
+		FROM users u
+		WHERE t.token_hash = $1 AND u.id = t.user_id AND t.revoked_at IS NULL AND t.expires_at > NOW()
+		RETURNING t.*, u.email, u.role
+	`
+
+	var owner APITokenOwner
+	if err := r.db.Get(&owner, query, tokenHash); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, apperror.Unauthorized("invalid or expired API token")
+		}
+		return nil, err
+	}
+
+	return &owner, nil
+}
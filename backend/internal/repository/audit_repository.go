@@ -0,0 +1,114 @@
+package repository
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/Ryo-cool/guideforge/internal/models"
+	"github.com/jmoiron/sqlx"
+)
+
+// AuditRepository は監査ログのデータアクセスを管理するインターフェース
+type AuditRepository struct {
+	db *sqlx.DB
+}
+
+// NewAuditRepository は新しいAuditRepositoryインスタンスを作成
+func NewAuditRepository(repo *Repository) *AuditRepository {
+	return &AuditRepository{
+		db: repo.GetDB(),
+	}
+}
+
+// CreateTx はトランザクション内で監査ログを記録する
+// 操作者のメールアドレスは記録時点の値を保存する（削除の場合は削除前に記録する）
+func (r *AuditRepository) CreateTx(tx *sqlx.Tx, event *models.AuditEvent) error {
+	query := `
+		INSERT INTO audit_events (actor_id, actor_email, action, target_type, target_id, before, after, ip_address, user_agent, created_at)
+		VALUES ($1, (SELECT email FROM users WHERE id = $1), $2, $3, $4, $5, $6, $7, $8, NOW())
+		RETURNING id, actor_email, created_at
+	`
+
+	return tx.QueryRowx(query,
+		event.ActorID,
+		event.Action,
+		event.TargetType,
+		event.TargetID,
+		event.Before,
+		event.After,
+		event.IPAddress,
+		event.UserAgent,
+	).Scan(&event.ID, &event.ActorEmail, &event.CreatedAt)
+}
+
+// List は条件に一致する監査ログを新しい順に取得する（ページネーション付き）
+func (r *AuditRepository) List(filter models.AuditEventFilter, page, limit int) ([]models.AuditEvent, int, error) {
+	where, args := auditEventConditions(filter)
+
+	// 総件数の取得
+	var total int
+	if err := r.db.Get(&total, `SELECT COUNT(*) FROM audit_events WHERE `+where, args...); err != nil {
+		return nil, 0, err
+	}
+
+	events := []models.AuditEvent{}
+	offset := (page - 1) * limit
+	query := `
+		SELECT * FROM audit_events
+		WHERE ` + where + `
+		ORDER BY id DESC
+		LIMIT ` + strconv.Itoa(limit) + ` OFFSET ` + strconv.Itoa(offset)
+	if err := r.db.Select(&events, query, args...); err != nil {
+		return nil, 0, err
+	}
+
+	return events, total, nil
+}
+
+// ListForExport は条件に一致する監査ログを古い順に最大 limit 件取得する
+func (r *AuditRepository) ListForExport(filter models.AuditEventFilter, limit int) ([]models.AuditEvent, error) {
+	where, args := auditEventConditions(filter)
+
+	events := []models.AuditEvent{}
+	query := `
+		SELECT * FROM audit_events
+		WHERE ` + where + `
+		ORDER BY id ASC
+		LIMIT ` + strconv.Itoa(limit)
+	if err := r.db.Select(&events, query, args...); err != nil {
+		return nil, err
+	}
+
+	return events, nil
+}
+
+// auditEventConditions は検索条件からWHERE句と引数を作成する
+func auditEventConditions(filter models.AuditEventFilter) (string, []interface{}) {
+	var args []interface{}
+	arg := func(v interface{}) string {
+		args = append(args, v)
+		return "$" + strconv.Itoa(len(args))
+	}
+
+	conditions := []string{"TRUE"}
+	if filter.ActorID != nil {
+		conditions = append(conditions, `actor_id = `+arg(*filter.ActorID))
+	}
+	if filter.Action != "" {
+		conditions = append(conditions, `action = `+arg(filter.Action))
+	}
+	if filter.TargetType != "" {
+		conditions = append(conditions, `target_type = `+arg(filter.TargetType))
+	}
+	if filter.TargetID != nil {
+		conditions = append(conditions, `target_id = `+arg(*filter.TargetID))
+	}
+	if filter.From != nil {
+		conditions = append(conditions, `created_at >= `+arg(*filter.From))
+	}
+	if filter.To != nil {
+		conditions = append(conditions, `created_at < `+arg(*filter.To))
+	}
+
+	return strings.Join(conditions, " AND "), args
+}
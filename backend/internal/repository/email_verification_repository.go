@@ -0,0 +1,71 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+
+	"github.com/Ryo-cool/guideforge/internal/apperror"
+	"github.com/Ryo-cool/guideforge/internal/models"
+	"github.com/jmoiron/sqlx"
+)
+
+// EmailVerificationRepository はメールアドレス確認トークンのデータアクセスを管理するインターフェース
+type EmailVerificationRepository struct {
+	db *sqlx.DB
+}
+
+// NewEmailVerificationRepository は新しいEmailVerificationRepositoryインスタンスを作成
+func NewEmailVerificationRepository(repo *Repository) *EmailVerificationRepository {
+	return &EmailVerificationRepository{
+		db: repo.GetDB(),
+	}
+}
+
+// Create は新しい確認トークンを作成する
+func (r *EmailVerificationRepository) Create(token *models.EmailVerificationToken) error {
+	query := `
+		INSERT INTO email_verification_tokens (user_id, email, token_hash, expires_at, created_at)
+		VALUES ($1, $2, $3, $4, NOW())
+		RETURNING id, created_at
+	`
+
+	return r.db.QueryRowx(query,
+		token.UserID,
+		token.Email,
+		token.TokenHash,
+		token.ExpiresAt,
+	).Scan(&token.ID, &token.CreatedAt)
+}
+
+// Consume は有効なトークンを使用済みにして返す
+// 期限切れ・使用済み・存在しないトークンはすべて同じエラーになる
+func (r *EmailVerificationRepository) Consume(tokenHash string) (*models.EmailVerificationToken, error) {
+	query := `
+		UPDATE email_verification_tokens
+		SET used_at = NOW()
+		WHERE token_hash = $1 AND used_at IS NULL AND expires_at > NOW()
+		RETURNING *
+	`
+
+	var token models.EmailVerificationToken
+	if err := r.db.Get(&token, query, tokenHash); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, apperror.Validation("invalid or expired verification token")
+		}
+		return nil, err
+	}
+
+	return &token, nil
+}
+
+// InvalidateByUserID はユーザーの未使用トークンをすべて無効化する
+func (r *EmailVerificationRepository) InvalidateByUserID(userID uint) error {
+	query := `
+		UPDATE email_verification_tokens
+		SET used_at = NOW()
+		WHERE user_id = $1 AND used_at IS NULL
+	`
+
+	_, err := r.db.Exec(query, userID)
+	return err
+}
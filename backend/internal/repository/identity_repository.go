@@ -0,0 +1,97 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+
+	"github.com/Ryo-cool/guideforge/internal/apperror"
+	"github.com/Ryo-cool/guideforge/internal/models"
+	"github.com/jmoiron/sqlx"
+)
+
+// IdentityRepository は外部IdPのアカウントの紐づけとログイン途中の状態のデータアクセスを管理する
+type IdentityRepository struct {
+	db *sqlx.DB
+}
+
+// NewIdentityRepository は新しいIdentityRepositoryインスタンスを作成
+func NewIdentityRepository(repo *Repository) *IdentityRepository {
+	return &IdentityRepository{
+		db: repo.GetDB(),
+	}
+}
+
+// GetByIssuerSubject はIdP上のアカウントに紐づくユーザーの情報を取得する
+func (r *IdentityRepository) GetByIssuerSubject(issuer, subject string) (*models.UserIdentity, error) {
+	var identity models.UserIdentity
+	query := `SELECT * FROM user_identities WHERE issuer = $1 AND subject = $2`
+
+	if err := r.db.Get(&identity, query, issuer, subject); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, apperror.NotFound("identity not found")
+		}
+		return nil, err
+	}
+	return &identity, nil
+}
+
+// CreateTx はトランザクション内でIdP上のアカウントをユーザーに紐づける
+func (r *IdentityRepository) CreateTx(tx *sqlx.Tx, identity *models.UserIdentity) error {
+	query := `
+		INSERT INTO user_identities (user_id, issuer, subject, email, last_login_at, created_at)
+		VALUES ($1, $2, $3, $4, NOW(), NOW())
+		RETURNING id, last_login_at, created_at
+	`
+
+	return tx.QueryRowx(query,
+		identity.UserID,
+		identity.Issuer,
+		identity.Subject,
+		identity.Email,
+	).Scan(&identity.ID, &identity.LastLoginAt, &identity.CreatedAt)
+}
+
+// UpdateLogin はログイン日時とIdP上のメールアドレスを更新する
+func (r *IdentityRepository) UpdateLogin(id uint, email string) error {
+	query := `UPDATE user_identities SET email = $1, last_login_at = NOW() WHERE id = $2`
+
+	_, err := r.db.Exec(query, email, id)
+	return err
+}
+
+// CreateLoginState は認可リクエストの途中状態を保存する
+// 期限切れの状態は保存のたびに削除する
+func (r *IdentityRepository) CreateLoginState(state *models.OIDCLoginState) error {
+	if _, err := r.db.Exec(`DELETE FROM oidc_login_states WHERE expires_at < NOW()`); err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO oidc_login_states (state_hash, nonce, code_verifier, redirect_path, expires_at, created_at)
+		VALUES ($1, $2, $3, $4, $5, NOW())
+		RETURNING created_at
+	`
+
+	return r.db.QueryRowx(query,
+		state.StateHash,
+		state.Nonce,
+		state.CodeVerifier,
+		state.RedirectPath,
+		state.ExpiresAt,
+	).Scan(&state.CreatedAt)
+}
+
+// ConsumeLoginState は有効な途中状態を削除して返す（同じstateは1回しか使用できない）
+// 期限切れ・使用済み・存在しないstateはすべて同じエラーになる
+func (r *IdentityRepository) ConsumeLoginState(stateHash string) (*models.OIDCLoginState, error) {
+	var state models.OIDCLoginState
+	query := `DELETE FROM oidc_login_states WHERE state_hash = $1 AND expires_at > NOW() RETURNING *`
+
+	if err := r.db.Get(&state, query, stateHash); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, apperror.Unauthorized("invalid or expired login state")
+		}
+		return nil, err
+	}
+	return &state, nil
+}
@@ -0,0 +1,108 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+// ImageFileRepository は画像ファイルの参照カウントを管理する
+// 参照カウントは images・manual_revisions のトリガーで更新される（database/init/08-image-files.sql）
+type ImageFileRepository struct {
+	db *sqlx.DB
+}
+
+// NewImageFileRepository は新しいImageFileRepositoryインスタンスを作成
+func NewImageFileRepository(repo *Repository) *ImageFileRepository {
+	return &ImageFileRepository{
+		db: repo.GetDB(),
+	}
+}
+
+// AcquireTx はファイルの行を作成またはロックし、新しく作成したかを返す
+// トランザクションが終わるまで他のトランザクションはこのファイルを削除できない
+// 新しく作成した場合、呼び出し側は同じトランザクション内でファイルを保存すること
+func (r *ImageFileRepository) AcquireTx(tx *sqlx.Tx, path string) (bool, error) {
+	// ON CONFLICT DO UPDATE で既存の行もロックする（xmax = 0 は新しく挿入された行）
+	query := `
+		INSERT INTO image_files (path, ref_count, created_at, updated_at)
+		VALUES ($1, 0, NOW(), NOW())
+		ON CONFLICT (path) DO UPDATE SET updated_at = NOW()
+		RETURNING (xmax = 0) AS created
+	`
+
+	var created bool
+	if err := tx.Get(&created, query, path); err != nil {
+		return false, err
+	}
+	return created, nil
+}
+
+// ReleaseTx は参照されていないファイルの行を削除し、削除したパスを返す
+// 行のないパス（保存後にロールバックされたファイルなど）も参照されていないものとして扱う
+// 呼び出し側は同じトランザクション内でファイルを削除すること
+func (r *ImageFileRepository) ReleaseTx(tx *sqlx.Tx, paths []string) ([]string, error) {
+	if len(paths) == 0 {
+		return nil, nil
+	}
+
+	// 行のないパスは参照カウント0の行を作成してロックする（同時に保存中のトランザクションがあれば完了を待つ）
+	insertQuery := `
+		INSERT INTO image_files (path, ref_count, created_at, updated_at)
+		SELECT DISTINCT p, 0, NOW(), NOW() FROM unnest($1::text[]) AS p
+		ON CONFLICT (path) DO NOTHING
+	`
+	if _, err := tx.Exec(insertQuery, pq.Array(paths)); err != nil {
+		return nil, err
+	}
+
+	deleted := []string{}
+	deleteQuery := `DELETE FROM image_files WHERE path = ANY($1) AND ref_count <= 0 RETURNING path`
+	if err := tx.Select(&deleted, deleteQuery, pq.Array(paths)); err != nil {
+		return nil, err
+	}
+
+	return deleted, nil
+}
+
+// GetReferencedPaths は画像・改訂履歴・プロフィール画像から参照されているファイルパスと参照元の種類を返す
+func (r *ImageFileRepository) GetReferencedPaths() (map[string]string, error) {
+	var refs []struct {
+		Path   string `db:"path"`
+		Source string `db:"source"`
+	}
+	query := `
+		SELECT DISTINCT ON (path) path, source FROM (
+			SELECT unnest(image_row_paths(file_path, thumbnail_path, medium_path)) AS path, 'image' AS source, 1 AS priority FROM images
+			UNION ALL
+			SELECT unnest(revision_image_paths(steps)), 'revision', 2 FROM manual_revisions
+			UNION ALL
+			SELECT profile_image, 'profile', 3 FROM users WHERE profile_image IS NOT NULL AND profile_image <> ''
+		) refs
+		ORDER BY path, priority
+	`
+	if err := r.db.Select(&refs, query); err != nil {
+		return nil, err
+	}
+
+	paths := make(map[string]string, len(refs))
+	for _, ref := range refs {
+		paths[ref.Path] = ref.Source
+	}
+	return paths, nil
+}
+
+// GetUnreferencedPaths は参照カウントが0のまま指定した期間以上更新されていないファイルパスを返す
+func (r *ImageFileRepository) GetUnreferencedPaths(olderThan time.Duration) ([]string, error) {
+	paths := []string{}
+	query := `
+		SELECT path FROM image_files
+		WHERE ref_count <= 0 AND updated_at < NOW() - make_interval(secs => $1)
+		ORDER BY path
+	`
+	if err := r.db.Select(&paths, query, olderThan.Seconds()); err != nil {
+		return nil, err
+	}
+	return paths, nil
+}
@@ -3,8 +3,8 @@ package repository
 import (
 	"database/sql"
 	"errors"
-	"fmt"
 
+	"github.com/Ryo-cool/guideforge/internal/apperror"
 	"github.com/Ryo-cool/guideforge/internal/models"
 	"github.com/jmoiron/sqlx"
 )
@@ -23,18 +23,32 @@ func NewImageRepository(repo *Repository) *ImageRepository {
 
 // Create は新しい画像を作成する
 func (r *ImageRepository) Create(image *models.Image) error {
+	return createImage(r.db, image)
+}
+
+// CreateTx はトランザクション内で新しい画像を作成する
+func (r *ImageRepository) CreateTx(tx *sqlx.Tx, image *models.Image) error {
+	return createImage(tx, image)
+}
+
+// createImage は画像を作成する（内部メソッド）
+func createImage(q sqlx.Queryer, image *models.Image) error {
 	query := `
-		INSERT INTO images (step_id, file_path, file_name, file_size, mime_type, created_at)
-		VALUES ($1, $2, $3, $4, $5, NOW())
+		INSERT INTO images (step_id, file_path, file_name, file_size, mime_type, width, height, thumbnail_path, medium_path, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, NOW())
 		RETURNING id, created_at
 	`
 
-	return r.db.QueryRowx(query,
+	return q.QueryRowx(query,
 		image.StepID,
 		image.FilePath,
 		image.FileName,
 		image.FileSize,
 		image.MimeType,
+		image.Width,
+		image.Height,
+		image.ThumbnailPath,
+		image.MediumPath,
 	).Scan(&image.ID, &image.CreatedAt)
 }
 
@@ -46,7 +60,7 @@ func (r *ImageRepository) GetByID(id uint) (*models.Image, error) {
 	err := r.db.Get(&image, query, id)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
-			return nil, fmt.Errorf("image not found: %w", err)
+			return nil, apperror.NotFound("image not found")
 		}
 		return nil, err
 	}
@@ -67,27 +81,26 @@ func (r *ImageRepository) GetImagesByStepID(stepID uint) ([]models.Image, error)
 	return images, nil
 }
 
-// Delete は画像を削除する（ユーザー所有権を確認）
-func (r *ImageRepository) Delete(id uint, userID uint) error {
-	// 画像が特定のユーザーに属しているか確認
+// DeleteTx はトランザクション内で画像を削除する（ユーザーの編集権限を確認）
+func (r *ImageRepository) DeleteTx(tx *sqlx.Tx, id uint, userID uint) error {
+	// 画像が特定のユーザーの編集可能なマニュアルに属しているか確認
 	checkQuery := `
 		SELECT 1 FROM images i
 		JOIN steps s ON i.step_id = s.id
 		JOIN manuals m ON s.manual_id = m.id
-		WHERE i.id = $1 AND m.user_id = $2
-	`
+		WHERE i.id = $1 AND ` + manualEditableBy
 	var exists bool
-	err := r.db.Get(&exists, checkQuery, id, userID)
+	err := tx.Get(&exists, checkQuery, id, userID)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
-			return fmt.Errorf("image not found or not owned by user")
+			return apperror.NotFound("image not found or not owned by user")
 		}
 		return err
 	}
 
 	// データベースから画像を削除
 	query := `DELETE FROM images WHERE id = $1`
-	if _, err := r.db.Exec(query, id); err != nil {
+	if _, err := tx.Exec(query, id); err != nil {
 		return err
 	}
 
@@ -102,28 +115,10 @@ func (r *ImageRepository) GetFilePath(id uint) (string, error) {
 	err := r.db.Get(&filePath, query, id)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
-			return "", fmt.Errorf("image not found: %w", err)
+			return "", apperror.NotFound("image not found")
 		}
 		return "", err
 	}
 
 	return filePath, nil
 }
-
-// IsOwnedByUser は画像が特定のユーザーに所有されているかを確認する
-func (r *ImageRepository) IsOwnedByUser(id uint, userID uint) (bool, error) {
-	query := `
-		SELECT COUNT(*) FROM images i
-		JOIN steps s ON i.step_id = s.id
-		JOIN manuals m ON s.manual_id = m.id
-		WHERE i.id = $1 AND m.user_id = $2
-	`
-
-	var count int
-	err := r.db.Get(&count, query, id, userID)
-	if err != nil {
-		return false, err
-	}
-
-	return count > 0, nil
-}
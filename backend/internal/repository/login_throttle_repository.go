@@ -0,0 +1,104 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/Ryo-cool/guideforge/internal/models"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+// LoginThrottleRepository はログイン失敗の記録のデータアクセスを管理する
+type LoginThrottleRepository struct {
+	db *sqlx.DB
+}
+
+// NewLoginThrottleRepository は新しいLoginThrottleRepositoryインスタンスを作成
+func NewLoginThrottleRepository(repo *Repository) *LoginThrottleRepository {
+	return &LoginThrottleRepository{
+		db: repo.GetDB(),
+	}
+}
+
+// LockedFor はいずれかのキーがロックされている場合に、ロックが解除されるまでの時間を返す
+// ロックされていない場合は0を返す
+func (r *LoginThrottleRepository) LockedFor(keys ...string) (time.Duration, error) {
+	query := `
+		SELECT COALESCE(MAX(EXTRACT(EPOCH FROM locked_until - NOW())), 0)
+		FROM login_throttles
+		WHERE key = ANY($1) AND locked_until > NOW()
+	`
+
+	var seconds float64
+	if err := r.db.Get(&seconds, query, pq.Array(keys)); err != nil {
+		return 0, err
+	}
+	return time.Duration(seconds * float64(time.Second)), nil
+}
+
+// RecordFailure はログインの失敗を記録し、更新後の記録を返す
+// 最後の失敗から window 以上経過している場合は失敗回数を数え直す
+func (r *LoginThrottleRepository) RecordFailure(key string, window time.Duration) (*models.LoginThrottle, error) {
+	query := `
+		INSERT INTO login_throttles (key, failures, last_failure_at)
+		VALUES ($1, 1, NOW())
+		ON CONFLICT (key) DO UPDATE
+		SET failures = CASE
+				WHEN login_throttles.last_failure_at < NOW() - make_interval(secs => $2) THEN 1
+				ELSE login_throttles.failures + 1
+			END,
+			locked_until = NULL,
+			last_failure_at = NOW()
+		RETURNING *
+	`
+
+	var throttle models.LoginThrottle
+	if err := r.db.Get(&throttle, query, key, window.Seconds()); err != nil {
+		return nil, err
+	}
+	return &throttle, nil
+}
+
+// Lock は指定した時間だけログインを拒否するよう設定する
+func (r *LoginThrottleRepository) Lock(key string, duration time.Duration) error {
+	query := `UPDATE login_throttles SET locked_until = NOW() + make_interval(secs => $2) WHERE key = $1`
+
+	_, err := r.db.Exec(query, key, duration.Seconds())
+	return err
+}
+
+// Delete はログイン失敗の記録を削除し、削除したかを返す
+func (r *LoginThrottleRepository) Delete(key string) (bool, error) {
+	return deleteLoginThrottle(r.db, key)
+}
+
+// DeleteTx はトランザクション内でログイン失敗の記録を削除し、削除したかを返す
+func (r *LoginThrottleRepository) DeleteTx(tx *sqlx.Tx, key string) (bool, error) {
+	return deleteLoginThrottle(tx, key)
+}
+
+// deleteLoginThrottle はログイン失敗の記録を削除する（内部メソッド）
+func deleteLoginThrottle(e sqlx.Execer, key string) (bool, error) {
+	result, err := e.Exec(`DELETE FROM login_throttles WHERE key = $1`, key)
+	if err != nil {
+		return false, err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return rowsAffected > 0, nil
+}
+
+// DeleteStale は最後の失敗から olderThan 以上経過し、ロックも解除された記録を削除する
+func (r *LoginThrottleRepository) DeleteStale(olderThan time.Duration) error {
+	query := `
+		DELETE FROM login_throttles
+		WHERE last_failure_at < NOW() - make_interval(secs => $1)
+			AND (locked_until IS NULL OR locked_until < NOW())
+	`
+
+	_, err := r.db.Exec(query, olderThan.Seconds())
+	return err
+}
@@ -3,8 +3,8 @@ package repository
 import (
 	"database/sql"
 	"errors"
-	"fmt"
 
+	"github.com/Ryo-cool/guideforge/internal/apperror"
 	"github.com/Ryo-cool/guideforge/internal/models"
 	"github.com/jmoiron/sqlx"
 )
@@ -23,17 +23,28 @@ func NewManualRepository(repo *Repository) *ManualRepository {
 
 // Create は新しいマニュアルを作成する
 func (r *ManualRepository) Create(manual *models.Manual) error {
+	return createManual(r.db, manual)
+}
+
+// CreateTx はトランザクション内で新しいマニュアルを作成する
+func (r *ManualRepository) CreateTx(tx *sqlx.Tx, manual *models.Manual) error {
+	return createManual(tx, manual)
+}
+
+// createManual はマニュアルを作成する（内部メソッド）
+func createManual(q sqlx.Queryer, manual *models.Manual) error {
 	query := `
-		INSERT INTO manuals (title, description, category, user_id, is_public, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, NOW(), NOW())
+		INSERT INTO manuals (title, description, category, user_id, workspace_id, is_public, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, NOW(), NOW())
 		RETURNING id, created_at, updated_at
 	`
 
-	return r.db.QueryRowx(query,
+	return q.QueryRowx(query,
 		manual.Title,
 		manual.Description,
 		manual.Category,
 		manual.UserID,
+		manual.WorkspaceID,
 		manual.IsPublic,
 	).Scan(&manual.ID, &manual.CreatedAt, &manual.UpdatedAt)
 }
@@ -46,7 +57,7 @@ func (r *ManualRepository) GetByID(id uint) (*models.Manual, error) {
 	err := r.db.Get(&manual, query, id)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
-			return nil, fmt.Errorf("manual not found: %w", err)
+			return nil, apperror.NotFound("manual not found")
 		}
 		return nil, err
 	}
@@ -106,13 +117,17 @@ func (r *ManualRepository) getImagesByStepID(stepID uint) ([]models.Image, error
 	return images, nil
 }
 
-// GetAllByUserID はユーザーIDから全てのマニュアルを取得する
+// GetAllByUserID はユーザーが作成したマニュアルと所属ワークスペースのマニュアルを取得する
 func (r *ManualRepository) GetAllByUserID(userID uint, page, limit int) ([]models.Manual, int, error) {
 	var manuals []models.Manual
 	var total int
 
 	// 合計件数の取得
-	countQuery := `SELECT COUNT(*) FROM manuals WHERE user_id = $1`
+	countQuery := `
+		SELECT COUNT(*) FROM manuals m
+		WHERE (m.user_id = $1 AND ` + manualCreatorTwoFactorSatisfied + `)
+		OR m.workspace_id IN (` + accessibleWorkspaceIDsQuery + `)
+	`
 	if err := r.db.Get(&total, countQuery, userID); err != nil {
 		return nil, 0, err
 	}
@@ -122,9 +137,10 @@ func (r *ManualRepository) GetAllByUserID(userID uint, page, limit int) ([]model
 
 	// データの取得
 	query := `
-		SELECT * FROM manuals 
-		WHERE user_id = $1
-		ORDER BY updated_at DESC
+		SELECT m.* FROM manuals m
+		WHERE (m.user_id = $1 AND ` + manualCreatorTwoFactorSatisfied + `)
+		OR m.workspace_id IN (` + accessibleWorkspaceIDsQuery + `)
+		ORDER BY m.updated_at DESC
 		LIMIT $2 OFFSET $3
 	`
 
@@ -135,6 +151,35 @@ func (r *ManualRepository) GetAllByUserID(userID uint, page, limit int) ([]model
 	return manuals, total, nil
 }
 
+// GetAllByWorkspaceID はワークスペースに属するマニュアルを取得する
+func (r *ManualRepository) GetAllByWorkspaceID(workspaceID uint, page, limit int) ([]models.Manual, int, error) {
+	var manuals []models.Manual
+	var total int
+
+	// 合計件数の取得
+	countQuery := `SELECT COUNT(*) FROM manuals WHERE workspace_id = $1`
+	if err := r.db.Get(&total, countQuery, workspaceID); err != nil {
+		return nil, 0, err
+	}
+
+	// オフセットの計算
+	offset := (page - 1) * limit
+
+	// データの取得
+	query := `
+		SELECT * FROM manuals
+		WHERE workspace_id = $1
+		ORDER BY updated_at DESC
+		LIMIT $2 OFFSET $3
+	`
+
+	if err := r.db.Select(&manuals, query, workspaceID, limit, offset); err != nil {
+		return nil, 0, err
+	}
+
+	return manuals, total, nil
+}
+
 // GetPublicManuals は公開マニュアルを取得する
 func (r *ManualRepository) GetPublicManuals(page, limit int) ([]models.Manual, int, error) {
 	var manuals []models.Manual
@@ -164,44 +209,39 @@ func (r *ManualRepository) GetPublicManuals(page, limit int) ([]models.Manual, i
 	return manuals, total, nil
 }
 
-// Update はマニュアル情報を更新する
-func (r *ManualRepository) Update(manual *models.Manual) error {
+// UpdateTx はトランザクション内でマニュアル情報を更新する
+func (r *ManualRepository) UpdateTx(tx *sqlx.Tx, manual *models.Manual) error {
 	query := `
 		UPDATE manuals
-		SET title = $1, description = $2, category = $3, is_public = $4, updated_at = NOW()
-		WHERE id = $5 AND user_id = $6
+		SET title = $1, description = $2, category = $3, is_public = $4, workspace_id = $5, updated_at = NOW()
+		WHERE id = $6 AND user_id = $7
 		RETURNING updated_at
 	`
 
-	result, err := r.db.Exec(query,
+	err := tx.QueryRowx(query,
 		manual.Title,
 		manual.Description,
 		manual.Category,
 		manual.IsPublic,
+		manual.WorkspaceID,
 		manual.ID,
 		manual.UserID,
-	)
-	if err != nil {
-		return err
-	}
-
-	rowsAffected, err := result.RowsAffected()
+	).Scan(&manual.UpdatedAt)
 	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return apperror.NotFound("manual not found or not owned by user")
+		}
 		return err
 	}
 
-	if rowsAffected == 0 {
-		return fmt.Errorf("manual not found or not owned by user")
-	}
-
 	return nil
 }
 
-// Delete はマニュアルを削除する
-func (r *ManualRepository) Delete(id, userID uint) error {
-	query := `DELETE FROM manuals WHERE id = $1 AND user_id = $2`
+// DeleteTx はトランザクション内でマニュアルを削除する
+func (r *ManualRepository) DeleteTx(tx *sqlx.Tx, id, userID uint) error {
+	query := `DELETE FROM manuals m WHERE m.id = $1 AND ` + manualEditableBy
 
-	result, err := r.db.Exec(query, id, userID)
+	result, err := tx.Exec(query, id, userID)
 	if err != nil {
 		return err
 	}
@@ -212,7 +252,7 @@ func (r *ManualRepository) Delete(id, userID uint) error {
 	}
 
 	if rowsAffected == 0 {
-		return fmt.Errorf("manual not found or not owned by user")
+		return apperror.NotFound("manual not found or not owned by user")
 	}
 
 	return nil
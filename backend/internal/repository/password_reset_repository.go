@@ -0,0 +1,88 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+
+	"github.com/Ryo-cool/guideforge/internal/apperror"
+	"github.com/Ryo-cool/guideforge/internal/models"
+	"github.com/jmoiron/sqlx"
+)
+
+// PasswordResetRepository はパスワードリセットトークンのデータアクセスを管理するインターフェース
+type PasswordResetRepository struct {
+	db *sqlx.DB
+}
+
+// NewPasswordResetRepository は新しいPasswordResetRepositoryインスタンスを作成
+func NewPasswordResetRepository(repo *Repository) *PasswordResetRepository {
+	return &PasswordResetRepository{
+		db: repo.GetDB(),
+	}
+}
+
+// Create は新しいリセットトークンを作成する
+func (r *PasswordResetRepository) Create(token *models.PasswordResetToken) error {
+	query := `
+		INSERT INTO password_reset_tokens (user_id, token_hash, expires_at, created_at)
+		VALUES ($1, $2, $3, NOW())
+		RETURNING id, created_at
+	`
+
+	return r.db.QueryRowx(query,
+		token.UserID,
+		token.TokenHash,
+		token.ExpiresAt,
+	).Scan(&token.ID, &token.CreatedAt)
+}
+
+// GetValidUserID は有効なトークンの対象ユーザーIDを使用済みにせずに返す
+func (r *PasswordResetRepository) GetValidUserID(tokenHash string) (uint, error) {
+	query := `
+		SELECT user_id FROM password_reset_tokens
+		WHERE token_hash = $1 AND used_at IS NULL AND expires_at > NOW()
+	`
+
+	var userID uint
+	if err := r.db.Get(&userID, query, tokenHash); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, apperror.Validation("invalid or expired reset token")
+		}
+		return 0, err
+	}
+
+	return userID, nil
+}
+
+// Consume は有効なトークンを使用済みにし、対象ユーザーIDを返す
+// 期限切れ・使用済み・存在しないトークンはすべて同じエラーになる
+func (r *PasswordResetRepository) Consume(tokenHash string) (uint, error) {
+	query := `
+		UPDATE password_reset_tokens
+		SET used_at = NOW()
+		WHERE token_hash = $1 AND used_at IS NULL AND expires_at > NOW()
+		RETURNING user_id
+	`
+
+	var userID uint
+	if err := r.db.Get(&userID, query, tokenHash); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, apperror.Validation("invalid or expired reset token")
+		}
+		return 0, err
+	}
+
+	return userID, nil
+}
+
+// InvalidateByUserID はユーザーの未使用トークンをすべて無効化する
+func (r *PasswordResetRepository) InvalidateByUserID(userID uint) error {
+	query := `
+		UPDATE password_reset_tokens
+		SET used_at = NOW()
+		WHERE user_id = $1 AND used_at IS NULL
+	`
+
+	_, err := r.db.Exec(query, userID)
+	return err
+}
@@ -1,6 +1,7 @@
 package repository
 
 import (
+	"context"
 	"fmt"
 
 	"github.com/Ryo-cool/guideforge/internal/config"
@@ -72,3 +73,27 @@ func (r *Repository) Transaction(fn func(*sqlx.Tx) error) error {
 
 	return tx.Commit()
 }
+
+// WithAdvisoryLock はPostgreSQLのアドバイザリーロックを取得できた場合のみfnを実行し、実行したかを返す
+// 複数のAPIサーバーで同じバックグラウンド処理が同時に実行されないようにするために使用する
+func (r *Repository) WithAdvisoryLock(key int64, fn func() error) (bool, error) {
+	ctx := context.Background()
+
+	// セッション単位のロックのため、同じ接続で取得と解放を行う
+	conn, err := r.db.Connx(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close()
+
+	var locked bool
+	if err := conn.GetContext(ctx, &locked, `SELECT pg_try_advisory_lock($1)`, key); err != nil {
+		return false, err
+	}
+	if !locked {
+		return false, nil
+	}
+	defer conn.ExecContext(ctx, `SELECT pg_advisory_unlock($1)`, key)
+
+	return true, fn()
+}
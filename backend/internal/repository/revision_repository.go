@@ -0,0 +1,295 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+
+	"github.com/Ryo-cool/guideforge/internal/apperror"
+	"github.com/Ryo-cool/guideforge/internal/models"
+	"github.com/jmoiron/sqlx"
+)
+
+// RevisionRepository はマニュアル改訂履歴のデータアクセスを管理するインターフェース
+type RevisionRepository struct {
+	db *sqlx.DB
+}
+
+// NewRevisionRepository は新しいRevisionRepositoryインスタンスを作成
+func NewRevisionRepository(repo *Repository) *RevisionRepository {
+	return &RevisionRepository{
+		db: repo.GetDB(),
+	}
+}
+
+// CreateTx はトランザクション内でマニュアルの現在の状態をスナップショットとして新しい改訂履歴に保存する
+func (r *RevisionRepository) CreateTx(tx *sqlx.Tx, manualID, userID uint, summary string) (*models.ManualRevision, error) {
+	// 改訂番号の採番が競合しないようマニュアル行をロックする
+	var manual models.Manual
+	if err := tx.Get(&manual, `SELECT * FROM manuals WHERE id = $1 FOR UPDATE`, manualID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, apperror.NotFound("manual not found")
+		}
+		return nil, err
+	}
+
+	steps, err := snapshotSteps(tx, manualID)
+	if err != nil {
+		return nil, err
+	}
+
+	revision := &models.ManualRevision{
+		ManualID:    manualID,
+		Title:       manual.Title,
+		Description: manual.Description,
+		Category:    manual.Category,
+		IsPublic:    manual.IsPublic,
+		Steps:       steps,
+		Summary:     summary,
+		CreatedBy:   &userID,
+	}
+
+	query := `
+		INSERT INTO manual_revisions (manual_id, revision_number, title, description, category, is_public, steps, summary, created_by, created_at)
+		VALUES ($1, (SELECT COALESCE(MAX(revision_number), 0) + 1 FROM manual_revisions WHERE manual_id = $1), $2, $3, $4, $5, $6, $7, $8, NOW())
+		RETURNING id, revision_number, created_at
+	`
+
+	err = tx.QueryRowx(query,
+		revision.ManualID,
+		revision.Title,
+		revision.Description,
+		revision.Category,
+		revision.IsPublic,
+		revision.Steps,
+		revision.Summary,
+		userID,
+	).Scan(&revision.ID, &revision.RevisionNumber, &revision.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	return revision, nil
+}
+
+// snapshotSteps はマニュアルの手順と画像参照を順序通りに取得する（内部メソッド）
+func snapshotSteps(q sqlx.Queryer, manualID uint) (models.RevisionSteps, error) {
+	var steps []models.Step
+	if err := sqlx.Select(q, &steps, `SELECT * FROM steps WHERE manual_id = $1 ORDER BY order_number ASC, id ASC`, manualID); err != nil {
+		return nil, err
+	}
+
+	var images []models.Image
+	imageQuery := `
+		SELECT i.* FROM images i
+		JOIN steps s ON i.step_id = s.id
+		WHERE s.manual_id = $1
+		ORDER BY i.id ASC
+	`
+	if err := sqlx.Select(q, &images, imageQuery, manualID); err != nil {
+		return nil, err
+	}
+
+	imagesByStep := make(map[uint][]models.RevisionImage)
+	for _, image := range images {
+		imagesByStep[image.StepID] = append(imagesByStep[image.StepID], models.RevisionImage{
+			ImageID:       image.ID,
+			FilePath:      image.FilePath,
+			FileName:      image.FileName,
+			FileSize:      image.FileSize,
+			MimeType:      image.MimeType,
+			Width:         image.Width,
+			Height:        image.Height,
+			ThumbnailPath: image.ThumbnailPath,
+			MediumPath:    image.MediumPath,
+		})
+	}
+
+	snapshot := make(models.RevisionSteps, 0, len(steps))
+	for _, step := range steps {
+		snapshot = append(snapshot, models.RevisionStep{
+			StepID:      step.ID,
+			OrderNumber: step.OrderNumber,
+			Title:       step.Title,
+			Content:     step.Content,
+			Images:      imagesByStep[step.ID],
+		})
+	}
+
+	return snapshot, nil
+}
+
+// GetAllByManualID はマニュアルの改訂履歴一覧を新しい順に取得する（手順スナップショットは含まない）
+func (r *RevisionRepository) GetAllByManualID(manualID uint) ([]models.ManualRevision, error) {
+	revisions := []models.ManualRevision{}
+	query := `
+		SELECT id, manual_id, revision_number, title, description, category, is_public, summary, created_by, created_at
+		FROM manual_revisions
+		WHERE manual_id = $1
+		ORDER BY revision_number DESC
+	`
+
+	if err := r.db.Select(&revisions, query, manualID); err != nil {
+		return nil, err
+	}
+
+	return revisions, nil
+}
+
+// GetByNumber は改訂番号から改訂履歴を取得する
+func (r *RevisionRepository) GetByNumber(manualID uint, revisionNumber int) (*models.ManualRevision, error) {
+	var revision models.ManualRevision
+	query := `SELECT * FROM manual_revisions WHERE manual_id = $1 AND revision_number = $2`
+
+	err := r.db.Get(&revision, query, manualID, revisionNumber)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, apperror.NotFound("revision not found")
+		}
+		return nil, err
+	}
+
+	return &revision, nil
+}
+
+// RestoreTx はトランザクション内でマニュアルと手順を改訂履歴の状態に戻す
+// 画像はimageExistsがtrueを返す（ファイルが残っている）参照のみ復元する
+func (r *RevisionRepository) RestoreTx(tx *sqlx.Tx, revision *models.ManualRevision, imageExists func(filePath string) bool) error {
+	// マニュアル本体の復元
+	manualQuery := `
+		UPDATE manuals
+		SET title = $1, description = $2, category = $3, is_public = $4, updated_at = NOW()
+		WHERE id = $5
+	`
+	if _, err := tx.Exec(manualQuery,
+		revision.Title,
+		revision.Description,
+		revision.Category,
+		revision.IsPublic,
+		revision.ManualID,
+	); err != nil {
+		return err
+	}
+
+	// 現在の手順IDを取得
+	var currentStepIDs []uint
+	if err := tx.Select(&currentStepIDs, `SELECT id FROM steps WHERE manual_id = $1`, revision.ManualID); err != nil {
+		return err
+	}
+	current := make(map[uint]bool, len(currentStepIDs))
+	for _, id := range currentStepIDs {
+		current[id] = true
+	}
+
+	// スナップショットに含まれない手順を削除
+	keep := make(map[uint]bool, len(revision.Steps))
+	for _, step := range revision.Steps {
+		if current[step.StepID] {
+			keep[step.StepID] = true
+		}
+	}
+	for _, id := range currentStepIDs {
+		if !keep[id] {
+			if _, err := tx.Exec(`DELETE FROM steps WHERE id = $1`, id); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, snapshot := range revision.Steps {
+		stepID := snapshot.StepID
+		if keep[stepID] {
+			// 既存の手順を更新
+			updateQuery := `
+				UPDATE steps
+				SET order_number = $1, title = $2, content = $3, updated_at = NOW()
+				WHERE id = $4
+			`
+			if _, err := tx.Exec(updateQuery, snapshot.OrderNumber, snapshot.Title, snapshot.Content, stepID); err != nil {
+				return err
+			}
+		} else {
+			// 削除済みの手順は新規作成する
+			insertQuery := `
+				INSERT INTO steps (manual_id, order_number, title, content, created_at, updated_at)
+				VALUES ($1, $2, $3, $4, NOW(), NOW())
+				RETURNING id
+			`
+			if err := tx.Get(&stepID, insertQuery, revision.ManualID, snapshot.OrderNumber, snapshot.Title, snapshot.Content); err != nil {
+				return err
+			}
+		}
+
+		if err := restoreStepImages(tx, stepID, snapshot.Images, imageExists); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// restoreStepImages は手順の画像行をスナップショットの状態に合わせる（内部メソッド）
+func restoreStepImages(tx *sqlx.Tx, stepID uint, snapshots []models.RevisionImage, imageExists func(filePath string) bool) error {
+	var currentImageIDs []uint
+	if err := tx.Select(&currentImageIDs, `SELECT id FROM images WHERE step_id = $1`, stepID); err != nil {
+		return err
+	}
+	current := make(map[uint]bool, len(currentImageIDs))
+	for _, id := range currentImageIDs {
+		current[id] = true
+	}
+
+	wanted := make(map[uint]bool, len(snapshots))
+	for _, image := range snapshots {
+		wanted[image.ImageID] = true
+	}
+
+	// スナップショットに含まれない画像行を削除（ファイルは他の履歴が参照し得るため残す）
+	for _, id := range currentImageIDs {
+		if !wanted[id] {
+			if _, err := tx.Exec(`DELETE FROM images WHERE id = $1`, id); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, image := range snapshots {
+		if current[image.ImageID] {
+			continue
+		}
+
+		// ファイルが既に削除されている画像は復元できない
+		if !imageExists(image.FilePath) {
+			continue
+		}
+
+		insertQuery := `
+			INSERT INTO images (step_id, file_path, file_name, file_size, mime_type, width, height, thumbnail_path, medium_path, created_at)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, NOW())
+		`
+		if _, err := tx.Exec(insertQuery, stepID, image.FilePath, image.FileName, image.FileSize, image.MimeType,
+			image.Width, image.Height, image.ThumbnailPath, image.MediumPath); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// GetImagePathsByManualID は改訂履歴が参照している画像ファイルパス（派生画像を含む）を重複なく取得する
+func (r *RevisionRepository) GetImagePathsByManualID(manualID uint) ([]string, error) {
+	paths := []string{}
+	query := `
+		SELECT DISTINCT p.path
+		FROM manual_revisions r,
+			jsonb_array_elements(r.steps) AS step,
+			jsonb_array_elements(COALESCE(step->'images', '[]'::jsonb)) AS image,
+			unnest(ARRAY[image->>'file_path', image->>'thumbnail_path', image->>'medium_path']) AS p(path)
+		WHERE r.manual_id = $1 AND p.path IS NOT NULL AND p.path <> ''
+	`
+
+	if err := r.db.Select(&paths, query, manualID); err != nil {
+		return nil, err
+	}
+
+	return paths, nil
+}
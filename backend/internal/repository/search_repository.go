@@ -0,0 +1,141 @@
+package repository
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/Ryo-cool/guideforge/internal/models"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+// 全文検索の対象となる文書の式
+// インデックス（database/init/06-search.sql）と同じ式でなければインデックスが使われない
+const (
+	manualSearchDocument = `(setweight(to_tsvector('simple', coalesce(m.title, '')), 'A') || setweight(to_tsvector('simple', coalesce(m.description, '')), 'B'))`
+	stepSearchDocument   = `(setweight(to_tsvector('simple', coalesce(s.title, '')), 'C') || setweight(to_tsvector('simple', coalesce(s.content, '')), 'D'))`
+)
+
+// SearchRepository はマニュアル検索のデータアクセスを管理するインターフェース
+type SearchRepository struct {
+	db *sqlx.DB
+}
+
+// NewSearchRepository は新しいSearchRepositoryインスタンスを作成
+func NewSearchRepository(repo *Repository) *SearchRepository {
+	return &SearchRepository{
+		db: repo.GetDB(),
+	}
+}
+
+// SearchManuals はユーザーが閲覧できるマニュアルを全文検索し、関連度順に取得する
+// 全文検索（websearch構文）に一致するか、すべての検索語が部分一致するマニュアルを対象とする
+func (r *SearchRepository) SearchManuals(userID uint, params models.ManualSearchParams, terms []string) ([]models.ManualSearchResult, int, error) {
+	args := []interface{}{userID, params.Query}
+	arg := func(v interface{}) string {
+		args = append(args, v)
+		return "$" + strconv.Itoa(len(args))
+	}
+
+	// 閲覧権限（公開・作成者本人・所属ワークスペース）
+	conditions := []string{`(m.is_public OR (m.user_id = $1 AND ` + manualCreatorTwoFactorSatisfied + `) OR m.workspace_id IN (` + accessibleWorkspaceIDsQuery + `))`}
+
+	// 検索語の一致条件
+	termConditions := make([]string, 0, len(terms))
+	for _, term := range terms {
+		p := arg(likePattern(term))
+		termConditions = append(termConditions, `(m.title ILIKE `+p+` OR m.description ILIKE `+p+` OR EXISTS (
+			SELECT 1 FROM steps s WHERE s.manual_id = m.id AND (s.title ILIKE `+p+` OR s.content ILIKE `+p+`)
+		))`)
+	}
+	match := `(` + manualSearchDocument + ` @@ websearch_to_tsquery('simple', $2) OR EXISTS (
+		SELECT 1 FROM steps s WHERE s.manual_id = m.id AND ` + stepSearchDocument + ` @@ websearch_to_tsquery('simple', $2)
+	)`
+	if len(termConditions) > 0 {
+		match += ` OR (` + strings.Join(termConditions, " AND ") + `)`
+	}
+	conditions = append(conditions, match+`)`)
+
+	// 絞り込み
+	if params.Category != "" {
+		conditions = append(conditions, `m.category = `+arg(params.Category))
+	}
+	if params.OwnerID != nil {
+		conditions = append(conditions, `m.user_id = `+arg(*params.OwnerID))
+	}
+	if params.WorkspaceID != nil {
+		conditions = append(conditions, `m.workspace_id = `+arg(*params.WorkspaceID))
+	}
+	switch params.Visibility {
+	case "public":
+		conditions = append(conditions, `m.is_public = true`)
+	case "private":
+		conditions = append(conditions, `m.is_public = false`)
+	}
+
+	where := strings.Join(conditions, " AND ")
+
+	// 合計件数の取得
+	var total int
+	if err := r.db.Get(&total, `SELECT COUNT(*) FROM manuals m WHERE `+where, args...); err != nil {
+		return nil, 0, err
+	}
+
+	// 関連度: 全文検索のランク（マニュアル + 最も一致した手順）と、タイトルとのトライグラム類似度
+	offset := (params.Page - 1) * params.Limit
+	query := `
+		SELECT m.*,
+			ts_rank(` + manualSearchDocument + `, websearch_to_tsquery('simple', $2))
+			+ COALESCE((
+				SELECT MAX(ts_rank(` + stepSearchDocument + `, websearch_to_tsquery('simple', $2)))
+				FROM steps s WHERE s.manual_id = m.id
+			), 0)
+			+ word_similarity($2, m.title) AS rank
+		FROM manuals m
+		WHERE ` + where + `
+		ORDER BY rank DESC, m.updated_at DESC
+		LIMIT ` + arg(params.Limit) + ` OFFSET ` + arg(offset)
+
+	results := []models.ManualSearchResult{}
+	if err := r.db.Select(&results, query, args...); err != nil {
+		return nil, 0, err
+	}
+
+	return results, total, nil
+}
+
+// GetMatchingSteps は指定マニュアルの手順のうち検索に一致するものを取得する（抜粋の作成用）
+func (r *SearchRepository) GetMatchingSteps(manualIDs []uint, query string, terms []string) ([]models.Step, error) {
+	steps := []models.Step{}
+	if len(manualIDs) == 0 {
+		return steps, nil
+	}
+
+	ids := make([]int64, len(manualIDs))
+	for i, id := range manualIDs {
+		ids[i] = int64(id)
+	}
+	patterns := make([]string, len(terms))
+	for i, term := range terms {
+		patterns[i] = likePattern(term)
+	}
+
+	stepQuery := `
+		SELECT s.* FROM steps s
+		WHERE s.manual_id = ANY($1)
+		AND (` + stepSearchDocument + ` @@ websearch_to_tsquery('simple', $2)
+			OR s.title ILIKE ANY($3) OR s.content ILIKE ANY($3))
+		ORDER BY s.manual_id ASC, s.order_number ASC
+	`
+	if err := r.db.Select(&steps, stepQuery, pq.Array(ids), query, pq.Array(patterns)); err != nil {
+		return nil, err
+	}
+
+	return steps, nil
+}
+
+// likePattern は検索語をLIKE演算子の部分一致パターンに変換する（ワイルドカードはエスケープする）
+func likePattern(term string) string {
+	escaped := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(term)
+	return "%" + escaped + "%"
+}
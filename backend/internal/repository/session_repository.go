@@ -0,0 +1,158 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/Ryo-cool/guideforge/internal/apperror"
+	"github.com/Ryo-cool/guideforge/internal/models"
+	"github.com/jmoiron/sqlx"
+)
+
+// SessionRepository はログインセッションのデータアクセスを管理する
+type SessionRepository struct {
+	db *sqlx.DB
+}
+
+// NewSessionRepository は新しいSessionRepositoryインスタンスを作成
+func NewSessionRepository(repo *Repository) *SessionRepository {
+	return &SessionRepository{
+		db: repo.GetDB(),
+	}
+}
+
+// Create は新しいセッションを作成する
+func (r *SessionRepository) Create(session *models.UserSession) error {
+	query := `
+		INSERT INTO user_sessions (id, user_id, refresh_token_hash, user_agent, ip_address, expires_at, last_used_at, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, NOW(), NOW())
+		RETURNING last_used_at, created_at
+	`
+
+	return r.db.QueryRowx(query,
+		session.ID,
+		session.UserID,
+		session.RefreshTokenHash,
+		session.UserAgent,
+		session.IPAddress,
+		session.ExpiresAt,
+	).Scan(&session.LastUsedAt, &session.CreatedAt)
+}
+
+// Rotate は有効なリフレッシュトークンを新しいトークンに置き換え、セッションを返す
+// 期限切れ・失効済み・存在しないトークンはすべて同じエラーになる
+func (r *SessionRepository) Rotate(tokenHash, newTokenHash string, expiresAt time.Time) (*models.UserSession, error) {
+	query := `
+		UPDATE user_sessions
+		SET previous_token_hash = refresh_token_hash, refresh_token_hash = $2, expires_at = $3, last_used_at = NOW()
+		WHERE refresh_token_hash = $1 AND revoked_at IS NULL AND expires_at > NOW()
+		RETURNING *
+	`
+
+	var session models.UserSession
+	if err := r.db.Get(&session, query, tokenHash, newTokenHash, expiresAt); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, apperror.Unauthorized("invalid or expired refresh token")
+		}
+		return nil, err
+	}
+
+	return &session, nil
+}
+
+// RevokeByPreviousToken は置き換え済みのリフレッシュトークンを持つセッションを失効させ、失効させたかを返す
+// 置き換え済みのトークンが使われた場合はトークンが漏洩したとみなす
+func (r *SessionRepository) RevokeByPreviousToken(tokenHash string) (bool, error) {
+	query := `
+		UPDATE user_sessions
+		SET revoked_at = NOW()
+		WHERE previous_token_hash = $1 AND revoked_at IS NULL
+	`
+
+	result, err := r.db.Exec(query, tokenHash)
+	if err != nil {
+		return false, err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return rowsAffected > 0, nil
+}
+
+// IsActive はセッションが失効・期限切れになっていないかを返す
+func (r *SessionRepository) IsActive(id string) (bool, error) {
+	query := `
+		SELECT EXISTS (
+			SELECT 1 FROM user_sessions
+			WHERE id = $1 AND revoked_at IS NULL AND expires_at > NOW()
+		)
+	`
+
+	var active bool
+	if err := r.db.Get(&active, query, id); err != nil {
+		return false, err
+	}
+	return active, nil
+}
+
+// ListActiveByUserID はユーザーの有効なセッションを最終利用日時の新しい順に取得する
+func (r *SessionRepository) ListActiveByUserID(userID uint) ([]models.UserSession, error) {
+	sessions := []models.UserSession{}
+	query := `
+		SELECT * FROM user_sessions
+		WHERE user_id = $1 AND revoked_at IS NULL AND expires_at > NOW()
+		ORDER BY last_used_at DESC
+	`
+
+	if err := r.db.Select(&sessions, query, userID); err != nil {
+		return nil, err
+	}
+	return sessions, nil
+}
+
+// Revoke はユーザーのセッションを失効させる
+func (r *SessionRepository) Revoke(userID uint, id string) error {
+	query := `
+		UPDATE user_sessions
+		SET revoked_at = NOW()
+		WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL
+	`
+
+	result, err := r.db.Exec(query, id, userID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return apperror.NotFound("session not found")
+	}
+	return nil
+}
+
+// RevokeAllByUserID はユーザーのセッションをすべて失効させる
+// exceptIDを指定した場合はそのセッションを残す
+func (r *SessionRepository) RevokeAllByUserID(userID uint, exceptID string) error {
+	query := `
+		UPDATE user_sessions
+		SET revoked_at = NOW()
+		WHERE user_id = $1 AND id <> $2 AND revoked_at IS NULL
+	`
+
+	_, err := r.db.Exec(query, userID, exceptID)
+	return err
+}
+
+// DeleteExpiredByUserID はユーザーの期限切れ・失効済みのセッションを削除する
+func (r *SessionRepository) DeleteExpiredByUserID(userID uint) error {
+	query := `DELETE FROM user_sessions WHERE user_id = $1 AND (expires_at < NOW() OR revoked_at IS NOT NULL)`
+
+	_, err := r.db.Exec(query, userID)
+	return err
+}
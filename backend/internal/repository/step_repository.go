@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 
+	"github.com/Ryo-cool/guideforge/internal/apperror"
 	"github.com/Ryo-cool/guideforge/internal/models"
 	"github.com/jmoiron/sqlx"
 )
@@ -23,11 +24,21 @@ func NewStepRepository(repo *Repository) *StepRepository {
 
 // Create は新しい手順を作成する
 func (r *StepRepository) Create(step *models.Step) error {
+	return createStep(r.db, step)
+}
+
+// CreateTx はトランザクション内で新しい手順を作成する
+func (r *StepRepository) CreateTx(tx *sqlx.Tx, step *models.Step) error {
+	return createStep(tx, step)
+}
+
+// createStep は手順を作成する（内部メソッド）
+func createStep(q sqlx.Queryer, step *models.Step) error {
 	// 手順の順番が指定されていない場合は、最後の順番を取得して+1する
 	if step.OrderNumber == 0 {
 		var maxOrder int
 		query := `SELECT COALESCE(MAX(order_number), -1) FROM steps WHERE manual_id = $1`
-		if err := r.db.Get(&maxOrder, query, step.ManualID); err != nil {
+		if err := sqlx.Get(q, &maxOrder, query, step.ManualID); err != nil {
 			return err
 		}
 		step.OrderNumber = maxOrder + 1
@@ -39,7 +50,7 @@ func (r *StepRepository) Create(step *models.Step) error {
 		RETURNING id, created_at, updated_at
 	`
 
-	return r.db.QueryRowx(query,
+	return q.QueryRowx(query,
 		step.ManualID,
 		step.OrderNumber,
 		step.Title,
@@ -55,7 +66,7 @@ func (r *StepRepository) GetByID(id uint) (*models.Step, error) {
 	err := r.db.Get(&step, query, id)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
-			return nil, fmt.Errorf("step not found: %w", err)
+			return nil, apperror.NotFound("step not found")
 		}
 		return nil, err
 	}
@@ -82,8 +93,8 @@ func (r *StepRepository) GetByIDWithImages(id uint) (*models.Step, error) {
 	return step, nil
 }
 
-// Update は手順情報を更新する
-func (r *StepRepository) Update(step *models.Step) error {
+// UpdateTx はトランザクション内で手順情報を更新する
+func (r *StepRepository) UpdateTx(tx *sqlx.Tx, step *models.Step) error {
 	// マニュアル所有者を確認
 	var userID uint
 	checkQuery := `
@@ -91,9 +102,9 @@ func (r *StepRepository) Update(step *models.Step) error {
 		JOIN manuals m ON s.manual_id = m.id
 		WHERE s.id = $1
 	`
-	if err := r.db.Get(&userID, checkQuery, step.ID); err != nil {
+	if err := tx.Get(&userID, checkQuery, step.ID); err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
-			return fmt.Errorf("step not found")
+			return apperror.NotFound("step not found")
 		}
 		return err
 	}
@@ -105,80 +116,61 @@ func (r *StepRepository) Update(step *models.Step) error {
 		RETURNING updated_at
 	`
 
-	return r.db.QueryRowx(query,
+	return tx.QueryRowx(query,
 		step.Title,
 		step.Content,
 		step.ID,
 	).Scan(&step.UpdatedAt)
 }
 
-// Delete は手順を削除する
-func (r *StepRepository) Delete(id uint, userID uint) error {
-	// マニュアル所有者を確認
+// DeleteTx はトランザクション内で手順を削除する
+func (r *StepRepository) DeleteTx(tx *sqlx.Tx, id uint, userID uint) error {
+	// マニュアルの編集権限を確認し、削除後の並び替えに必要な情報を取得
+	var target struct {
+		ManualID    uint `db:"manual_id"`
+		OrderNumber int  `db:"order_number"`
+	}
 	checkQuery := `
-		SELECT 1 FROM steps s
+		SELECT s.manual_id, s.order_number FROM steps s
 		JOIN manuals m ON s.manual_id = m.id
-		WHERE s.id = $1 AND m.user_id = $2
-	`
-	var exists bool
-	err := r.db.Get(&exists, checkQuery, id, userID)
+		WHERE s.id = $1 AND ` + manualEditableBy
+	err := tx.Get(&target, checkQuery, id, userID)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
-			return fmt.Errorf("step not found or not owned by user")
+			return apperror.NotFound("step not found or not owned by user")
 		}
 		return err
 	}
 
-	// トランザクション開始
-	tx, err := r.db.Beginx()
-	if err != nil {
-		return err
-	}
-	defer tx.Rollback()
-
 	// 手順の削除
 	query := `DELETE FROM steps WHERE id = $1`
 	if _, err := tx.Exec(query, id); err != nil {
 		return err
 	}
 
-	// 順序の更新
+	// 順序の更新（削除した手順より後ろを詰める）
 	reorderQuery := `
 		UPDATE steps
 		SET order_number = order_number - 1
-		WHERE manual_id = (
-			SELECT manual_id FROM steps WHERE id = $1
-		) AND order_number > (
-			SELECT order_number FROM steps WHERE id = $1
-		)
+		WHERE manual_id = $1 AND order_number > $2
 	`
-	if _, err := tx.Exec(reorderQuery, id); err != nil {
-		return err
-	}
-
-	return tx.Commit()
+	_, err = tx.Exec(reorderQuery, target.ManualID, target.OrderNumber)
+	return err
 }
 
-// UpdateOrder は手順の順序を更新する
-func (r *StepRepository) UpdateOrder(manualID uint, orders []models.StepOrder, userID uint) error {
+// UpdateOrderTx はトランザクション内で手順の順序を更新する
+func (r *StepRepository) UpdateOrderTx(tx *sqlx.Tx, manualID uint, orders []models.StepOrder, userID uint) error {
 	// マニュアル所有者を確認
-	checkQuery := `SELECT 1 FROM manuals WHERE id = $1 AND user_id = $2`
+	checkQuery := `SELECT 1 FROM manuals m WHERE m.id = $1 AND ` + manualEditableBy
 	var exists bool
-	err := r.db.Get(&exists, checkQuery, manualID, userID)
+	err := tx.Get(&exists, checkQuery, manualID, userID)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
-			return fmt.Errorf("manual not found or not owned by user")
+			return apperror.NotFound("manual not found or not owned by user")
 		}
 		return err
 	}
 
-	// トランザクション開始
-	tx, err := r.db.Beginx()
-	if err != nil {
-		return err
-	}
-	defer tx.Rollback()
-
 	// 各手順の順序を更新
 	for _, order := range orders {
 		query := `
@@ -197,9 +189,12 @@ func (r *StepRepository) UpdateOrder(manualID uint, orders []models.StepOrder, u
 		}
 
 		if rowsAffected == 0 {
-			return fmt.Errorf("step with id %d not found in manual %d", order.ID, manualID)
+			return apperror.Validation(
+				fmt.Sprintf("step with id %d not found in manual %d", order.ID, manualID),
+				apperror.FieldError{Field: "steps", Message: fmt.Sprintf("unknown step id %d", order.ID)},
+			)
 		}
 	}
 
-	return tx.Commit()
+	return nil
 }
@@ -0,0 +1,147 @@
+package repository
+
+import (
+	"github.com/Ryo-cool/guideforge/internal/apperror"
+	"github.com/jmoiron/sqlx"
+)
+
+// TwoFactorRepository は二要素認証（TOTP・リカバリーコード）のデータアクセスを管理する
+type TwoFactorRepository struct {
+	db *sqlx.DB
+}
+
+// NewTwoFactorRepository は新しいTwoFactorRepositoryインスタンスを作成
+func NewTwoFactorRepository(repo *Repository) *TwoFactorRepository {
+	return &TwoFactorRepository{
+		db: repo.GetDB(),
+	}
+}
+
+// SetPendingSecret は確認前のTOTPシークレットを保存する
+// 既に有効な場合は上書きしない
+func (r *TwoFactorRepository) SetPendingSecret(userID uint, secret string) error {
+	query := `
+		UPDATE users
+		SET totp_secret = $2, totp_last_step = 0
+		WHERE id = $1 AND totp_enabled_at IS NULL
+	`
+
+	result, err := r.db.Exec(query, userID, secret)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return apperror.Conflict("two-factor authentication is already enabled")
+	}
+	return nil
+}
+
+// EnableTx はトランザクション内で確認済みのTOTPを有効にし、リカバリーコードを登録する
+// step は確認に使用したコードの時間ステップで、同じコードを再利用できないよう記録する
+func (r *TwoFactorRepository) EnableTx(tx *sqlx.Tx, userID uint, step int64, codeHashes []string) error {
+	query := `
+		UPDATE users
+		SET totp_enabled_at = NOW(), totp_last_step = $2
+		WHERE id = $1 AND totp_enabled_at IS NULL AND totp_secret IS NOT NULL
+	`
+	result, err := tx.Exec(query, userID, step)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return apperror.Conflict("two-factor authentication is already enabled")
+	}
+
+	return replaceRecoveryCodes(tx, userID, codeHashes)
+}
+
+// DisableTx はトランザクション内でTOTPを無効にし、リカバリーコードを削除する
+func (r *TwoFactorRepository) DisableTx(tx *sqlx.Tx, userID uint) error {
+	query := `
+		UPDATE users
+		SET totp_secret = NULL, totp_enabled_at = NULL, totp_last_step = 0
+		WHERE id = $1
+	`
+	if _, err := tx.Exec(query, userID); err != nil {
+		return err
+	}
+	_, err := tx.Exec(`DELETE FROM user_recovery_codes WHERE user_id = $1`, userID)
+	return err
+}
+
+// UseStep はTOTPコードの時間ステップを使用済みにし、使用できたかを返す
+// 同じまたはそれ以前のステップのコードは使用済みとみなし、リプレイ攻撃を防ぐ
+func (r *TwoFactorRepository) UseStep(userID uint, step int64) (bool, error) {
+	query := `UPDATE users SET totp_last_step = $2 WHERE id = $1 AND totp_last_step < $2`
+
+	result, err := r.db.Exec(query, userID, step)
+	if err != nil {
+		return false, err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return rowsAffected > 0, nil
+}
+
+// ReplaceRecoveryCodesTx はトランザクション内でリカバリーコードを新しいものに置き換える
+func (r *TwoFactorRepository) ReplaceRecoveryCodesTx(tx *sqlx.Tx, userID uint, codeHashes []string) error {
+	return replaceRecoveryCodes(tx, userID, codeHashes)
+}
+
+// UseRecoveryCode は未使用のリカバリーコードを使用済みにし、使用できたかを返す
+func (r *TwoFactorRepository) UseRecoveryCode(userID uint, codeHash string) (bool, error) {
+	query := `
+		UPDATE user_recovery_codes
+		SET used_at = NOW()
+		WHERE user_id = $1 AND code_hash = $2 AND used_at IS NULL
+	`
+
+	result, err := r.db.Exec(query, userID, codeHash)
+	if err != nil {
+		return false, err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return rowsAffected > 0, nil
+}
+
+// CountRecoveryCodes は未使用のリカバリーコードの数を返す
+func (r *TwoFactorRepository) CountRecoveryCodes(userID uint) (int, error) {
+	var count int
+	query := `SELECT COUNT(*) FROM user_recovery_codes WHERE user_id = $1 AND used_at IS NULL`
+
+	if err := r.db.Get(&count, query, userID); err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// replaceRecoveryCodes はトランザクション内でユーザーのリカバリーコードを置き換える
+func replaceRecoveryCodes(tx *sqlx.Tx, userID uint, codeHashes []string) error {
+	if _, err := tx.Exec(`DELETE FROM user_recovery_codes WHERE user_id = $1`, userID); err != nil {
+		return err
+	}
+
+	query := `INSERT INTO user_recovery_codes (user_id, code_hash, created_at) VALUES ($1, $2, NOW())`
+	for _, hash := range codeHashes {
+		if _, err := tx.Exec(query, userID, hash); err != nil {
+			return err
+		}
+	}
+	return nil
+}
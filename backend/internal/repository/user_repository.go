@@ -3,8 +3,9 @@ package repository
 import (
 	"database/sql"
 	"errors"
-	"fmt"
+	"time"
 
+	"github.com/Ryo-cool/guideforge/internal/apperror"
 	"github.com/Ryo-cool/guideforge/internal/models"
 	"github.com/jmoiron/sqlx"
 )
@@ -21,20 +22,21 @@ func NewUserRepository(repo *Repository) *UserRepository {
 	}
 }
 
-// Create は新しいユーザーを作成する
-func (r *UserRepository) Create(user *models.User) error {
+// CreateTx はトランザクション内で新しいユーザーを作成する
+func (r *UserRepository) CreateTx(tx *sqlx.Tx, user *models.User) error {
 	query := `
-		INSERT INTO users (username, email, password_hash, profile_image, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, NOW(), NOW())
-		RETURNING id, created_at, updated_at
+		INSERT INTO users (username, email, password_hash, profile_image, email_verified_at, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, NOW(), NOW())
+		RETURNING id, role, created_at, updated_at
 	`
 
-	return r.db.QueryRowx(query,
+	return tx.QueryRowx(query,
 		user.Username,
 		user.Email,
 		user.PasswordHash,
 		user.ProfileImage,
-	).Scan(&user.ID, &user.CreatedAt, &user.UpdatedAt)
+		user.EmailVerifiedAt,
+	).Scan(&user.ID, &user.Role, &user.CreatedAt, &user.UpdatedAt)
 }
 
 // GetByID はIDからユーザーを取得する
@@ -45,7 +47,7 @@ func (r *UserRepository) GetByID(id uint) (*models.User, error) {
 	err := r.db.Get(&user, query, id)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
-			return nil, fmt.Errorf("user not found: %w", err)
+			return nil, apperror.NotFound("user not found")
 		}
 		return nil, err
 	}
@@ -61,7 +63,7 @@ func (r *UserRepository) GetByEmail(email string) (*models.User, error) {
 	err := r.db.Get(&user, query, email)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
-			return nil, fmt.Errorf("user not found: %w", err)
+			return nil, apperror.NotFound("user not found")
 		}
 		return nil, err
 	}
@@ -69,8 +71,8 @@ func (r *UserRepository) GetByEmail(email string) (*models.User, error) {
 	return &user, nil
 }
 
-// Update はユーザー情報を更新する
-func (r *UserRepository) Update(user *models.User) error {
+// UpdateTx はトランザクション内でユーザー情報を更新する
+func (r *UserRepository) UpdateTx(tx *sqlx.Tx, user *models.User) error {
 	query := `
 		UPDATE users
 		SET username = $1, email = $2, profile_image = $3, updated_at = NOW()
@@ -78,7 +80,7 @@ func (r *UserRepository) Update(user *models.User) error {
 		RETURNING updated_at
 	`
 
-	return r.db.QueryRowx(query,
+	return tx.QueryRowx(query,
 		user.Username,
 		user.Email,
 		user.ProfileImage,
@@ -88,13 +90,158 @@ func (r *UserRepository) Update(user *models.User) error {
 
 // UpdatePassword はユーザーのパスワードを更新する
 func (r *UserRepository) UpdatePassword(id uint, passwordHash string) error {
+	return updatePassword(r.db, id, passwordHash)
+}
+
+// UpdatePasswordTx はトランザクション内でユーザーのパスワードを更新する
+func (r *UserRepository) UpdatePasswordTx(tx *sqlx.Tx, id uint, passwordHash string) error {
+	return updatePassword(tx, id, passwordHash)
+}
+
+// updatePassword はユーザーのパスワードを更新する（内部メソッド）
+func updatePassword(e sqlx.Execer, id uint, passwordHash string) error {
 	query := `
 		UPDATE users
 		SET password_hash = $1, updated_at = NOW()
 		WHERE id = $2
 	`
 
-	result, err := r.db.Exec(query, passwordHash, id)
+	result, err := e.Exec(query, passwordHash, id)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return apperror.NotFound("user not found")
+	}
+
+	return nil
+}
+
+// MarkEmailVerifiedTx はトランザクション内でユーザーのメールアドレスを確認済みにする（確認済みの場合は確認日時を変えない）
+func (r *UserRepository) MarkEmailVerifiedTx(tx *sqlx.Tx, id uint) error {
+	query := `
+		UPDATE users
+		SET email_verified_at = COALESCE(email_verified_at, NOW()), updated_at = NOW()
+		WHERE id = $1
+	`
+
+	result, err := tx.Exec(query, id)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return apperror.NotFound("user not found")
+	}
+
+	return nil
+}
+
+// UpdateEmailTx はトランザクション内で確認の済んだ新しいメールアドレスに変更する
+// 確認待ちの間に他のユーザーが同じアドレスを使い始めた場合は変更しない
+func (r *UserRepository) UpdateEmailTx(tx *sqlx.Tx, id uint, email string) error {
+	query := `
+		UPDATE users
+		SET email = $1, email_verified_at = NOW(), updated_at = NOW()
+		WHERE id = $2
+		  AND NOT EXISTS (SELECT 1 FROM users WHERE email = $1 AND id <> $2)
+	`
+
+	result, err := tx.Exec(query, email, id)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		var exists bool
+		if err := tx.Get(&exists, `SELECT EXISTS (SELECT 1 FROM users WHERE id = $1)`, id); err != nil {
+			return err
+		}
+		if !exists {
+			return apperror.NotFound("user not found")
+		}
+		return apperror.Conflict("email already registered")
+	}
+
+	return nil
+}
+
+// DeleteUnverifiedTx はトランザクション内で、確認されないまま指定日時より前に登録されたユーザーを削除し、削除したユーザーを返す
+// 他人のメールアドレスで登録されたアカウントが、本人の登録を妨げ続けないようにするために使用する
+// マニュアル・ワークスペース・プロフィール画像・外部ID・APIトークン・セッションのいずれかを持つアカウントは利用されているため削除しない
+// 削除しなかった場合は nil を返す
+func (r *UserRepository) DeleteUnverifiedTx(tx *sqlx.Tx, email string, registeredBefore time.Time) (*models.User, error) {
+	var user models.User
+	query := `
+		DELETE FROM users u
+		WHERE u.email = $1 AND u.email_verified_at IS NULL AND u.created_at < $2
+			AND COALESCE(u.profile_image, '') = ''
+			AND NOT EXISTS (SELECT 1 FROM manuals m WHERE m.user_id = u.id)
+			AND NOT EXISTS (SELECT 1 FROM workspace_members wm WHERE wm.user_id = u.id)
+			AND NOT EXISTS (SELECT 1 FROM user_identities ui WHERE ui.user_id = u.id)
+			AND NOT EXISTS (SELECT 1 FROM api_tokens t WHERE t.user_id = u.id)
+			AND NOT EXISTS (SELECT 1 FROM user_sessions us WHERE us.user_id = u.id)
+		RETURNING u.*
+	`
+
+	if err := tx.Get(&user, query, email, registeredBefore); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return &user, nil
+}
+
+// GetAll はユーザー一覧を取得する（ページネーション付き）
+func (r *UserRepository) GetAll(page, limit int) ([]models.User, int, error) {
+	users := []models.User{}
+	offset := (page - 1) * limit
+
+	// 総件数の取得
+	var total int
+	if err := r.db.Get(&total, `SELECT COUNT(*) FROM users`); err != nil {
+		return nil, 0, err
+	}
+
+	query := `
+		SELECT * FROM users
+		ORDER BY id ASC
+		LIMIT $1 OFFSET $2
+	`
+	if err := r.db.Select(&users, query, limit, offset); err != nil {
+		return nil, 0, err
+	}
+
+	return users, total, nil
+}
+
+// UpdateRoleTx はトランザクション内でユーザーのロールを更新する
+func (r *UserRepository) UpdateRoleTx(tx *sqlx.Tx, id uint, role string) error {
+	query := `
+		UPDATE users
+		SET role = $1, updated_at = NOW()
+		WHERE id = $2
+	`
+
+	result, err := tx.Exec(query, role, id)
 	if err != nil {
 		return err
 	}
@@ -105,17 +252,27 @@ func (r *UserRepository) UpdatePassword(id uint, passwordHash string) error {
 	}
 
 	if rowsAffected == 0 {
-		return fmt.Errorf("user not found")
+		return apperror.NotFound("user not found")
 	}
 
 	return nil
 }
 
-// Delete はユーザーを削除する
-func (r *UserRepository) Delete(id uint) error {
+// CountByRole は指定ロールのユーザー数を取得する
+func (r *UserRepository) CountByRole(role string) (int, error) {
+	var count int
+	if err := r.db.Get(&count, `SELECT COUNT(*) FROM users WHERE role = $1`, role); err != nil {
+		return 0, err
+	}
+
+	return count, nil
+}
+
+// DeleteTx はトランザクション内でユーザーを削除する
+func (r *UserRepository) DeleteTx(tx *sqlx.Tx, id uint) error {
 	query := `DELETE FROM users WHERE id = $1`
 
-	result, err := r.db.Exec(query, id)
+	result, err := tx.Exec(query, id)
 	if err != nil {
 		return err
 	}
@@ -126,7 +283,7 @@ func (r *UserRepository) Delete(id uint) error {
 	}
 
 	if rowsAffected == 0 {
-		return fmt.Errorf("user not found")
+		return apperror.NotFound("user not found")
 	}
 
 	return nil
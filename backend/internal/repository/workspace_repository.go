@@ -0,0 +1,291 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+
+	"github.com/Ryo-cool/guideforge/internal/apperror"
+	"github.com/Ryo-cool/guideforge/internal/models"
+	"github.com/jmoiron/sqlx"
+)
+
+// manualCreatorTwoFactorSatisfied はマニュアル（エイリアス m）の作成者が、所属ワークスペースの二要素認証の条件を満たしているかのSQL断片
+// 二要素認証が必須のワークスペースのマニュアルには、作成者本人も二要素認証を有効にしている場合のみアクセスできる
+const manualCreatorTwoFactorSatisfied = `(m.workspace_id IS NULL OR NOT EXISTS (
+	SELECT 1 FROM workspaces cw
+	JOIN users cu ON cu.id = m.user_id
+	WHERE cw.id = m.workspace_id AND cw.require_two_factor AND cu.totp_enabled_at IS NULL
+))`
+
+// manualEditableBy はマニュアル（エイリアス m）を指定ユーザーが編集できる条件のSQL断片
+// 作成者本人、または所属ワークスペースのオーナー・編集者であれば編集可能
+// 二要素認証が必須のワークスペースでは、作成者・オーナー・編集者も二要素認証を有効にしている場合のみ編集できる
+const manualEditableBy = `((m.user_id = $2 AND ` + manualCreatorTwoFactorSatisfied + `) OR EXISTS (
+	SELECT 1 FROM workspace_members wm
+	JOIN workspaces w ON w.id = wm.workspace_id
+	JOIN users u ON u.id = wm.user_id
+	WHERE wm.workspace_id = m.workspace_id AND wm.user_id = $2 AND wm.role IN ('owner', 'editor')
+		AND (NOT w.require_two_factor OR u.totp_enabled_at IS NOT NULL)
+))`
+
+// accessibleWorkspaceIDsQuery はユーザー（$1）がアクセスできるワークスペースのIDを返すサブクエリ
+// 二要素認証が必須のワークスペースは、ユーザーが二要素認証を有効にしている場合のみ含める
+const accessibleWorkspaceIDsQuery = `
+	SELECT wm.workspace_id FROM workspace_members wm
+	JOIN workspaces w ON w.id = wm.workspace_id
+	JOIN users u ON u.id = wm.user_id
+	WHERE wm.user_id = $1 AND (NOT w.require_two_factor OR u.totp_enabled_at IS NOT NULL)
+`
+
+// WorkspaceRepository はワークスペースのデータアクセスを管理するインターフェース
+type WorkspaceRepository struct {
+	db *sqlx.DB
+}
+
+// NewWorkspaceRepository は新しいWorkspaceRepositoryインスタンスを作成
+func NewWorkspaceRepository(repo *Repository) *WorkspaceRepository {
+	return &WorkspaceRepository{
+		db: repo.GetDB(),
+	}
+}
+
+// CreateTx はトランザクション内で新しいワークスペースを作成し、作成者をオーナーとして登録する
+func (r *WorkspaceRepository) CreateTx(tx *sqlx.Tx, workspace *models.Workspace, ownerID uint) error {
+	query := `
+		INSERT INTO workspaces (name, require_two_factor, created_by, created_at, updated_at)
+		VALUES ($1, $2, $3, NOW(), NOW())
+		RETURNING id, created_at, updated_at
+	`
+	if err := tx.QueryRowx(query, workspace.Name, workspace.RequireTwoFactor, ownerID).Scan(&workspace.ID, &workspace.CreatedAt, &workspace.UpdatedAt); err != nil {
+		return err
+	}
+
+	memberQuery := `
+		INSERT INTO workspace_members (workspace_id, user_id, role, created_at)
+		VALUES ($1, $2, $3, NOW())
+	`
+	if _, err := tx.Exec(memberQuery, workspace.ID, ownerID, models.WorkspaceRoleOwner); err != nil {
+		return err
+	}
+
+	workspace.CreatedBy = &ownerID
+	workspace.Role = models.WorkspaceRoleOwner
+	return nil
+}
+
+// GetByID はIDからワークスペースを取得する
+func (r *WorkspaceRepository) GetByID(id uint) (*models.Workspace, error) {
+	var workspace models.Workspace
+	query := `SELECT * FROM workspaces WHERE id = $1`
+
+	err := r.db.Get(&workspace, query, id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, apperror.NotFound("workspace not found")
+		}
+		return nil, err
+	}
+
+	return &workspace, nil
+}
+
+// GetAllByUserID はユーザーが所属するワークスペース一覧を取得する
+func (r *WorkspaceRepository) GetAllByUserID(userID uint) ([]models.Workspace, error) {
+	workspaces := []models.Workspace{}
+	query := `
+		SELECT w.*, wm.role FROM workspaces w
+		JOIN workspace_members wm ON wm.workspace_id = w.id
+		WHERE wm.user_id = $1
+		ORDER BY w.name ASC
+	`
+
+	if err := r.db.Select(&workspaces, query, userID); err != nil {
+		return nil, err
+	}
+
+	return workspaces, nil
+}
+
+// UpdateTx はトランザクション内でワークスペース情報を更新する
+func (r *WorkspaceRepository) UpdateTx(tx *sqlx.Tx, workspace *models.Workspace) error {
+	query := `
+		UPDATE workspaces
+		SET name = $1, require_two_factor = $2, updated_at = NOW()
+		WHERE id = $3
+		RETURNING updated_at
+	`
+
+	err := tx.QueryRowx(query, workspace.Name, workspace.RequireTwoFactor, workspace.ID).Scan(&workspace.UpdatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return apperror.NotFound("workspace not found")
+	}
+	return err
+}
+
+// DeleteTx はトランザクション内でワークスペースを削除する（所属マニュアルは作成者の個人マニュアルに戻る）
+func (r *WorkspaceRepository) DeleteTx(tx *sqlx.Tx, id uint) error {
+	result, err := tx.Exec(`DELETE FROM workspaces WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return apperror.NotFound("workspace not found")
+	}
+
+	return nil
+}
+
+// GetMembers はワークスペースのメンバー一覧を取得する
+func (r *WorkspaceRepository) GetMembers(workspaceID uint) ([]models.WorkspaceMember, error) {
+	members := []models.WorkspaceMember{}
+	query := `
+		SELECT wm.workspace_id, wm.user_id, u.username, u.email, wm.role, wm.created_at
+		FROM workspace_members wm
+		JOIN users u ON u.id = wm.user_id
+		WHERE wm.workspace_id = $1
+		ORDER BY wm.created_at ASC
+	`
+
+	if err := r.db.Select(&members, query, workspaceID); err != nil {
+		return nil, err
+	}
+
+	return members, nil
+}
+
+// GetMemberRole はユーザーのワークスペース内の役割を取得する（非メンバーの場合は空文字）
+func (r *WorkspaceRepository) GetMemberRole(workspaceID, userID uint) (string, error) {
+	var role string
+	query := `SELECT role FROM workspace_members WHERE workspace_id = $1 AND user_id = $2`
+
+	err := r.db.Get(&role, query, workspaceID, userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", nil
+		}
+		return "", err
+	}
+
+	return role, nil
+}
+
+// GetMemberAccess はユーザーのワークスペース内の役割（非メンバーの場合は空文字）と、
+// ワークスペースが二要素認証を必須にしているのにユーザーが有効にしていないかを取得する
+func (r *WorkspaceRepository) GetMemberAccess(workspaceID, userID uint) (string, bool, error) {
+	var access struct {
+		Role             string `db:"role"`
+		TwoFactorMissing bool   `db:"two_factor_missing"`
+	}
+	query := `
+		SELECT wm.role, (w.require_two_factor AND u.totp_enabled_at IS NULL) AS two_factor_missing
+		FROM workspace_members wm
+		JOIN workspaces w ON w.id = wm.workspace_id
+		JOIN users u ON u.id = wm.user_id
+		WHERE wm.workspace_id = $1 AND wm.user_id = $2
+	`
+
+	err := r.db.Get(&access, query, workspaceID, userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", false, nil
+		}
+		return "", false, err
+	}
+
+	return access.Role, access.TwoFactorMissing, nil
+}
+
+// IsTwoFactorMissing はワークスペースが二要素認証を必須にしているのに、ユーザーが有効にしていないかを返す（メンバーかどうかは問わない）
+func (r *WorkspaceRepository) IsTwoFactorMissing(workspaceID, userID uint) (bool, error) {
+	var missing bool
+	query := `
+		SELECT w.require_two_factor AND u.totp_enabled_at IS NULL
+		FROM workspaces w, users u
+		WHERE w.id = $1 AND u.id = $2
+	`
+
+	err := r.db.Get(&missing, query, workspaceID, userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	return missing, nil
+}
+
+// AddMemberTx はトランザクション内でワークスペースにメンバーを追加する（既存メンバーの場合は役割を更新）
+func (r *WorkspaceRepository) AddMemberTx(tx *sqlx.Tx, workspaceID, userID uint, role string) error {
+	query := `
+		INSERT INTO workspace_members (workspace_id, user_id, role, created_at)
+		VALUES ($1, $2, $3, NOW())
+		ON CONFLICT (workspace_id, user_id) DO UPDATE SET role = EXCLUDED.role
+	`
+
+	_, err := tx.Exec(query, workspaceID, userID, role)
+	return err
+}
+
+// RemoveMemberTx はトランザクション内でワークスペースからメンバーを削除する
+func (r *WorkspaceRepository) RemoveMemberTx(tx *sqlx.Tx, workspaceID, userID uint) error {
+	query := `DELETE FROM workspace_members WHERE workspace_id = $1 AND user_id = $2`
+
+	result, err := tx.Exec(query, workspaceID, userID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return apperror.NotFound("workspace member not found")
+	}
+
+	return nil
+}
+
+// CountOwners はワークスペースのオーナー数を取得する
+func (r *WorkspaceRepository) CountOwners(workspaceID uint) (int, error) {
+	var count int
+	query := `SELECT COUNT(*) FROM workspace_members WHERE workspace_id = $1 AND role = $2`
+
+	if err := r.db.Get(&count, query, workspaceID, models.WorkspaceRoleOwner); err != nil {
+		return 0, err
+	}
+
+	return count, nil
+}
+
+// TransferManualsFromUserTx はユーザー削除前に、ワークスペースに属するマニュアルの作成者を
+// 同じワークスペースの別のオーナー（いなければ編集者）に付け替える
+// 個人マニュアルや引き継ぎ先がいないマニュアルはユーザーと共に削除される
+func (r *WorkspaceRepository) TransferManualsFromUserTx(tx *sqlx.Tx, userID uint) error {
+	query := `
+		UPDATE manuals m
+		SET user_id = (
+			SELECT wm.user_id FROM workspace_members wm
+			WHERE wm.workspace_id = m.workspace_id AND wm.user_id <> $1 AND wm.role IN ('owner', 'editor')
+			ORDER BY (wm.role = 'owner') DESC, wm.created_at ASC
+			LIMIT 1
+		)
+		WHERE m.user_id = $1
+		AND m.workspace_id IS NOT NULL
+		AND EXISTS (
+			SELECT 1 FROM workspace_members wm
+			WHERE wm.workspace_id = m.workspace_id AND wm.user_id <> $1 AND wm.role IN ('owner', 'editor')
+		)
+	`
+
+	_, err := tx.Exec(query, userID)
+	return err
+}
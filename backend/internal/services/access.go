@@ -0,0 +1,135 @@
+package services
+
+import (
+	"github.com/Ryo-cool/guideforge/internal/apperror"
+	"github.com/Ryo-cool/guideforge/internal/models"
+	"github.com/Ryo-cool/guideforge/internal/repository"
+)
+
+// errTwoFactorRequired は二要素認証が必須のワークスペースに、二要素認証を有効にしていないメンバーがアクセスした場合のエラー
+var errTwoFactorRequired = apperror.Forbidden("this workspace requires two-factor authentication, please enable it in your account settings")
+
+// workspaceMemberRole はユーザーのワークスペース内の役割を取得する（非メンバーの場合は空文字）
+// 二要素認証が必須のワークスペースで、ユーザーが有効にしていない場合はエラーを返す
+func workspaceMemberRole(workspaceRepo *repository.WorkspaceRepository, workspaceID, userID uint) (string, error) {
+	role, twoFactorMissing, err := workspaceRepo.GetMemberAccess(workspaceID, userID)
+	if err != nil {
+		return "", err
+	}
+	if role != "" && twoFactorMissing {
+		return "", errTwoFactorRequired
+	}
+	return role, nil
+}
+
+// manualAccess はマニュアルへのアクセス権限を判定する
+// 作成者本人または所属ワークスペースのオーナー・編集者が編集でき、
+// ワークスペースの閲覧者は閲覧のみ、公開マニュアルは誰でも閲覧できる
+type manualAccess struct {
+	workspaceRepo *repository.WorkspaceRepository
+}
+
+// checkCreatorTwoFactor は作成者本人としてのアクセスに、所属ワークスペースの二要素認証の条件を満たしていない場合にエラーを返す
+// 二要素認証が必須のワークスペースのマニュアルには、作成者本人も二要素認証を有効にしている場合のみアクセスできる
+func (a manualAccess) checkCreatorTwoFactor(manual *models.Manual, userID uint) error {
+	if manual.WorkspaceID == nil {
+		return nil
+	}
+	missing, err := a.workspaceRepo.IsTwoFactorMissing(*manual.WorkspaceID, userID)
+	if err != nil {
+		return err
+	}
+	if missing {
+		return errTwoFactorRequired
+	}
+	return nil
+}
+
+// canEdit はユーザーがマニュアルを編集できるかを返す
+func (a manualAccess) canEdit(manual *models.Manual, userID uint) (bool, error) {
+	if manual.UserID == userID {
+		if err := a.checkCreatorTwoFactor(manual, userID); err != nil {
+			return false, err
+		}
+		return true, nil
+	}
+
+	if manual.WorkspaceID == nil {
+		return false, nil
+	}
+
+	role, err := workspaceMemberRole(a.workspaceRepo, *manual.WorkspaceID, userID)
+	if err != nil {
+		return false, err
+	}
+
+	return models.CanEditInWorkspace(role), nil
+}
+
+// checkEdit は編集権限がない場合にエラーを返す
+func (a manualAccess) checkEdit(manual *models.Manual, userID uint) error {
+	ok, err := a.canEdit(manual, userID)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return apperror.Forbidden("unauthorized access")
+	}
+	return nil
+}
+
+// checkRead は閲覧権限がない場合にエラーを返す
+func (a manualAccess) checkRead(manual *models.Manual, userID uint) error {
+	if manual.IsPublic {
+		return nil
+	}
+	if manual.UserID == userID {
+		return a.checkCreatorTwoFactor(manual, userID)
+	}
+
+	if manual.WorkspaceID != nil {
+		role, err := workspaceMemberRole(a.workspaceRepo, *manual.WorkspaceID, userID)
+		if err != nil {
+			return err
+		}
+		if role != "" {
+			return nil
+		}
+	}
+
+	return apperror.Forbidden("unauthorized access")
+}
+
+// checkWorkspaceMember はユーザーがワークスペースのメンバーでない場合にエラーを返す
+func (a manualAccess) checkWorkspaceMember(workspaceID, userID uint) error {
+	_, err := a.workspaceRole(workspaceID, userID)
+	return err
+}
+
+// checkWorkspaceEditor はユーザーがワークスペースでマニュアルを編集できない場合にエラーを返す
+func (a manualAccess) checkWorkspaceEditor(workspaceID, userID uint) error {
+	role, err := a.workspaceRole(workspaceID, userID)
+	if err != nil {
+		return err
+	}
+	if !models.CanEditInWorkspace(role) {
+		return apperror.Forbidden("workspace viewers cannot edit manuals")
+	}
+	return nil
+}
+
+// workspaceRole はメンバーの役割を取得し、メンバーでない場合はエラーを返す
+func (a manualAccess) workspaceRole(workspaceID, userID uint) (string, error) {
+	if _, err := a.workspaceRepo.GetByID(workspaceID); err != nil {
+		return "", err
+	}
+
+	role, err := workspaceMemberRole(a.workspaceRepo, workspaceID, userID)
+	if err != nil {
+		return "", err
+	}
+	if role == "" {
+		return "", apperror.Forbidden("not a member of this workspace")
+	}
+	return role, nil
+}
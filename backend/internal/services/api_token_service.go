@@ -0,0 +1,116 @@
+package services
+
+import (
+	"strings"
+	"time"
+
+	"github.com/Ryo-cool/guideforge/internal/auth"
+	"github.com/Ryo-cool/guideforge/internal/models"
+	"github.com/Ryo-cool/guideforge/internal/repository"
+	"github.com/jmoiron/sqlx"
+)
+
+// apiTokenDisplayLength は一覧で見分けるために保存するトークンの先頭部分の長さ
+const apiTokenDisplayLength = len(auth.APITokenPrefix) + 8
+
+// APITokenService はCIなどの自動化向けの個人用APIトークンを管理する
+type APITokenService struct {
+	repo         *repository.Repository
+	apiTokenRepo *repository.APITokenRepository
+	audit        *AuditService
+}
+
+// NewAPITokenService は新しいAPITokenServiceインスタンスを作成
+func NewAPITokenService(repo *repository.Repository, apiTokenRepo *repository.APITokenRepository, audit *AuditService) *APITokenService {
+	return &APITokenService{
+		repo:         repo,
+		apiTokenRepo: apiTokenRepo,
+		audit:        audit,
+	}
+}
+
+// Create はAPIトークンを発行する
+// 平文のトークンはレスポンスでのみ返し、DBにはハッシュのみを保存する
+func (s *APITokenService) Create(userID uint, req models.APITokenCreateRequest, client models.ClientInfo) (*models.APITokenCreateResponse, error) {
+	random, err := generateRandomToken()
+	if err != nil {
+		return nil, err
+	}
+	raw := auth.APITokenPrefix + random
+
+	token := models.APIToken{
+		UserID:      userID,
+		Name:        strings.TrimSpace(req.Name),
+		TokenHash:   hashToken(raw),
+		TokenPrefix: raw[:apiTokenDisplayLength],
+		Scopes:      normalizeScopes(req.Scopes),
+		ExpiresAt:   time.Now().AddDate(0, 0, req.ExpiresInDays),
+	}
+	err = s.repo.Transaction(func(tx *sqlx.Tx) error {
+		if err := s.apiTokenRepo.CreateTx(tx, &token); err != nil {
+			return err
+		}
+		event := newAuditEvent(userID, client, models.AuditActionAPITokenCreate, models.AuditTargetAPIToken, token.ID)
+		event.After = models.AuditSummary{
+			"name":         token.Name,
+			"token_prefix": token.TokenPrefix,
+			"scopes":       token.Scopes,
+			"expires_at":   token.ExpiresAt,
+		}
+		return s.audit.RecordTx(tx, event)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.APITokenCreateResponse{
+		APIToken: token,
+		Token:    raw,
+	}, nil
+}
+
+// List はユーザーのAPIトークン一覧を取得する
+func (s *APITokenService) List(userID uint) ([]models.APIToken, error) {
+	return s.apiTokenRepo.ListByUserID(userID)
+}
+
+// Revoke はユーザーのAPIトークンを失効させる
+func (s *APITokenService) Revoke(userID, id uint, client models.ClientInfo) error {
+	return s.repo.Transaction(func(tx *sqlx.Tx) error {
+		if err := s.apiTokenRepo.RevokeTx(tx, userID, id); err != nil {
+			return err
+		}
+		return s.audit.RecordTx(tx, newAuditEvent(userID, client, models.AuditActionAPITokenRevoke, models.AuditTargetAPIToken, id))
+	})
+}
+
+// VerifyAPIToken はAPIトークンを検証し、JWTと同じ形式のクレームを返す
+// ユーザーのメールアドレスとロールは発行時ではなく現在の値を使用する
+func (s *APITokenService) VerifyAPIToken(raw string) (*auth.JWTClaims, error) {
+	owner, err := s.apiTokenRepo.Authenticate(hashToken(raw))
+	if err != nil {
+		return nil, err
+	}
+
+	return &auth.JWTClaims{
+		Email:      owner.Email,
+		Role:       owner.Role,
+		UserID:     owner.UserID,
+		APITokenID: owner.ID,
+		Scopes:     owner.Scopes,
+	}, nil
+}
+
+// normalizeScopes はスコープの重複を取り除き、定義順に並べる
+func normalizeScopes(scopes []string) []string {
+	normalized := []string{}
+	for _, scope := range []string{models.APITokenScopeRead, models.APITokenScopeWrite} {
+		for _, s := range scopes {
+			if s == scope {
+				normalized = append(normalized, scope)
+				break
+			}
+		}
+	}
+	return normalized
+}
@@ -0,0 +1,133 @@
+package services
+
+import (
+	"math"
+	"unicode/utf8"
+
+	"github.com/Ryo-cool/guideforge/internal/apperror"
+	"github.com/Ryo-cool/guideforge/internal/models"
+	"github.com/Ryo-cool/guideforge/internal/repository"
+	"github.com/jmoiron/sqlx"
+)
+
+// maxAuditExportRows はエクスポートできる監査ログの最大件数（超える場合は期間などで絞り込む）
+const maxAuditExportRows = 100000
+
+// AuditService は監査ログの記録と検索を行うサービス
+// 変更を行う各サービスは、変更と同じトランザクション内で RecordTx を呼び出す
+type AuditService struct {
+	auditRepo *repository.AuditRepository
+}
+
+// NewAuditService は新しいAuditServiceインスタンスを作成
+func NewAuditService(auditRepo *repository.AuditRepository) *AuditService {
+	return &AuditService{
+		auditRepo: auditRepo,
+	}
+}
+
+// RecordTx はトランザクション内で監査ログを記録する
+// 記録に失敗した場合は変更もロールバックされるよう、エラーはそのまま返す
+func (s *AuditService) RecordTx(tx *sqlx.Tx, event *models.AuditEvent) error {
+	return s.auditRepo.CreateTx(tx, event)
+}
+
+// ListEvents は管理者向けに監査ログを新しい順に取得する
+func (s *AuditService) ListEvents(filter models.AuditEventFilter, page, limit int) (*models.PaginatedResponse, error) {
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 50
+	}
+
+	events, total, err := s.auditRepo.List(filter, page, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	totalPages := int(math.Ceil(float64(total) / float64(limit)))
+
+	return &models.PaginatedResponse{
+		Pagination: models.PaginationResponse{
+			Total:      total,
+			Page:       page,
+			Limit:      limit,
+			TotalPages: totalPages,
+		},
+		Items: events,
+	}, nil
+}
+
+// ExportEvents はエクスポート用に条件に一致する監査ログを古い順に取得する
+func (s *AuditService) ExportEvents(filter models.AuditEventFilter) ([]models.AuditEvent, error) {
+	events, err := s.auditRepo.ListForExport(filter, maxAuditExportRows+1)
+	if err != nil {
+		return nil, err
+	}
+	if len(events) > maxAuditExportRows {
+		return nil, apperror.Validation("too many audit events to export, please narrow the date range or filters")
+	}
+	return events, nil
+}
+
+// newAuditEvent は操作者・クライアント情報・対象から監査ログを作成する
+func newAuditEvent(actorID uint, client models.ClientInfo, action, targetType string, targetID uint) *models.AuditEvent {
+	return &models.AuditEvent{
+		ActorID:    &actorID,
+		Action:     action,
+		TargetType: targetType,
+		TargetID:   targetID,
+		IPAddress:  truncateRunes(client.IPAddress, maxIPAddressLength),
+		UserAgent:  truncateRunes(client.UserAgent, maxUserAgentLength),
+	}
+}
+
+// manualAuditSummary は監査ログに記録するマニュアルの値
+func manualAuditSummary(manual *models.Manual) models.AuditSummary {
+	return models.AuditSummary{
+		"title":        manual.Title,
+		"category":     manual.Category,
+		"is_public":    manual.IsPublic,
+		"workspace_id": manual.WorkspaceID,
+		"user_id":      manual.UserID,
+	}
+}
+
+// stepAuditSummary は監査ログに記録する手順の値（本文は長さのみ）
+func stepAuditSummary(step *models.Step) models.AuditSummary {
+	return models.AuditSummary{
+		"manual_id":      step.ManualID,
+		"title":          step.Title,
+		"order_number":   step.OrderNumber,
+		"content_length": utf8.RuneCountInString(step.Content),
+	}
+}
+
+// imageAuditSummary は監査ログに記録する画像の値
+func imageAuditSummary(image *models.Image, manualID uint) models.AuditSummary {
+	return models.AuditSummary{
+		"manual_id": manualID,
+		"step_id":   image.StepID,
+		"file_name": image.FileName,
+		"file_size": image.FileSize,
+		"mime_type": image.MimeType,
+	}
+}
+
+// workspaceAuditSummary は監査ログに記録するワークスペースの値
+func workspaceAuditSummary(workspace *models.Workspace) models.AuditSummary {
+	return models.AuditSummary{
+		"name":               workspace.Name,
+		"require_two_factor": workspace.RequireTwoFactor,
+	}
+}
+
+// userAuditSummary は監査ログに記録するユーザーの値
+func userAuditSummary(user *models.User) models.AuditSummary {
+	return models.AuditSummary{
+		"username": user.Username,
+		"email":    user.Email,
+		"role":     user.Role,
+	}
+}
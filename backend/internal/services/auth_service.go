@@ -1,27 +1,75 @@
 package services
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"errors"
+	"log"
 	"time"
 
+	"github.com/Ryo-cool/guideforge/internal/apperror"
+	"github.com/Ryo-cool/guideforge/internal/auth"
 	"github.com/Ryo-cool/guideforge/internal/config"
 	"github.com/Ryo-cool/guideforge/internal/models"
+	"github.com/Ryo-cool/guideforge/internal/password"
 	"github.com/Ryo-cool/guideforge/internal/repository"
-	"github.com/golang-jwt/jwt/v4"
-	"golang.org/x/crypto/bcrypt"
+	"github.com/jmoiron/sqlx"
 )
 
+const (
+	// maxUserAgentLength はセッションに記録するUser-Agentの最大文字数
+	maxUserAgentLength = 512
+	// maxIPAddressLength はセッションに記録するIPアドレスの最大文字数
+	maxIPAddressLength = 64
+)
+
+// errInvalidCredentials はメールアドレスまたはパスワードが誤っている場合のエラー
+var errInvalidCredentials = apperror.Unauthorized("invalid email or password")
+
+// errPasswordLoginDisabled はシングルサインオンが必須の場合にパスワードでのログイン・登録を拒否するエラー
+var errPasswordLoginDisabled = apperror.Forbidden("password login is disabled, please sign in with single sign-on")
+
 // AuthService は認証関連の機能を提供するサービス
 type AuthService struct {
-	userRepo *repository.UserRepository
-	config   *config.Config
+	repo              *repository.Repository
+	userRepo          *repository.UserRepository
+	sessionRepo       *repository.SessionRepository
+	tokens            *auth.TokenService
+	passwords         *password.Hasher
+	passwordPolicy    *password.Policy
+	loginThrottle     *LoginThrottleService
+	twoFactor         *TwoFactorService
+	emailVerification *EmailVerificationService
+	audit             *AuditService
+	config            *config.Config
 }
 
 // NewAuthService は新しいAuthServiceインスタンスを作成
-func NewAuthService(userRepo *repository.UserRepository, cfg *config.Config) *AuthService {
+func NewAuthService(
+	repo *repository.Repository,
+	userRepo *repository.UserRepository,
+	sessionRepo *repository.SessionRepository,
+	tokens *auth.TokenService,
+	passwords *password.Hasher,
+	passwordPolicy *password.Policy,
+	loginThrottle *LoginThrottleService,
+	twoFactor *TwoFactorService,
+	emailVerification *EmailVerificationService,
+	audit *AuditService,
+	cfg *config.Config,
+) *AuthService {
 	return &AuthService{
-		userRepo: userRepo,
-		config:   cfg,
+		repo:              repo,
+		userRepo:          userRepo,
+		sessionRepo:       sessionRepo,
+		tokens:            tokens,
+		passwords:         passwords,
+		passwordPolicy:    passwordPolicy,
+		loginThrottle:     loginThrottle,
+		twoFactor:         twoFactor,
+		emailVerification: emailVerification,
+		audit:             audit,
+		config:            cfg,
 	}
 }
 
@@ -33,159 +81,254 @@ func (s *AuthService) GetUserByID(userID uint) (*models.UserResponse, error) {
 	}
 
 	return &models.UserResponse{
-		ID:           user.ID,
-		Username:     user.Username,
-		Email:        user.Email,
-		ProfileImage: user.ProfileImage,
-		CreatedAt:    user.CreatedAt,
-		UpdatedAt:    user.UpdatedAt,
+		ID:               user.ID,
+		Username:         user.Username,
+		Email:            user.Email,
+		ProfileImage:     user.ProfileImage,
+		Role:             user.Role,
+		TwoFactorEnabled: user.TwoFactorEnabled(),
+		EmailVerified:    user.EmailVerified(),
+		CreatedAt:        user.CreatedAt,
+		UpdatedAt:        user.UpdatedAt,
 	}, nil
 }
 
 // UpdateUser はユーザー情報を更新する
-func (s *AuthService) UpdateUser(user *models.User) (*models.UserResponse, error) {
+func (s *AuthService) UpdateUser(userID uint, req *models.UserUpdateRequest, client models.ClientInfo) (*models.UserResponse, error) {
 	// 既存ユーザーの取得
-	existingUser, err := s.userRepo.GetByID(user.ID)
+	existingUser, err := s.userRepo.GetByID(userID)
 	if err != nil {
 		return nil, err
 	}
 
+	before := userAuditSummary(existingUser)
+
 	// 更新するフィールドのみ設定
-	if user.Username != "" {
-		existingUser.Username = user.Username
-	}
-	if user.ProfileImage != "" {
-		existingUser.ProfileImage = user.ProfileImage
+	if req.Username != "" {
+		existingUser.Username = req.Username
 	}
 
-	// メールアドレス変更の場合は重複チェック
-	if user.Email != "" && user.Email != existingUser.Email {
-		existingUserWithEmail, _ := s.userRepo.GetByEmail(user.Email)
-		if existingUserWithEmail != nil {
-			return nil, errors.New("email already registered")
+	// メールアドレス変更の場合は新しいアドレスの確認が済んでから反映する
+	pendingEmail := ""
+	if req.Email != "" && req.Email != existingUser.Email {
+		if err := s.emailVerification.RequestEmailChange(existingUser, req.Email); err != nil {
+			return nil, err
 		}
-		existingUser.Email = user.Email
+		pendingEmail = req.Email
 	}
 
 	// ユーザー情報更新
-	if err := s.userRepo.Update(existingUser); err != nil {
+	err = s.repo.Transaction(func(tx *sqlx.Tx) error {
+		if err := s.userRepo.UpdateTx(tx, existingUser); err != nil {
+			return err
+		}
+		event := newAuditEvent(existingUser.ID, client, models.AuditActionUserUpdate, models.AuditTargetUser, existingUser.ID)
+		event.Before = before
+		event.After = userAuditSummary(existingUser)
+		return s.audit.RecordTx(tx, event)
+	})
+	if err != nil {
 		return nil, err
 	}
 
 	return &models.UserResponse{
-		ID:           existingUser.ID,
-		Username:     existingUser.Username,
-		Email:        existingUser.Email,
-		ProfileImage: existingUser.ProfileImage,
-		CreatedAt:    existingUser.CreatedAt,
-		UpdatedAt:    existingUser.UpdatedAt,
+		ID:               existingUser.ID,
+		Username:         existingUser.Username,
+		Email:            existingUser.Email,
+		ProfileImage:     existingUser.ProfileImage,
+		Role:             existingUser.Role,
+		TwoFactorEnabled: existingUser.TwoFactorEnabled(),
+		EmailVerified:    existingUser.EmailVerified(),
+		PendingEmail:     pendingEmail,
+		CreatedAt:        existingUser.CreatedAt,
+		UpdatedAt:        existingUser.UpdatedAt,
 	}, nil
 }
 
-// RegisterUser は新しいユーザーを登録する
-func (s *AuthService) RegisterUser(req models.UserRegisterRequest) (*models.AuthResponse, error) {
-	// 既存ユーザーの確認
-	existingUser, _ := s.userRepo.GetByEmail(req.Email)
-	if existingUser != nil {
-		return nil, errors.New("email already registered")
+// RegisterUser は新しいユーザーを登録し、メールアドレスの確認メールを送信する
+// 確認が必須の場合はセッションを作成せず、確認待ちであることを返す
+func (s *AuthService) RegisterUser(req models.UserRegisterRequest, client models.ClientInfo) (*models.AuthResponse, *models.EmailVerificationPending, error) {
+	if !s.config.PasswordLoginEnabled {
+		return nil, nil, errPasswordLoginDisabled
+	}
+
+	if err := validatePassword(s.passwordPolicy, "password", req.Password, req.Email, req.Username); err != nil {
+		return nil, nil, err
+	}
+
+	// 許可されたドメインか、既に登録されていないかの確認
+	if err := s.emailVerification.checkEmailAvailable(req.Email); err != nil {
+		return nil, nil, err
 	}
 
 	// パスワードハッシュ化
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+	hashedPassword, err := s.passwords.Hash(req.Password)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	// ユーザー作成
+	// 確認が不要な場合は確認済みとして登録する（後から確認を必須にした際に未確認として扱われないようにする）
 	user := &models.User{
 		Username:     req.Username,
 		Email:        req.Email,
-		PasswordHash: string(hashedPassword),
+		PasswordHash: hashedPassword,
 	}
-
-	if err := s.userRepo.Create(user); err != nil {
-		return nil, err
+	if !s.config.EmailVerificationRequired {
+		verifiedAt := time.Now()
+		user.EmailVerifiedAt = &verifiedAt
 	}
 
-	// JWTトークン生成
-	token, err := s.generateToken(user.ID)
+	err = s.repo.Transaction(func(tx *sqlx.Tx) error {
+		if err := s.userRepo.CreateTx(tx, user); err != nil {
+			return err
+		}
+		event := newAuditEvent(user.ID, client, models.AuditActionUserRegister, models.AuditTargetUser, user.ID)
+		event.After = userAuditSummary(user)
+		return s.audit.RecordTx(tx, event)
+	})
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
-	// レスポンス作成
-	return &models.AuthResponse{
-		Token: token,
-		User: models.UserResponse{
-			ID:           user.ID,
-			Username:     user.Username,
-			Email:        user.Email,
-			ProfileImage: user.ProfileImage,
-			CreatedAt:    user.CreatedAt,
-			UpdatedAt:    user.UpdatedAt,
-		},
-	}, nil
+	if s.config.EmailVerificationRequired {
+		if err := s.emailVerification.SendVerification(user); err != nil {
+			return nil, nil, err
+		}
+		return nil, &models.EmailVerificationPending{
+			VerificationRequired: true,
+			Email:                user.Email,
+		}, nil
+	}
+
+	// セッションを作成してトークンを発行
+	res, err := s.createSession(user, client)
+	return res, nil, err
 }
 
 // Login はユーザーログイン認証を行う
-func (s *AuthService) Login(req models.UserLoginRequest) (*models.AuthResponse, error) {
+// 二要素認証が有効なユーザーにはセッションを作成せず、二要素目の入力を求めるチャレンジを返す
+func (s *AuthService) Login(req models.UserLoginRequest, client models.ClientInfo) (*models.AuthResponse, *models.TwoFactorChallenge, error) {
+	if !s.config.PasswordLoginEnabled {
+		return nil, nil, errPasswordLoginDisabled
+	}
+
+	// 失敗が続いているアカウント・IPアドレスはパスワードを検証せずに拒否する
+	if err := s.loginThrottle.Check(req.Email, client.IPAddress); err != nil {
+		return nil, nil, err
+	}
+
 	// ユーザー取得
 	user, err := s.userRepo.GetByEmail(req.Email)
 	if err != nil {
-		return nil, errors.New("invalid email or password")
+		return nil, nil, s.loginFailed(req.Email, client, nil, errInvalidCredentials)
 	}
 
 	// パスワード検証
-	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(req.Password)); err != nil {
-		return nil, errors.New("invalid email or password")
+	if err := s.passwords.Compare(user.PasswordHash, req.Password); err != nil {
+		return nil, nil, s.loginFailed(req.Email, client, user, errInvalidCredentials)
 	}
 
-	// JWTトークン生成
-	token, err := s.generateToken(user.ID)
+	// ハッシュの設定が変わっている場合は、平文のパスワードを受け取れるログイン時にハッシュし直す
+	rehashPassword(s.userRepo, s.passwords, user, req.Password)
+
+	// 他人のメールアドレスで登録されたアカウントを使わせないよう、確認が済むまでログインさせない
+	if s.config.EmailVerificationRequired && !user.EmailVerified() {
+		return nil, nil, errEmailNotVerified
+	}
+
+	// 二要素目の確認が済むまでは失敗回数を消去しない（パスワードを知る第三者にコードを総当たりさせない）
+	if user.TwoFactorEnabled() {
+		token, err := s.tokens.IssueTwoFactorToken(user.ID)
+		if err != nil {
+			return nil, nil, err
+		}
+		return nil, &models.TwoFactorChallenge{
+			TwoFactorRequired: true,
+			TwoFactorToken:    token,
+			ExpiresIn:         int64(auth.TwoFactorTokenExpiration.Seconds()),
+		}, nil
+	}
+
+	if err := s.loginThrottle.RecordSuccess(req.Email); err != nil {
+		return nil, nil, err
+	}
+
+	// セッションを作成してトークンを発行
+	res, err := s.createSession(user, client)
+	return res, nil, err
+}
+
+// PasswordPolicy は新しいパスワードが満たすべき条件を返す（入力画面での案内に使用する）
+func (s *AuthService) PasswordPolicy() *models.PasswordPolicyResponse {
+	return &models.PasswordPolicyResponse{
+		MinLength:           s.passwordPolicy.MinLength,
+		MaxLength:           s.passwordPolicy.MaxLength,
+		MinCharacterClasses: s.passwordPolicy.MinCharacterClasses,
+	}
+}
+
+// LoginTwoFactor はログインの二要素目（認証アプリのコードまたはリカバリーコード）を検証してセッションを作成する
+// 誤ったコードはパスワードの誤りと同じくログインの失敗として記録する
+func (s *AuthService) LoginTwoFactor(req models.TwoFactorLoginRequest, client models.ClientInfo) (*models.AuthResponse, error) {
+	userID, err := s.tokens.VerifyTwoFactorToken(req.TwoFactorToken)
 	if err != nil {
+		return nil, apperror.Wrap(apperror.ErrUnauthorized, err, "invalid or expired two-factor token")
+	}
+
+	user, err := s.userRepo.GetByID(userID)
+	if err != nil {
+		return nil, apperror.Unauthorized("invalid or expired two-factor token")
+	}
+
+	if err := s.loginThrottle.Check(user.Email, client.IPAddress); err != nil {
 		return nil, err
 	}
 
-	// レスポンス作成
-	return &models.AuthResponse{
-		Token: token,
-		User: models.UserResponse{
-			ID:           user.ID,
-			Username:     user.Username,
-			Email:        user.Email,
-			ProfileImage: user.ProfileImage,
-			CreatedAt:    user.CreatedAt,
-			UpdatedAt:    user.UpdatedAt,
-		},
-	}, nil
+	if err := s.twoFactor.VerifyCode(user, req.Code); err != nil {
+		if isTwoFactorCodeError(err) {
+			return nil, s.loginFailed(user.Email, client, user, err)
+		}
+		return nil, err
+	}
+
+	if err := s.loginThrottle.RecordSuccess(user.Email); err != nil {
+		return nil, err
+	}
+
+	return s.createSession(user, client)
+}
+
+// loginFailed はログインの失敗を記録し、利用者に返すエラーを返す
+// 未登録のメールアドレスとパスワードの誤りは同じエラーにする
+func (s *AuthService) loginFailed(email string, client models.ClientInfo, user *models.User, loginErr error) error {
+	if err := s.loginThrottle.RecordFailure(email, client.IPAddress, user); err != nil {
+		return err
+	}
+	return loginErr
 }
 
-// VerifyToken はJWTトークンを検証してユーザーIDを返す
+// VerifyToken はアクセストークンを検証してユーザーIDを返す
+// 署名・クレームの検証はJWTMiddlewareと同じTokenServiceで行い、セッションの失効も確認する
 func (s *AuthService) VerifyToken(tokenString string) (uint, error) {
-	// トークン解析
-	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, errors.New("unexpected signing method")
-		}
-		return []byte(s.config.JWTSecret), nil
-	})
+	claims, err := s.tokens.Verify(tokenString)
+	if err != nil {
+		return 0, apperror.Wrap(apperror.ErrUnauthorized, err, "invalid token")
+	}
 
+	active, err := s.sessionRepo.IsActive(claims.SessionID)
 	if err != nil {
 		return 0, err
 	}
-
-	// クレーム検証
-	if claims, ok := token.Claims.(jwt.MapClaims); ok && token.Valid {
-		if userID, ok := claims["user_id"].(float64); ok {
-			return uint(userID), nil
-		}
+	if !active {
+		return 0, apperror.Unauthorized("invalid token")
 	}
 
-	return 0, errors.New("invalid token")
+	return claims.UserID, nil
 }
 
-// ChangePassword はユーザーのパスワードを変更する
-func (s *AuthService) ChangePassword(userID uint, currentPassword, newPassword string) error {
+// ChangePassword はユーザーのパスワードを変更し、現在のセッション以外を失効させる
+func (s *AuthService) ChangePassword(userID uint, sessionID, currentPassword, newPassword string, client models.ClientInfo) error {
 	// ユーザー取得
 	user, err := s.userRepo.GetByID(userID)
 	if err != nil {
@@ -193,29 +336,170 @@ func (s *AuthService) ChangePassword(userID uint, currentPassword, newPassword s
 	}
 
 	// 現在のパスワード検証
-	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(currentPassword)); err != nil {
-		return errors.New("current password is incorrect")
+	if err := s.passwords.Compare(user.PasswordHash, currentPassword); err != nil {
+		return apperror.Validation(
+			"current password is incorrect",
+			apperror.FieldError{Field: "current_password", Message: "current password is incorrect"},
+		)
+	}
+
+	if err := validatePassword(s.passwordPolicy, "new_password", newPassword, user.Email, user.Username); err != nil {
+		return err
 	}
 
 	// 新しいパスワードのハッシュ化
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(newPassword), bcrypt.DefaultCost)
+	hashedPassword, err := s.passwords.Hash(newPassword)
 	if err != nil {
 		return err
 	}
 
 	// パスワード更新
-	return s.userRepo.UpdatePassword(userID, string(hashedPassword))
+	err = s.repo.Transaction(func(tx *sqlx.Tx) error {
+		if err := s.userRepo.UpdatePasswordTx(tx, userID, hashedPassword); err != nil {
+			return err
+		}
+		return s.audit.RecordTx(tx, newAuditEvent(userID, client, models.AuditActionPasswordChange, models.AuditTargetUser, userID))
+	})
+	if err != nil {
+		return err
+	}
+
+	// パスワードを変更したセッション以外はすべて失効させる
+	return s.sessionRepo.RevokeAllByUserID(userID, sessionID)
+}
+
+// RefreshToken はリフレッシュトークンを新しいものに置き換え、アクセストークンを再発行する
+// 置き換え済みのトークンが再利用された場合は漏洩とみなし、そのセッションを失効させる
+func (s *AuthService) RefreshToken(rawToken string) (*models.AuthResponse, error) {
+	newToken, err := generateRandomToken()
+	if err != nil {
+		return nil, err
+	}
+
+	session, err := s.sessionRepo.Rotate(hashToken(rawToken), hashToken(newToken), time.Now().Add(s.config.RefreshTokenExpiration))
+	if err != nil {
+		if errors.Is(err, apperror.ErrUnauthorized) {
+			reused, revokeErr := s.sessionRepo.RevokeByPreviousToken(hashToken(rawToken))
+			if revokeErr != nil {
+				return nil, revokeErr
+			}
+			if reused {
+				log.Printf("refresh token reuse detected, session revoked")
+			}
+		}
+		return nil, err
+	}
+
+	// ロールの変更などを反映するため、ユーザー情報は毎回取得し直す
+	user, err := s.userRepo.GetByID(session.UserID)
+	if err != nil {
+		if errors.Is(err, apperror.ErrNotFound) {
+			return nil, apperror.Unauthorized("invalid or expired refresh token")
+		}
+		return nil, err
+	}
+
+	return s.newAuthResponse(user, session.ID, newToken)
+}
+
+// Logout はセッションを失効させる
+func (s *AuthService) Logout(userID uint, sessionID string) error {
+	return s.sessionRepo.Revoke(userID, sessionID)
 }
 
-// generateToken はJWTトークンを生成する
-func (s *AuthService) generateToken(userID uint) (string, error) {
-	// クレーム作成
-	claims := jwt.MapClaims{
-		"user_id": userID,
-		"exp":     time.Now().Add(s.config.JWTExpiration).Unix(),
+// ListSessions はユーザーの有効なセッション一覧を取得する
+func (s *AuthService) ListSessions(userID uint, currentSessionID string) ([]models.SessionResponse, error) {
+	sessions, err := s.sessionRepo.ListActiveByUserID(userID)
+	if err != nil {
+		return nil, err
 	}
 
-	// トークン生成
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString([]byte(s.config.JWTSecret))
+	res := make([]models.SessionResponse, len(sessions))
+	for i, session := range sessions {
+		res[i] = models.SessionResponse{
+			ID:         session.ID,
+			UserAgent:  session.UserAgent,
+			IPAddress:  session.IPAddress,
+			Current:    session.ID == currentSessionID,
+			LastUsedAt: session.LastUsedAt,
+			ExpiresAt:  session.ExpiresAt,
+			CreatedAt:  session.CreatedAt,
+		}
+	}
+	return res, nil
+}
+
+// RevokeSession はユーザーの指定したセッションを失効させる
+func (s *AuthService) RevokeSession(userID uint, sessionID string) error {
+	return s.sessionRepo.Revoke(userID, sessionID)
+}
+
+// RevokeAllSessions はユーザーのすべてのセッションを失効させる（現在のセッションも含む）
+func (s *AuthService) RevokeAllSessions(userID uint) error {
+	return s.sessionRepo.RevokeAllByUserID(userID, "")
+}
+
+// createSession はセッションを作成し、アクセストークンとリフレッシュトークンを発行する
+func (s *AuthService) createSession(user *models.User, client models.ClientInfo) (*models.AuthResponse, error) {
+	sessionID, err := generateSessionID()
+	if err != nil {
+		return nil, err
+	}
+	refreshToken, err := generateRandomToken()
+	if err != nil {
+		return nil, err
+	}
+
+	// 期限切れ・失効済みのセッションはログインのたびに片付ける
+	if err := s.sessionRepo.DeleteExpiredByUserID(user.ID); err != nil {
+		return nil, err
+	}
+
+	session := &models.UserSession{
+		ID:               sessionID,
+		UserID:           user.ID,
+		RefreshTokenHash: hashToken(refreshToken),
+		UserAgent:        truncateRunes(client.UserAgent, maxUserAgentLength),
+		IPAddress:        truncateRunes(client.IPAddress, maxIPAddressLength),
+		ExpiresAt:        time.Now().Add(s.config.RefreshTokenExpiration),
+	}
+	if err := s.sessionRepo.Create(session); err != nil {
+		return nil, err
+	}
+
+	return s.newAuthResponse(user, session.ID, refreshToken)
+}
+
+// newAuthResponse はセッションのアクセストークンを生成してレスポンスを作成する
+func (s *AuthService) newAuthResponse(user *models.User, sessionID, refreshToken string) (*models.AuthResponse, error) {
+	token, err := s.tokens.Issue(user.ID, user.Email, user.Role, sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.AuthResponse{
+		Token:        token,
+		RefreshToken: refreshToken,
+		ExpiresIn:    int64(s.config.AccessTokenExpiration.Seconds()),
+		User: models.UserResponse{
+			ID:               user.ID,
+			Username:         user.Username,
+			Email:            user.Email,
+			ProfileImage:     user.ProfileImage,
+			Role:             user.Role,
+			TwoFactorEnabled: user.TwoFactorEnabled(),
+			EmailVerified:    user.EmailVerified(),
+			CreatedAt:        user.CreatedAt,
+			UpdatedAt:        user.UpdatedAt,
+		},
+	}, nil
+}
+
+// generateSessionID はランダムなセッションIDを生成する
+func generateSessionID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
 }
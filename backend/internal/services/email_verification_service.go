@@ -0,0 +1,248 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/Ryo-cool/guideforge/internal/apperror"
+	"github.com/Ryo-cool/guideforge/internal/config"
+	"github.com/Ryo-cool/guideforge/internal/mail"
+	"github.com/Ryo-cool/guideforge/internal/models"
+	"github.com/Ryo-cool/guideforge/internal/repository"
+	"github.com/jmoiron/sqlx"
+)
+
+// errEmailNotVerified はメールアドレスの確認が済んでいないユーザーのログインを拒否するエラー
+var errEmailNotVerified = apperror.Forbidden("email address has not been verified, please check your inbox")
+
+// EmailVerificationService はメールアドレスの確認に関する機能を提供するサービス
+// 登録時のアドレスと変更後のアドレスは、届いたリンクで確認が済むまでログインに使用できない
+type EmailVerificationService struct {
+	repo             *repository.Repository
+	userRepo         *repository.UserRepository
+	verificationRepo *repository.EmailVerificationRepository
+	audit            *AuditService
+	mailer           mail.Sender
+	config           *config.Config
+}
+
+// NewEmailVerificationService は新しいEmailVerificationServiceインスタンスを作成
+func NewEmailVerificationService(
+	repo *repository.Repository,
+	userRepo *repository.UserRepository,
+	verificationRepo *repository.EmailVerificationRepository,
+	audit *AuditService,
+	mailer mail.Sender,
+	cfg *config.Config,
+) *EmailVerificationService {
+	return &EmailVerificationService{
+		repo:             repo,
+		userRepo:         userRepo,
+		verificationRepo: verificationRepo,
+		audit:            audit,
+		mailer:           mailer,
+		config:           cfg,
+	}
+}
+
+// SendVerification は登録したメールアドレスに確認メールを送信する
+func (s *EmailVerificationService) SendVerification(user *models.User) error {
+	rawToken, err := s.issueToken(user.ID, user.Email)
+	if err != nil {
+		return err
+	}
+
+	body := fmt.Sprintf(
+		"%s 様\n\nGuideForgeへのご登録ありがとうございます。\n以下のリンクからメールアドレスを確認してください（有効期限: %d時間）。\n\n%s\n\nこの登録に心当たりがない場合は、このメールを破棄してください。\n",
+		user.Username,
+		int(s.config.EmailVerificationExpiration.Hours()),
+		s.verificationURL(rawToken),
+	)
+	s.send(user.ID, user.Email, "【GuideForge】メールアドレスの確認", body)
+
+	return nil
+}
+
+// RequestEmailChange は新しいメールアドレスに確認メールを送信する
+// 確認が済むまでは現在のメールアドレスのまま変更しない
+func (s *EmailVerificationService) RequestEmailChange(user *models.User, newEmail string) error {
+	if err := s.checkEmailAvailable(newEmail); err != nil {
+		return err
+	}
+
+	rawToken, err := s.issueToken(user.ID, newEmail)
+	if err != nil {
+		return err
+	}
+
+	body := fmt.Sprintf(
+		"%s 様\n\nメールアドレスの変更を受け付けました。\n以下のリンクから新しいメールアドレスを確認すると変更が完了します（有効期限: %d時間）。\n\n%s\n\nこの変更に心当たりがない場合は、このメールを破棄してください。\n",
+		user.Username,
+		int(s.config.EmailVerificationExpiration.Hours()),
+		s.verificationURL(rawToken),
+	)
+	s.send(user.ID, newEmail, "【GuideForge】メールアドレス変更の確認", body)
+
+	// 第三者による変更に気付けるよう、現在のメールアドレスにも通知する
+	notice := fmt.Sprintf(
+		"%s 様\n\nアカウントのメールアドレスを %s に変更する申請がありました。\n新しいメールアドレスの確認が済むまで変更は反映されません。\n\nこの変更に心当たりがない場合は、パスワードを変更してください。\n",
+		user.Username,
+		newEmail,
+	)
+	s.send(user.ID, user.Email, "【GuideForge】メールアドレス変更の申請がありました", notice)
+
+	return nil
+}
+
+// Resend は確認の済んでいないユーザーに確認メールを再送信する
+// ユーザーの存在有無や確認状況を漏らさないため、対象外の場合もエラーにしない
+func (s *EmailVerificationService) Resend(email string) error {
+	user, err := s.userRepo.GetByEmail(email)
+	if err != nil {
+		if errors.Is(err, apperror.ErrNotFound) {
+			return nil
+		}
+		return err
+	}
+	if user.EmailVerified() {
+		return nil
+	}
+
+	return s.SendVerification(user)
+}
+
+// Verify はトークンを検証し、登録したメールアドレスを確認済みにするか、変更後のメールアドレスに変更する
+func (s *EmailVerificationService) Verify(rawToken string, client models.ClientInfo) error {
+	token, err := s.verificationRepo.Consume(hashToken(rawToken))
+	if err != nil {
+		return err
+	}
+
+	user, err := s.userRepo.GetByID(token.UserID)
+	if err != nil {
+		return err
+	}
+
+	err = s.repo.Transaction(func(tx *sqlx.Tx) error {
+		if token.Email == user.Email {
+			if err := s.userRepo.MarkEmailVerifiedTx(tx, user.ID); err != nil {
+				return err
+			}
+		} else if err := s.userRepo.UpdateEmailTx(tx, user.ID, token.Email); err != nil {
+			return err
+		}
+
+		event := newAuditEvent(user.ID, client, models.AuditActionUserEmailVerify, models.AuditTargetUser, user.ID)
+		event.Before = models.AuditSummary{"email": user.Email, "email_verified": user.EmailVerified()}
+		event.After = models.AuditSummary{"email": token.Email, "email_verified": true}
+		return s.audit.RecordTx(tx, event)
+	})
+	if err != nil {
+		return err
+	}
+
+	// 同一ユーザーの他のトークンも無効化する
+	return s.verificationRepo.InvalidateByUserID(user.ID)
+}
+
+// checkEmailAvailable はメールアドレスを登録・変更に使用できるかを確認する
+// 確認が必須の場合、確認されないまま有効期間を過ぎ、何も作成していないアカウントは削除して使用できるようにする
+func (s *EmailVerificationService) checkEmailAvailable(email string) error {
+	if !emailDomainAllowed(email, s.config.RegistrationAllowedDomains) {
+		return apperror.Validation(
+			"this email domain is not allowed",
+			apperror.FieldError{Field: "email", Message: "this email domain is not allowed"},
+		)
+	}
+
+	if s.config.EmailVerificationRequired {
+		if err := s.deleteStaleUnverified(email); err != nil {
+			return err
+		}
+	}
+
+	if _, err := s.userRepo.GetByEmail(email); err == nil {
+		return apperror.Conflict("email already registered")
+	} else if !errors.Is(err, apperror.ErrNotFound) {
+		return err
+	}
+
+	return nil
+}
+
+// deleteStaleUnverified は確認されないまま有効期間を過ぎたアカウントを削除し、監査ログに記録する
+// 操作者はいないため、監査ログの操作者は空とする
+func (s *EmailVerificationService) deleteStaleUnverified(email string) error {
+	return s.repo.Transaction(func(tx *sqlx.Tx) error {
+		user, err := s.userRepo.DeleteUnverifiedTx(tx, email, time.Now().Add(-s.config.EmailVerificationExpiration))
+		if err != nil || user == nil {
+			return err
+		}
+
+		log.Printf("deleted stale unverified account %d for a new registration", user.ID)
+		event := newAuditEvent(0, models.ClientInfo{}, models.AuditActionUserUnverifiedDelete, models.AuditTargetUser, user.ID)
+		event.ActorID = nil
+		event.Before = userAuditSummary(user)
+		return s.audit.RecordTx(tx, event)
+	})
+}
+
+// issueToken は以前のトークンを無効化して新しい確認トークンを発行する
+func (s *EmailVerificationService) issueToken(userID uint, email string) (string, error) {
+	if err := s.verificationRepo.InvalidateByUserID(userID); err != nil {
+		return "", err
+	}
+
+	rawToken, err := generateRandomToken()
+	if err != nil {
+		return "", err
+	}
+
+	token := &models.EmailVerificationToken{
+		UserID:    userID,
+		Email:     email,
+		TokenHash: hashToken(rawToken),
+		ExpiresAt: time.Now().Add(s.config.EmailVerificationExpiration),
+	}
+	if err := s.verificationRepo.Create(token); err != nil {
+		return "", err
+	}
+
+	return rawToken, nil
+}
+
+// verificationURL は確認用のフロントエンドのURLを返す
+func (s *EmailVerificationService) verificationURL(rawToken string) string {
+	return strings.TrimRight(s.config.FrontendURL, "/") + "/email/verify?token=" + url.QueryEscape(rawToken)
+}
+
+// send はメールを送信する
+// メール送信失敗は利用者には通知せずログに残す（確認メールは再送信できる）
+func (s *EmailVerificationService) send(userID uint, to, subject, body string) {
+	if err := s.mailer.Send(to, subject, body); err != nil {
+		log.Printf("failed to send mail to user %d: %v", userID, err)
+	}
+}
+
+// emailDomainAllowed はメールアドレスのドメインが許可されたドメインのいずれかかを返す
+// 許可するドメインが空の場合はすべてのドメインを許可する
+func emailDomainAllowed(email string, allowedDomains []string) bool {
+	if len(allowedDomains) == 0 {
+		return true
+	}
+	at := strings.LastIndex(email, "@")
+	if at < 0 {
+		return false
+	}
+	domain := email[at+1:]
+	for _, allowed := range allowedDomains {
+		if strings.EqualFold(domain, allowed) {
+			return true
+		}
+	}
+	return false
+}
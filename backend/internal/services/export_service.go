@@ -0,0 +1,80 @@
+package services
+
+import (
+	"io"
+
+	"github.com/Ryo-cool/guideforge/internal/config"
+	"github.com/Ryo-cool/guideforge/internal/export"
+	"github.com/Ryo-cool/guideforge/internal/models"
+	"github.com/Ryo-cool/guideforge/internal/repository"
+	"github.com/Ryo-cool/guideforge/internal/storage"
+)
+
+// ExportService はマニュアルのエクスポート機能を提供するサービス
+type ExportService struct {
+	manualRepo *repository.ManualRepository
+	access     manualAccess
+	storage    storage.Storage
+	config     *config.Config
+}
+
+// NewExportService は新しいExportServiceインスタンスを作成
+func NewExportService(
+	manualRepo *repository.ManualRepository,
+	workspaceRepo *repository.WorkspaceRepository,
+	store storage.Storage,
+	cfg *config.Config,
+) *ExportService {
+	return &ExportService{
+		manualRepo: manualRepo,
+		access:     manualAccess{workspaceRepo: workspaceRepo},
+		storage:    store,
+		config:     cfg,
+	}
+}
+
+// ExportPDF はマニュアルをPDFとして書き出し、エクスポートしたマニュアルを返す
+func (s *ExportService) ExportPDF(w io.Writer, manualID, userID uint) (*models.Manual, error) {
+	manual, err := s.getReadableManual(manualID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	opts := export.PDFOptions{
+		FontPath: s.config.PDFFontPath,
+		Storage:  s.storage,
+	}
+	if err := export.WritePDF(w, manual, opts); err != nil {
+		return nil, err
+	}
+
+	return manual, nil
+}
+
+// ExportMarkdown はマニュアルをMarkdownバンドル（zip）として書き出し、エクスポートしたマニュアルを返す
+func (s *ExportService) ExportMarkdown(w io.Writer, manualID, userID uint) (*models.Manual, error) {
+	manual, err := s.getReadableManual(manualID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := export.WriteMarkdownBundle(w, manual, s.storage); err != nil {
+		return nil, err
+	}
+
+	return manual, nil
+}
+
+// getReadableManual は閲覧権限を確認して手順・画像付きのマニュアルを取得する
+func (s *ExportService) getReadableManual(manualID, userID uint) (*models.Manual, error) {
+	manual, err := s.manualRepo.GetByIDWithSteps(manualID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.access.checkRead(manual, userID); err != nil {
+		return nil, err
+	}
+
+	return manual, nil
+}
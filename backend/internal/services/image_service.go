@@ -0,0 +1,277 @@
+package services
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"path"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/Ryo-cool/guideforge/internal/apperror"
+	"github.com/Ryo-cool/guideforge/internal/config"
+	"github.com/Ryo-cool/guideforge/internal/imageproc"
+	"github.com/Ryo-cool/guideforge/internal/models"
+	"github.com/Ryo-cool/guideforge/internal/repository"
+	"github.com/Ryo-cool/guideforge/internal/storage"
+	"github.com/jmoiron/sqlx"
+)
+
+// 手順画像のサイズ
+const (
+	ImageSizeOriginal  = "original"
+	ImageSizeMedium    = "medium"
+	ImageSizeThumbnail = "thumbnail"
+)
+
+// ImageService はアップロード画像の配信機能を提供するサービス
+type ImageService struct {
+	manualRepo *repository.ManualRepository
+	stepRepo   *repository.StepRepository
+	imageRepo  *repository.ImageRepository
+	userRepo   *repository.UserRepository
+	access     manualAccess
+	storage    storage.Storage
+}
+
+// NewImageService は新しいImageServiceインスタンスを作成
+func NewImageService(
+	manualRepo *repository.ManualRepository,
+	stepRepo *repository.StepRepository,
+	imageRepo *repository.ImageRepository,
+	userRepo *repository.UserRepository,
+	workspaceRepo *repository.WorkspaceRepository,
+	store storage.Storage,
+) *ImageService {
+	return &ImageService{
+		manualRepo: manualRepo,
+		stepRepo:   stepRepo,
+		imageRepo:  imageRepo,
+		userRepo:   userRepo,
+		access:     manualAccess{workspaceRepo: workspaceRepo},
+		storage:    store,
+	}
+}
+
+// GetStepImage は手順画像の情報とファイルを取得する（呼び出し側でファイルをCloseすること）
+// sizeには "original"（省略時）・"medium"・"thumbnail" を指定し、派生画像がない場合は元画像を返す
+// 画像が属するマニュアルの閲覧権限を確認する
+func (s *ImageService) GetStepImage(imageID, userID uint, size string) (*models.Image, *storage.Object, error) {
+	if !validImageSize(size) {
+		return nil, nil, apperror.Validation("Invalid image size")
+	}
+
+	image, err := s.imageRepo.GetByID(imageID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	step, err := s.stepRepo.GetByID(image.StepID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	manual, err := s.manualRepo.GetByID(step.ManualID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if err := s.access.checkRead(manual, userID); err != nil {
+		return nil, nil, err
+	}
+
+	obj, err := s.open(variantPath(image, size))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return image, obj, nil
+}
+
+// GetProfileImage はユーザーのプロフィール画像のファイルを取得する（呼び出し側でCloseすること）
+func (s *ImageService) GetProfileImage(userID uint) (*storage.Object, error) {
+	user, err := s.userRepo.GetByID(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	// プロフィール画像用のキー以外（マニュアルの画像など）は配信しない
+	if user.ProfileImage == "" || !isProfileImageKey(userID, user.ProfileImage) {
+		return nil, apperror.NotFound("profile image not found")
+	}
+
+	return s.open(user.ProfileImage)
+}
+
+// open はストレージからファイルを取得する
+func (s *ImageService) open(key string) (*storage.Object, error) {
+	obj, err := s.storage.Get(key)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return nil, apperror.NotFound("image file not found")
+		}
+		return nil, err
+	}
+	return obj, nil
+}
+
+// storedVariant は保存する画像とそのキー
+type storedVariant struct {
+	key     string
+	variant *imageproc.Variant
+}
+
+// processStepImage は画像を検証・処理し、保存する画像情報とファイルを返す（ファイルの保存は行わない）
+// 形式はマジックバイトで判定し、EXIF（位置情報など）を除去したうえで大きすぎる画像は縮小する
+// 戻り値の画像情報にはファイルに関する項目のみ設定される（StepID・FileNameは呼び出し側で設定する）
+func processStepImage(cfg *config.Config, data []byte) (*models.Image, []storedVariant, error) {
+	src, err := imageproc.Decode(data)
+	if err != nil {
+		if errors.Is(err, imageproc.ErrUnsupportedFormat) || errors.Is(err, imageproc.ErrInvalidImage) {
+			return nil, nil, apperror.Wrap(apperror.ErrValidation, err, "Invalid image file")
+		}
+		return nil, nil, err
+	}
+
+	original, err := src.Encode(cfg.ImageMaxDimension)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	image := &models.Image{
+		FilePath: contentKey(original),
+		FileSize: int64(len(original.Data)),
+		MimeType: original.MimeType,
+		Width:    original.Width,
+		Height:   original.Height,
+	}
+	files := []storedVariant{{key: image.FilePath, variant: original}}
+
+	// 派生画像は元画像より小さくなる場合のみ生成し、それ以外は元画像で代用する
+	if !src.Fits(cfg.ImageMediumSize) {
+		medium, err := src.Encode(cfg.ImageMediumSize)
+		if err != nil {
+			return nil, nil, err
+		}
+		image.MediumPath = contentKey(medium)
+		files = append(files, storedVariant{key: image.MediumPath, variant: medium})
+	}
+	if !src.Fits(cfg.ImageThumbnailSize) {
+		thumbnail, err := src.Encode(cfg.ImageThumbnailSize)
+		if err != nil {
+			return nil, nil, err
+		}
+		image.ThumbnailPath = contentKey(thumbnail)
+		files = append(files, storedVariant{key: image.ThumbnailPath, variant: thumbnail})
+	}
+
+	return image, files, nil
+}
+
+// contentKey は画像の内容のハッシュから保存先のキーを返す
+// 同じ内容の画像は同じキーになり、ファイルは1つだけ保存される
+func contentKey(v *imageproc.Variant) string {
+	sum := sha256.Sum256(v.Data)
+	hash := hex.EncodeToString(sum[:])
+	return path.Join("images", hash[:2], hash+v.Ext)
+}
+
+// sanitizeFileName はファイル名からディレクトリと制御文字を取り除く
+// 元のファイル名は表示用のメタデータとしてのみ保存し、保存先のパスには使用しない
+func sanitizeFileName(name string) string {
+	name = path.Base(strings.ReplaceAll(name, `\`, "/"))
+	name = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) || r == utf8.RuneError {
+			return -1
+		}
+		return r
+	}, name)
+	name = strings.TrimSpace(name)
+	if name == "" || name == "." || name == "/" {
+		name = "image"
+	}
+	return truncateRunes(name, 255)
+}
+
+// imageFileStore は画像ファイルを参照カウント付きで保存・削除する
+type imageFileStore struct {
+	repo     *repository.Repository
+	fileRepo *repository.ImageFileRepository
+	storage  storage.Storage
+}
+
+// putTx はトランザクション内で画像ファイルを保存する
+// 同じ内容のファイルが既に保存されている場合は保存を省略する
+// 参照カウントは同じトランザクションで画像行を作成したときにトリガーで加算される
+func (f imageFileStore) putTx(tx *sqlx.Tx, files []storedVariant) error {
+	for _, file := range files {
+		created, err := f.fileRepo.AcquireTx(tx, file.key)
+		if err != nil {
+			return err
+		}
+		if !created {
+			continue
+		}
+
+		if err := f.storage.Put(file.key, bytes.NewReader(file.variant.Data), int64(len(file.variant.Data)), file.variant.MimeType); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// release は画像・改訂履歴のどこからも参照されなくなったファイルを削除する
+// ファイルの削除に失敗した場合は行の削除もロールバックし、参照されていない状態のまま残す
+func (f imageFileStore) release(paths []string) error {
+	if len(paths) == 0 {
+		return nil
+	}
+
+	return f.repo.Transaction(func(tx *sqlx.Tx) error {
+		unreferenced, err := f.fileRepo.ReleaseTx(tx, paths)
+		if err != nil {
+			return err
+		}
+
+		for _, filePath := range unreferenced {
+			if err := f.storage.Delete(filePath); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// imageFilePaths は画像と派生画像のファイルパスを返す
+func imageFilePaths(image *models.Image) []string {
+	paths := []string{image.FilePath}
+	if image.MediumPath != "" {
+		paths = append(paths, image.MediumPath)
+	}
+	if image.ThumbnailPath != "" {
+		paths = append(paths, image.ThumbnailPath)
+	}
+	return paths
+}
+
+// validImageSize は画像サイズの指定が有効かを返す
+func validImageSize(size string) bool {
+	switch size {
+	case "", ImageSizeOriginal, ImageSizeMedium, ImageSizeThumbnail:
+		return true
+	}
+	return false
+}
+
+// variantPath は指定サイズの画像のファイルパスを返す（派生画像がない場合は元画像）
+func variantPath(image *models.Image, size string) string {
+	switch {
+	case size == ImageSizeMedium && image.MediumPath != "":
+		return image.MediumPath
+	case size == ImageSizeThumbnail && image.ThumbnailPath != "":
+		return image.ThumbnailPath
+	}
+	return image.FilePath
+}
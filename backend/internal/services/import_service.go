@@ -0,0 +1,139 @@
+package services
+
+import (
+	"io"
+
+	"github.com/Ryo-cool/guideforge/internal/config"
+	"github.com/Ryo-cool/guideforge/internal/export"
+	"github.com/Ryo-cool/guideforge/internal/models"
+	"github.com/Ryo-cool/guideforge/internal/repository"
+	"github.com/Ryo-cool/guideforge/internal/storage"
+	"github.com/jmoiron/sqlx"
+)
+
+// ImportService はマニュアルのインポート機能を提供するサービス
+type ImportService struct {
+	repo         *repository.Repository
+	manualRepo   *repository.ManualRepository
+	stepRepo     *repository.StepRepository
+	imageRepo    *repository.ImageRepository
+	revisionRepo *repository.RevisionRepository
+	audit        *AuditService
+	access       manualAccess
+	imageFiles   imageFileStore
+	config       *config.Config
+}
+
+// NewImportService は新しいImportServiceインスタンスを作成
+func NewImportService(
+	repo *repository.Repository,
+	manualRepo *repository.ManualRepository,
+	stepRepo *repository.StepRepository,
+	imageRepo *repository.ImageRepository,
+	imageFileRepo *repository.ImageFileRepository,
+	revisionRepo *repository.RevisionRepository,
+	workspaceRepo *repository.WorkspaceRepository,
+	audit *AuditService,
+	store storage.Storage,
+	cfg *config.Config,
+) *ImportService {
+	return &ImportService{
+		repo:         repo,
+		manualRepo:   manualRepo,
+		stepRepo:     stepRepo,
+		imageRepo:    imageRepo,
+		revisionRepo: revisionRepo,
+		audit:        audit,
+		access:       manualAccess{workspaceRepo: workspaceRepo},
+		imageFiles:   imageFileStore{repo: repo, fileRepo: imageFileRepo, storage: store},
+		config:       cfg,
+	}
+}
+
+// ImportMarkdown はMarkdownバンドル（zip）から新しいマニュアルを作成する
+// マニュアル・手順・画像は1つのトランザクションで作成し、失敗時は保存した画像ファイルも削除する
+func (s *ImportService) ImportMarkdown(userID uint, workspaceID *uint, r io.ReaderAt, size int64, client models.ClientInfo) (*models.Manual, error) {
+	// ワークスペースに作成する場合は編集権限を持つメンバーであることを確認
+	if workspaceID != nil {
+		if err := s.access.checkWorkspaceEditor(*workspaceID, userID); err != nil {
+			return nil, err
+		}
+	}
+
+	bundle, err := export.ReadMarkdownBundle(r, size, export.BundleLimits{
+		MaxImageSize: s.config.MaxUploadSize,
+		MaxTotalSize: s.config.MaxImportSize,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	manual := &models.Manual{
+		Title:       bundle.Title,
+		Description: bundle.Description,
+		Category:    bundle.Category,
+		UserID:      userID,
+		WorkspaceID: workspaceID,
+		IsPublic:    bundle.IsPublic,
+	}
+
+	var written []string
+	err = s.repo.Transaction(func(tx *sqlx.Tx) error {
+		if err := s.manualRepo.CreateTx(tx, manual); err != nil {
+			return err
+		}
+
+		for i, bundleStep := range bundle.Steps {
+			step := &models.Step{
+				ManualID:    manual.ID,
+				OrderNumber: i,
+				Title:       bundleStep.Title,
+				Content:     bundleStep.Content,
+			}
+			if err := s.stepRepo.CreateTx(tx, step); err != nil {
+				return err
+			}
+
+			for _, bundleImage := range bundleStep.Images {
+				image, files, err := processStepImage(s.config, bundleImage.Data)
+				if err != nil {
+					return err
+				}
+				written = append(written, imageFilePaths(image)...)
+				if err := s.imageFiles.putTx(tx, files); err != nil {
+					return err
+				}
+
+				image.StepID = step.ID
+				image.FileName = sanitizeFileName(bundleImage.FileName)
+				if err := s.imageRepo.CreateTx(tx, image); err != nil {
+					return err
+				}
+			}
+		}
+
+		if _, err := s.revisionRepo.CreateTx(tx, manual.ID, userID, "manual imported"); err != nil {
+			return err
+		}
+
+		event := newAuditEvent(userID, client, models.AuditActionManualImport, models.AuditTargetManual, manual.ID)
+		event.After = manualAuditSummary(manual)
+		event.After["step_count"] = len(bundle.Steps)
+		return s.audit.RecordTx(tx, event)
+	})
+	if err != nil {
+		// 保存済みで参照されていない画像ファイルを削除
+		s.imageFiles.release(written) // エラーは無視
+		return nil, err
+	}
+
+	return s.manualRepo.GetByIDWithSteps(manual.ID)
+}
+
+// truncateRunes は文字列を指定した文字数までに切り詰める
+func truncateRunes(s string, n int) string {
+	if runes := []rune(s); len(runes) > n {
+		return string(runes[:n])
+	}
+	return s
+}
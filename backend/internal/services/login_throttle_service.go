@@ -0,0 +1,217 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/Ryo-cool/guideforge/internal/apperror"
+	"github.com/Ryo-cool/guideforge/internal/config"
+	"github.com/Ryo-cool/guideforge/internal/mail"
+	"github.com/Ryo-cool/guideforge/internal/models"
+	"github.com/Ryo-cool/guideforge/internal/repository"
+	"github.com/jmoiron/sqlx"
+)
+
+const (
+	// loginBackoffBase は失敗回数に応じて待たせる時間の初期値（失敗するたびに倍になる）
+	loginBackoffBase = time.Second
+	// loginBackoffMax は上限に達するまでの待ち時間の最大値
+	loginBackoffMax = time.Minute
+)
+
+// errLoginThrottled はログイン試行が制限されている場合のエラーメッセージ
+// アカウントとIPアドレスのどちらで制限されたかは区別しない
+const errLoginThrottled = "too many failed login attempts, please try again later"
+
+// LoginThrottleService はアカウント・IPアドレスごとにログインの失敗を記録し、総当たり攻撃を防ぐ
+// 上限の半分までは制限せず、それを超えると失敗するたびに待ち時間を倍にし、上限に達するとロックする
+type LoginThrottleService struct {
+	repo         *repository.Repository
+	throttleRepo *repository.LoginThrottleRepository
+	userRepo     *repository.UserRepository
+	audit        *AuditService
+	mailer       mail.Sender
+	config       *config.Config
+}
+
+// NewLoginThrottleService は新しいLoginThrottleServiceインスタンスを作成
+func NewLoginThrottleService(
+	repo *repository.Repository,
+	throttleRepo *repository.LoginThrottleRepository,
+	userRepo *repository.UserRepository,
+	audit *AuditService,
+	mailer mail.Sender,
+	cfg *config.Config,
+) *LoginThrottleService {
+	return &LoginThrottleService{
+		repo:         repo,
+		throttleRepo: throttleRepo,
+		userRepo:     userRepo,
+		audit:        audit,
+		mailer:       mailer,
+		config:       cfg,
+	}
+}
+
+// Check はアカウントまたはIPアドレスがロックされていないかを確認する
+// ロック中はパスワードを検証せずにエラーを返す
+func (s *LoginThrottleService) Check(email, ipAddress string) error {
+	lockedFor, err := s.throttleRepo.LockedFor(accountThrottleKey(email), ipThrottleKey(ipAddress))
+	if err != nil {
+		return err
+	}
+	if lockedFor > 0 {
+		return apperror.RateLimited(lockedFor, errLoginThrottled)
+	}
+	return nil
+}
+
+// RecordFailure はログインの失敗を記録し、必要に応じてアカウント・IPアドレスをロックする
+// user は該当するユーザーがいる場合のみ指定し、アカウントがロックされたときに通知する
+func (s *LoginThrottleService) RecordFailure(email, ipAddress string, user *models.User) error {
+	// 古い記録はログインの失敗のたびに片付ける
+	if err := s.throttleRepo.DeleteStale(s.config.LoginLockoutDuration); err != nil {
+		return err
+	}
+
+	locked, err := s.recordFailure(accountThrottleKey(email), s.config.LoginMaxFailures)
+	if err != nil {
+		return err
+	}
+	if locked {
+		log.Printf("login locked for account %q after %d failed attempts (last from %s)", email, s.config.LoginMaxFailures, ipAddress)
+		if user != nil {
+			s.notifyLockout(user, ipAddress)
+		}
+	}
+
+	if ipAddress == "" {
+		return nil
+	}
+	locked, err = s.recordFailure(ipThrottleKey(ipAddress), s.config.LoginIPMaxFailures)
+	if err != nil {
+		return err
+	}
+	if locked {
+		log.Printf("login locked for IP address %s after %d failed attempts", ipAddress, s.config.LoginIPMaxFailures)
+	}
+	return nil
+}
+
+// RecordSuccess はログインに成功したアカウントの失敗の記録を消去する
+// IPアドレスの記録は、リスト型攻撃の途中で成功したログインにより消去されないよう残す
+func (s *LoginThrottleService) RecordSuccess(email string) error {
+	_, err := s.throttleRepo.Delete(accountThrottleKey(email))
+	return err
+}
+
+// UnlockUser はユーザーのアカウントのロックを解除し、失敗回数を消去する
+// ロックされていなかった場合も成功とする
+func (s *LoginThrottleService) UnlockUser(userID uint) error {
+	user, err := s.userRepo.GetByID(userID)
+	if err != nil {
+		return err
+	}
+
+	unlocked, err := s.throttleRepo.Delete(accountThrottleKey(user.Email))
+	if err != nil {
+		return err
+	}
+	if unlocked {
+		log.Printf("login unlocked for user %d", userID)
+	}
+	return nil
+}
+
+// UnlockUserByAdmin は管理者の操作としてユーザーのアカウントのロックを解除し、監査ログに記録する
+// ロックされていなかった場合も成功とする
+func (s *LoginThrottleService) UnlockUserByAdmin(actorID, userID uint, client models.ClientInfo) error {
+	user, err := s.userRepo.GetByID(userID)
+	if err != nil {
+		return err
+	}
+
+	return s.repo.Transaction(func(tx *sqlx.Tx) error {
+		unlocked, err := s.throttleRepo.DeleteTx(tx, accountThrottleKey(user.Email))
+		if err != nil {
+			return err
+		}
+		event := newAuditEvent(actorID, client, models.AuditActionUserUnlock, models.AuditTargetUser, userID)
+		event.After = models.AuditSummary{"was_locked": unlocked}
+		return s.audit.RecordTx(tx, event)
+	})
+}
+
+// recordFailure は失敗を記録し、失敗回数に応じた期間だけロックする
+// 今回の失敗で上限に達した場合は true を返す
+func (s *LoginThrottleService) recordFailure(key string, maxFailures int) (bool, error) {
+	throttle, err := s.throttleRepo.RecordFailure(key, s.config.LoginLockoutDuration)
+	if err != nil {
+		return false, err
+	}
+
+	delay := loginBackoff(throttle.Failures, maxFailures, s.config.LoginLockoutDuration)
+	if delay <= 0 {
+		return false, nil
+	}
+	if err := s.throttleRepo.Lock(key, delay); err != nil {
+		return false, err
+	}
+	return throttle.Failures == maxFailures, nil
+}
+
+// notifyLockout はアカウントがロックされたことをユーザーにメールで通知する
+func (s *LoginThrottleService) notifyLockout(user *models.User, ipAddress string) {
+	resetURL := strings.TrimRight(s.config.FrontendURL, "/") + "/password/reset"
+	body := fmt.Sprintf(
+		"%s 様\n\nお使いのアカウントでログインの失敗が続いたため、一時的にログインを制限しました（%d分間）。\n\n最後に失敗したアクセス元: %s\n日時: %s\n\nご自身の操作でない場合は、第三者がパスワードを推測しようとしている可能性があります。\n以下のページからパスワードを変更してください。\n\n%s\n",
+		user.Username,
+		int(s.config.LoginLockoutDuration.Minutes()),
+		ipAddress,
+		time.Now().Format("2006-01-02 15:04:05 MST"),
+		resetURL,
+	)
+
+	if err := s.mailer.Send(user.Email, "【GuideForge】ログインを一時的に制限しました", body); err != nil {
+		// 通知の失敗でログインのレスポンスを変えないようログに残す
+		log.Printf("failed to send lockout notification to user %d: %v", user.ID, err)
+	}
+}
+
+// loginBackoff は失敗回数に応じてログインを拒否する期間を返す
+// 上限の半分までは0、それ以降は1秒から倍々に増やし（最大 loginBackoffMax）、上限に達した場合は lockout とする
+func loginBackoff(failures, maxFailures int, lockout time.Duration) time.Duration {
+	if failures >= maxFailures {
+		return lockout
+	}
+
+	free := maxFailures / 2
+	if failures <= free {
+		return 0
+	}
+
+	delay := loginBackoffBase
+	for i := free + 1; i < failures && delay < loginBackoffMax; i++ {
+		delay *= 2
+	}
+	if delay > loginBackoffMax {
+		delay = loginBackoffMax
+	}
+	if delay > lockout {
+		delay = lockout
+	}
+	return delay
+}
+
+// accountThrottleKey はアカウントの失敗を記録するキーを返す
+// 登録の有無にかかわらずメールアドレスで記録し、アカウントの存在を推測できないようにする
+func accountThrottleKey(email string) string {
+	return "account:" + strings.ToLower(strings.TrimSpace(email))
+}
+
+// ipThrottleKey はIPアドレスの失敗を記録するキーを返す
+func ipThrottleKey(ipAddress string) string {
+	return "ip:" + ipAddress
+}
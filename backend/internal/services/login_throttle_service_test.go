@@ -0,0 +1,37 @@
+package services
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLoginBackoff(t *testing.T) {
+	const lockout = 15 * time.Minute
+
+	tests := []struct {
+		name        string
+		failures    int
+		maxFailures int
+		lockout     time.Duration
+		want        time.Duration
+	}{
+		{name: "no failures", failures: 0, maxFailures: 10, lockout: lockout, want: 0},
+		{name: "half of max", failures: 5, maxFailures: 10, lockout: lockout, want: 0},
+		{name: "just over half", failures: 6, maxFailures: 10, lockout: lockout, want: time.Second},
+		{name: "doubles", failures: 7, maxFailures: 10, lockout: lockout, want: 2 * time.Second},
+		{name: "max minus one", failures: 9, maxFailures: 10, lockout: lockout, want: 8 * time.Second},
+		{name: "at max", failures: 10, maxFailures: 10, lockout: lockout, want: lockout},
+		{name: "over max", failures: 11, maxFailures: 10, lockout: lockout, want: lockout},
+		{name: "capped at loginBackoffMax", failures: 29, maxFailures: 30, lockout: lockout, want: loginBackoffMax},
+		{name: "capped at lockout", failures: 29, maxFailures: 30, lockout: 30 * time.Second, want: 30 * time.Second},
+		{name: "odd max", failures: 3, maxFailures: 5, lockout: lockout, want: time.Second},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := loginBackoff(tt.failures, tt.maxFailures, tt.lockout); got != tt.want {
+				t.Errorf("loginBackoff(%d, %d, %v) = %v, want %v", tt.failures, tt.maxFailures, tt.lockout, got, tt.want)
+			}
+		})
+	}
+}
@@ -1,51 +1,86 @@
 package services
 
 import (
-	"errors"
+	"log"
 	"math"
-	"os"
-	"path/filepath"
-	"strconv"
 
+	"github.com/Ryo-cool/guideforge/internal/apperror"
 	"github.com/Ryo-cool/guideforge/internal/config"
 	"github.com/Ryo-cool/guideforge/internal/models"
 	"github.com/Ryo-cool/guideforge/internal/repository"
+	"github.com/Ryo-cool/guideforge/internal/storage"
+	"github.com/jmoiron/sqlx"
 )
 
 // ManualService はマニュアル関連の機能を提供するサービス
 type ManualService struct {
-	manualRepo *repository.ManualRepository
-	stepRepo   *repository.StepRepository
-	imageRepo  *repository.ImageRepository
-	config     *config.Config
+	repo         *repository.Repository
+	manualRepo   *repository.ManualRepository
+	stepRepo     *repository.StepRepository
+	imageRepo    *repository.ImageRepository
+	revisionRepo *repository.RevisionRepository
+	audit        *AuditService
+	access       manualAccess
+	imageFiles   imageFileStore
+	config       *config.Config
 }
 
 // NewManualService は新しいManualServiceインスタンスを作成
 func NewManualService(
+	repo *repository.Repository,
 	manualRepo *repository.ManualRepository,
 	stepRepo *repository.StepRepository,
 	imageRepo *repository.ImageRepository,
+	imageFileRepo *repository.ImageFileRepository,
+	revisionRepo *repository.RevisionRepository,
+	workspaceRepo *repository.WorkspaceRepository,
+	audit *AuditService,
+	store storage.Storage,
 	cfg *config.Config,
 ) *ManualService {
 	return &ManualService{
-		manualRepo: manualRepo,
-		stepRepo:   stepRepo,
-		imageRepo:  imageRepo,
-		config:     cfg,
+		repo:         repo,
+		manualRepo:   manualRepo,
+		stepRepo:     stepRepo,
+		imageRepo:    imageRepo,
+		revisionRepo: revisionRepo,
+		audit:        audit,
+		access:       manualAccess{workspaceRepo: workspaceRepo},
+		imageFiles:   imageFileStore{repo: repo, fileRepo: imageFileRepo, storage: store},
+		config:       cfg,
 	}
 }
 
 // CreateManual は新しいマニュアルを作成する
-func (s *ManualService) CreateManual(userID uint, req models.ManualRequest) (*models.Manual, error) {
+func (s *ManualService) CreateManual(userID uint, req models.ManualRequest, client models.ClientInfo) (*models.Manual, error) {
+	// ワークスペースに作成する場合は編集権限を持つメンバーであることを確認
+	if req.WorkspaceID != nil {
+		if err := s.access.checkWorkspaceEditor(*req.WorkspaceID, userID); err != nil {
+			return nil, err
+		}
+	}
+
 	manual := &models.Manual{
 		Title:       req.Title,
 		Description: req.Description,
 		Category:    req.Category,
 		UserID:      userID,
+		WorkspaceID: req.WorkspaceID,
 		IsPublic:    req.IsPublic,
 	}
 
-	if err := s.manualRepo.Create(manual); err != nil {
+	err := s.repo.Transaction(func(tx *sqlx.Tx) error {
+		if err := s.manualRepo.CreateTx(tx, manual); err != nil {
+			return err
+		}
+		if _, err := s.revisionRepo.CreateTx(tx, manual.ID, userID, "manual created"); err != nil {
+			return err
+		}
+		event := newAuditEvent(userID, client, models.AuditActionManualCreate, models.AuditTargetManual, manual.ID)
+		event.After = manualAuditSummary(manual)
+		return s.audit.RecordTx(tx, event)
+	})
+	if err != nil {
 		return nil, err
 	}
 
@@ -59,14 +94,46 @@ func (s *ManualService) GetManualByID(id uint, userID uint) (*models.Manual, err
 		return nil, err
 	}
 
-	// 非公開マニュアルの場合、所有者のみアクセス可能
-	if !manual.IsPublic && manual.UserID != userID {
-		return nil, errors.New("unauthorized access")
+	// 非公開マニュアルの場合、作成者またはワークスペースメンバーのみアクセス可能
+	if err := s.access.checkRead(manual, userID); err != nil {
+		return nil, err
 	}
 
 	return manual, nil
 }
 
+// GetWorkspaceManuals はワークスペースのマニュアル一覧を取得する
+func (s *ManualService) GetWorkspaceManuals(workspaceID, userID uint, page, limit int) (*models.PaginatedResponse, error) {
+	if err := s.access.checkWorkspaceMember(workspaceID, userID); err != nil {
+		return nil, err
+	}
+
+	// 不正な値をデフォルト値に修正
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 10
+	}
+
+	manuals, total, err := s.manualRepo.GetAllByWorkspaceID(workspaceID, page, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	totalPages := int(math.Ceil(float64(total) / float64(limit)))
+
+	return &models.PaginatedResponse{
+		Pagination: models.PaginationResponse{
+			Total:      total,
+			Page:       page,
+			Limit:      limit,
+			TotalPages: totalPages,
+		},
+		Items: manuals,
+	}, nil
+}
+
 // GetUserManuals はユーザーのマニュアル一覧を取得する
 func (s *ManualService) GetUserManuals(userID uint, page, limit int) (*models.PaginatedResponse, error) {
 	// 不正な値をデフォルト値に修正
@@ -124,15 +191,31 @@ func (s *ManualService) GetPublicManuals(page, limit int) (*models.PaginatedResp
 }
 
 // UpdateManual はマニュアル情報を更新する
-func (s *ManualService) UpdateManual(id, userID uint, req models.ManualRequest) (*models.Manual, error) {
+func (s *ManualService) UpdateManual(id, userID uint, req models.ManualRequest, client models.ClientInfo) (*models.Manual, error) {
 	manual, err := s.manualRepo.GetByID(id)
 	if err != nil {
 		return nil, err
 	}
 
-	// 所有者チェック
-	if manual.UserID != userID {
-		return nil, errors.New("unauthorized access")
+	// 編集権限チェック
+	if err := s.access.checkEdit(manual, userID); err != nil {
+		return nil, err
+	}
+
+	before := manualAuditSummary(manual)
+
+	// 所属ワークスペースの変更
+	if !sameWorkspace(manual.WorkspaceID, req.WorkspaceID) {
+		if req.WorkspaceID != nil {
+			// 移動先で編集権限を持つメンバーであることを確認
+			if err := s.access.checkWorkspaceEditor(*req.WorkspaceID, userID); err != nil {
+				return nil, err
+			}
+		} else if manual.UserID != userID {
+			// 個人マニュアルに戻せるのは作成者のみ
+			return nil, apperror.Forbidden("only the creator can move a manual out of its workspace")
+		}
+		manual.WorkspaceID = req.WorkspaceID
 	}
 
 	// 情報更新
@@ -141,7 +224,19 @@ func (s *ManualService) UpdateManual(id, userID uint, req models.ManualRequest)
 	manual.Category = req.Category
 	manual.IsPublic = req.IsPublic
 
-	if err := s.manualRepo.Update(manual); err != nil {
+	err = s.repo.Transaction(func(tx *sqlx.Tx) error {
+		if err := s.manualRepo.UpdateTx(tx, manual); err != nil {
+			return err
+		}
+		if _, err := s.revisionRepo.CreateTx(tx, manual.ID, userID, "manual updated"); err != nil {
+			return err
+		}
+		event := newAuditEvent(userID, client, models.AuditActionManualUpdate, models.AuditTargetManual, manual.ID)
+		event.Before = before
+		event.After = manualAuditSummary(manual)
+		return s.audit.RecordTx(tx, event)
+	})
+	if err != nil {
 		return nil, err
 	}
 
@@ -149,40 +244,60 @@ func (s *ManualService) UpdateManual(id, userID uint, req models.ManualRequest)
 }
 
 // DeleteManual はマニュアルを削除する
-func (s *ManualService) DeleteManual(id, userID uint) error {
+func (s *ManualService) DeleteManual(id, userID uint, client models.ClientInfo) error {
 	// マニュアルの取得
 	manual, err := s.manualRepo.GetByIDWithSteps(id)
 	if err != nil {
 		return err
 	}
 
-	// 所有者チェック
-	if manual.UserID != userID {
-		return errors.New("unauthorized access")
+	// 編集権限チェック
+	if err := s.access.checkEdit(manual, userID); err != nil {
+		return err
 	}
 
-	// 関連する画像ファイルの削除
+	// 改訂履歴が参照している画像ファイルも削除対象に含める
+	paths, err := s.revisionRepo.GetImagePathsByManualID(id)
+	if err != nil {
+		return err
+	}
 	for _, step := range manual.Steps {
-		for _, image := range step.Images {
-			imagePath := filepath.Join(s.config.UploadDir, image.FilePath)
-			os.Remove(imagePath) // エラーは無視
+		for i := range step.Images {
+			paths = append(paths, imageFilePaths(&step.Images[i])...)
 		}
 	}
 
-	// マニュアルの削除
-	return s.manualRepo.Delete(id, userID)
+	// マニュアルの削除（手順・画像・改訂履歴はカスケード削除される）
+	err = s.repo.Transaction(func(tx *sqlx.Tx) error {
+		event := newAuditEvent(userID, client, models.AuditActionManualDelete, models.AuditTargetManual, id)
+		event.Before = manualAuditSummary(manual)
+		if err := s.audit.RecordTx(tx, event); err != nil {
+			return err
+		}
+		return s.manualRepo.DeleteTx(tx, id, userID)
+	})
+	if err != nil {
+		return err
+	}
+
+	// 他のマニュアルから参照されていない画像ファイルの削除
+	if err := s.imageFiles.release(paths); err != nil {
+		log.Printf("failed to release image files of manual %d: %v", id, err)
+	}
+
+	return nil
 }
 
 // CreateStep はマニュアルに新しい手順を追加する
-func (s *ManualService) CreateStep(manualID, userID uint, req models.StepRequest) (*models.Step, error) {
-	// マニュアルの所有者チェック
+func (s *ManualService) CreateStep(manualID, userID uint, req models.StepRequest, client models.ClientInfo) (*models.Step, error) {
+	// マニュアルの編集権限チェック
 	manual, err := s.manualRepo.GetByID(manualID)
 	if err != nil {
 		return nil, err
 	}
 
-	if manual.UserID != userID {
-		return nil, errors.New("unauthorized access")
+	if err := s.access.checkEdit(manual, userID); err != nil {
+		return nil, err
 	}
 
 	// 手順の作成
@@ -197,7 +312,18 @@ func (s *ManualService) CreateStep(manualID, userID uint, req models.StepRequest
 		step.OrderNumber = *req.OrderNumber
 	}
 
-	if err := s.stepRepo.Create(step); err != nil {
+	err = s.repo.Transaction(func(tx *sqlx.Tx) error {
+		if err := s.stepRepo.CreateTx(tx, step); err != nil {
+			return err
+		}
+		if _, err := s.revisionRepo.CreateTx(tx, manualID, userID, "step created"); err != nil {
+			return err
+		}
+		event := newAuditEvent(userID, client, models.AuditActionStepCreate, models.AuditTargetStep, step.ID)
+		event.After = stepAuditSummary(step)
+		return s.audit.RecordTx(tx, event)
+	})
+	if err != nil {
 		return nil, err
 	}
 
@@ -205,102 +331,193 @@ func (s *ManualService) CreateStep(manualID, userID uint, req models.StepRequest
 }
 
 // UpdateStep は手順情報を更新する
-func (s *ManualService) UpdateStep(id, userID uint, req models.StepRequest) (*models.Step, error) {
+func (s *ManualService) UpdateStep(id, userID uint, req models.StepRequest, client models.ClientInfo) (*models.Step, error) {
 	// 手順の取得
 	step, err := s.stepRepo.GetByID(id)
 	if err != nil {
 		return nil, err
 	}
 
-	// マニュアルの所有者チェック
+	// マニュアルの編集権限チェック
 	manual, err := s.manualRepo.GetByID(step.ManualID)
 	if err != nil {
 		return nil, err
 	}
 
-	if manual.UserID != userID {
-		return nil, errors.New("unauthorized access")
+	if err := s.access.checkEdit(manual, userID); err != nil {
+		return nil, err
 	}
 
+	before := stepAuditSummary(step)
+
 	// 情報更新
 	step.Title = req.Title
 	step.Content = req.Content
 
-	if err := s.stepRepo.Update(step); err != nil {
+	err = s.repo.Transaction(func(tx *sqlx.Tx) error {
+		if err := s.stepRepo.UpdateTx(tx, step); err != nil {
+			return err
+		}
+		if _, err := s.revisionRepo.CreateTx(tx, step.ManualID, userID, "step updated"); err != nil {
+			return err
+		}
+		event := newAuditEvent(userID, client, models.AuditActionStepUpdate, models.AuditTargetStep, step.ID)
+		event.Before = before
+		event.After = stepAuditSummary(step)
+		return s.audit.RecordTx(tx, event)
+	})
+	if err != nil {
 		return nil, err
 	}
 
 	return step, nil
 }
 
+// GetManualSteps はマニュアルの手順一覧を順序通りに取得する
+func (s *ManualService) GetManualSteps(manualID, userID uint) ([]models.Step, error) {
+	manual, err := s.GetManualByID(manualID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if manual.Steps == nil {
+		return []models.Step{}, nil
+	}
+
+	return manual.Steps, nil
+}
+
 // DeleteStep は手順を削除する
-func (s *ManualService) DeleteStep(id, userID uint) error {
-	// 関連する画像ファイルの削除
+func (s *ManualService) DeleteStep(id, userID uint, client models.ClientInfo) error {
+	// 手順の取得
+	step, err := s.stepRepo.GetByID(id)
+	if err != nil {
+		return err
+	}
+
+	// マニュアルの編集権限チェック
+	manual, err := s.manualRepo.GetByID(step.ManualID)
+	if err != nil {
+		return err
+	}
+
+	if err := s.access.checkEdit(manual, userID); err != nil {
+		return err
+	}
+
 	images, err := s.imageRepo.GetImagesByStepID(id)
 	if err != nil {
 		return err
 	}
 
-	for _, image := range images {
-		imagePath := filepath.Join(s.config.UploadDir, image.FilePath)
-		os.Remove(imagePath) // エラーは無視
+	// 手順の削除（画像はカスケード削除される）
+	err = s.repo.Transaction(func(tx *sqlx.Tx) error {
+		event := newAuditEvent(userID, client, models.AuditActionStepDelete, models.AuditTargetStep, id)
+		event.Before = stepAuditSummary(step)
+		if err := s.audit.RecordTx(tx, event); err != nil {
+			return err
+		}
+		if err := s.stepRepo.DeleteTx(tx, id, userID); err != nil {
+			return err
+		}
+		_, err := s.revisionRepo.CreateTx(tx, manual.ID, userID, "step deleted")
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	// 画像ファイルは改訂履歴などから参照されている間は復元できるよう残し、参照がなくなった場合のみ削除する
+	var paths []string
+	for i := range images {
+		paths = append(paths, imageFilePaths(&images[i])...)
+	}
+	if err := s.imageFiles.release(paths); err != nil {
+		log.Printf("failed to release image files of step %d: %v", id, err)
 	}
 
-	// 手順の削除
-	return s.stepRepo.Delete(id, userID)
+	return nil
 }
 
 // UpdateStepOrder は手順の順序を更新する
-func (s *ManualService) UpdateStepOrder(manualID, userID uint, orders []models.StepOrder) error {
-	return s.stepRepo.UpdateOrder(manualID, orders, userID)
+func (s *ManualService) UpdateStepOrder(manualID, userID uint, orders []models.StepOrder, client models.ClientInfo) error {
+	// マニュアルの編集権限チェック
+	manual, err := s.manualRepo.GetByID(manualID)
+	if err != nil {
+		return err
+	}
+
+	if err := s.access.checkEdit(manual, userID); err != nil {
+		return err
+	}
+
+	err = s.repo.Transaction(func(tx *sqlx.Tx) error {
+		if err := s.stepRepo.UpdateOrderTx(tx, manualID, orders, userID); err != nil {
+			return err
+		}
+		if _, err := s.revisionRepo.CreateTx(tx, manualID, userID, "steps reordered"); err != nil {
+			return err
+		}
+		stepIDs := make([]uint, len(orders))
+		for i, order := range orders {
+			stepIDs[i] = order.ID
+		}
+		event := newAuditEvent(userID, client, models.AuditActionStepReorder, models.AuditTargetManual, manualID)
+		event.After = models.AuditSummary{"step_ids": stepIDs}
+		return s.audit.RecordTx(tx, event)
+	})
+	if err != nil {
+		return err
+	}
+
+	return nil
 }
 
 // UploadStepImage は手順の画像をアップロードする
-func (s *ManualService) UploadStepImage(stepID, userID uint, filename string, fileData []byte, fileSize int64, mimeType string) (*models.Image, error) {
+// 画像は検証・EXIF除去・縮小したうえで派生画像とともに保存する
+func (s *ManualService) UploadStepImage(stepID, userID uint, filename string, fileData []byte, client models.ClientInfo) (*models.Image, error) {
 	// 手順の取得
 	step, err := s.stepRepo.GetByID(stepID)
 	if err != nil {
 		return nil, err
 	}
 
-	// マニュアルの所有者チェック
+	// マニュアルの編集権限チェック
 	manual, err := s.manualRepo.GetByID(step.ManualID)
 	if err != nil {
 		return nil, err
 	}
 
-	if manual.UserID != userID {
-		return nil, errors.New("unauthorized access")
-	}
-
-	// ファイル保存用のディレクトリを作成
-	uploadDir := filepath.Join(s.config.UploadDir, "steps", filepath.Join("manual_"+strconv.FormatUint(uint64(manual.ID), 10), "step_"+strconv.FormatUint(uint64(stepID), 10)))
-	if err := os.MkdirAll(uploadDir, 0755); err != nil {
+	if err := s.access.checkEdit(manual, userID); err != nil {
 		return nil, err
 	}
 
-	// 一意のファイル名を生成
-	newFilename := "image_" + filepath.Base(filename)
-	filePath := filepath.Join("steps", filepath.Join("manual_"+strconv.FormatUint(uint64(manual.ID), 10), "step_"+strconv.FormatUint(uint64(stepID), 10)), newFilename)
-	fullPath := filepath.Join(s.config.UploadDir, filePath)
-
-	// ファイル書き込み
-	if err := os.WriteFile(fullPath, fileData, 0644); err != nil {
+	// 画像の処理
+	image, files, err := processStepImage(s.config, fileData)
+	if err != nil {
 		return nil, err
 	}
+	image.StepID = stepID
+	image.FileName = sanitizeFileName(filename)
 
-	// 画像情報をDBに保存
-	image := &models.Image{
-		StepID:   stepID,
-		FilePath: filePath,
-		FileName: filename,
-		FileSize: fileSize,
-		MimeType: mimeType,
-	}
-
-	if err := s.imageRepo.Create(image); err != nil {
-		// エラー時はファイルを削除
-		os.Remove(fullPath)
+	// ファイル保存と画像情報の登録
+	err = s.repo.Transaction(func(tx *sqlx.Tx) error {
+		if err := s.imageFiles.putTx(tx, files); err != nil {
+			return err
+		}
+		if err := s.imageRepo.CreateTx(tx, image); err != nil {
+			return err
+		}
+		if _, err := s.revisionRepo.CreateTx(tx, manual.ID, userID, "image uploaded"); err != nil {
+			return err
+		}
+		event := newAuditEvent(userID, client, models.AuditActionImageUpload, models.AuditTargetImage, image.ID)
+		event.After = imageAuditSummary(image, manual.ID)
+		return s.audit.RecordTx(tx, event)
+	})
+	if err != nil {
+		// エラー時は参照されていないファイルを削除
+		s.imageFiles.release(imageFilePaths(image)) // エラーは無視
 		return nil, err
 	}
 
@@ -308,29 +525,56 @@ func (s *ManualService) UploadStepImage(stepID, userID uint, filename string, fi
 }
 
 // DeleteStepImage は手順の画像を削除する
-func (s *ManualService) DeleteStepImage(imageID, userID uint) error {
-	// 画像が特定のユーザーに所有されているか確認
-	isOwned, err := s.imageRepo.IsOwnedByUser(imageID, userID)
+func (s *ManualService) DeleteStepImage(imageID, userID uint, client models.ClientInfo) error {
+	image, err := s.imageRepo.GetByID(imageID)
 	if err != nil {
 		return err
 	}
 
-	if !isOwned {
-		return errors.New("unauthorized access")
+	step, err := s.stepRepo.GetByID(image.StepID)
+	if err != nil {
+		return err
 	}
 
-	// 画像のファイルパスを取得
-	filePath, err := s.imageRepo.GetFilePath(imageID)
+	// マニュアルの編集権限チェック
+	manual, err := s.manualRepo.GetByID(step.ManualID)
 	if err != nil {
 		return err
 	}
 
-	// ファイルシステムから削除
-	fullPath := filepath.Join(s.config.UploadDir, filePath)
-	if err := os.Remove(fullPath); err != nil && !os.IsNotExist(err) {
+	if err := s.access.checkEdit(manual, userID); err != nil {
 		return err
 	}
 
 	// データベースから削除
-	return s.imageRepo.Delete(imageID, userID)
+	err = s.repo.Transaction(func(tx *sqlx.Tx) error {
+		event := newAuditEvent(userID, client, models.AuditActionImageDelete, models.AuditTargetImage, imageID)
+		event.Before = imageAuditSummary(image, step.ManualID)
+		if err := s.audit.RecordTx(tx, event); err != nil {
+			return err
+		}
+		if err := s.imageRepo.DeleteTx(tx, imageID, userID); err != nil {
+			return err
+		}
+		_, err := s.revisionRepo.CreateTx(tx, step.ManualID, userID, "image deleted")
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	// 画像ファイルは改訂履歴などから参照されている間は復元できるよう残し、参照がなくなった場合のみ削除する
+	if err := s.imageFiles.release(imageFilePaths(image)); err != nil {
+		log.Printf("failed to release image files of image %d: %v", imageID, err)
+	}
+
+	return nil
+}
+
+// sameWorkspace は2つのワークスペースIDが同じかを返す
+func sameWorkspace(a, b *uint) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return *a == *b
 }
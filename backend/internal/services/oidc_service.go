@@ -0,0 +1,355 @@
+package services
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Ryo-cool/guideforge/internal/apperror"
+	"github.com/Ryo-cool/guideforge/internal/config"
+	"github.com/Ryo-cool/guideforge/internal/models"
+	"github.com/Ryo-cool/guideforge/internal/repository"
+	"github.com/coreos/go-oidc/v3/oidc"
+	"github.com/jmoiron/sqlx"
+	"golang.org/x/oauth2"
+)
+
+const (
+	// OIDCLoginStateExpiration は認可リクエストを開始してからコールバックまでの有効期間
+	OIDCLoginStateExpiration = 10 * time.Minute
+	// oidcHTTPTimeout はIdPへのリクエストのタイムアウト
+	oidcHTTPTimeout = 15 * time.Second
+)
+
+// oidcClaims はIDトークンから使用するクレーム
+type oidcClaims struct {
+	Email             string      `json:"email"`
+	EmailVerified     interface{} `json:"email_verified"` // IdPによっては文字列で返される
+	Name              string      `json:"name"`
+	PreferredUsername string      `json:"preferred_username"`
+}
+
+// OIDCService はOpenID Connectの認可コードフロー（PKCE）によるシングルサインオンを提供する
+type OIDCService struct {
+	userRepo     *repository.UserRepository
+	identityRepo *repository.IdentityRepository
+	authService  *AuthService
+	config       *config.Config
+	httpClient   *http.Client
+
+	// IdPの設定（ディスカバリー）は最初のログイン時に取得する
+	mu       sync.Mutex
+	provider *oidc.Provider
+}
+
+// NewOIDCService は新しいOIDCServiceインスタンスを作成
+func NewOIDCService(
+	userRepo *repository.UserRepository,
+	identityRepo *repository.IdentityRepository,
+	authService *AuthService,
+	cfg *config.Config,
+) *OIDCService {
+	return &OIDCService{
+		userRepo:     userRepo,
+		identityRepo: identityRepo,
+		authService:  authService,
+		config:       cfg,
+		httpClient:   &http.Client{Timeout: oidcHTTPTimeout},
+	}
+}
+
+// Enabled はシングルサインオンが設定されているかを返す
+func (s *OIDCService) Enabled() bool {
+	return s.config.OIDCIssuerURL != ""
+}
+
+// BeginLogin は認可リクエストを開始し、IdPの認可エンドポイントのURLとstateを返す
+// stateはブラウザに紐づけるため、呼び出し側でCookieにも保存すること
+func (s *OIDCService) BeginLogin(ctx context.Context, redirectPath string) (string, string, error) {
+	if !s.Enabled() {
+		return "", "", apperror.NotFound("single sign-on is not configured")
+	}
+
+	oauthConfig, _, err := s.oauthConfig(ctx)
+	if err != nil {
+		return "", "", err
+	}
+
+	state, err := generateRandomToken()
+	if err != nil {
+		return "", "", err
+	}
+	nonce, err := generateRandomToken()
+	if err != nil {
+		return "", "", err
+	}
+	verifier := oauth2.GenerateVerifier()
+
+	loginState := &models.OIDCLoginState{
+		StateHash:    hashToken(state),
+		Nonce:        nonce,
+		CodeVerifier: verifier,
+		RedirectPath: sanitizeRedirectPath(redirectPath),
+		ExpiresAt:    time.Now().Add(OIDCLoginStateExpiration),
+	}
+	if err := s.identityRepo.CreateLoginState(loginState); err != nil {
+		return "", "", err
+	}
+
+	authURL := oauthConfig.AuthCodeURL(state, oidc.Nonce(nonce), oauth2.S256ChallengeOption(verifier))
+	return authURL, state, nil
+}
+
+// CompleteLogin は認可コードをトークンに交換してIDトークンを検証し、ユーザーのセッションを作成する
+// ログイン後の遷移先のパスも返す
+func (s *OIDCService) CompleteLogin(ctx context.Context, state, code string, client models.ClientInfo) (*models.AuthResponse, string, error) {
+	if !s.Enabled() {
+		return nil, "", apperror.NotFound("single sign-on is not configured")
+	}
+
+	loginState, err := s.identityRepo.ConsumeLoginState(hashToken(state))
+	if err != nil {
+		return nil, "", err
+	}
+
+	idToken, claims, err := s.exchangeCode(ctx, loginState, code)
+	if err != nil {
+		return nil, "", err
+	}
+
+	user, err := s.resolveUser(idToken.Issuer, idToken.Subject, claims, client)
+	if err != nil {
+		return nil, "", err
+	}
+
+	// 多要素認証はIdPの認証ポリシーに任せ、TOTPの確認は求めない
+	res, err := s.authService.createSession(user, client)
+	if err != nil {
+		return nil, "", err
+	}
+	return res, loginState.RedirectPath, nil
+}
+
+// exchangeCode は認可コードをPKCEのcode_verifierとともにトークンに交換し、IDトークンとそのクレームを返す
+// IDトークンの署名・発行者・利用者（client_id）・有効期限と、認可リクエストで指定したnonceを検証する
+func (s *OIDCService) exchangeCode(ctx context.Context, loginState *models.OIDCLoginState, code string) (*oidc.IDToken, oidcClaims, error) {
+	var claims oidcClaims
+
+	oauthConfig, provider, err := s.oauthConfig(ctx)
+	if err != nil {
+		return nil, claims, err
+	}
+
+	ctx = oidc.ClientContext(ctx, s.httpClient)
+	token, err := oauthConfig.Exchange(ctx, code, oauth2.VerifierOption(loginState.CodeVerifier))
+	if err != nil {
+		return nil, claims, apperror.Wrap(apperror.ErrUnauthorized, err, "failed to exchange authorization code")
+	}
+
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok || rawIDToken == "" {
+		return nil, claims, apperror.Unauthorized("identity provider did not return an ID token")
+	}
+
+	idToken, err := provider.Verifier(&oidc.Config{ClientID: s.config.OIDCClientID}).Verify(ctx, rawIDToken)
+	if err != nil {
+		return nil, claims, apperror.Wrap(apperror.ErrUnauthorized, err, "invalid ID token")
+	}
+	if subtle.ConstantTimeCompare([]byte(idToken.Nonce), []byte(loginState.Nonce)) != 1 {
+		return nil, claims, apperror.Unauthorized("invalid ID token nonce")
+	}
+
+	if err := idToken.Claims(&claims); err != nil {
+		return nil, claims, apperror.Wrap(apperror.ErrUnauthorized, err, "invalid ID token claims")
+	}
+	return idToken, claims, nil
+}
+
+// resolveUser はIdP上のアカウントに対応するユーザーを返す
+// 紐づけ済みでない場合は確認済みのメールアドレスで既存のユーザーに紐づけ、
+// 該当するユーザーがいなければ OIDCAutoProvision に従ってユーザーを作成する
+func (s *OIDCService) resolveUser(issuer, subject string, claims oidcClaims, client models.ClientInfo) (*models.User, error) {
+	email := strings.TrimSpace(claims.Email)
+	if !emailDomainAllowed(email, s.config.OIDCAllowedDomains) {
+		return nil, apperror.Forbidden("this email domain is not allowed to sign in")
+	}
+
+	identity, err := s.identityRepo.GetByIssuerSubject(issuer, subject)
+	if err == nil {
+		user, err := s.userRepo.GetByID(identity.UserID)
+		if err != nil {
+			return nil, err
+		}
+		if err := s.identityRepo.UpdateLogin(identity.ID, email); err != nil {
+			return nil, err
+		}
+		return user, nil
+	}
+	if !errors.Is(err, apperror.ErrNotFound) {
+		return nil, err
+	}
+
+	// 未確認のメールアドレスで紐づけると他人のアカウントを乗っ取れるため、確認済みの場合のみ紐づける
+	if email == "" || !emailVerified(claims.EmailVerified) {
+		return nil, apperror.Forbidden("identity provider did not return a verified email address")
+	}
+
+	// 該当するユーザーがいなければ作成し、確認の済んでいないユーザーであれば引き継ぐ
+	// どちらの場合もパスワードは推測できないランダムな値とし、パスワードでのログインにはリセットが必要になる
+	provisioned, claimed := false, false
+	user, err := s.userRepo.GetByEmail(email)
+	if err != nil {
+		if !errors.Is(err, apperror.ErrNotFound) {
+			return nil, err
+		}
+		if !s.config.OIDCAutoProvision {
+			return nil, apperror.Forbidden("no account is registered for this email address")
+		}
+		provisioned = true
+	} else {
+		claimed = !user.EmailVerified()
+	}
+
+	var hashedPassword string
+	if provisioned || claimed {
+		if hashedPassword, err = s.randomPasswordHash(); err != nil {
+			return nil, err
+		}
+	}
+
+	if provisioned {
+		// IdPが確認済みのメールアドレスのみ受け付けるため、確認済みとして作成する
+		verifiedAt := time.Now()
+		user = &models.User{
+			Username:        provisionedUsername(email, claims),
+			Email:           email,
+			PasswordHash:    hashedPassword,
+			EmailVerifiedAt: &verifiedAt,
+		}
+	} else if claimed {
+		// 他人が先にこのアドレスで登録していた場合に備え、登録者のセッションを失効させる
+		if err := s.authService.sessionRepo.RevokeAllByUserID(user.ID, ""); err != nil {
+			return nil, err
+		}
+	}
+
+	identity = &models.UserIdentity{
+		Issuer:  issuer,
+		Subject: subject,
+		Email:   email,
+	}
+	err = s.authService.repo.Transaction(func(tx *sqlx.Tx) error {
+		if provisioned {
+			if err := s.userRepo.CreateTx(tx, user); err != nil {
+				return err
+			}
+		} else if claimed {
+			// 登録者が設定したパスワードを無効にして確認済みにする
+			if err := s.userRepo.UpdatePasswordTx(tx, user.ID, hashedPassword); err != nil {
+				return err
+			}
+			if err := s.userRepo.MarkEmailVerifiedTx(tx, user.ID); err != nil {
+				return err
+			}
+		}
+
+		identity.UserID = user.ID
+		if err := s.identityRepo.CreateTx(tx, identity); err != nil {
+			return err
+		}
+
+		event := newAuditEvent(user.ID, client, models.AuditActionUserIdentityLink, models.AuditTargetUser, user.ID)
+		event.After = models.AuditSummary{
+			"issuer":      issuer,
+			"email":       email,
+			"provisioned": provisioned,
+			"claimed":     claimed,
+		}
+		return s.authService.audit.RecordTx(tx, event)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if claimed {
+		verifiedAt := time.Now()
+		user.EmailVerifiedAt = &verifiedAt
+	}
+
+	return user, nil
+}
+
+// randomPasswordHash は推測できないランダムなパスワードのハッシュを返す
+func (s *OIDCService) randomPasswordHash() (string, error) {
+	password, err := generateRandomToken()
+	if err != nil {
+		return "", err
+	}
+	return s.authService.passwords.Hash(password)
+}
+
+// oauthConfig はIdPの設定を取得し、認可コードフローの設定を返す
+// 取得に失敗した場合は次のログイン時に再試行する
+func (s *OIDCService) oauthConfig(ctx context.Context) (*oauth2.Config, *oidc.Provider, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.provider == nil {
+		provider, err := oidc.NewProvider(oidc.ClientContext(ctx, s.httpClient), s.config.OIDCIssuerURL)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to discover OpenID provider: %w", err)
+		}
+		s.provider = provider
+	}
+
+	scopes := []string{oidc.ScopeOpenID}
+	for _, scope := range s.config.OIDCScopes {
+		if scope != oidc.ScopeOpenID {
+			scopes = append(scopes, scope)
+		}
+	}
+
+	return &oauth2.Config{
+		ClientID:     s.config.OIDCClientID,
+		ClientSecret: s.config.OIDCClientSecret,
+		RedirectURL:  s.config.OIDCRedirectURL,
+		Endpoint:     s.provider.Endpoint(),
+		Scopes:       scopes,
+	}, s.provider, nil
+}
+
+// emailVerified は email_verified クレームが真かを返す
+func emailVerified(value interface{}) bool {
+	switch v := value.(type) {
+	case bool:
+		return v
+	case string:
+		return strings.EqualFold(v, "true")
+	}
+	return false
+}
+
+// provisionedUsername はIdPのアカウント情報からユーザー名を決める
+func provisionedUsername(email string, claims oidcClaims) string {
+	for _, name := range []string{claims.Name, claims.PreferredUsername, strings.SplitN(email, "@", 2)[0]} {
+		name = strings.TrimSpace(name)
+		if len([]rune(name)) >= 3 {
+			return truncateRunes(name, 100)
+		}
+	}
+	return truncateRunes(email, 100)
+}
+
+// sanitizeRedirectPath はログイン後の遷移先を同一オリジンのパスに限定する
+// オープンリダイレクトを防ぐため、スキームやホストを含む値は破棄する
+func sanitizeRedirectPath(path string) string {
+	if !strings.HasPrefix(path, "/") || strings.HasPrefix(path, "//") || strings.ContainsAny(path, "\\\r\n") {
+		return ""
+	}
+	return path
+}
@@ -0,0 +1,404 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Ryo-cool/guideforge/internal/apperror"
+	"github.com/Ryo-cool/guideforge/internal/auth"
+	"github.com/Ryo-cool/guideforge/internal/config"
+	"github.com/Ryo-cool/guideforge/internal/mail"
+	"github.com/Ryo-cool/guideforge/internal/mockoidc"
+	"github.com/Ryo-cool/guideforge/internal/models"
+	"github.com/Ryo-cool/guideforge/internal/password"
+	"github.com/Ryo-cool/guideforge/internal/repository"
+	"github.com/coreos/go-oidc/v3/oidc"
+	"github.com/jmoiron/sqlx"
+	"golang.org/x/crypto/bcrypt"
+	"golang.org/x/oauth2"
+)
+
+const (
+	testOIDCClientID     = "guideforge"
+	testOIDCClientSecret = "secret"
+	testOIDCRedirectURL  = "http://localhost:8080/api/auth/oidc/callback"
+)
+
+// startMockIdP はテスト用のIdPを起動し、接続する設定を返す
+func startMockIdP(t *testing.T) *config.Config {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	issuer := "http://" + listener.Addr().String()
+
+	idp, err := mockoidc.New(mockoidc.Options{
+		Issuer:       issuer,
+		ClientID:     testOIDCClientID,
+		ClientSecret: testOIDCClientSecret,
+		Email:        "sso@example.com",
+		Name:         "SSO User",
+	})
+	if err != nil {
+		t.Fatalf("mockoidc.New() error = %v", err)
+	}
+
+	server := httptest.NewUnstartedServer(idp)
+	server.Listener.Close()
+	server.Listener = listener
+	server.Start()
+	t.Cleanup(server.Close)
+
+	return &config.Config{
+		OIDCIssuerURL:    issuer,
+		OIDCClientID:     testOIDCClientID,
+		OIDCClientSecret: testOIDCClientSecret,
+		OIDCRedirectURL:  testOIDCRedirectURL,
+		OIDCScopes:       []string{"openid", "email", "profile"},
+
+		PasswordHashAlgorithm:  password.AlgorithmBcrypt,
+		BcryptCost:             bcrypt.MinCost,
+		JWTSecret:              "test-secret",
+		JWTIssuer:              "guideforge",
+		JWTAudience:            "guideforge-api",
+		AccessTokenExpiration:  15 * time.Minute,
+		RefreshTokenExpiration: time.Hour,
+	}
+}
+
+// authorizeAtIdP はブラウザの代わりに認可エンドポイントへアクセスし、コールバックに渡される認可コードとstateを返す
+// mutate で認可リクエストのパラメータを書き換えられる
+func authorizeAtIdP(t *testing.T, authURL string, mutate func(url.Values)) (string, string) {
+	t.Helper()
+
+	u, err := url.Parse(authURL)
+	if err != nil {
+		t.Fatalf("invalid authorization URL %q: %v", authURL, err)
+	}
+	if mutate != nil {
+		q := u.Query()
+		mutate(q)
+		u.RawQuery = q.Encode()
+	}
+
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+	res, err := client.Get(u.String())
+	if err != nil {
+		t.Fatalf("authorization request failed: %v", err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusFound {
+		t.Fatalf("authorization request status = %d, want %d", res.StatusCode, http.StatusFound)
+	}
+
+	callback, err := url.Parse(res.Header.Get("Location"))
+	if err != nil {
+		t.Fatalf("invalid callback URL: %v", err)
+	}
+	if !strings.HasPrefix(callback.String(), testOIDCRedirectURL) {
+		t.Fatalf("callback URL = %q, want prefix %q", callback, testOIDCRedirectURL)
+	}
+	return callback.Query().Get("code"), callback.Query().Get("state")
+}
+
+// loginHint はログインするメールアドレスを指定する
+func loginHint(email string) func(url.Values) {
+	return func(q url.Values) { q.Set("login_hint", email) }
+}
+
+// TestOIDCExchangeCode はデータベースを使用せずに、認可コードの交換とIDトークンの検証を確認する
+func TestOIDCExchangeCode(t *testing.T) {
+	cfg := startMockIdP(t)
+	s := NewOIDCService(nil, nil, nil, cfg)
+	ctx := context.Background()
+
+	// BeginLogin と同じ認可リクエストを作成する
+	begin := func(t *testing.T, mutate func(url.Values)) (*models.OIDCLoginState, string) {
+		t.Helper()
+		oauthConfig, _, err := s.oauthConfig(ctx)
+		if err != nil {
+			t.Fatalf("oauthConfig() error = %v", err)
+		}
+		loginState := &models.OIDCLoginState{Nonce: "nonce-" + t.Name(), CodeVerifier: oauth2.GenerateVerifier()}
+		authURL := oauthConfig.AuthCodeURL("state", oidc.Nonce(loginState.Nonce), oauth2.S256ChallengeOption(loginState.CodeVerifier))
+		code, state := authorizeAtIdP(t, authURL, mutate)
+		if state != "state" {
+			t.Fatalf("callback state = %q, want %q", state, "state")
+		}
+		return loginState, code
+	}
+
+	t.Run("valid", func(t *testing.T) {
+		loginState, code := begin(t, loginHint("alice@example.com"))
+
+		idToken, claims, err := s.exchangeCode(ctx, loginState, code)
+		if err != nil {
+			t.Fatalf("exchangeCode() error = %v", err)
+		}
+		if idToken.Issuer != cfg.OIDCIssuerURL || idToken.Subject == "" {
+			t.Errorf("ID token issuer = %q, subject = %q", idToken.Issuer, idToken.Subject)
+		}
+		if claims.Email != "alice@example.com" || !emailVerified(claims.EmailVerified) {
+			t.Errorf("claims = %+v, want verified alice@example.com", claims)
+		}
+	})
+
+	t.Run("PKCE verifier mismatch", func(t *testing.T) {
+		loginState, code := begin(t, nil)
+		loginState.CodeVerifier = oauth2.GenerateVerifier()
+
+		if _, _, err := s.exchangeCode(ctx, loginState, code); !errors.Is(err, apperror.ErrUnauthorized) {
+			t.Errorf("exchangeCode() error = %v, want ErrUnauthorized", err)
+		}
+	})
+
+	t.Run("PKCE challenge replaced", func(t *testing.T) {
+		loginState, code := begin(t, func(q url.Values) {
+			q.Set("code_challenge", oauth2.S256ChallengeFromVerifier(oauth2.GenerateVerifier()))
+		})
+
+		if _, _, err := s.exchangeCode(ctx, loginState, code); !errors.Is(err, apperror.ErrUnauthorized) {
+			t.Errorf("exchangeCode() error = %v, want ErrUnauthorized", err)
+		}
+	})
+
+	t.Run("nonce mismatch", func(t *testing.T) {
+		loginState, code := begin(t, func(q url.Values) { q.Set("nonce", "injected-nonce") })
+
+		_, _, err := s.exchangeCode(ctx, loginState, code)
+		if !errors.Is(err, apperror.ErrUnauthorized) || !strings.Contains(err.Error(), "nonce") {
+			t.Errorf("exchangeCode() error = %v, want an invalid nonce error", err)
+		}
+	})
+
+	t.Run("code reused", func(t *testing.T) {
+		loginState, code := begin(t, nil)
+		if _, _, err := s.exchangeCode(ctx, loginState, code); err != nil {
+			t.Fatalf("first exchangeCode() error = %v", err)
+		}
+
+		if _, _, err := s.exchangeCode(ctx, loginState, code); !errors.Is(err, apperror.ErrUnauthorized) {
+			t.Errorf("second exchangeCode() error = %v, want ErrUnauthorized", err)
+		}
+	})
+}
+
+// oidcTestEnv はデータベースとIdPを使用するテストの環境
+type oidcTestEnv struct {
+	service  *OIDCService
+	repo     *repository.Repository
+	userRepo *repository.UserRepository
+}
+
+// newOIDCTestEnv はテスト用のデータベースとIdPに接続したOIDCServiceを作成する
+func newOIDCTestEnv(t *testing.T, configure func(*config.Config)) *oidcTestEnv {
+	t.Helper()
+
+	repo := openTestDB(t)
+	cfg := startMockIdP(t)
+	if configure != nil {
+		configure(cfg)
+	}
+
+	tokens, err := auth.NewTokenService(cfg)
+	if err != nil {
+		t.Fatalf("NewTokenService() error = %v", err)
+	}
+	passwords, err := password.NewHasher(cfg)
+	if err != nil {
+		t.Fatalf("NewHasher() error = %v", err)
+	}
+	policy, err := password.NewPolicy(cfg)
+	if err != nil {
+		t.Fatalf("NewPolicy() error = %v", err)
+	}
+	mailer := mail.NewLogSender("")
+
+	userRepo := repository.NewUserRepository(repo)
+	auditService := NewAuditService(repository.NewAuditRepository(repo))
+	emailVerification := NewEmailVerificationService(repo, userRepo, repository.NewEmailVerificationRepository(repo), auditService, mailer, cfg)
+	loginThrottle := NewLoginThrottleService(repo, repository.NewLoginThrottleRepository(repo), userRepo, auditService, mailer, cfg)
+	twoFactor := NewTwoFactorService(repo, userRepo, repository.NewTwoFactorRepository(repo), passwords, auditService)
+	authService := NewAuthService(repo, userRepo, repository.NewSessionRepository(repo), tokens, passwords, policy, loginThrottle, twoFactor, emailVerification, auditService, cfg)
+
+	return &oidcTestEnv{
+		service:  NewOIDCService(userRepo, repository.NewIdentityRepository(repo), authService, cfg),
+		repo:     repo,
+		userRepo: userRepo,
+	}
+}
+
+// createUser はテスト用のユーザーを作成する
+func (e *oidcTestEnv) createUser(t *testing.T, email string, verified bool) *models.User {
+	t.Helper()
+
+	user := &models.User{Username: "existing user", Email: email, PasswordHash: "existing-hash"}
+	if verified {
+		verifiedAt := time.Now()
+		user.EmailVerifiedAt = &verifiedAt
+	}
+	err := e.repo.Transaction(func(tx *sqlx.Tx) error {
+		return e.userRepo.CreateTx(tx, user)
+	})
+	if err != nil {
+		t.Fatalf("failed to create user: %v", err)
+	}
+	return user
+}
+
+// login は BeginLogin から CompleteLogin までのログインを行う
+func (e *oidcTestEnv) login(t *testing.T, email string) (*models.AuthResponse, string, error) {
+	t.Helper()
+	ctx := context.Background()
+
+	authURL, state, err := e.service.BeginLogin(ctx, "/manuals/1")
+	if err != nil {
+		t.Fatalf("BeginLogin() error = %v", err)
+	}
+	code, callbackState := authorizeAtIdP(t, authURL, loginHint(email))
+	if callbackState != state {
+		t.Fatalf("callback state = %q, want %q", callbackState, state)
+	}
+
+	return e.service.CompleteLogin(ctx, state, code, models.ClientInfo{})
+}
+
+func TestOIDCCompleteLoginState(t *testing.T) {
+	env := newOIDCTestEnv(t, func(cfg *config.Config) { cfg.OIDCAutoProvision = true })
+	ctx := context.Background()
+
+	authURL, state, err := env.service.BeginLogin(ctx, "https://evil.example.com/")
+	if err != nil {
+		t.Fatalf("BeginLogin() error = %v", err)
+	}
+	code, _ := authorizeAtIdP(t, authURL, loginHint("state@example.com"))
+
+	// 別のブラウザで開始したログインのstateは受け付けない
+	if _, _, err := env.service.CompleteLogin(ctx, "forged-state", code, models.ClientInfo{}); !errors.Is(err, apperror.ErrUnauthorized) {
+		t.Fatalf("CompleteLogin() with a forged state error = %v, want ErrUnauthorized", err)
+	}
+
+	res, redirectPath, err := env.service.CompleteLogin(ctx, state, code, models.ClientInfo{})
+	if err != nil {
+		t.Fatalf("CompleteLogin() error = %v", err)
+	}
+	if res.Token == "" || res.User.Email != "state@example.com" {
+		t.Errorf("CompleteLogin() response = %+v", res)
+	}
+	if redirectPath != "" {
+		t.Errorf("redirect path = %q, want an external URL to be discarded", redirectPath)
+	}
+
+	// stateは1回だけ使用できる
+	if _, _, err := env.service.CompleteLogin(ctx, state, code, models.ClientInfo{}); !errors.Is(err, apperror.ErrUnauthorized) {
+		t.Errorf("CompleteLogin() with a used state error = %v, want ErrUnauthorized", err)
+	}
+}
+
+func TestOIDCCompleteLoginLinksVerifiedEmail(t *testing.T) {
+	env := newOIDCTestEnv(t, nil)
+	existing := env.createUser(t, "linked@example.com", true)
+
+	res, redirectPath, err := env.login(t, "linked@example.com")
+	if err != nil {
+		t.Fatalf("CompleteLogin() error = %v", err)
+	}
+	if res.User.ID != existing.ID {
+		t.Errorf("logged in as user %d, want the existing user %d", res.User.ID, existing.ID)
+	}
+	if redirectPath != "/manuals/1" {
+		t.Errorf("redirect path = %q, want %q", redirectPath, "/manuals/1")
+	}
+
+	// 確認済みのユーザーに紐づける場合、設定済みのパスワードは変更しない
+	user, err := env.userRepo.GetByID(existing.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.PasswordHash != "existing-hash" {
+		t.Errorf("password hash was replaced when linking a verified account")
+	}
+
+	var identities int
+	if err := env.repo.GetDB().Get(&identities, `SELECT COUNT(*) FROM user_identities WHERE user_id = $1`, existing.ID); err != nil {
+		t.Fatal(err)
+	}
+	if identities != 1 {
+		t.Errorf("identities for the user = %d, want 1", identities)
+	}
+
+	// 2回目以降は紐づけたIdPのアカウントで同じユーザーとしてログインする
+	res, _, err = env.login(t, "linked@example.com")
+	if err != nil {
+		t.Fatalf("second CompleteLogin() error = %v", err)
+	}
+	if res.User.ID != existing.ID {
+		t.Errorf("second login as user %d, want %d", res.User.ID, existing.ID)
+	}
+}
+
+func TestOIDCCompleteLoginClaimsUnverifiedAccount(t *testing.T) {
+	env := newOIDCTestEnv(t, nil)
+	existing := env.createUser(t, "unverified@example.com", false)
+
+	res, _, err := env.login(t, "unverified@example.com")
+	if err != nil {
+		t.Fatalf("CompleteLogin() error = %v", err)
+	}
+	if res.User.ID != existing.ID || !res.User.EmailVerified {
+		t.Errorf("CompleteLogin() user = %+v, want the existing user marked verified", res.User)
+	}
+
+	// 先に登録した人が設定したパスワードではログインできないようにする
+	user, err := env.userRepo.GetByID(existing.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.PasswordHash == "existing-hash" || !user.EmailVerified() {
+		t.Errorf("claimed account still has the registrant's password or is unverified")
+	}
+}
+
+func TestOIDCCompleteLoginWithoutAccount(t *testing.T) {
+	t.Run("auto provision disabled", func(t *testing.T) {
+		env := newOIDCTestEnv(t, nil)
+
+		if _, _, err := env.login(t, "new@example.com"); !errors.Is(err, apperror.ErrForbidden) {
+			t.Errorf("CompleteLogin() error = %v, want ErrForbidden", err)
+		}
+	})
+
+	t.Run("auto provision enabled", func(t *testing.T) {
+		env := newOIDCTestEnv(t, func(cfg *config.Config) { cfg.OIDCAutoProvision = true })
+
+		res, _, err := env.login(t, "new@example.com")
+		if err != nil {
+			t.Fatalf("CompleteLogin() error = %v", err)
+		}
+		if res.User.Email != "new@example.com" || !res.User.EmailVerified {
+			t.Errorf("provisioned user = %+v, want verified new@example.com", res.User)
+		}
+	})
+
+	t.Run("domain not allowed", func(t *testing.T) {
+		env := newOIDCTestEnv(t, func(cfg *config.Config) {
+			cfg.OIDCAutoProvision = true
+			cfg.OIDCAllowedDomains = []string{"example.org"}
+		})
+
+		if _, _, err := env.login(t, "new@example.com"); !errors.Is(err, apperror.ErrForbidden) {
+			t.Errorf("CompleteLogin() error = %v, want ErrForbidden", err)
+		}
+	})
+}
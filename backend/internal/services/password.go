@@ -0,0 +1,44 @@
+package services
+
+import (
+	"log"
+
+	"github.com/Ryo-cool/guideforge/internal/apperror"
+	"github.com/Ryo-cool/guideforge/internal/models"
+	"github.com/Ryo-cool/guideforge/internal/password"
+	"github.com/Ryo-cool/guideforge/internal/repository"
+)
+
+// validatePassword は新しいパスワードがポリシーを満たすかを確認する
+// field にはエラーとして返すリクエストのフィールド名を指定する
+func validatePassword(policy *password.Policy, field, newPassword, email, username string) error {
+	violations := policy.Validate(newPassword, email, username)
+	if len(violations) == 0 {
+		return nil
+	}
+
+	fields := make([]apperror.FieldError, len(violations))
+	for i, violation := range violations {
+		fields[i] = apperror.FieldError{Field: field, Message: violation}
+	}
+	return apperror.Validation("password does not meet the password policy", fields...)
+}
+
+// rehashPassword はハッシュのアルゴリズムやパラメータが現在の設定と異なる場合に、
+// 確認済みの平文のパスワードで現在の設定によりハッシュし直す
+// 失敗してもログインは妨げず、次回のログイン時に再試行する
+func rehashPassword(userRepo *repository.UserRepository, hasher *password.Hasher, user *models.User, plainPassword string) {
+	if !hasher.NeedsRehash(user.PasswordHash) {
+		return
+	}
+
+	hashedPassword, err := hasher.Hash(plainPassword)
+	if err == nil {
+		err = userRepo.UpdatePassword(user.ID, hashedPassword)
+	}
+	if err != nil {
+		log.Printf("failed to rehash password for user %d: %v", user.ID, err)
+		return
+	}
+	user.PasswordHash = hashedPassword
+}
@@ -0,0 +1,182 @@
+package services
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/Ryo-cool/guideforge/internal/config"
+	"github.com/Ryo-cool/guideforge/internal/mail"
+	"github.com/Ryo-cool/guideforge/internal/models"
+	"github.com/Ryo-cool/guideforge/internal/password"
+	"github.com/Ryo-cool/guideforge/internal/repository"
+	"github.com/jmoiron/sqlx"
+)
+
+// PasswordResetService はパスワードリセット関連の機能を提供するサービス
+type PasswordResetService struct {
+	repo           *repository.Repository
+	userRepo       *repository.UserRepository
+	resetRepo      *repository.PasswordResetRepository
+	sessionRepo    *repository.SessionRepository
+	passwords      *password.Hasher
+	passwordPolicy *password.Policy
+	loginThrottle  *LoginThrottleService
+	audit          *AuditService
+	mailer         mail.Sender
+	config         *config.Config
+}
+
+// NewPasswordResetService は新しいPasswordResetServiceインスタンスを作成
+func NewPasswordResetService(
+	repo *repository.Repository,
+	userRepo *repository.UserRepository,
+	resetRepo *repository.PasswordResetRepository,
+	sessionRepo *repository.SessionRepository,
+	passwords *password.Hasher,
+	passwordPolicy *password.Policy,
+	loginThrottle *LoginThrottleService,
+	audit *AuditService,
+	mailer mail.Sender,
+	cfg *config.Config,
+) *PasswordResetService {
+	return &PasswordResetService{
+		repo:           repo,
+		userRepo:       userRepo,
+		resetRepo:      resetRepo,
+		sessionRepo:    sessionRepo,
+		passwords:      passwords,
+		passwordPolicy: passwordPolicy,
+		loginThrottle:  loginThrottle,
+		audit:          audit,
+		mailer:         mailer,
+		config:         cfg,
+	}
+}
+
+// RequestPasswordReset はリセットトークンを発行してメールで送信する
+// ユーザーの存在有無を漏らさないため、該当ユーザーがいない場合もエラーにしない
+func (s *PasswordResetService) RequestPasswordReset(email string) error {
+	if !s.config.PasswordLoginEnabled {
+		return errPasswordLoginDisabled
+	}
+
+	user, err := s.userRepo.GetByEmail(email)
+	if err != nil {
+		return nil
+	}
+
+	// 以前に発行した未使用トークンは無効化する
+	if err := s.resetRepo.InvalidateByUserID(user.ID); err != nil {
+		return err
+	}
+
+	rawToken, err := generateRandomToken()
+	if err != nil {
+		return err
+	}
+
+	token := &models.PasswordResetToken{
+		UserID:    user.ID,
+		TokenHash: hashToken(rawToken),
+		ExpiresAt: time.Now().Add(s.config.PasswordResetExpiration),
+	}
+	if err := s.resetRepo.Create(token); err != nil {
+		return err
+	}
+
+	resetURL := strings.TrimRight(s.config.FrontendURL, "/") + "/password/reset?token=" + url.QueryEscape(rawToken)
+	body := fmt.Sprintf(
+		"%s 様\n\nパスワードリセットのリクエストを受け付けました。\n以下のリンクから新しいパスワードを設定してください（有効期限: %d分）。\n\n%s\n\nこのリクエストに心当たりがない場合は、このメールを破棄してください。\n",
+		user.Username,
+		int(s.config.PasswordResetExpiration.Minutes()),
+		resetURL,
+	)
+
+	if err := s.mailer.Send(user.Email, "【GuideForge】パスワードリセットのご案内", body); err != nil {
+		// メール送信失敗は利用者には通知せずログに残す
+		log.Printf("failed to send password reset mail to user %d: %v", user.ID, err)
+	}
+
+	return nil
+}
+
+// ResetPassword はトークンを検証してパスワードを更新する
+func (s *PasswordResetService) ResetPassword(rawToken, newPassword string, client models.ClientInfo) error {
+	if !s.config.PasswordLoginEnabled {
+		return errPasswordLoginDisabled
+	}
+
+	// ポリシーを満たさないパスワードでトークンを使い切らないよう、使用済みにする前に確認する
+	userID, err := s.resetRepo.GetValidUserID(hashToken(rawToken))
+	if err != nil {
+		return err
+	}
+	user, err := s.userRepo.GetByID(userID)
+	if err != nil {
+		return err
+	}
+	if err := validatePassword(s.passwordPolicy, "new_password", newPassword, user.Email, user.Username); err != nil {
+		return err
+	}
+
+	// トークンを使用済みにする（期限切れ・使用済みの場合はエラー）
+	userID, err = s.resetRepo.Consume(hashToken(rawToken))
+	if err != nil {
+		return err
+	}
+
+	// 新しいパスワードのハッシュ化
+	hashedPassword, err := s.passwords.Hash(newPassword)
+	if err != nil {
+		return err
+	}
+
+	// リセットメールを受け取れたことでメールアドレスの確認も済んだとみなす
+	err = s.repo.Transaction(func(tx *sqlx.Tx) error {
+		if err := s.userRepo.UpdatePasswordTx(tx, userID, hashedPassword); err != nil {
+			return err
+		}
+		if err := s.userRepo.MarkEmailVerifiedTx(tx, userID); err != nil {
+			return err
+		}
+		return s.audit.RecordTx(tx, newAuditEvent(userID, client, models.AuditActionPasswordReset, models.AuditTargetUser, userID))
+	})
+	if err != nil {
+		return err
+	}
+
+	// 同一ユーザーの他のトークンも無効化する
+	if err := s.resetRepo.InvalidateByUserID(userID); err != nil {
+		return err
+	}
+
+	// パスワードを知っている第三者のセッションが残らないよう、すべてのセッションを失効させる
+	if err := s.sessionRepo.RevokeAllByUserID(userID, ""); err != nil {
+		return err
+	}
+
+	// ロック中でも新しいパスワードですぐにログインできるようにする
+	return s.loginThrottle.UnlockUser(userID)
+}
+
+// generateRandomToken はURLセーフなランダムトークンを生成する（リセットトークン・リフレッシュトークンに使用）
+func generateRandomToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// hashToken はトークンをDB保存用にハッシュ化する
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
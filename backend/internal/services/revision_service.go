@@ -0,0 +1,215 @@
+package services
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/Ryo-cool/guideforge/internal/config"
+	"github.com/Ryo-cool/guideforge/internal/models"
+	"github.com/Ryo-cool/guideforge/internal/repository"
+	"github.com/Ryo-cool/guideforge/internal/storage"
+	"github.com/jmoiron/sqlx"
+)
+
+// RevisionService はマニュアル改訂履歴の機能を提供するサービス
+type RevisionService struct {
+	repo         *repository.Repository
+	manualRepo   *repository.ManualRepository
+	revisionRepo *repository.RevisionRepository
+	audit        *AuditService
+	access       manualAccess
+	storage      storage.Storage
+	config       *config.Config
+}
+
+// NewRevisionService は新しいRevisionServiceインスタンスを作成
+func NewRevisionService(
+	repo *repository.Repository,
+	manualRepo *repository.ManualRepository,
+	revisionRepo *repository.RevisionRepository,
+	workspaceRepo *repository.WorkspaceRepository,
+	audit *AuditService,
+	store storage.Storage,
+	cfg *config.Config,
+) *RevisionService {
+	return &RevisionService{
+		repo:         repo,
+		manualRepo:   manualRepo,
+		revisionRepo: revisionRepo,
+		audit:        audit,
+		access:       manualAccess{workspaceRepo: workspaceRepo},
+		storage:      store,
+		config:       cfg,
+	}
+}
+
+// ListRevisions はマニュアルの改訂履歴一覧を取得する
+func (s *RevisionService) ListRevisions(manualID, userID uint) ([]models.ManualRevision, error) {
+	if err := s.checkReadAccess(manualID, userID); err != nil {
+		return nil, err
+	}
+
+	return s.revisionRepo.GetAllByManualID(manualID)
+}
+
+// GetRevision は指定した改訂履歴を手順スナップショット付きで取得する
+func (s *RevisionService) GetRevision(manualID, userID uint, revisionNumber int) (*models.ManualRevision, error) {
+	if err := s.checkReadAccess(manualID, userID); err != nil {
+		return nil, err
+	}
+
+	return s.revisionRepo.GetByNumber(manualID, revisionNumber)
+}
+
+// DiffRevisions は2つの改訂履歴の差分を手順単位で取得する
+func (s *RevisionService) DiffRevisions(manualID, userID uint, fromNumber, toNumber int) (*models.RevisionDiff, error) {
+	if err := s.checkReadAccess(manualID, userID); err != nil {
+		return nil, err
+	}
+
+	from, err := s.revisionRepo.GetByNumber(manualID, fromNumber)
+	if err != nil {
+		return nil, err
+	}
+
+	to, err := s.revisionRepo.GetByNumber(manualID, toNumber)
+	if err != nil {
+		return nil, err
+	}
+
+	return diffRevisions(from, to), nil
+}
+
+// RestoreRevision はマニュアルを指定した改訂履歴の状態に戻す
+// 復元自体も新しい改訂履歴として記録される
+func (s *RevisionService) RestoreRevision(manualID, userID uint, revisionNumber int, client models.ClientInfo) (*models.Manual, error) {
+	manual, err := s.manualRepo.GetByID(manualID)
+	if err != nil {
+		return nil, err
+	}
+
+	// 編集権限チェック
+	if err := s.access.checkEdit(manual, userID); err != nil {
+		return nil, err
+	}
+
+	revision, err := s.revisionRepo.GetByNumber(manualID, revisionNumber)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.repo.Transaction(func(tx *sqlx.Tx) error {
+		if err := s.revisionRepo.RestoreTx(tx, revision, s.imageExists); err != nil {
+			return err
+		}
+
+		summary := fmt.Sprintf("restored from revision %d", revision.RevisionNumber)
+		if _, err := s.revisionRepo.CreateTx(tx, manualID, userID, summary); err != nil {
+			return err
+		}
+
+		event := newAuditEvent(userID, client, models.AuditActionManualRestore, models.AuditTargetManual, manualID)
+		event.Before = manualAuditSummary(manual)
+		event.After = models.AuditSummary{"revision_number": revision.RevisionNumber}
+		return s.audit.RecordTx(tx, event)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.manualRepo.GetByIDWithSteps(manualID)
+}
+
+// checkReadAccess はマニュアルの閲覧権限を確認する
+func (s *RevisionService) checkReadAccess(manualID, userID uint) error {
+	manual, err := s.manualRepo.GetByID(manualID)
+	if err != nil {
+		return err
+	}
+
+	// 非公開マニュアルの場合、作成者またはワークスペースメンバーのみアクセス可能
+	return s.access.checkRead(manual, userID)
+}
+
+// imageExists は画像ファイルがストレージに存在するかを確認する
+func (s *RevisionService) imageExists(filePath string) bool {
+	_, err := s.storage.Stat(filePath)
+	return err == nil
+}
+
+// diffRevisions は2つの改訂履歴を比較する
+// 手順は手順IDで対応付け、並び順は変更後の改訂履歴に従う
+func diffRevisions(from, to *models.ManualRevision) *models.RevisionDiff {
+	diff := &models.RevisionDiff{
+		FromRevision: from.RevisionNumber,
+		ToRevision:   to.RevisionNumber,
+		Fields:       []models.FieldChange{},
+		Steps:        []models.StepDiff{},
+	}
+
+	diff.Fields = appendChange(diff.Fields, "title", from.Title, to.Title)
+	diff.Fields = appendChange(diff.Fields, "description", from.Description, to.Description)
+	diff.Fields = appendChange(diff.Fields, "category", from.Category, to.Category)
+	diff.Fields = appendChange(diff.Fields, "is_public", from.IsPublic, to.IsPublic)
+
+	fromSteps := make(map[uint]*models.RevisionStep, len(from.Steps))
+	for i := range from.Steps {
+		fromSteps[from.Steps[i].StepID] = &from.Steps[i]
+	}
+
+	seen := make(map[uint]bool, len(to.Steps))
+	for i := range to.Steps {
+		toStep := &to.Steps[i]
+		seen[toStep.StepID] = true
+
+		fromStep, ok := fromSteps[toStep.StepID]
+		if !ok {
+			diff.Steps = append(diff.Steps, models.StepDiff{StepID: toStep.StepID, Status: "added", To: toStep})
+			continue
+		}
+
+		var changes []models.FieldChange
+		changes = appendChange(changes, "order_number", fromStep.OrderNumber, toStep.OrderNumber)
+		changes = appendChange(changes, "title", fromStep.Title, toStep.Title)
+		changes = appendChange(changes, "content", fromStep.Content, toStep.Content)
+		changes = appendChange(changes, "images", imagePaths(fromStep.Images), imagePaths(toStep.Images))
+
+		status := "unchanged"
+		if len(changes) > 0 {
+			status = "modified"
+		}
+		diff.Steps = append(diff.Steps, models.StepDiff{
+			StepID:  toStep.StepID,
+			Status:  status,
+			From:    fromStep,
+			To:      toStep,
+			Changes: changes,
+		})
+	}
+
+	// 変更後に存在しない手順は削除扱い
+	for i := range from.Steps {
+		if !seen[from.Steps[i].StepID] {
+			diff.Steps = append(diff.Steps, models.StepDiff{StepID: from.Steps[i].StepID, Status: "removed", From: &from.Steps[i]})
+		}
+	}
+
+	return diff
+}
+
+// appendChange は値が異なる場合のみ変更内容を追加する
+func appendChange(changes []models.FieldChange, field string, from, to interface{}) []models.FieldChange {
+	if reflect.DeepEqual(from, to) {
+		return changes
+	}
+	return append(changes, models.FieldChange{Field: field, From: from, To: to})
+}
+
+// imagePaths は比較用に画像のファイルパス一覧を返す
+func imagePaths(images []models.RevisionImage) []string {
+	names := make([]string, 0, len(images))
+	for _, image := range images {
+		names = append(names, image.FilePath)
+	}
+	return names
+}
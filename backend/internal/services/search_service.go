@@ -0,0 +1,226 @@
+package services
+
+import (
+	"html"
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/Ryo-cool/guideforge/internal/apperror"
+	"github.com/Ryo-cool/guideforge/internal/models"
+	"github.com/Ryo-cool/guideforge/internal/repository"
+)
+
+const (
+	// maxSearchTerms は部分一致に使用する検索語の上限
+	maxSearchTerms = 10
+	// snippetRadius は抜粋で一致箇所の前後に含める文字数
+	snippetRadius = 60
+	// maxStepHighlights は1マニュアルあたりに返す手順の抜粋数の上限
+	maxStepHighlights = 3
+)
+
+// SearchService はマニュアル検索の機能を提供するサービス
+type SearchService struct {
+	searchRepo *repository.SearchRepository
+}
+
+// NewSearchService は新しいSearchServiceインスタンスを作成
+func NewSearchService(searchRepo *repository.SearchRepository) *SearchService {
+	return &SearchService{
+		searchRepo: searchRepo,
+	}
+}
+
+// SearchManuals はユーザーが閲覧できるマニュアルを検索し、強調表示付きの抜粋とともに返す
+func (s *SearchService) SearchManuals(userID uint, params models.ManualSearchParams) (*models.PaginatedResponse, error) {
+	params.Query = strings.TrimSpace(params.Query)
+	terms := searchTerms(params.Query)
+	if len(terms) == 0 {
+		return nil, apperror.Validation(
+			"Validation error",
+			apperror.FieldError{Field: "q", Message: "is required"},
+		)
+	}
+
+	if params.Page < 1 {
+		params.Page = 1
+	}
+	if params.Limit < 1 || params.Limit > 100 {
+		params.Limit = 10
+	}
+
+	results, total, err := s.searchRepo.SearchManuals(userID, params, terms)
+	if err != nil {
+		return nil, err
+	}
+
+	manualIDs := make([]uint, len(results))
+	for i := range results {
+		manualIDs[i] = results[i].ID
+	}
+	steps, err := s.searchRepo.GetMatchingSteps(manualIDs, params.Query, terms)
+	if err != nil {
+		return nil, err
+	}
+	stepsByManual := make(map[uint][]models.Step)
+	for _, step := range steps {
+		stepsByManual[step.ManualID] = append(stepsByManual[step.ManualID], step)
+	}
+
+	for i := range results {
+		results[i].Highlights = buildHighlights(&results[i].Manual, stepsByManual[results[i].ID], terms)
+	}
+
+	totalPages := int(math.Ceil(float64(total) / float64(params.Limit)))
+
+	return &models.PaginatedResponse{
+		Pagination: models.PaginationResponse{
+			Total:      total,
+			Page:       params.Page,
+			Limit:      params.Limit,
+			TotalPages: totalPages,
+		},
+		Items: results,
+	}, nil
+}
+
+// searchTerms は検索クエリから部分一致と強調表示に使う検索語を取り出す
+// websearch構文の除外語（-から始まる語）やOR演算子は対象外とする
+func searchTerms(query string) []string {
+	seen := make(map[string]bool)
+	terms := []string{}
+	for _, field := range strings.Fields(query) {
+		field = strings.Trim(field, `"`)
+		if field == "" || strings.HasPrefix(field, "-") || strings.EqualFold(field, "or") {
+			continue
+		}
+
+		key := strings.ToLower(field)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+
+		terms = append(terms, field)
+		if len(terms) == maxSearchTerms {
+			break
+		}
+	}
+	return terms
+}
+
+// buildHighlights はマニュアルと一致した手順から抜粋を作成する
+func buildHighlights(manual *models.Manual, steps []models.Step, terms []string) []models.SearchHighlight {
+	highlights := []models.SearchHighlight{}
+
+	if snippet := highlight(manual.Title, terms, 0); snippet != "" {
+		highlights = append(highlights, models.SearchHighlight{Field: "title", Snippet: snippet})
+	}
+	if snippet := highlight(manual.Description, terms, snippetRadius); snippet != "" {
+		highlights = append(highlights, models.SearchHighlight{Field: "description", Snippet: snippet})
+	}
+
+	count := 0
+	for i := range steps {
+		if count == maxStepHighlights {
+			break
+		}
+
+		stepID := steps[i].ID
+		matched := false
+		if snippet := highlight(steps[i].Title, terms, 0); snippet != "" {
+			highlights = append(highlights, models.SearchHighlight{Field: "step_title", StepID: &stepID, Snippet: snippet})
+			matched = true
+		}
+		if snippet := highlight(steps[i].Content, terms, snippetRadius); snippet != "" {
+			highlights = append(highlights, models.SearchHighlight{Field: "step_content", StepID: &stepID, Snippet: snippet})
+			matched = true
+		}
+		if matched {
+			count++
+		}
+	}
+
+	return highlights
+}
+
+// highlight はテキスト中の検索語を <mark> で囲んだ抜粋を返す（一致しない場合は空文字）
+// radius が0以下の場合はテキスト全体を、それ以外は最初の一致箇所の前後 radius 文字を返す
+func highlight(text string, terms []string, radius int) string {
+	runes := []rune(strings.Join(strings.Fields(text), " "))
+	ranges := matchRanges(runes, terms)
+	if len(ranges) == 0 {
+		return ""
+	}
+
+	start, end := 0, len(runes)
+	if radius > 0 {
+		if ranges[0][0] > radius {
+			start = ranges[0][0] - radius
+		}
+		if ranges[0][1]+radius < end {
+			end = ranges[0][1] + radius
+		}
+	}
+
+	var b strings.Builder
+	if start > 0 {
+		b.WriteString("…")
+	}
+	pos := start
+	for _, r := range ranges {
+		if r[1] <= start {
+			continue
+		}
+		if r[0] >= end {
+			break
+		}
+		from, to := max(r[0], start), min(r[1], end)
+		b.WriteString(html.EscapeString(string(runes[pos:from])))
+		b.WriteString("<mark>" + html.EscapeString(string(runes[from:to])) + "</mark>")
+		pos = to
+	}
+	b.WriteString(html.EscapeString(string(runes[pos:end])))
+	if end < len(runes) {
+		b.WriteString("…")
+	}
+
+	return b.String()
+}
+
+// matchRanges は検索語に一致する範囲（文字単位、大文字小文字は区別しない）を重なりを統合して返す
+func matchRanges(text []rune, terms []string) [][2]int {
+	var ranges [][2]int
+	for _, term := range terms {
+		t := []rune(term)
+		for i := 0; i+len(t) <= len(text); i++ {
+			if runesEqualFold(text[i:i+len(t)], t) {
+				ranges = append(ranges, [2]int{i, i + len(t)})
+			}
+		}
+	}
+
+	sort.Slice(ranges, func(i, j int) bool { return ranges[i][0] < ranges[j][0] })
+
+	merged := make([][2]int, 0, len(ranges))
+	for _, r := range ranges {
+		if n := len(merged); n > 0 && r[0] <= merged[n-1][1] {
+			merged[n-1][1] = max(merged[n-1][1], r[1])
+			continue
+		}
+		merged = append(merged, r)
+	}
+	return merged
+}
+
+// runesEqualFold は2つの文字列を大文字小文字を区別せずに比較する
+func runesEqualFold(a, b []rune) bool {
+	for i := range a {
+		if unicode.ToLower(a[i]) != unicode.ToLower(b[i]) {
+			return false
+		}
+	}
+	return true
+}
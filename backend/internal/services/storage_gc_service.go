@@ -0,0 +1,196 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/Ryo-cool/guideforge/internal/apperror"
+	"github.com/Ryo-cool/guideforge/internal/config"
+	"github.com/Ryo-cool/guideforge/internal/models"
+	"github.com/Ryo-cool/guideforge/internal/repository"
+	"github.com/Ryo-cool/guideforge/internal/storage"
+	"github.com/jmoiron/sqlx"
+)
+
+const (
+	// storageGCLockKey は複数のサーバーでGCが同時に実行されないようにするアドバイザリーロックのキー
+	storageGCLockKey int64 = 0x6766_7363 // "gfsc"
+	// storageGCReportLimit はレポートに含める孤立・欠損ファイルの最大件数
+	storageGCReportLimit = 1000
+)
+
+// StorageGCService はストレージと画像・改訂履歴・プロフィール画像の参照を突き合わせ、
+// どこからも参照されていないファイル（孤立ファイル）を削除する
+type StorageGCService struct {
+	repo          *repository.Repository
+	imageFileRepo *repository.ImageFileRepository
+	storage       storage.Storage
+	config        *config.Config
+}
+
+// NewStorageGCService は新しいStorageGCServiceインスタンスを作成
+func NewStorageGCService(repo *repository.Repository, imageFileRepo *repository.ImageFileRepository, store storage.Storage, cfg *config.Config) *StorageGCService {
+	return &StorageGCService{
+		repo:          repo,
+		imageFileRepo: imageFileRepo,
+		storage:       store,
+		config:        cfg,
+	}
+}
+
+// Run はストレージ全体を走査し、孤立ファイルと欠損ファイルを報告する
+// dryRunがfalseの場合、猶予期間（StorageGCGracePeriod）より古い孤立ファイルを削除する
+// 他のサーバーやコマンドで実行中の場合は競合エラーを返す
+func (s *StorageGCService) Run(dryRun bool) (*models.StorageGCReport, error) {
+	var report *models.StorageGCReport
+	ran, err := s.repo.WithAdvisoryLock(storageGCLockKey, func() error {
+		var err error
+		report, err = s.run(dryRun)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !ran {
+		return nil, apperror.Conflict("Storage garbage collection is already running")
+	}
+	return report, nil
+}
+
+// run はGCを実行する
+// 猶予期間内のファイルはアップロード途中でまだ画像行が作成されていない可能性があるため削除しない
+func (s *StorageGCService) run(dryRun bool) (*models.StorageGCReport, error) {
+	grace := s.config.StorageGCGracePeriod
+	report := &models.StorageGCReport{
+		DryRun:      dryRun,
+		GracePeriod: grace.String(),
+		StartedAt:   time.Now(),
+		Orphans:     []models.OrphanFile{},
+		Missing:     []models.MissingFile{},
+	}
+
+	// 走査中に作成されたファイルは参照の取得後に保存されている可能性があるため、
+	// 参照を取得する前の時刻を基準に猶予期間を判定する
+	cutoff := report.StartedAt.Add(-grace)
+
+	refs, err := s.imageFileRepo.GetReferencedPaths()
+	if err != nil {
+		return nil, err
+	}
+	report.Referenced = len(refs)
+
+	seen := make(map[string]bool, len(refs))
+	var orphans []storage.ObjectInfo
+	err = s.storage.List("", func(info storage.ObjectInfo) error {
+		report.Scanned++
+		if _, ok := refs[info.Key]; ok {
+			seen[info.Key] = true
+			return nil
+		}
+		orphans = append(orphans, info)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, info := range orphans {
+		orphan := models.OrphanFile{
+			Path:    info.Key,
+			Size:    info.Size,
+			ModTime: info.ModTime,
+		}
+		report.OrphanCount++
+
+		if !dryRun && info.ModTime.Before(cutoff) {
+			deleted, err := s.deleteOrphan(info.Key)
+			if err != nil {
+				log.Printf("storage gc: failed to delete %s: %v", info.Key, err)
+			} else if deleted {
+				orphan.Deleted = true
+				report.DeletedCount++
+				report.DeletedBytes += info.Size
+			}
+		}
+
+		if len(report.Orphans) < storageGCReportLimit {
+			report.Orphans = append(report.Orphans, orphan)
+		}
+	}
+
+	for path, source := range refs {
+		if seen[path] {
+			continue
+		}
+		report.MissingCount++
+		if len(report.Missing) < storageGCReportLimit {
+			report.Missing = append(report.Missing, models.MissingFile{Path: path, Source: source})
+		}
+	}
+
+	// ファイルが既に存在しない参照カウント0の行を片付ける
+	if !dryRun {
+		stale, err := s.imageFileRepo.GetUnreferencedPaths(grace)
+		if err != nil {
+			return nil, err
+		}
+		for _, path := range stale {
+			if _, err := s.deleteOrphan(path); err != nil {
+				log.Printf("storage gc: failed to release %s: %v", path, err)
+			}
+		}
+	}
+
+	report.FinishedAt = time.Now()
+	return report, nil
+}
+
+// deleteOrphan は参照カウントの行をロックしたうえで孤立ファイルを削除し、削除したかを返す
+// 走査後に同じ内容の画像がアップロードされて参照された場合は削除しない
+func (s *StorageGCService) deleteOrphan(path string) (bool, error) {
+	deleted := false
+	err := s.repo.Transaction(func(tx *sqlx.Tx) error {
+		released, err := s.imageFileRepo.ReleaseTx(tx, []string{path})
+		if err != nil {
+			return err
+		}
+		if len(released) == 0 {
+			return nil
+		}
+		if err := s.storage.Delete(path); err != nil {
+			return err
+		}
+		deleted = true
+		return nil
+	})
+	return deleted, err
+}
+
+// RunPeriodically はctxがキャンセルされるまで一定間隔でGCを実行する
+// 他のサーバーで実行中の場合はその回をスキップする
+func (s *StorageGCService) RunPeriodically(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		report, err := s.Run(false)
+		if errors.Is(err, apperror.ErrConflict) {
+			continue
+		}
+		if err != nil {
+			log.Printf("storage gc: %v", err)
+			continue
+		}
+
+		log.Printf("storage gc: scanned %d files, %d orphans, %d deleted (%d bytes), %d missing",
+			report.Scanned, report.OrphanCount, report.DeletedCount, report.DeletedBytes, report.MissingCount)
+	}
+}
@@ -0,0 +1,328 @@
+package services
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/base64"
+	"errors"
+	"image/png"
+	"strings"
+	"time"
+
+	"github.com/Ryo-cool/guideforge/internal/apperror"
+	"github.com/Ryo-cool/guideforge/internal/models"
+	"github.com/Ryo-cool/guideforge/internal/password"
+	"github.com/Ryo-cool/guideforge/internal/repository"
+	"github.com/jmoiron/sqlx"
+	"github.com/pquerna/otp"
+	"github.com/pquerna/otp/totp"
+)
+
+const (
+	// totpIssuer は認証アプリに表示するサービス名
+	totpIssuer = "GuideForge"
+	// totpPeriod はTOTPコードの時間ステップ（秒）
+	totpPeriod = 30
+	// totpSkew は時計のずれを許容する前後のステップ数
+	totpSkew = 1
+	// totpQRCodeSize は登録用のQRコード画像の一辺のピクセル数
+	totpQRCodeSize = 200
+	// recoveryCodeCount は一度に発行するリカバリーコードの数
+	recoveryCodeCount = 10
+	// recoveryCodeLength はリカバリーコードの文字数（区切りのハイフンを除く）
+	recoveryCodeLength = 10
+)
+
+// totpOpts はTOTPコードの生成に使用する設定（一般的な認証アプリと互換の値）
+var totpOpts = totp.ValidateOpts{
+	Period:    totpPeriod,
+	Digits:    otp.DigitsSix,
+	Algorithm: otp.AlgorithmSHA1,
+}
+
+// errTwoFactorCodeInvalid は二要素目のコードが誤っている場合のエラー
+var errTwoFactorCodeInvalid = apperror.Unauthorized("invalid two-factor authentication code")
+
+// TwoFactorService は二要素認証（TOTP・リカバリーコード）の登録と検証を行う
+type TwoFactorService struct {
+	repo          *repository.Repository
+	userRepo      *repository.UserRepository
+	twoFactorRepo *repository.TwoFactorRepository
+	passwords     *password.Hasher
+	audit         *AuditService
+}
+
+// NewTwoFactorService は新しいTwoFactorServiceインスタンスを作成
+func NewTwoFactorService(
+	repo *repository.Repository,
+	userRepo *repository.UserRepository,
+	twoFactorRepo *repository.TwoFactorRepository,
+	passwords *password.Hasher,
+	audit *AuditService,
+) *TwoFactorService {
+	return &TwoFactorService{
+		repo:          repo,
+		userRepo:      userRepo,
+		twoFactorRepo: twoFactorRepo,
+		passwords:     passwords,
+		audit:         audit,
+	}
+}
+
+// Status は二要素認証の設定状況を返す
+func (s *TwoFactorService) Status(userID uint) (*models.TwoFactorStatus, error) {
+	user, err := s.userRepo.GetByID(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	status := &models.TwoFactorStatus{
+		Enabled:   user.TwoFactorEnabled(),
+		EnabledAt: user.TOTPEnabledAt,
+	}
+	if status.Enabled {
+		if status.RecoveryCodesRemaining, err = s.twoFactorRepo.CountRecoveryCodes(userID); err != nil {
+			return nil, err
+		}
+	}
+	return status, nil
+}
+
+// BeginEnrollment は認証アプリの登録を開始し、シークレットとQRコードを返す
+// 登録は ConfirmEnrollment でコードを確認するまで有効にならない
+func (s *TwoFactorService) BeginEnrollment(userID uint) (*models.TOTPEnrollment, error) {
+	user, err := s.userRepo.GetByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.TwoFactorEnabled() {
+		return nil, apperror.Conflict("two-factor authentication is already enabled")
+	}
+
+	key, err := totp.Generate(totp.GenerateOpts{
+		Issuer:      totpIssuer,
+		AccountName: user.Email,
+		Period:      totpPeriod,
+		Digits:      totpOpts.Digits,
+		Algorithm:   totpOpts.Algorithm,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.twoFactorRepo.SetPendingSecret(userID, key.Secret()); err != nil {
+		return nil, err
+	}
+
+	img, err := key.Image(totpQRCodeSize, totpQRCodeSize)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+
+	return &models.TOTPEnrollment{
+		Secret:          key.Secret(),
+		ProvisioningURI: key.URL(),
+		QRCode:          "data:image/png;base64," + base64.StdEncoding.EncodeToString(buf.Bytes()),
+	}, nil
+}
+
+// ConfirmEnrollment は認証アプリのコードを確認して二要素認証を有効にし、リカバリーコードを発行する
+func (s *TwoFactorService) ConfirmEnrollment(userID uint, code string, client models.ClientInfo) (*models.RecoveryCodesResponse, error) {
+	user, err := s.userRepo.GetByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.TwoFactorEnabled() {
+		return nil, apperror.Conflict("two-factor authentication is already enabled")
+	}
+	if user.TOTPSecret == nil {
+		return nil, apperror.Validation("two-factor enrollment has not been started")
+	}
+
+	step, ok := matchTOTP(*user.TOTPSecret, code, time.Now())
+	if !ok {
+		return nil, apperror.Validation(
+			"invalid verification code",
+			apperror.FieldError{Field: "code", Message: "is invalid or expired"},
+		)
+	}
+
+	codes, hashes, err := generateRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	err = s.repo.Transaction(func(tx *sqlx.Tx) error {
+		if err := s.twoFactorRepo.EnableTx(tx, userID, step, hashes); err != nil {
+			return err
+		}
+		return s.audit.RecordTx(tx, newAuditEvent(userID, client, models.AuditActionTwoFactorEnable, models.AuditTargetUser, userID))
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.RecoveryCodesResponse{RecoveryCodes: codes}, nil
+}
+
+// Disable は現在のパスワードと二要素目のコードを確認して二要素認証を無効にする
+func (s *TwoFactorService) Disable(userID uint, currentPassword, code string, client models.ClientInfo) error {
+	user, err := s.userRepo.GetByID(userID)
+	if err != nil {
+		return err
+	}
+	if !user.TwoFactorEnabled() {
+		return apperror.Conflict("two-factor authentication is not enabled")
+	}
+
+	if err := s.passwords.Compare(user.PasswordHash, currentPassword); err != nil {
+		return apperror.Validation(
+			"password is incorrect",
+			apperror.FieldError{Field: "password", Message: "is incorrect"},
+		)
+	}
+	if err := s.VerifyCode(user, code); err != nil {
+		return err
+	}
+
+	return s.repo.Transaction(func(tx *sqlx.Tx) error {
+		if err := s.twoFactorRepo.DisableTx(tx, userID); err != nil {
+			return err
+		}
+		return s.audit.RecordTx(tx, newAuditEvent(userID, client, models.AuditActionTwoFactorDisable, models.AuditTargetUser, userID))
+	})
+}
+
+// RegenerateRecoveryCodes は二要素目のコードを確認してリカバリーコードを発行し直す
+// 以前のリカバリーコードはすべて使用できなくなる
+func (s *TwoFactorService) RegenerateRecoveryCodes(userID uint, code string, client models.ClientInfo) (*models.RecoveryCodesResponse, error) {
+	user, err := s.userRepo.GetByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if !user.TwoFactorEnabled() {
+		return nil, apperror.Conflict("two-factor authentication is not enabled")
+	}
+	if err := s.VerifyCode(user, code); err != nil {
+		return nil, err
+	}
+
+	codes, hashes, err := generateRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	err = s.repo.Transaction(func(tx *sqlx.Tx) error {
+		if err := s.twoFactorRepo.ReplaceRecoveryCodesTx(tx, userID, hashes); err != nil {
+			return err
+		}
+		return s.audit.RecordTx(tx, newAuditEvent(userID, client, models.AuditActionTwoFactorRecoveryCodes, models.AuditTargetUser, userID))
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.RecoveryCodesResponse{RecoveryCodes: codes}, nil
+}
+
+// VerifyCode は認証アプリのコードまたはリカバリーコードを検証し、使用済みにする
+// 6桁の数字はTOTPコード、それ以外はリカバリーコードとして扱う
+func (s *TwoFactorService) VerifyCode(user *models.User, code string) error {
+	if !user.TwoFactorEnabled() || user.TOTPSecret == nil {
+		return errTwoFactorCodeInvalid
+	}
+
+	code = normalizeCode(code)
+	if isTOTPCode(code) {
+		step, ok := matchTOTP(*user.TOTPSecret, code, time.Now())
+		if !ok {
+			return errTwoFactorCodeInvalid
+		}
+		// 一度使用したコードは有効期間内でも再利用できない
+		used, err := s.twoFactorRepo.UseStep(user.ID, step)
+		if err != nil {
+			return err
+		}
+		if !used {
+			return errTwoFactorCodeInvalid
+		}
+		return nil
+	}
+
+	used, err := s.twoFactorRepo.UseRecoveryCode(user.ID, hashToken(code))
+	if err != nil {
+		return err
+	}
+	if !used {
+		return errTwoFactorCodeInvalid
+	}
+	return nil
+}
+
+// matchTOTP はコードが前後 totpSkew ステップ以内のTOTPコードと一致するかを確認し、一致した時間ステップを返す
+func matchTOTP(secret, code string, now time.Time) (int64, bool) {
+	code = normalizeCode(code)
+	if !isTOTPCode(code) {
+		return 0, false
+	}
+
+	current := now.Unix() / totpPeriod
+	for offset := int64(-totpSkew); offset <= totpSkew; offset++ {
+		step := current + offset
+		expected, err := totp.GenerateCodeCustom(secret, time.Unix(step*totpPeriod, 0), totpOpts)
+		if err != nil {
+			return 0, false
+		}
+		if subtle.ConstantTimeCompare([]byte(expected), []byte(code)) == 1 {
+			return step, true
+		}
+	}
+	return 0, false
+}
+
+// generateRecoveryCodes はリカバリーコードを生成し、平文と保存用のハッシュを返す
+// 平文は読み間違えにくいよう5文字ごとにハイフンで区切る
+func generateRecoveryCodes() ([]string, []string, error) {
+	encoding := base32.StdEncoding.WithPadding(base32.NoPadding)
+	codes := make([]string, 0, recoveryCodeCount)
+	hashes := make([]string, 0, recoveryCodeCount)
+
+	for i := 0; i < recoveryCodeCount; i++ {
+		b := make([]byte, recoveryCodeLength)
+		if _, err := rand.Read(b); err != nil {
+			return nil, nil, err
+		}
+		raw := strings.ToLower(encoding.EncodeToString(b))[:recoveryCodeLength]
+		codes = append(codes, raw[:recoveryCodeLength/2]+"-"+raw[recoveryCodeLength/2:])
+		hashes = append(hashes, hashToken(raw))
+	}
+
+	return codes, hashes, nil
+}
+
+// normalizeCode は入力されたコードから空白・ハイフンを取り除き、小文字にする
+func normalizeCode(code string) string {
+	return strings.ToLower(strings.NewReplacer(" ", "", "-", "").Replace(strings.TrimSpace(code)))
+}
+
+// isTOTPCode はTOTPコードの形式（6桁の数字）かを返す
+func isTOTPCode(code string) bool {
+	if len(code) != int(totpOpts.Digits) {
+		return false
+	}
+	for _, r := range code {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// isTwoFactorCodeError は二要素目のコードが誤っていることによるエラーかを返す
+func isTwoFactorCodeError(err error) bool {
+	return errors.Is(err, errTwoFactorCodeInvalid)
+}
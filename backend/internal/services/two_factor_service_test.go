@@ -0,0 +1,74 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pquerna/otp/totp"
+)
+
+func TestMatchTOTP(t *testing.T) {
+	const secret = "JBSWY3DPEHPK3PXPJBSWY3DPEHPK3PXP"
+	now := time.Unix(1_700_000_015, 0)
+	current := now.Unix() / totpPeriod
+
+	codeAt := func(offset int64) string {
+		t.Helper()
+		code, err := totp.GenerateCodeCustom(secret, time.Unix((current+offset)*totpPeriod, 0), totpOpts)
+		if err != nil {
+			t.Fatalf("GenerateCodeCustom() error = %v", err)
+		}
+		return code
+	}
+
+	tests := []struct {
+		name     string
+		code     string
+		wantStep int64
+		wantOK   bool
+	}{
+		{name: "current step", code: codeAt(0), wantStep: current, wantOK: true},
+		{name: "previous step", code: codeAt(-1), wantStep: current - 1, wantOK: true},
+		{name: "next step", code: codeAt(1), wantStep: current + 1, wantOK: true},
+		{name: "two steps behind", code: codeAt(-2), wantOK: false},
+		{name: "two steps ahead", code: codeAt(2), wantOK: false},
+		{name: "with spaces", code: codeAt(0)[:3] + " " + codeAt(0)[3:], wantStep: current, wantOK: true},
+		{name: "not a code", code: "abcdef", wantOK: false},
+		{name: "empty", code: "", wantOK: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			step, ok := matchTOTP(secret, tt.code, now)
+			if ok != tt.wantOK {
+				t.Fatalf("matchTOTP(%q) ok = %v, want %v", tt.code, ok, tt.wantOK)
+			}
+			if ok && step != tt.wantStep {
+				t.Errorf("matchTOTP(%q) step = %d, want %d", tt.code, step, tt.wantStep)
+			}
+		})
+	}
+}
+
+func TestIsTOTPCode(t *testing.T) {
+	tests := []struct {
+		code string
+		want bool
+	}{
+		{code: "123456", want: true},
+		{code: "000000", want: true},
+		{code: "12345", want: false},
+		{code: "1234567", want: false},
+		{code: "12345a", want: false},
+		{code: "１２３４５６", want: false},
+		{code: "", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.code, func(t *testing.T) {
+			if got := isTOTPCode(tt.code); got != tt.want {
+				t.Errorf("isTOTPCode(%q) = %v, want %v", tt.code, got, tt.want)
+			}
+		})
+	}
+}
@@ -1,28 +1,54 @@
 package services
 
 import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
 	"errors"
-	"os"
-	"path/filepath"
+	"math"
+	"path"
 
 	"strconv"
+	"strings"
 
+	"github.com/Ryo-cool/guideforge/internal/apperror"
 	"github.com/Ryo-cool/guideforge/internal/config"
+	"github.com/Ryo-cool/guideforge/internal/imageproc"
 	"github.com/Ryo-cool/guideforge/internal/models"
 	"github.com/Ryo-cool/guideforge/internal/repository"
+	"github.com/Ryo-cool/guideforge/internal/storage"
+	"github.com/jmoiron/sqlx"
 )
 
 // UserService はユーザー関連の機能を提供するサービス
 type UserService struct {
-	userRepo *repository.UserRepository
-	config   *config.Config
+	repo              *repository.Repository
+	userRepo          *repository.UserRepository
+	workspaceRepo     *repository.WorkspaceRepository
+	emailVerification *EmailVerificationService
+	audit             *AuditService
+	storage           storage.Storage
+	config            *config.Config
 }
 
 // NewUserService は新しいUserServiceインスタンスを作成
-func NewUserService(userRepo *repository.UserRepository, cfg *config.Config) *UserService {
+func NewUserService(
+	repo *repository.Repository,
+	userRepo *repository.UserRepository,
+	workspaceRepo *repository.WorkspaceRepository,
+	emailVerification *EmailVerificationService,
+	audit *AuditService,
+	store storage.Storage,
+	cfg *config.Config,
+) *UserService {
 	return &UserService{
-		userRepo: userRepo,
-		config:   cfg,
+		repo:              repo,
+		userRepo:          userRepo,
+		workspaceRepo:     workspaceRepo,
+		emailVerification: emailVerification,
+		audit:             audit,
+		storage:           store,
+		config:            cfg,
 	}
 }
 
@@ -34,108 +60,285 @@ func (s *UserService) GetUserByID(id uint) (*models.UserResponse, error) {
 	}
 
 	return &models.UserResponse{
-		ID:           user.ID,
-		Username:     user.Username,
-		Email:        user.Email,
-		ProfileImage: user.ProfileImage,
-		CreatedAt:    user.CreatedAt,
-		UpdatedAt:    user.UpdatedAt,
+		ID:               user.ID,
+		Username:         user.Username,
+		Email:            user.Email,
+		ProfileImage:     user.ProfileImage,
+		Role:             user.Role,
+		TwoFactorEnabled: user.TwoFactorEnabled(),
+		EmailVerified:    user.EmailVerified(),
+		CreatedAt:        user.CreatedAt,
+		UpdatedAt:        user.UpdatedAt,
 	}, nil
 }
 
 // UpdateUserProfile はユーザープロフィールを更新する
-func (s *UserService) UpdateUserProfile(id uint, username, email string) (*models.UserResponse, error) {
+func (s *UserService) UpdateUserProfile(id uint, username, email string, client models.ClientInfo) (*models.UserResponse, error) {
 	// 現在のユーザー情報を取得
 	user, err := s.userRepo.GetByID(id)
 	if err != nil {
 		return nil, err
 	}
 
-	// メールアドレスが変更される場合は重複チェック
+	// メールアドレスが変更される場合は新しいアドレスの確認が済んでから反映する
+	pendingEmail := ""
 	if email != user.Email {
-		existingUser, _ := s.userRepo.GetByEmail(email)
-		if existingUser != nil {
-			return nil, errors.New("email already registered")
+		if err := s.emailVerification.RequestEmailChange(user, email); err != nil {
+			return nil, err
 		}
+		pendingEmail = email
 	}
 
+	before := userAuditSummary(user)
+
 	// ユーザー情報更新
 	user.Username = username
-	user.Email = email
 
-	if err := s.userRepo.Update(user); err != nil {
+	err = s.repo.Transaction(func(tx *sqlx.Tx) error {
+		if err := s.userRepo.UpdateTx(tx, user); err != nil {
+			return err
+		}
+		event := newAuditEvent(id, client, models.AuditActionUserUpdate, models.AuditTargetUser, id)
+		event.Before = before
+		event.After = userAuditSummary(user)
+		return s.audit.RecordTx(tx, event)
+	})
+	if err != nil {
 		return nil, err
 	}
 
 	return &models.UserResponse{
-		ID:           user.ID,
-		Username:     user.Username,
-		Email:        user.Email,
-		ProfileImage: user.ProfileImage,
-		CreatedAt:    user.CreatedAt,
-		UpdatedAt:    user.UpdatedAt,
+		ID:               user.ID,
+		Username:         user.Username,
+		Email:            user.Email,
+		ProfileImage:     user.ProfileImage,
+		Role:             user.Role,
+		TwoFactorEnabled: user.TwoFactorEnabled(),
+		EmailVerified:    user.EmailVerified(),
+		PendingEmail:     pendingEmail,
+		CreatedAt:        user.CreatedAt,
+		UpdatedAt:        user.UpdatedAt,
 	}, nil
 }
 
 // UpdateProfileImage はプロフィール画像を更新する
-func (s *UserService) UpdateProfileImage(userID uint, filename string, fileData []byte) (*models.UserResponse, error) {
+// 画像は検証・EXIF除去したうえで ProfileImageSize に収まるよう縮小して保存する
+func (s *UserService) UpdateProfileImage(userID uint, fileData []byte, client models.ClientInfo) (*models.UserResponse, error) {
 	// 現在のユーザー情報を取得
 	user, err := s.userRepo.GetByID(userID)
 	if err != nil {
 		return nil, err
 	}
 
-	// 古い画像を削除（存在する場合）
-	if user.ProfileImage != "" {
-		oldPath := filepath.Join(s.config.UploadDir, user.ProfileImage)
-		os.Remove(oldPath) // エラーは無視
+	// 画像の処理
+	src, err := imageproc.Decode(fileData)
+	if err != nil {
+		if errors.Is(err, imageproc.ErrUnsupportedFormat) || errors.Is(err, imageproc.ErrInvalidImage) {
+			return nil, apperror.Wrap(apperror.ErrValidation, err, "Invalid image file")
+		}
+		return nil, err
+	}
+	processed, err := src.Encode(s.config.ProfileImageSize)
+	if err != nil {
+		return nil, err
 	}
 
 	// 新しい画像を保存
-	ext := filepath.Ext(filename)
-	newFilename := filepath.Join("profiles", "user_"+strconv.FormatUint(uint64(userID), 10)+ext)
-	newPath := filepath.Join(s.config.UploadDir, newFilename)
-
-	// ディレクトリ作成
-	if err := os.MkdirAll(filepath.Dir(newPath), 0755); err != nil {
+	// 更新が確定するまで現在の画像を上書きしないよう、毎回新しいキーに保存する
+	newFilename, err := newProfileImageKey(userID, processed.Ext)
+	if err != nil {
 		return nil, err
 	}
-
-	// ファイル書き込み
-	if err := os.WriteFile(newPath, fileData, 0644); err != nil {
+	if err := s.storage.Put(newFilename, bytes.NewReader(processed.Data), int64(len(processed.Data)), processed.MimeType); err != nil {
 		return nil, err
 	}
 
 	// ユーザー情報更新
+	oldFilename := user.ProfileImage
+	before := models.AuditSummary{"profile_image": oldFilename}
 	user.ProfileImage = newFilename
-	if err := s.userRepo.Update(user); err != nil {
+	err = s.repo.Transaction(func(tx *sqlx.Tx) error {
+		if err := s.userRepo.UpdateTx(tx, user); err != nil {
+			return err
+		}
+		event := newAuditEvent(userID, client, models.AuditActionUserProfileImageUpdate, models.AuditTargetUser, userID)
+		event.Before = before
+		event.After = models.AuditSummary{"profile_image": user.ProfileImage}
+		return s.audit.RecordTx(tx, event)
+	})
+	if err != nil {
+		s.storage.Delete(newFilename) // 失敗した場合は孤立ファイルのGCで削除される
 		return nil, err
 	}
 
+	// 参照されなくなった古い画像を更新の確定後に削除する
+	// プロフィール画像用のキー以外は他で共有されている可能性があるため削除しない（参照カウントとGCで管理する）
+	if isProfileImageKey(userID, oldFilename) {
+		s.storage.Delete(oldFilename) // 失敗した場合は孤立ファイルのGCで削除される
+	}
+
 	return &models.UserResponse{
-		ID:           user.ID,
-		Username:     user.Username,
-		Email:        user.Email,
-		ProfileImage: user.ProfileImage,
-		CreatedAt:    user.CreatedAt,
-		UpdatedAt:    user.UpdatedAt,
+		ID:               user.ID,
+		Username:         user.Username,
+		Email:            user.Email,
+		ProfileImage:     user.ProfileImage,
+		Role:             user.Role,
+		TwoFactorEnabled: user.TwoFactorEnabled(),
+		EmailVerified:    user.EmailVerified(),
+		CreatedAt:        user.CreatedAt,
+		UpdatedAt:        user.UpdatedAt,
 	}, nil
 }
 
 // DeleteUser はユーザーアカウントを削除する
-func (s *UserService) DeleteUser(id uint) error {
+func (s *UserService) DeleteUser(id uint, client models.ClientInfo) error {
 	// ユーザー情報を取得
 	user, err := s.userRepo.GetByID(id)
 	if err != nil {
 		return err
 	}
 
-	// プロフィール画像を削除（存在する場合）
-	if user.ProfileImage != "" {
-		imagePath := filepath.Join(s.config.UploadDir, user.ProfileImage)
-		os.Remove(imagePath) // エラーは無視
+	err = s.repo.Transaction(func(tx *sqlx.Tx) error {
+		// 削除後は操作者のメールアドレスを参照できないため、削除前に記録する
+		event := newAuditEvent(id, client, models.AuditActionUserDelete, models.AuditTargetUser, id)
+		event.Before = userAuditSummary(user)
+		if err := s.audit.RecordTx(tx, event); err != nil {
+			return err
+		}
+
+		// ワークスペースのマニュアルは他のメンバーに引き継ぐ
+		if err := s.workspaceRepo.TransferManualsFromUserTx(tx, id); err != nil {
+			return err
+		}
+
+		// ユーザーを削除（セッションも削除されるため、発行済みのアクセストークンも使用できなくなる）
+		return s.userRepo.DeleteTx(tx, id)
+	})
+	if err != nil {
+		return err
+	}
+
+	// プロフィール画像は削除の確定後に削除する（プロフィール画像用のキーの場合のみ）
+	if isProfileImageKey(id, user.ProfileImage) {
+		s.storage.Delete(user.ProfileImage) // 失敗した場合は孤立ファイルのGCで削除される
+	}
+
+	return nil
+}
+
+// ListUsers は管理者向けにユーザー一覧を取得する
+func (s *UserService) ListUsers(page, limit int) (*models.PaginatedResponse, error) {
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 10
+	}
+
+	users, total, err := s.userRepo.GetAll(page, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	items := make([]models.UserResponse, 0, len(users))
+	for i := range users {
+		items = append(items, newUserResponse(&users[i]))
+	}
+
+	totalPages := int(math.Ceil(float64(total) / float64(limit)))
+
+	return &models.PaginatedResponse{
+		Pagination: models.PaginationResponse{
+			Total:      total,
+			Page:       page,
+			Limit:      limit,
+			TotalPages: totalPages,
+		},
+		Items: items,
+	}, nil
+}
+
+// UpdateUserRole はユーザーのロールを変更する（管理者のみ）
+// トークンのロールは発行時点のものなので、操作者の現在のロールをDBで再確認する
+func (s *UserService) UpdateUserRole(actorID, targetID uint, role string, client models.ClientInfo) (*models.UserResponse, error) {
+	actor, err := s.userRepo.GetByID(actorID)
+	if err != nil {
+		return nil, err
+	}
+	if actor.Role != models.RoleAdmin {
+		return nil, apperror.Forbidden("insufficient privileges")
+	}
+
+	user, err := s.userRepo.GetByID(targetID)
+	if err != nil {
+		return nil, err
+	}
+
+	// 最後の管理者は降格させない
+	if user.Role == models.RoleAdmin && role != models.RoleAdmin {
+		admins, err := s.userRepo.CountByRole(models.RoleAdmin)
+		if err != nil {
+			return nil, err
+		}
+		if admins <= 1 {
+			return nil, apperror.Conflict("at least one admin is required")
+		}
+	}
+
+	err = s.repo.Transaction(func(tx *sqlx.Tx) error {
+		if err := s.userRepo.UpdateRoleTx(tx, targetID, role); err != nil {
+			return err
+		}
+		event := newAuditEvent(actorID, client, models.AuditActionUserRoleChange, models.AuditTargetUser, targetID)
+		event.Before = models.AuditSummary{"role": user.Role}
+		event.After = models.AuditSummary{"role": role}
+		return s.audit.RecordTx(tx, event)
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	// ユーザーを削除
-	return s.userRepo.Delete(id)
+	user.Role = role
+	res := newUserResponse(user)
+	return &res, nil
+}
+
+// newUserResponse はユーザーモデルからレスポンスを作成する
+func newUserResponse(user *models.User) models.UserResponse {
+	return models.UserResponse{
+		ID:               user.ID,
+		Username:         user.Username,
+		Email:            user.Email,
+		ProfileImage:     user.ProfileImage,
+		Role:             user.Role,
+		TwoFactorEnabled: user.TwoFactorEnabled(),
+		EmailVerified:    user.EmailVerified(),
+		CreatedAt:        user.CreatedAt,
+		UpdatedAt:        user.UpdatedAt,
+	}
+}
+
+// profileImageKeyPrefix はユーザーのプロフィール画像を保存するキーの先頭を返す
+func profileImageKeyPrefix(userID uint) string {
+	return path.Join("profiles", "user_"+strconv.FormatUint(uint64(userID), 10))
+}
+
+// newProfileImageKey はプロフィール画像を保存する新しいキー（profiles/user_<id>/<ランダムな値><拡張子>）を返す
+func newProfileImageKey(userID uint, ext string) (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return profileImageKeyPrefix(userID) + "/" + hex.EncodeToString(b) + ext, nil
+}
+
+// isProfileImageKey はキーがユーザーのプロフィール画像用のものかを返す
+// profiles/user_<id>/<ファイル名> と、以前の形式の profiles/user_<id>.<拡張子> を対象とする
+func isProfileImageKey(userID uint, key string) bool {
+	prefix := profileImageKeyPrefix(userID)
+	if rest, ok := strings.CutPrefix(key, prefix+"/"); ok {
+		return rest != "" && !strings.Contains(rest, "/")
+	}
+	rest, ok := strings.CutPrefix(key, prefix+".")
+	return ok && rest != "" && !strings.Contains(rest, "/")
 }
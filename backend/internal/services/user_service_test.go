@@ -0,0 +1,54 @@
+package services
+
+import "testing"
+
+func TestNewProfileImageKey(t *testing.T) {
+	first, err := newProfileImageKey(42, ".jpg")
+	if err != nil {
+		t.Fatalf("newProfileImageKey() error = %v", err)
+	}
+	second, err := newProfileImageKey(42, ".jpg")
+	if err != nil {
+		t.Fatalf("newProfileImageKey() error = %v", err)
+	}
+
+	if first == second {
+		t.Errorf("newProfileImageKey() returned the same key twice: %q", first)
+	}
+	for _, key := range []string{first, second} {
+		if !isProfileImageKey(42, key) {
+			t.Errorf("isProfileImageKey(42, %q) = false, want true", key)
+		}
+		if isProfileImageKey(43, key) {
+			t.Errorf("isProfileImageKey(43, %q) = true, want false", key)
+		}
+	}
+}
+
+func TestIsProfileImageKey(t *testing.T) {
+	tests := []struct {
+		key  string
+		want bool
+	}{
+		{key: "profiles/user_42/0123456789abcdef0123456789abcdef.jpg", want: true},
+		{key: "profiles/user_42/", want: false},
+		{key: "profiles/user_42/a/b.jpg", want: false},
+		{key: "profiles/user_4/abc.jpg", want: false},
+		{key: "profiles/user_42.jpg", want: true},
+		{key: "profiles/user_42.png", want: true},
+		{key: "profiles/user_4.jpg", want: false},
+		{key: "profiles/user_420.jpg", want: false},
+		{key: "profiles/user_42.", want: false},
+		{key: "profiles/user_42./x.jpg", want: false},
+		{key: "images/ab/abcdef.jpg", want: false},
+		{key: "", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			if got := isProfileImageKey(42, tt.key); got != tt.want {
+				t.Errorf("isProfileImageKey(42, %q) = %v, want %v", tt.key, got, tt.want)
+			}
+		})
+	}
+}
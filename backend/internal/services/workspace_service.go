@@ -0,0 +1,272 @@
+package services
+
+import (
+	"github.com/Ryo-cool/guideforge/internal/apperror"
+	"github.com/Ryo-cool/guideforge/internal/models"
+	"github.com/Ryo-cool/guideforge/internal/repository"
+	"github.com/jmoiron/sqlx"
+)
+
+// WorkspaceService はワークスペース関連の機能を提供するサービス
+type WorkspaceService struct {
+	repo          *repository.Repository
+	workspaceRepo *repository.WorkspaceRepository
+	userRepo      *repository.UserRepository
+	audit         *AuditService
+}
+
+// NewWorkspaceService は新しいWorkspaceServiceインスタンスを作成
+func NewWorkspaceService(
+	repo *repository.Repository,
+	workspaceRepo *repository.WorkspaceRepository,
+	userRepo *repository.UserRepository,
+	audit *AuditService,
+) *WorkspaceService {
+	return &WorkspaceService{
+		repo:          repo,
+		workspaceRepo: workspaceRepo,
+		userRepo:      userRepo,
+		audit:         audit,
+	}
+}
+
+// CreateWorkspace は新しいワークスペースを作成する（作成者はオーナーになる）
+func (s *WorkspaceService) CreateWorkspace(userID uint, req models.WorkspaceRequest, client models.ClientInfo) (*models.Workspace, error) {
+	workspace := &models.Workspace{
+		Name: req.Name,
+	}
+	if req.RequireTwoFactor != nil && *req.RequireTwoFactor {
+		if err := s.ensureTwoFactorEnabled(userID); err != nil {
+			return nil, err
+		}
+		workspace.RequireTwoFactor = true
+	}
+
+	err := s.repo.Transaction(func(tx *sqlx.Tx) error {
+		if err := s.workspaceRepo.CreateTx(tx, workspace, userID); err != nil {
+			return err
+		}
+		event := newAuditEvent(userID, client, models.AuditActionWorkspaceCreate, models.AuditTargetWorkspace, workspace.ID)
+		event.After = workspaceAuditSummary(workspace)
+		return s.audit.RecordTx(tx, event)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return workspace, nil
+}
+
+// GetUserWorkspaces はユーザーが所属するワークスペース一覧を取得する
+func (s *WorkspaceService) GetUserWorkspaces(userID uint) ([]models.Workspace, error) {
+	return s.workspaceRepo.GetAllByUserID(userID)
+}
+
+// GetWorkspace はメンバー一覧付きでワークスペースを取得する
+func (s *WorkspaceService) GetWorkspace(id, userID uint) (*models.Workspace, error) {
+	workspace, role, err := s.getWithRole(id, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if role == "" {
+		return nil, apperror.Forbidden("not a member of this workspace")
+	}
+
+	members, err := s.workspaceRepo.GetMembers(id)
+	if err != nil {
+		return nil, err
+	}
+
+	workspace.Role = role
+	workspace.Members = members
+	return workspace, nil
+}
+
+// UpdateWorkspace はワークスペース情報を更新する
+func (s *WorkspaceService) UpdateWorkspace(id, userID uint, req models.WorkspaceRequest, client models.ClientInfo) (*models.Workspace, error) {
+	workspace, role, err := s.getWithRole(id, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if role != models.WorkspaceRoleOwner {
+		return nil, apperror.Forbidden("only workspace owners can update the workspace")
+	}
+
+	before := workspaceAuditSummary(workspace)
+
+	workspace.Name = req.Name
+	if req.RequireTwoFactor != nil {
+		// 設定したオーナー自身が締め出されないよう、先に二要素認証を有効にさせる
+		if *req.RequireTwoFactor && !workspace.RequireTwoFactor {
+			if err := s.ensureTwoFactorEnabled(userID); err != nil {
+				return nil, err
+			}
+		}
+		workspace.RequireTwoFactor = *req.RequireTwoFactor
+	}
+	err = s.repo.Transaction(func(tx *sqlx.Tx) error {
+		if err := s.workspaceRepo.UpdateTx(tx, workspace); err != nil {
+			return err
+		}
+		event := newAuditEvent(userID, client, models.AuditActionWorkspaceUpdate, models.AuditTargetWorkspace, id)
+		event.Before = before
+		event.After = workspaceAuditSummary(workspace)
+		return s.audit.RecordTx(tx, event)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	workspace.Role = role
+	return workspace, nil
+}
+
+// DeleteWorkspace はワークスペースを削除する
+// 所属していたマニュアルは削除されず、作成者の個人マニュアルに戻る
+func (s *WorkspaceService) DeleteWorkspace(id, userID uint, client models.ClientInfo) error {
+	workspace, role, err := s.getWithRole(id, userID)
+	if err != nil {
+		return err
+	}
+
+	if role != models.WorkspaceRoleOwner {
+		return apperror.Forbidden("only workspace owners can delete the workspace")
+	}
+
+	return s.repo.Transaction(func(tx *sqlx.Tx) error {
+		event := newAuditEvent(userID, client, models.AuditActionWorkspaceDelete, models.AuditTargetWorkspace, id)
+		event.Before = workspaceAuditSummary(workspace)
+		if err := s.audit.RecordTx(tx, event); err != nil {
+			return err
+		}
+		return s.workspaceRepo.DeleteTx(tx, id)
+	})
+}
+
+// AddMember はメールアドレスで指定したユーザーをワークスペースに追加する
+func (s *WorkspaceService) AddMember(id, userID uint, req models.WorkspaceMemberRequest, client models.ClientInfo) ([]models.WorkspaceMember, error) {
+	_, role, err := s.getWithRole(id, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if role != models.WorkspaceRoleOwner {
+		return nil, apperror.Forbidden("only workspace owners can manage members")
+	}
+
+	member, err := s.userRepo.GetByEmail(req.Email)
+	if err != nil {
+		return nil, err
+	}
+
+	memberRole := req.Role
+	if memberRole == "" {
+		memberRole = models.WorkspaceRoleEditor
+	}
+
+	// 最後のオーナーを降格させない
+	if member.ID == userID && memberRole != models.WorkspaceRoleOwner {
+		if err := s.ensureAnotherOwner(id); err != nil {
+			return nil, err
+		}
+	}
+
+	previousRole, err := s.workspaceRepo.GetMemberRole(id, member.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.repo.Transaction(func(tx *sqlx.Tx) error {
+		if err := s.workspaceRepo.AddMemberTx(tx, id, member.ID, memberRole); err != nil {
+			return err
+		}
+		event := newAuditEvent(userID, client, models.AuditActionWorkspaceMemberAdd, models.AuditTargetWorkspace, id)
+		if previousRole != "" {
+			event.Before = models.AuditSummary{"user_id": member.ID, "role": previousRole}
+		}
+		event.After = models.AuditSummary{"user_id": member.ID, "email": member.Email, "role": memberRole}
+		return s.audit.RecordTx(tx, event)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.workspaceRepo.GetMembers(id)
+}
+
+// RemoveMember はワークスペースからメンバーを削除する
+// オーナーは任意のメンバーを、メンバーは自分自身のみ削除（脱退）できる
+func (s *WorkspaceService) RemoveMember(id, userID, memberID uint, client models.ClientInfo) error {
+	_, role, err := s.getWithRole(id, userID)
+	if err != nil {
+		return err
+	}
+
+	if role == "" || (role != models.WorkspaceRoleOwner && memberID != userID) {
+		return apperror.Forbidden("only workspace owners can manage members")
+	}
+
+	memberRole, err := s.workspaceRepo.GetMemberRole(id, memberID)
+	if err != nil {
+		return err
+	}
+
+	// 最後のオーナーは削除できない
+	if memberRole == models.WorkspaceRoleOwner {
+		if err := s.ensureAnotherOwner(id); err != nil {
+			return err
+		}
+	}
+
+	return s.repo.Transaction(func(tx *sqlx.Tx) error {
+		if err := s.workspaceRepo.RemoveMemberTx(tx, id, memberID); err != nil {
+			return err
+		}
+		event := newAuditEvent(userID, client, models.AuditActionWorkspaceMemberRemove, models.AuditTargetWorkspace, id)
+		event.Before = models.AuditSummary{"user_id": memberID, "role": memberRole}
+		return s.audit.RecordTx(tx, event)
+	})
+}
+
+// getWithRole はワークスペースとユーザーの役割を取得する
+func (s *WorkspaceService) getWithRole(id, userID uint) (*models.Workspace, string, error) {
+	workspace, err := s.workspaceRepo.GetByID(id)
+	if err != nil {
+		return nil, "", err
+	}
+
+	role, err := workspaceMemberRole(s.workspaceRepo, id, userID)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return workspace, role, nil
+}
+
+// ensureTwoFactorEnabled はユーザーが二要素認証を有効にしていない場合にエラーを返す
+func (s *WorkspaceService) ensureTwoFactorEnabled(userID uint) error {
+	user, err := s.userRepo.GetByID(userID)
+	if err != nil {
+		return err
+	}
+	if !user.TwoFactorEnabled() {
+		return apperror.Forbidden("enable two-factor authentication on your account before requiring it for the workspace")
+	}
+	return nil
+}
+
+// ensureAnotherOwner はオーナーが他にもいることを確認する
+func (s *WorkspaceService) ensureAnotherOwner(id uint) error {
+	owners, err := s.workspaceRepo.CountOwners(id)
+	if err != nil {
+		return err
+	}
+
+	if owners <= 1 {
+		return apperror.Conflict("a workspace must have at least one owner")
+	}
+
+	return nil
+}
@@ -0,0 +1,210 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime"
+	"os"
+	"path/filepath"
+)
+
+// LocalStorage はローカルファイルシステムにファイルを保存する
+// 複数のAPIサーバーで共有できないため、単一構成や開発環境向け
+type LocalStorage struct {
+	root      string
+	publicURL string
+}
+
+// NewLocalStorage は新しいLocalStorageインスタンスを作成
+// rootが存在しない場合は作成する
+func NewLocalStorage(root, publicURL string) (*LocalStorage, error) {
+	if err := os.MkdirAll(root, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create upload directory: %w", err)
+	}
+	if publicURL == "" {
+		publicURL = "/uploads"
+	}
+
+	return &LocalStorage{
+		root:      root,
+		publicURL: publicURL,
+	}, nil
+}
+
+// Put はファイルを保存する
+// 書き込み途中のファイルを読まれないよう、一時ファイルに書き込んでから置き換える
+func (s *LocalStorage) Put(key string, r io.Reader, size int64, contentType string) error {
+	fullPath, err := s.path(key)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(fullPath), 0755); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(fullPath), ".upload-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name()) // 置き換え後は存在しないためエラーは無視
+
+	if _, err := io.Copy(tmp, r); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), fullPath)
+}
+
+// Get はファイルを取得する
+func (s *LocalStorage) Get(key string) (*Object, error) {
+	fullPath, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := os.Open(fullPath)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	if info.IsDir() {
+		f.Close()
+		return nil, ErrNotFound
+	}
+
+	return &Object{
+		ReadSeekCloser: f,
+		ObjectInfo:     s.objectInfo(key, info),
+	}, nil
+}
+
+// Stat はファイルの情報を取得する
+func (s *LocalStorage) Stat(key string) (*ObjectInfo, error) {
+	fullPath, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := os.Stat(fullPath)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	if info.IsDir() {
+		return nil, ErrNotFound
+	}
+
+	objectInfo := s.objectInfo(key, info)
+	return &objectInfo, nil
+}
+
+// Delete はファイルを削除する
+func (s *LocalStorage) Delete(key string) error {
+	fullPath, err := s.path(key)
+	if err != nil {
+		return err
+	}
+
+	if err := os.Remove(fullPath); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// URL はファイルのURLを返す
+func (s *LocalStorage) URL(key string) string {
+	cleaned, err := cleanKey(key)
+	if err != nil {
+		return ""
+	}
+	return joinURL(s.publicURL, cleaned)
+}
+
+// List はprefix配下のファイルを列挙する
+// 書き込み途中の一時ファイルも含まれる（呼び出し側で更新日時により判断する）
+func (s *LocalStorage) List(prefix string, fn func(ObjectInfo) error) error {
+	dir := s.root
+	if prefix != "" {
+		var err error
+		if dir, err = s.path(prefix); err != nil {
+			return err
+		}
+	}
+
+	err := filepath.WalkDir(dir, func(fullPath string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if entry.IsDir() {
+			return nil
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+
+		rel, err := filepath.Rel(s.root, fullPath)
+		if err != nil {
+			return err
+		}
+		return fn(s.objectInfo(filepath.ToSlash(rel), info))
+	})
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+// path はキーに対応するファイルパスを返す
+func (s *LocalStorage) path(key string) (string, error) {
+	cleaned, err := cleanKey(key)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(s.root, filepath.FromSlash(cleaned)), nil
+}
+
+// objectInfo はファイル情報からObjectInfoを作成する
+// ローカルファイルはContent-TypeとETagを保持しないため、拡張子と更新日時・サイズから求める
+func (s *LocalStorage) objectInfo(key string, info fs.FileInfo) ObjectInfo {
+	key, _ = cleanKey(key)
+	contentType := mime.TypeByExtension(filepath.Ext(key))
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+
+	return ObjectInfo{
+		Key:         key,
+		Size:        info.Size(),
+		ContentType: contentType,
+		ModTime:     info.ModTime(),
+		ETag:        fmt.Sprintf(`"%x-%x"`, info.ModTime().UnixNano(), info.Size()),
+	}
+}
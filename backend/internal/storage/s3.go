@@ -0,0 +1,368 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/smithy-go"
+)
+
+// s3Timeout はS3への1回のリクエストのタイムアウト
+const s3Timeout = 30 * time.Second
+
+// S3Options はS3互換ストレージの接続設定
+type S3Options struct {
+	// Endpoint はMinIOなどS3互換サービスのURL（空の場合はAWSのエンドポイントを使用）
+	Endpoint string
+	Region   string
+	Bucket   string
+	// AccessKeyID・SecretAccessKey が空の場合は環境変数やIAMロールなどの既定の認証情報を使用
+	AccessKeyID     string
+	SecretAccessKey string
+	// UsePathStyle はバケット名をホスト名ではなくパスに含める（MinIOでは通常true）
+	UsePathStyle bool
+	// PublicURL はファイルを配信するURLのベース（空の場合はエンドポイントとバケットから組み立てる）
+	PublicURL string
+}
+
+// S3Storage はS3互換のオブジェクトストレージにファイルを保存する
+type S3Storage struct {
+	client    *s3.Client
+	bucket    string
+	publicURL string
+}
+
+// NewS3Storage は新しいS3Storageインスタンスを作成
+func NewS3Storage(opts S3Options) (*S3Storage, error) {
+	if opts.Bucket == "" {
+		return nil, fmt.Errorf("S3 bucket is required")
+	}
+	if opts.Region == "" {
+		opts.Region = "us-east-1"
+	}
+
+	loadOpts := []func(*awsconfig.LoadOptions) error{
+		awsconfig.WithRegion(opts.Region),
+	}
+	if opts.AccessKeyID != "" {
+		loadOpts = append(loadOpts, awsconfig.WithCredentialsProvider(
+			credentials.NewStaticCredentialsProvider(opts.AccessKeyID, opts.SecretAccessKey, ""),
+		))
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), s3Timeout)
+	defer cancel()
+
+	awsCfg, err := awsconfig.LoadDefaultConfig(ctx, loadOpts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load S3 configuration: %w", err)
+	}
+
+	client := s3.NewFromConfig(awsCfg, func(o *s3.Options) {
+		if opts.Endpoint != "" {
+			o.BaseEndpoint = aws.String(opts.Endpoint)
+		}
+		o.UsePathStyle = opts.UsePathStyle
+		// S3互換サービスはチェックサムに対応していない場合があるため、必要な操作でのみ使用する
+		o.RequestChecksumCalculation = aws.RequestChecksumCalculationWhenRequired
+		o.ResponseChecksumValidation = aws.ResponseChecksumValidationWhenRequired
+	})
+
+	publicURL := opts.PublicURL
+	if publicURL == "" {
+		publicURL = defaultS3URL(opts)
+	}
+
+	return &S3Storage{
+		client:    client,
+		bucket:    opts.Bucket,
+		publicURL: publicURL,
+	}, nil
+}
+
+// Put はファイルを保存する
+func (s *S3Storage) Put(key string, r io.Reader, size int64, contentType string) error {
+	cleaned, err := cleanKey(key)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), s3Timeout)
+	defer cancel()
+
+	input := &s3.PutObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(cleaned),
+		Body:   r,
+	}
+	if size >= 0 {
+		input.ContentLength = aws.Int64(size)
+	}
+	if contentType != "" {
+		input.ContentType = aws.String(contentType)
+	}
+
+	if _, err := s.client.PutObject(ctx, input); err != nil {
+		return fmt.Errorf("failed to put object: %w", err)
+	}
+	return nil
+}
+
+// Get はファイルを取得する
+func (s *S3Storage) Get(key string) (*Object, error) {
+	cleaned, err := cleanKey(key)
+	if err != nil {
+		return nil, err
+	}
+
+	// 本文の読み込みが終わるまでタイムアウトさせないよう、キャンセルはCloseで行う
+	ctx, cancel := context.WithCancel(context.Background())
+
+	out, err := s.client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(cleaned),
+	})
+	if err != nil {
+		cancel()
+		if isS3NotFound(err) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to get object: %w", err)
+	}
+
+	reader := &s3Reader{
+		storage: s,
+		ctx:     ctx,
+		cancel:  cancel,
+		key:     cleaned,
+		size:    aws.ToInt64(out.ContentLength),
+		body:    out.Body,
+	}
+
+	return &Object{
+		ReadSeekCloser: reader,
+		ObjectInfo: ObjectInfo{
+			Key:         cleaned,
+			Size:        reader.size,
+			ContentType: aws.ToString(out.ContentType),
+			ModTime:     aws.ToTime(out.LastModified),
+			ETag:        aws.ToString(out.ETag),
+		},
+	}, nil
+}
+
+// Stat はファイルの情報を取得する
+func (s *S3Storage) Stat(key string) (*ObjectInfo, error) {
+	cleaned, err := cleanKey(key)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), s3Timeout)
+	defer cancel()
+
+	out, err := s.client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(cleaned),
+	})
+	if err != nil {
+		if isS3NotFound(err) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to stat object: %w", err)
+	}
+
+	return &ObjectInfo{
+		Key:         cleaned,
+		Size:        aws.ToInt64(out.ContentLength),
+		ContentType: aws.ToString(out.ContentType),
+		ModTime:     aws.ToTime(out.LastModified),
+		ETag:        aws.ToString(out.ETag),
+	}, nil
+}
+
+// Delete はファイルを削除する（S3は存在しないキーの削除も成功として扱う）
+func (s *S3Storage) Delete(key string) error {
+	cleaned, err := cleanKey(key)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), s3Timeout)
+	defer cancel()
+
+	if _, err := s.client.DeleteObject(ctx, &s3.DeleteObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(cleaned),
+	}); err != nil && !isS3NotFound(err) {
+		return fmt.Errorf("failed to delete object: %w", err)
+	}
+	return nil
+}
+
+// List はprefix配下のファイルを列挙する
+func (s *S3Storage) List(prefix string, fn func(ObjectInfo) error) error {
+	input := &s3.ListObjectsV2Input{
+		Bucket: aws.String(s.bucket),
+	}
+	if prefix != "" {
+		cleaned, err := cleanKey(prefix)
+		if err != nil {
+			return err
+		}
+		input.Prefix = aws.String(cleaned + "/")
+	}
+
+	paginator := s3.NewListObjectsV2Paginator(s.client, input)
+	for paginator.HasMorePages() {
+		ctx, cancel := context.WithTimeout(context.Background(), s3Timeout)
+		page, err := paginator.NextPage(ctx)
+		cancel()
+		if err != nil {
+			return fmt.Errorf("failed to list objects: %w", err)
+		}
+
+		for _, obj := range page.Contents {
+			if err := fn(ObjectInfo{
+				Key:     aws.ToString(obj.Key),
+				Size:    aws.ToInt64(obj.Size),
+				ModTime: aws.ToTime(obj.LastModified),
+				ETag:    aws.ToString(obj.ETag),
+			}); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// URL はファイルのURLを返す
+func (s *S3Storage) URL(key string) string {
+	cleaned, err := cleanKey(key)
+	if err != nil {
+		return ""
+	}
+	return joinURL(s.publicURL, cleaned)
+}
+
+// defaultS3URL はエンドポイントとバケットからファイル配信用のベースURLを組み立てる
+func defaultS3URL(opts S3Options) string {
+	if opts.Endpoint == "" {
+		if opts.UsePathStyle {
+			return fmt.Sprintf("https://s3.%s.amazonaws.com/%s", opts.Region, opts.Bucket)
+		}
+		return fmt.Sprintf("https://%s.s3.%s.amazonaws.com", opts.Bucket, opts.Region)
+	}
+
+	endpoint, err := url.Parse(opts.Endpoint)
+	if err != nil || opts.UsePathStyle {
+		return joinURL(opts.Endpoint, opts.Bucket)
+	}
+	endpoint.Host = opts.Bucket + "." + endpoint.Host
+	return endpoint.String()
+}
+
+// isS3NotFound はオブジェクトが存在しないことを示すエラーかを判定する
+func isS3NotFound(err error) bool {
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) {
+		switch apiErr.ErrorCode() {
+		case "NoSuchKey", "NotFound":
+			return true
+		}
+	}
+	return false
+}
+
+// s3Reader はS3オブジェクトをSeek可能に読み込む
+// Seek後の読み込みでは、位置が変わっていればRange指定で取得し直す
+type s3Reader struct {
+	storage *S3Storage
+	ctx     context.Context
+	cancel  context.CancelFunc
+	key     string
+	size    int64
+
+	pos     int64         // 次に読み込む位置
+	body    io.ReadCloser // 現在開いている本文
+	bodyPos int64         // bodyから次に読み込まれる位置
+}
+
+// Read は現在の位置から読み込む
+func (r *s3Reader) Read(p []byte) (int, error) {
+	if r.pos >= r.size {
+		return 0, io.EOF
+	}
+
+	if r.body == nil || r.bodyPos != r.pos {
+		if err := r.open(); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := r.body.Read(p)
+	r.pos += int64(n)
+	r.bodyPos += int64(n)
+	return n, err
+}
+
+// Seek は次に読み込む位置を変更する（実際の取得は次のReadで行う）
+func (r *s3Reader) Seek(offset int64, whence int) (int64, error) {
+	var pos int64
+	switch whence {
+	case io.SeekStart:
+		pos = offset
+	case io.SeekCurrent:
+		pos = r.pos + offset
+	case io.SeekEnd:
+		pos = r.size + offset
+	default:
+		return 0, fmt.Errorf("storage: invalid whence")
+	}
+	if pos < 0 {
+		return 0, fmt.Errorf("storage: negative position")
+	}
+
+	r.pos = pos
+	return pos, nil
+}
+
+// Close は本文を閉じてコンテキストをキャンセルする
+func (r *s3Reader) Close() error {
+	var err error
+	if r.body != nil {
+		err = r.body.Close()
+		r.body = nil
+	}
+	r.cancel()
+	return err
+}
+
+// open は現在の位置から末尾までをRange指定で取得し直す
+func (r *s3Reader) open() error {
+	if r.body != nil {
+		r.body.Close()
+		r.body = nil
+	}
+
+	out, err := r.storage.client.GetObject(r.ctx, &s3.GetObjectInput{
+		Bucket: aws.String(r.storage.bucket),
+		Key:    aws.String(r.key),
+		Range:  aws.String(fmt.Sprintf("bytes=%d-", r.pos)),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to get object: %w", err)
+	}
+
+	r.body = out.Body
+	r.bodyPos = r.pos
+	return nil
+}
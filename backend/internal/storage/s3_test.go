@@ -0,0 +1,364 @@
+package storage
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const testBucket = "guideforge-test"
+
+// s3Stub はテスト用のS3互換サーバー（パス形式のPut・Get・Head・Delete・ListObjectsV2のみ対応）
+type s3Stub struct {
+	mu       sync.Mutex
+	objects  map[string]s3StubObject
+	pageSize int // ListObjectsV2 の1ページの件数（ページ送りを確認するため小さくする）
+}
+
+type s3StubObject struct {
+	data        []byte
+	contentType string
+	modTime     time.Time
+}
+
+func (o s3StubObject) etag() string {
+	sum := md5.Sum(o.data)
+	return `"` + hex.EncodeToString(sum[:]) + `"`
+}
+
+func (s *s3Stub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	bucketPrefix := "/" + testBucket
+	if r.URL.Path != bucketPrefix && !strings.HasPrefix(r.URL.Path, bucketPrefix+"/") {
+		writeS3Error(w, http.StatusNotFound, "NoSuchBucket")
+		return
+	}
+	key := strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, bucketPrefix), "/")
+
+	switch {
+	case key == "" && r.Method == http.MethodGet && r.URL.Query().Get("list-type") == "2":
+		s.list(w, r)
+	case key == "":
+		writeS3Error(w, http.StatusMethodNotAllowed, "MethodNotAllowed")
+	case r.Method == http.MethodPut:
+		data, err := io.ReadAll(r.Body)
+		if err != nil {
+			writeS3Error(w, http.StatusBadRequest, "IncompleteBody")
+			return
+		}
+		obj := s3StubObject{data: data, contentType: r.Header.Get("Content-Type"), modTime: time.Now().UTC().Truncate(time.Second)}
+		s.objects[key] = obj
+		w.Header().Set("ETag", obj.etag())
+		w.WriteHeader(http.StatusOK)
+	case r.Method == http.MethodGet || r.Method == http.MethodHead:
+		obj, ok := s.objects[key]
+		if !ok {
+			if r.Method == http.MethodHead {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			writeS3Error(w, http.StatusNotFound, "NoSuchKey")
+			return
+		}
+		body, status := obj.data, http.StatusOK
+		if rng := r.Header.Get("Range"); rng != "" {
+			start, err := strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(rng, "bytes="), "-"))
+			if err != nil || start >= len(obj.data) {
+				writeS3Error(w, http.StatusRequestedRangeNotSatisfiable, "InvalidRange")
+				return
+			}
+			body, status = obj.data[start:], http.StatusPartialContent
+			w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, len(obj.data)-1, len(obj.data)))
+		}
+		w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+		w.Header().Set("Content-Type", obj.contentType)
+		w.Header().Set("ETag", obj.etag())
+		w.Header().Set("Last-Modified", obj.modTime.Format(http.TimeFormat))
+		w.WriteHeader(status)
+		if r.Method == http.MethodGet {
+			w.Write(body)
+		}
+	case r.Method == http.MethodDelete:
+		delete(s.objects, key)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		writeS3Error(w, http.StatusMethodNotAllowed, "MethodNotAllowed")
+	}
+}
+
+// list は ListObjectsV2 に応答する（継続トークンは次のページの先頭のキー）
+func (s *s3Stub) list(w http.ResponseWriter, r *http.Request) {
+	prefix := r.URL.Query().Get("prefix")
+	start := r.URL.Query().Get("continuation-token")
+
+	keys := make([]string, 0, len(s.objects))
+	for key := range s.objects {
+		if strings.HasPrefix(key, prefix) && key >= start {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	type content struct {
+		Key          string `xml:"Key"`
+		LastModified string `xml:"LastModified"`
+		ETag         string `xml:"ETag"`
+		Size         int    `xml:"Size"`
+	}
+	result := struct {
+		XMLName               xml.Name  `xml:"http://s3.amazonaws.com/doc/2006-03-01/ ListBucketResult"`
+		Name                  string    `xml:"Name"`
+		Prefix                string    `xml:"Prefix"`
+		KeyCount              int       `xml:"KeyCount"`
+		MaxKeys               int       `xml:"MaxKeys"`
+		IsTruncated           bool      `xml:"IsTruncated"`
+		NextContinuationToken string    `xml:"NextContinuationToken,omitempty"`
+		Contents              []content `xml:"Contents"`
+	}{Name: testBucket, Prefix: prefix, MaxKeys: s.pageSize}
+
+	if len(keys) > s.pageSize {
+		result.IsTruncated = true
+		result.NextContinuationToken = keys[s.pageSize]
+		keys = keys[:s.pageSize]
+	}
+	for _, key := range keys {
+		obj := s.objects[key]
+		result.Contents = append(result.Contents, content{
+			Key:          key,
+			LastModified: obj.modTime.Format(time.RFC3339),
+			ETag:         obj.etag(),
+			Size:         len(obj.data),
+		})
+	}
+	result.KeyCount = len(result.Contents)
+
+	w.Header().Set("Content-Type", "application/xml")
+	w.Write([]byte(xml.Header))
+	xml.NewEncoder(w).Encode(result)
+}
+
+func writeS3Error(w http.ResponseWriter, status int, code string) {
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(status)
+	fmt.Fprintf(w, `%s<Error><Code>%s</Code><Message>%s</Message></Error>`, xml.Header, code, code)
+}
+
+// newTestS3Storage はスタブに接続するS3Storageを作成する
+func newTestS3Storage(t *testing.T) (*S3Storage, *s3Stub) {
+	t.Helper()
+	// 既定の認証情報・設定ファイルの探索がテスト環境の影響を受けないようにする
+	t.Setenv("AWS_CONFIG_FILE", "/dev/null")
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", "/dev/null")
+	t.Setenv("AWS_EC2_METADATA_DISABLED", "true")
+
+	stub := &s3Stub{objects: map[string]s3StubObject{}, pageSize: 2}
+	server := httptest.NewServer(stub)
+	t.Cleanup(server.Close)
+
+	s, err := NewS3Storage(S3Options{
+		Endpoint:        server.URL,
+		Region:          "us-east-1",
+		Bucket:          testBucket,
+		AccessKeyID:     "test",
+		SecretAccessKey: "test",
+		UsePathStyle:    true,
+	})
+	if err != nil {
+		t.Fatalf("NewS3Storage() error = %v", err)
+	}
+	return s, stub
+}
+
+func putString(t *testing.T, s *S3Storage, key, content, contentType string) {
+	t.Helper()
+	if err := s.Put(key, strings.NewReader(content), int64(len(content)), contentType); err != nil {
+		t.Fatalf("Put(%q) error = %v", key, err)
+	}
+}
+
+func TestS3StoragePutGetStat(t *testing.T) {
+	s, stub := newTestS3Storage(t)
+
+	putString(t, s, "images/ab/abcdef.png", "png-bytes", "image/png")
+
+	stored, ok := stub.objects["images/ab/abcdef.png"]
+	if !ok {
+		t.Fatalf("object was not stored under the cleaned key, got %v", stub.objects)
+	}
+	if string(stored.data) != "png-bytes" || stored.contentType != "image/png" {
+		t.Errorf("stored object = %q (%s), want %q (image/png)", stored.data, stored.contentType, "png-bytes")
+	}
+
+	obj, err := s.Get("images/ab/abcdef.png")
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	defer obj.Close()
+
+	if obj.Size != int64(len("png-bytes")) || obj.ContentType != "image/png" || obj.ETag == "" {
+		t.Errorf("Get() info = %+v", obj.ObjectInfo)
+	}
+	data, err := io.ReadAll(obj)
+	if err != nil {
+		t.Fatalf("ReadAll() error = %v", err)
+	}
+	if string(data) != "png-bytes" {
+		t.Errorf("Get() content = %q, want %q", data, "png-bytes")
+	}
+
+	info, err := s.Stat("images/ab/abcdef.png")
+	if err != nil {
+		t.Fatalf("Stat() error = %v", err)
+	}
+	if info.Size != obj.Size || info.ContentType != "image/png" || info.ETag != obj.ETag {
+		t.Errorf("Stat() = %+v, want the same info as Get() %+v", info, obj.ObjectInfo)
+	}
+}
+
+func TestS3StorageGetSeek(t *testing.T) {
+	s, _ := newTestS3Storage(t)
+	putString(t, s, "images/cd/cdef.jpg", "0123456789", "image/jpeg")
+
+	obj, err := s.Get("images/cd/cdef.jpg")
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	defer obj.Close()
+
+	// Rangeリクエストと同様に途中から読み込む
+	if _, err := obj.Seek(6, io.SeekStart); err != nil {
+		t.Fatalf("Seek() error = %v", err)
+	}
+	data, err := io.ReadAll(obj)
+	if err != nil {
+		t.Fatalf("ReadAll() error = %v", err)
+	}
+	if string(data) != "6789" {
+		t.Errorf("content after Seek(6) = %q, want %q", data, "6789")
+	}
+}
+
+func TestS3StorageMissingKey(t *testing.T) {
+	s, _ := newTestS3Storage(t)
+
+	if _, err := s.Get("images/00/missing.png"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get() error = %v, want ErrNotFound", err)
+	}
+	if _, err := s.Stat("images/00/missing.png"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Stat() error = %v, want ErrNotFound", err)
+	}
+	if err := s.Delete("images/00/missing.png"); err != nil {
+		t.Errorf("Delete() of a missing key error = %v, want nil", err)
+	}
+}
+
+func TestS3StorageDelete(t *testing.T) {
+	s, stub := newTestS3Storage(t)
+	putString(t, s, "profiles/user_1/a.jpg", "jpeg", "image/jpeg")
+
+	if err := s.Delete("profiles/user_1/a.jpg"); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if _, ok := stub.objects["profiles/user_1/a.jpg"]; ok {
+		t.Errorf("object still exists after Delete()")
+	}
+	if _, err := s.Get("profiles/user_1/a.jpg"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get() after Delete() error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestS3StorageList(t *testing.T) {
+	s, _ := newTestS3Storage(t)
+	keys := []string{
+		"images/ab/1.png",
+		"images/ab/2.png",
+		"images/cd/3.png",
+		"profiles/user_1/a.jpg",
+		"profiles/user_2/b.jpg",
+	}
+	for _, key := range keys {
+		putString(t, s, key, key, "image/png")
+	}
+
+	tests := []struct {
+		prefix string
+		want   []string
+	}{
+		// GCは全体を列挙する（スタブは2件ずつ返すため、ページ送りも確認できる）
+		{prefix: "", want: keys},
+		{prefix: "images", want: keys[:3]},
+		{prefix: "profiles/user_1", want: keys[3:4]},
+		{prefix: "missing", want: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run("prefix="+tt.prefix, func(t *testing.T) {
+			var got []string
+			err := s.List(tt.prefix, func(info ObjectInfo) error {
+				if info.Size != int64(len(info.Key)) || info.ModTime.IsZero() {
+					t.Errorf("List() info = %+v", info)
+				}
+				got = append(got, info.Key)
+				return nil
+			})
+			if err != nil {
+				t.Fatalf("List() error = %v", err)
+			}
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("List(%q) = %v, want %v", tt.prefix, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestS3StorageListStopsOnError(t *testing.T) {
+	s, _ := newTestS3Storage(t)
+	for _, key := range []string{"images/a.png", "images/b.png", "images/c.png"} {
+		putString(t, s, key, "x", "image/png")
+	}
+
+	errStop := errors.New("stop")
+	calls := 0
+	err := s.List("", func(ObjectInfo) error {
+		calls++
+		return errStop
+	})
+	if !errors.Is(err, errStop) || calls != 1 {
+		t.Errorf("List() error = %v after %d calls, want errStop after 1 call", err, calls)
+	}
+}
+
+func TestS3StorageCleansKeys(t *testing.T) {
+	s, stub := newTestS3Storage(t)
+
+	// バケットの外やキーの外を指すことはできない
+	for _, key := range []string{"../images/a.png", "/images/a.png", `images\a.png`} {
+		if err := s.Put(key, strings.NewReader("x"), 1, "image/png"); err != nil {
+			t.Fatalf("Put(%q) error = %v", key, err)
+		}
+	}
+	if len(stub.objects) != 1 {
+		t.Errorf("stored keys = %v, want only images/a.png", stub.objects)
+	}
+	if _, ok := stub.objects["images/a.png"]; !ok {
+		t.Errorf("stored keys = %v, want images/a.png", stub.objects)
+	}
+
+	if err := s.Put("", bytes.NewReader(nil), 0, ""); err == nil {
+		t.Errorf("Put(\"\") error = nil, want an error")
+	}
+}
@@ -0,0 +1,97 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/Ryo-cool/guideforge/internal/config"
+)
+
+// ErrNotFound は指定したキーのファイルが存在しない場合のエラー
+var ErrNotFound = errors.New("storage: object not found")
+
+// Storage はアップロードファイルの保存先を抽象化するインターフェース
+// キーは "images/ab/<SHA-256の16進表記>.jpg"（手順画像は内容のハッシュで保存する）や
+// "profiles/user_1/<ランダムな値>.jpg" のようなスラッシュ区切りの相対パス
+type Storage interface {
+	// Put はファイルを保存する（同じキーが存在する場合は上書きする）
+	Put(key string, r io.Reader, size int64, contentType string) error
+	// Get はファイルを取得する（呼び出し側でCloseすること）
+	// 返されるObjectはSeekでき、Rangeリクエストに応答できる
+	Get(key string) (*Object, error)
+	// Stat はファイルの情報を取得する
+	Stat(key string) (*ObjectInfo, error)
+	// Delete はファイルを削除する（存在しない場合もエラーにしない）
+	Delete(key string) error
+	// URL はファイルにアクセスするためのURLを返す
+	URL(key string) string
+	// List はprefix配下のファイルを列挙し、1件ごとにfnを呼び出す（fnがエラーを返すと中断する）
+	List(prefix string, fn func(ObjectInfo) error) error
+}
+
+// ObjectInfo は保存されたファイルの情報
+type ObjectInfo struct {
+	Key         string
+	Size        int64
+	ContentType string
+	ModTime     time.Time
+	// ETag はファイルの内容が変わると変化する引用符付きの値
+	ETag string
+}
+
+// Object は取得したファイルの内容と情報
+type Object struct {
+	io.ReadSeekCloser
+	ObjectInfo
+}
+
+// New は設定に応じたStorageを作成する
+func New(cfg *config.Config) (Storage, error) {
+	switch cfg.StorageDriver {
+	case "local", "":
+		return NewLocalStorage(cfg.UploadDir, cfg.StoragePublicURL)
+	case "s3":
+		return NewS3Storage(S3Options{
+			Endpoint:        cfg.S3Endpoint,
+			Region:          cfg.S3Region,
+			Bucket:          cfg.S3Bucket,
+			AccessKeyID:     cfg.S3AccessKeyID,
+			SecretAccessKey: cfg.S3SecretAccessKey,
+			UsePathStyle:    cfg.S3UsePathStyle,
+			PublicURL:       cfg.StoragePublicURL,
+		})
+	default:
+		return nil, fmt.Errorf("unknown storage driver: %s", cfg.StorageDriver)
+	}
+}
+
+// ReadAll はファイルの内容をすべて読み込む
+func ReadAll(s Storage, key string) ([]byte, error) {
+	obj, err := s.Get(key)
+	if err != nil {
+		return nil, err
+	}
+	defer obj.Close()
+
+	return io.ReadAll(obj)
+}
+
+// cleanKey はキーを正規化し、保存先の外を指さないようにする
+// OS依存の区切り文字で保存された既存のパスも扱えるようにバックスラッシュをスラッシュに変換する
+func cleanKey(key string) (string, error) {
+	key = path.Clean("/" + strings.ReplaceAll(key, `\`, "/"))
+	key = strings.TrimPrefix(key, "/")
+	if key == "" {
+		return "", fmt.Errorf("storage: invalid key")
+	}
+	return key, nil
+}
+
+// joinURL はベースURLとキーを連結する
+func joinURL(base, key string) string {
+	return strings.TrimRight(base, "/") + "/" + key
+}
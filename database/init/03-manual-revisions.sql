@@ -0,0 +1,29 @@
+-- マニュアル改訂履歴テーブル
+-- マニュアル本体と手順（画像参照を含む）のスナップショットを不変の履歴として保存する
+CREATE TABLE manual_revisions (
+  id SERIAL PRIMARY KEY,
+  manual_id INTEGER NOT NULL REFERENCES manuals(id) ON DELETE CASCADE,
+  revision_number INTEGER NOT NULL,
+  title VARCHAR(255) NOT NULL,
+  description TEXT NOT NULL DEFAULT '',
+  category VARCHAR(100) NOT NULL DEFAULT '',
+  is_public BOOLEAN NOT NULL DEFAULT false,
+  steps JSONB NOT NULL DEFAULT '[]',
+  summary VARCHAR(255) NOT NULL DEFAULT '',
+  created_by INTEGER REFERENCES users(id) ON DELETE SET NULL,
+  created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+  UNIQUE (manual_id, revision_number)
+);
+
+CREATE INDEX idx_manual_revisions_manual_id ON manual_revisions (manual_id);
+
+-- 改訂履歴は追記のみ許可する
+CREATE FUNCTION prevent_manual_revision_update() RETURNS trigger AS $$
+BEGIN
+  RAISE EXCEPTION 'manual revisions are immutable';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER manual_revisions_immutable
+  BEFORE UPDATE ON manual_revisions
+  FOR EACH ROW EXECUTE FUNCTION prevent_manual_revision_update();
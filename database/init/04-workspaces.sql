@@ -0,0 +1,25 @@
+-- ワークスペーステーブル
+CREATE TABLE workspaces (
+  id SERIAL PRIMARY KEY,
+  name VARCHAR(100) NOT NULL,
+  created_by INTEGER REFERENCES users(id) ON DELETE SET NULL,
+  created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+  updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+-- ワークスペースメンバーテーブル
+CREATE TABLE workspace_members (
+  workspace_id INTEGER NOT NULL REFERENCES workspaces(id) ON DELETE CASCADE,
+  user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  role VARCHAR(20) NOT NULL DEFAULT 'member',
+  created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+  PRIMARY KEY (workspace_id, user_id)
+);
+
+CREATE INDEX idx_workspace_members_user_id ON workspace_members (user_id);
+
+-- マニュアルの所属ワークスペース（NULLの場合は作成者の個人マニュアル）
+-- ワークスペース削除時はマニュアルを作成者の個人マニュアルに戻す
+ALTER TABLE manuals ADD COLUMN workspace_id INTEGER REFERENCES workspaces(id) ON DELETE SET NULL;
+
+CREATE INDEX idx_manuals_workspace_id ON manuals (workspace_id);
@@ -0,0 +1,12 @@
+-- ユーザーのシステムロール（admin: 管理者, editor: 編集者, viewer: 閲覧者）
+-- 最初の管理者はSQLで設定する: UPDATE users SET role = 'admin' WHERE email = '...';
+ALTER TABLE users ADD COLUMN role VARCHAR(20) NOT NULL DEFAULT 'editor'
+  CHECK (role IN ('admin', 'editor', 'viewer'));
+
+CREATE INDEX idx_users_role ON users (role);
+
+-- ワークスペース内の役割（owner: オーナー, editor: 編集者, viewer: 閲覧者）
+UPDATE workspace_members SET role = 'editor' WHERE role = 'member';
+ALTER TABLE workspace_members ALTER COLUMN role SET DEFAULT 'editor';
+ALTER TABLE workspace_members ADD CONSTRAINT workspace_members_role_check
+  CHECK (role IN ('owner', 'editor', 'viewer'));
@@ -0,0 +1,23 @@
+-- 全文検索用のインデックス
+-- 日本語は空白で分かち書きされないため、'simple' 設定の全文検索に加えて
+-- pg_trgm の部分一致（ILIKE）をフォールバックとして使用する
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+-- マニュアル（タイトル: 重みA、説明: 重みB）
+-- クエリ側の式（repository/search_repository.go）と一致させること
+CREATE INDEX idx_manuals_search ON manuals USING GIN ((
+  setweight(to_tsvector('simple', coalesce(title, '')), 'A') ||
+  setweight(to_tsvector('simple', coalesce(description, '')), 'B')
+));
+
+-- 手順（タイトル: 重みC、本文: 重みD）
+CREATE INDEX idx_steps_search ON steps USING GIN ((
+  setweight(to_tsvector('simple', coalesce(title, '')), 'C') ||
+  setweight(to_tsvector('simple', coalesce(content, '')), 'D')
+));
+
+-- 部分一致検索用のトライグラムインデックス
+CREATE INDEX idx_manuals_title_trgm ON manuals USING GIN (title gin_trgm_ops);
+CREATE INDEX idx_manuals_description_trgm ON manuals USING GIN (description gin_trgm_ops);
+CREATE INDEX idx_steps_title_trgm ON steps USING GIN (title gin_trgm_ops);
+CREATE INDEX idx_steps_content_trgm ON steps USING GIN (content gin_trgm_ops);
@@ -0,0 +1,7 @@
+-- 画像のサイズと派生画像（サムネイル・中サイズ）
+-- 派生画像が不要な小さい画像はパスを空文字とし、元画像で代用する
+ALTER TABLE images
+  ADD COLUMN width INTEGER NOT NULL DEFAULT 0,
+  ADD COLUMN height INTEGER NOT NULL DEFAULT 0,
+  ADD COLUMN thumbnail_path VARCHAR(255) NOT NULL DEFAULT '',
+  ADD COLUMN medium_path VARCHAR(255) NOT NULL DEFAULT '';
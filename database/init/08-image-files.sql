@@ -0,0 +1,81 @@
+-- 画像ファイルの参照カウント
+-- 画像ファイルは内容のハッシュをパスとして保存し、同じ内容のファイルを複数の画像・改訂履歴で共有する
+-- ref_count は画像行と改訂履歴のスナップショットからの参照数で、トリガーで自動的に更新する
+-- 行が存在する間はストレージ上のファイルも存在する（行の追加・削除とファイルの保存・削除は同じ行ロックの下で行う）
+CREATE TABLE image_files (
+  path VARCHAR(255) PRIMARY KEY,
+  ref_count INTEGER NOT NULL DEFAULT 0,
+  created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+  updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_image_files_unreferenced ON image_files (updated_at) WHERE ref_count <= 0;
+
+-- 画像行が参照するファイルパス
+CREATE FUNCTION image_row_paths(file_path TEXT, thumbnail_path TEXT, medium_path TEXT) RETURNS TEXT[] AS $$
+  SELECT COALESCE(array_agg(DISTINCT p), '{}')
+  FROM unnest(ARRAY[file_path, thumbnail_path, medium_path]) AS p
+  WHERE p IS NOT NULL AND p <> ''
+$$ LANGUAGE sql IMMUTABLE;
+
+-- 改訂履歴のスナップショットが参照するファイルパス
+CREATE FUNCTION revision_image_paths(steps JSONB) RETURNS TEXT[] AS $$
+  SELECT COALESCE(array_agg(DISTINCT p), '{}')
+  FROM jsonb_array_elements(COALESCE(steps, '[]'::jsonb)) AS step,
+    jsonb_array_elements(COALESCE(step->'images', '[]'::jsonb)) AS image,
+    unnest(ARRAY[image->>'file_path', image->>'thumbnail_path', image->>'medium_path']) AS p
+  WHERE p IS NOT NULL AND p <> ''
+$$ LANGUAGE sql IMMUTABLE;
+
+-- 参照カウントの増減
+CREATE FUNCTION image_files_add_refs(paths TEXT[]) RETURNS VOID AS $$
+  INSERT INTO image_files (path, ref_count, created_at, updated_at)
+  SELECT p, 1, NOW(), NOW() FROM unnest(paths) AS p
+  ON CONFLICT (path) DO UPDATE SET ref_count = image_files.ref_count + 1, updated_at = NOW()
+$$ LANGUAGE sql;
+
+CREATE FUNCTION image_files_remove_refs(paths TEXT[]) RETURNS VOID AS $$
+  UPDATE image_files SET ref_count = ref_count - 1, updated_at = NOW()
+  WHERE path = ANY(paths)
+$$ LANGUAGE sql;
+
+CREATE FUNCTION images_ref_count_trigger() RETURNS TRIGGER AS $$
+BEGIN
+  IF TG_OP IN ('UPDATE', 'DELETE') THEN
+    PERFORM image_files_remove_refs(image_row_paths(OLD.file_path, OLD.thumbnail_path, OLD.medium_path));
+  END IF;
+  IF TG_OP IN ('INSERT', 'UPDATE') THEN
+    PERFORM image_files_add_refs(image_row_paths(NEW.file_path, NEW.thumbnail_path, NEW.medium_path));
+  END IF;
+  RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE FUNCTION manual_revisions_ref_count_trigger() RETURNS TRIGGER AS $$
+BEGIN
+  IF TG_OP IN ('UPDATE', 'DELETE') THEN
+    PERFORM image_files_remove_refs(revision_image_paths(OLD.steps));
+  END IF;
+  IF TG_OP IN ('INSERT', 'UPDATE') THEN
+    PERFORM image_files_add_refs(revision_image_paths(NEW.steps));
+  END IF;
+  RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER images_ref_count
+  AFTER INSERT OR DELETE OR UPDATE OF file_path, thumbnail_path, medium_path ON images
+  FOR EACH ROW EXECUTE FUNCTION images_ref_count_trigger();
+
+CREATE TRIGGER manual_revisions_ref_count
+  AFTER INSERT OR DELETE OR UPDATE OF steps ON manual_revisions
+  FOR EACH ROW EXECUTE FUNCTION manual_revisions_ref_count_trigger();
+
+-- 既存の画像・改訂履歴からの参照を登録
+INSERT INTO image_files (path, ref_count)
+SELECT p, COUNT(*) FROM (
+  SELECT unnest(image_row_paths(file_path, thumbnail_path, medium_path)) AS p FROM images
+  UNION ALL
+  SELECT unnest(revision_image_paths(steps)) AS p FROM manual_revisions
+) refs
+GROUP BY p;
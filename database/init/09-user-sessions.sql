@@ -0,0 +1,19 @@
+-- ログインセッションテーブル
+-- アクセストークンは短命とし、セッションごとのリフレッシュトークンで再発行する
+-- リフレッシュトークンは使用するたびに新しいものへ置き換え、SHA-256ハッシュのみを保存する
+CREATE TABLE user_sessions (
+  id VARCHAR(32) PRIMARY KEY,
+  user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  refresh_token_hash VARCHAR(64) NOT NULL UNIQUE,
+  -- 1つ前のリフレッシュトークン（再利用された場合は漏洩とみなしてセッションを失効させる）
+  previous_token_hash VARCHAR(64),
+  user_agent TEXT NOT NULL DEFAULT '',
+  ip_address VARCHAR(64) NOT NULL DEFAULT '',
+  expires_at TIMESTAMP NOT NULL,
+  revoked_at TIMESTAMP,
+  last_used_at TIMESTAMP NOT NULL DEFAULT NOW(),
+  created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_user_sessions_user_id ON user_sessions (user_id);
+CREATE INDEX idx_user_sessions_previous_token_hash ON user_sessions (previous_token_hash);
@@ -0,0 +1,25 @@
+-- 外部IdP（OpenID Connect）のアカウントとユーザーの紐づけ
+-- IdP上のアカウントは issuer と subject の組で一意に識別する（メールアドレスは変更されうるため）
+CREATE TABLE user_identities (
+  id SERIAL PRIMARY KEY,
+  user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  issuer VARCHAR(255) NOT NULL,
+  subject VARCHAR(255) NOT NULL,
+  email VARCHAR(255) NOT NULL,
+  last_login_at TIMESTAMP NOT NULL DEFAULT NOW(),
+  created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+  UNIQUE (issuer, subject)
+);
+
+CREATE INDEX idx_user_identities_user_id ON user_identities (user_id);
+
+-- 認可リクエストの途中状態（state・nonce・PKCEのcode_verifier）
+-- stateはSHA-256ハッシュのみを保存し、コールバックで1回だけ使用できる
+CREATE TABLE oidc_login_states (
+  state_hash VARCHAR(64) PRIMARY KEY,
+  nonce VARCHAR(64) NOT NULL,
+  code_verifier VARCHAR(128) NOT NULL,
+  redirect_path TEXT NOT NULL DEFAULT '',
+  expires_at TIMESTAMP NOT NULL,
+  created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
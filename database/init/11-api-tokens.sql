@@ -0,0 +1,17 @@
+-- 個人用APIトークン（CIなどの自動化向け）
+-- トークンはSHA-256ハッシュのみを保存し、平文は作成時に1回だけ返す
+CREATE TABLE api_tokens (
+  id SERIAL PRIMARY KEY,
+  user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  name VARCHAR(100) NOT NULL,
+  token_hash VARCHAR(64) NOT NULL UNIQUE,
+  -- 一覧で見分けるためのトークンの先頭部分
+  token_prefix VARCHAR(16) NOT NULL,
+  scopes TEXT[] NOT NULL DEFAULT '{}',
+  expires_at TIMESTAMP NOT NULL,
+  last_used_at TIMESTAMP,
+  revoked_at TIMESTAMP,
+  created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_api_tokens_user_id ON api_tokens (user_id);
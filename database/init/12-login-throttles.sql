@@ -0,0 +1,12 @@
+-- ログイン失敗の記録（総当たり・リスト型攻撃対策）
+-- key は 'account:<メールアドレス>' または 'ip:<IPアドレス>'
+-- 未登録のメールアドレスも同じように記録し、アカウントの有無を推測できないようにする
+CREATE TABLE login_throttles (
+  key VARCHAR(320) PRIMARY KEY,
+  failures INTEGER NOT NULL DEFAULT 0,
+  -- この日時まではパスワードを検証せずにログインを拒否する
+  locked_until TIMESTAMP,
+  last_failure_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_login_throttles_last_failure_at ON login_throttles (last_failure_at);
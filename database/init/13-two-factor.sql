@@ -0,0 +1,20 @@
+-- 二要素認証（TOTP）
+-- totp_secret は登録の確認前から保存し、totp_enabled_at が設定されるまでは有効にしない
+-- totp_last_step は最後に使用したコードの時間ステップで、同じコードの再利用を防ぐ
+ALTER TABLE users ADD COLUMN totp_secret VARCHAR(64);
+ALTER TABLE users ADD COLUMN totp_enabled_at TIMESTAMP;
+ALTER TABLE users ADD COLUMN totp_last_step BIGINT NOT NULL DEFAULT 0;
+
+-- リカバリーコード（認証アプリを使えない場合に1回だけ使用できる）
+-- SHA-256ハッシュのみを保存し、平文は発行時に1回だけ返す
+CREATE TABLE user_recovery_codes (
+  id SERIAL PRIMARY KEY,
+  user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  code_hash VARCHAR(64) NOT NULL,
+  used_at TIMESTAMP,
+  created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+  UNIQUE (user_id, code_hash)
+);
+
+-- ワークスペースのメンバーに二要素認証を必須にする（オーナーが設定する）
+ALTER TABLE workspaces ADD COLUMN require_two_factor BOOLEAN NOT NULL DEFAULT FALSE;
@@ -0,0 +1,19 @@
+-- メールアドレスの確認
+-- email_verified_at が NULL のユーザーは確認が済むまでパスワードでログインできない
+-- 既存のユーザーは締め出さないよう確認済みとして扱う
+ALTER TABLE users ADD COLUMN email_verified_at TIMESTAMP;
+UPDATE users SET email_verified_at = created_at;
+
+-- メールアドレス確認トークン
+-- 登録時は登録したアドレス、変更時は変更後のアドレスを email に保存し、確認後に反映する
+CREATE TABLE email_verification_tokens (
+  id SERIAL PRIMARY KEY,
+  user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  email VARCHAR(255) NOT NULL,
+  token_hash VARCHAR(64) NOT NULL UNIQUE,
+  expires_at TIMESTAMP NOT NULL,
+  used_at TIMESTAMP,
+  created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_email_verification_tokens_user_id ON email_verification_tokens (user_id);
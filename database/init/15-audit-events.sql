@@ -0,0 +1,32 @@
+-- 監査ログ
+-- 変更と同じトランザクションで記録し、記録できなかった変更はロールバックする
+-- 操作者が削除されても記録を残せるよう、操作者は外部キーにせずメールアドレスも記録時点の値を保存する
+CREATE TABLE audit_events (
+  id BIGSERIAL PRIMARY KEY,
+  actor_id INTEGER,
+  actor_email VARCHAR(255),
+  action VARCHAR(64) NOT NULL,
+  target_type VARCHAR(32) NOT NULL,
+  target_id INTEGER NOT NULL,
+  before JSONB,
+  after JSONB,
+  ip_address VARCHAR(64) NOT NULL DEFAULT '',
+  user_agent VARCHAR(512) NOT NULL DEFAULT '',
+  created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_audit_events_created_at ON audit_events (created_at);
+CREATE INDEX idx_audit_events_actor_id ON audit_events (actor_id, created_at);
+CREATE INDEX idx_audit_events_target ON audit_events (target_type, target_id, created_at);
+CREATE INDEX idx_audit_events_action ON audit_events (action, created_at);
+
+-- 監査ログは追記のみ許可する（更新・削除による改ざんを防ぐ）
+CREATE FUNCTION prevent_audit_event_change() RETURNS trigger AS $$
+BEGIN
+  RAISE EXCEPTION 'audit events are immutable';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER audit_events_immutable
+  BEFORE UPDATE OR DELETE ON audit_events
+  FOR EACH ROW EXECUTE FUNCTION prevent_audit_event_change();